@@ -1,17 +1,29 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"net/http"
+	"time"
 
 	"go-training-system/internal/config"
+	"go-training-system/internal/event"
 	"go-training-system/internal/graph"
+	"go-training-system/internal/graph/loader"
 	"go-training-system/internal/handler"
+	"go-training-system/internal/job"
+	"go-training-system/internal/model"
 	"go-training-system/internal/repository"
 	"go-training-system/internal/service"
+	"go-training-system/pkg/blobstore"
 	"go-training-system/pkg/db"
 	"go-training-system/pkg/logger"
+	"go-training-system/pkg/markdown"
 	"go-training-system/pkg/middleware"
+	"go-training-system/pkg/notearchive"
+	"go-training-system/pkg/pubsub"
+	"go-training-system/pkg/ratelimit"
 
 	graphqlhandler "github.com/99designs/gqlgen/graphql/handler"
 	"github.com/99designs/gqlgen/graphql/handler/extension"
@@ -19,8 +31,10 @@ import (
 	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 	"github.com/vektah/gqlparser/v2/ast"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 func main() {
@@ -39,19 +53,71 @@ func main() {
 	}
 	defer db.Close(conn)
 
+	blobs, err := openBlobStore(cfg)
+	if err != nil {
+		logger.Log.Error("failed to open attachment storage", zap.Error(err))
+		return
+	}
+
+	pubsubBackend, err := openPubSubBackend(cfg, conn)
+	if err != nil {
+		logger.Log.Error("failed to open subscription event backend", zap.Error(err))
+		return
+	}
+	broker := pubsub.NewBroker(pubsubBackend)
+	events := event.NewBus(broker)
+
 	userRepo := repository.NewUserRepository(conn)
 	userService := service.NewUserService(userRepo)
+	teamRepo := repository.NewTeamRepository(conn)
+	teamSvc := service.NewTeamService(teamRepo, events)
+	folderRepo := repository.NewFolderRepository(conn)
+	noteRepo := repository.NewNoteRepository(conn)
+	shareRepo := repository.NewShareRepository(conn)
+	teamShareRepo := repository.NewTeamShareRepository(conn)
+	tagRepo := repository.NewTagRepository(conn)
+	permissions := service.NewPermissionResolver(repository.NewPermissionRepository(conn))
+	folderSvc := service.NewFolderService(folderRepo, noteRepo, userRepo, shareRepo, teamShareRepo, permissions, events)
+	noteSvc := service.NewNoteService(noteRepo, folderRepo, userRepo, shareRepo, teamShareRepo, tagRepo, permissions, markdown.NewRenderer(cfg.MarkdownCacheSize), events, cfg.NoteRevisionRetention)
+	tagSvc := service.NewTagService(tagRepo, teamRepo, noteRepo, permissions)
+	attachmentRepo := repository.NewAttachmentRepository(conn)
+	attachmentSvc := service.NewAttachmentService(attachmentRepo, noteRepo, permissions, blobs, int64(cfg.AttachmentMaxSize))
+	trashSvc := service.NewTrashService(repository.NewTrashRepository(conn), attachmentRepo, blobs, cfg.TrashRetention, events)
+	archiveSvc := service.NewArchiveService(folderRepo, noteRepo, tagRepo, repository.NewImportRepository(conn), permissions, int64(cfg.ImportMaxSize), notearchive.Limits{
+		MaxEntries: cfg.ImportMaxEntries,
+		MaxSize:    int64(cfg.ImportMaxUncompressed),
+	})
+	commentSvc := service.NewCommentService(repository.NewCommentRepository(conn), noteRepo, userRepo, permissions)
+	collabSvc := service.NewCollabService(repository.NewCollabRepository(conn), noteRepo, userRepo, permissions, broker, events, cfg.CollabSnapshotInterval, cfg.NoteRevisionRetention)
+	shareLinkSvc := service.NewShareLinkService(repository.NewShareLinkRepository(conn), folderRepo, noteRepo, permissions, ratelimit.New(cfg.ShareLinkRateLimit, time.Minute))
+	shareExpirySvc := service.NewShareExpiryService(shareRepo, cfg.ShareExpiryWarning, events)
+	templateSvc := service.NewTemplateService(repository.NewTemplateRepository(conn), shareRepo, teamShareRepo, teamRepo, userRepo, folderRepo, noteSvc)
 	resolver := &graph.Resolver{
-		UserService: userService,
-		JWTSecret:   cfg.JWTSecret,
+		UserService:    userService,
+		TeamService:    teamSvc,
+		FolderService:  folderSvc,
+		NoteService:    noteSvc,
+		CommentService: commentSvc,
+		Events:         events,
+		JWTSecret:      cfg.JWTSecret,
 	}
 	srv := graphqlhandler.New(graph.NewExecutableSchema(graph.Config{Resolvers: resolver}))
 
 	srv.AddTransport(transport.Options{})
 	srv.AddTransport(transport.GET{})
 	srv.AddTransport(transport.POST{})
+	srv.AddTransport(transport.Websocket{
+		KeepAlivePingInterval: 10 * time.Second,
+		Upgrader: websocket.Upgrader{
+			// Connections authenticate with a bearer token, never cookies,
+			// so pages on other origins cannot ride on a user's session
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+		InitFunc: middleware.WebsocketInit(cfg.JWTSecret),
+	})
 
 	srv.SetQueryCache(lru.New[*ast.QueryDocument](1000))
+	srv.AroundResponses(loader.Middleware(userService, folderSvc, noteSvc))
 
 	srv.Use(extension.Introspection{})
 	srv.Use(extension.AutomaticPersistedQuery{
@@ -79,28 +145,216 @@ func main() {
 	r.POST("/graphQL", func(c *gin.Context) {
 		srv.ServeHTTP(c.Writer, c.Request)
 	})
+	// Subscriptions upgrade a GET to a websocket
+	r.GET("/graphQL", func(c *gin.Context) {
+		srv.ServeHTTP(c.Writer, c.Request)
+	})
+
+	// Collaborative editing authenticates in its first message when the
+	// websocket carries no Authorization header
+	collabHdl := handler.NewCollabHandler(collabSvc, cfg.JWTSecret)
+	r.GET("/notes/:id/collab", collabHdl.Connect)
+
+	// Public links need no account
+	shareLinkHdl := handler.NewShareLinkHandler(shareLinkSvc)
+	publicGroup := r.Group("/public/links/:token")
+	{
+		publicGroup.GET("", shareLinkHdl.OpenLink)
+		publicGroup.GET("/folders/:id", shareLinkHdl.OpenLinkFolder)
+		publicGroup.GET("/notes/:id", shareLinkHdl.OpenLinkNote)
+	}
 
 	// Protected routes group: yêu cầu auth
 	authGroup := r.Group("/")
 	authGroup.Use(middleware.RequiredAuthMiddleware(cfg.JWTSecret))
 
-	teamRepo := repository.NewTeamRepository(conn)
-	teamSvc := service.NewTeamService(teamRepo)
 	teamHdl := handler.NewTeamHandler(teamSvc)
 
 	// Routes cho team management chỉ dành cho manager
 	teamGroup := authGroup.Group("/teams")
-	teamGroup.Use(middleware.RequireManagerRole("manager"))
+	teamGroup.Use(middleware.RequireManagerRole(string(model.UserRoleManager)))
 	{
 		teamGroup.POST("/", teamHdl.CreateTeam)
+		teamGroup.POST("/memberships/bulk", teamHdl.BulkUpdateMembers)
+		teamGroup.GET("/:teamId", teamHdl.GetTeam)
+		teamGroup.GET("/:teamId/ancestors", teamHdl.GetAncestors)
+		teamGroup.GET("/:teamId/descendants", teamHdl.GetDescendants)
+		teamGroup.GET("/:teamId/subtree", teamHdl.GetSubtree)
+		teamGroup.PUT("/:teamId/parent", teamHdl.MoveTeam)
+		teamGroup.GET("/:teamId/members/history", teamHdl.GetMembershipHistory)
 		teamGroup.POST("/:teamId/members", teamHdl.AddMember)
 		teamGroup.DELETE("/:teamId/members/:memberId", teamHdl.RemoveMember)
 		teamGroup.POST("/:teamId/managers", teamHdl.AddManager)
 		teamGroup.DELETE("/:teamId/managers/:managerId", teamHdl.RemoveManager)
 	}
 
+	authGroup.GET("/users/:userId/teams/history", teamHdl.GetUserTeamHistory)
+
+	folderHdl := handler.NewFolderHandler(folderSvc)
+	noteHdl := handler.NewNoteHandler(noteSvc)
+	tagHdl := handler.NewTagHandler(tagSvc)
+	attachmentHdl := handler.NewAttachmentHandler(attachmentSvc)
+	trashHdl := handler.NewTrashHandler(trashSvc)
+	archiveHdl := handler.NewArchiveHandler(archiveSvc)
+	templateHdl := handler.NewTemplateHandler(templateSvc)
+	commentHdl := handler.NewCommentHandler(commentSvc)
+
+	folderGroup := authGroup.Group("/folders")
+	{
+		folderGroup.POST("/", folderHdl.CreateFolder)
+		folderGroup.GET("/", folderHdl.GetUserFolders)
+		folderGroup.GET("/tree", folderHdl.GetFolderTree)
+		folderGroup.GET("/:id", folderHdl.GetFolder)
+		folderGroup.PUT("/:id", folderHdl.UpdateFolder)
+		folderGroup.DELETE("/:id", folderHdl.DeleteFolder)
+		folderGroup.GET("/:id/children", folderHdl.GetFolderChildren)
+		folderGroup.GET("/:id/path", folderHdl.GetFolderPath)
+		folderGroup.PUT("/:id/parent", folderHdl.MoveFolder)
+		folderGroup.POST("/:id/copy", folderHdl.CopyFolder)
+		folderGroup.GET("/:id/export", archiveHdl.ExportFolder)
+		folderGroup.POST("/import", archiveHdl.ImportArchive)
+		folderGroup.GET("/:id/notes", noteHdl.GetFolderNotes)
+		folderGroup.GET("/:id/shares", folderHdl.GetFolderShares)
+		folderGroup.POST("/:id/shares", folderHdl.ShareFolder)
+		folderGroup.PUT("/:id/shares/:user_id", folderHdl.UpdateFolderShare)
+		folderGroup.DELETE("/:id/shares/:user_id", folderHdl.RevokeFolderShare)
+		folderGroup.GET("/:id/team-shares", folderHdl.GetFolderTeamShares)
+		folderGroup.GET("/:id/links", shareLinkHdl.GetFolderLinks)
+		folderGroup.POST("/:id/links", shareLinkHdl.CreateFolderLink)
+		folderGroup.POST("/:id/team-shares", folderHdl.ShareFolderWithTeam)
+		folderGroup.DELETE("/:id/team-shares/:team_id", folderHdl.RevokeFolderTeamShare)
+	}
+
+	noteGroup := authGroup.Group("/notes")
+	{
+		noteGroup.POST("/", noteHdl.CreateNote)
+		noteGroup.GET("/", noteHdl.GetUserNotes)
+		noteGroup.GET("/search", noteHdl.SearchNotes)
+		noteGroup.POST("/move", noteHdl.MoveNotes)
+		noteGroup.POST("/copy", noteHdl.CopyNotes)
+		noteGroup.GET("/:id", noteHdl.GetNote)
+		noteGroup.PUT("/:id", noteHdl.UpdateNote)
+		noteGroup.DELETE("/:id", noteHdl.DeleteNote)
+		noteGroup.GET("/:id/shares", noteHdl.GetNoteShares)
+		noteGroup.POST("/:id/shares", noteHdl.ShareNote)
+		noteGroup.PUT("/:id/shares/:user_id", noteHdl.UpdateNoteShare)
+		noteGroup.DELETE("/:id/shares/:user_id", noteHdl.RevokeNoteShare)
+		noteGroup.GET("/:id/team-shares", noteHdl.GetNoteTeamShares)
+		noteGroup.POST("/:id/team-shares", noteHdl.ShareNoteWithTeam)
+		noteGroup.DELETE("/:id/team-shares/:team_id", noteHdl.RevokeNoteTeamShare)
+		noteGroup.GET("/:id/links", shareLinkHdl.GetNoteLinks)
+		noteGroup.POST("/:id/links", shareLinkHdl.CreateNoteLink)
+		noteGroup.GET("/:id/revisions", noteHdl.ListNoteRevisions)
+		noteGroup.GET("/:id/revisions/diff", noteHdl.DiffNoteRevisions)
+		noteGroup.GET("/:id/revisions/:revision", noteHdl.GetNoteRevision)
+		noteGroup.POST("/:id/revisions/:revision/restore", noteHdl.RestoreNoteRevision)
+		noteGroup.GET("/:id/tags", tagHdl.GetNoteTags)
+		noteGroup.POST("/:id/tags", tagHdl.AddNoteTag)
+		noteGroup.DELETE("/:id/tags/:tag_id", tagHdl.RemoveNoteTag)
+		noteGroup.GET("/:id/attachments", attachmentHdl.ListAttachments)
+		noteGroup.POST("/:id/attachments", attachmentHdl.UploadAttachment)
+		noteGroup.GET("/:id/attachments/:attachment_id", attachmentHdl.DownloadAttachment)
+		noteGroup.DELETE("/:id/attachments/:attachment_id", attachmentHdl.DeleteAttachment)
+		noteGroup.GET("/:id/comments", commentHdl.ListComments)
+		noteGroup.POST("/:id/comments", commentHdl.CreateComment)
+	}
+
+	authGroup.DELETE("/links/:id", shareLinkHdl.RevokeLink)
+
+	commentGroup := authGroup.Group("/comments")
+	{
+		commentGroup.PUT("/:id", commentHdl.UpdateComment)
+		commentGroup.PUT("/:id/resolved", commentHdl.ResolveComment)
+		commentGroup.DELETE("/:id", commentHdl.DeleteComment)
+	}
+
+	tagGroup := authGroup.Group("/tags")
+	{
+		tagGroup.POST("/", tagHdl.CreateTag)
+		tagGroup.GET("/", tagHdl.ListTags)
+		tagGroup.PUT("/:id", tagHdl.RenameTag)
+		tagGroup.POST("/:id/merge", tagHdl.MergeTag)
+		tagGroup.DELETE("/:id", tagHdl.DeleteTag)
+	}
+
+	templateGroup := authGroup.Group("/templates")
+	{
+		templateGroup.POST("/", templateHdl.CreateTemplate)
+		templateGroup.GET("/", templateHdl.ListTemplates)
+		templateGroup.GET("/:id", templateHdl.GetTemplate)
+		templateGroup.PUT("/:id", templateHdl.UpdateTemplate)
+		templateGroup.DELETE("/:id", templateHdl.DeleteTemplate)
+		templateGroup.POST("/:id/notes", templateHdl.CreateNote)
+		templateGroup.GET("/:id/shares", templateHdl.GetTemplateShares)
+		templateGroup.POST("/:id/shares", templateHdl.ShareTemplate)
+		templateGroup.PUT("/:id/shares/:user_id", templateHdl.UpdateTemplateShare)
+		templateGroup.DELETE("/:id/shares/:user_id", templateHdl.RevokeTemplateShare)
+		templateGroup.GET("/:id/team-shares", templateHdl.GetTemplateTeamShares)
+		templateGroup.POST("/:id/team-shares", templateHdl.ShareTemplateWithTeam)
+		templateGroup.DELETE("/:id/team-shares/:team_id", templateHdl.RevokeTemplateTeamShare)
+	}
+
+	trashGroup := authGroup.Group("/trash")
+	{
+		trashGroup.GET("/", trashHdl.ListTrash)
+		trashGroup.POST("/folders/:id/restore", trashHdl.RestoreFolder)
+		trashGroup.DELETE("/folders/:id", trashHdl.PurgeFolder)
+		trashGroup.POST("/notes/:id/restore", trashHdl.RestoreNote)
+		trashGroup.DELETE("/notes/:id", trashHdl.PurgeNote)
+	}
+
+	// Background jobs
+	jobCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	job.Schedule(jobCtx, "expire-team-memberships", cfg.MembershipExpiryInterval, teamSvc.ExpireMemberships)
+	job.Schedule(jobCtx, "purge-trash", cfg.TrashPurgeInterval, trashSvc.PurgeExpired)
+	job.Schedule(jobCtx, "expire-shares", cfg.ShareExpiryInterval, shareExpirySvc.ExpireShares)
+	go func() {
+		if err := broker.Run(jobCtx); err != nil {
+			logger.Log.Error("subscription event broker stopped", zap.Error(err))
+		}
+	}()
+
 	logger.Log.Info("Starting server on port " + cfg.Port)
 	if err := r.Run(":" + cfg.Port); err != nil {
 		logger.Log.Fatal("failed to start server", zap.Error(err))
 	}
 }
+
+// openBlobStore opens the attachment storage selected by STORAGE_BACKEND.
+func openBlobStore(cfg *config.Config) (blobstore.BlobStore, error) {
+	switch cfg.StorageBackend {
+	case "local":
+		return blobstore.NewLocal(cfg.StorageLocalDir)
+	case "s3":
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		return blobstore.NewS3(ctx, blobstore.S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			Bucket:    cfg.S3Bucket,
+			Region:    cfg.S3Region,
+			UseSSL:    cfg.S3UseSSL,
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.StorageBackend)
+	}
+}
+
+// openPubSubBackend opens the subscription event backend selected by
+// PUBSUB_BACKEND. Run more than one replica only with "postgres".
+func openPubSubBackend(cfg *config.Config, conn *gorm.DB) (pubsub.Backend, error) {
+	switch cfg.PubSubBackend {
+	case "local":
+		return pubsub.NewLocal(), nil
+	case "postgres":
+		sqlDB, err := conn.DB()
+		if err != nil {
+			return nil, err
+		}
+		return pubsub.NewPostgres(sqlDB, cfg.DatabaseURL, cfg.PubSubChannel), nil
+	default:
+		return nil, fmt.Errorf("unknown pubsub backend %q", cfg.PubSubBackend)
+	}
+}
@@ -1,8 +1,12 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -12,6 +16,55 @@ type Config struct {
 	JWTSecret   string `mapstructure:"JWT_SECRET"`
 	Port        string `mapstructure:"PORT"`
 	Production  bool   `mapstructure:"PRODUCTION"`
+
+	// Background jobs
+	MembershipExpiryInterval time.Duration `mapstructure:"MEMBERSHIP_EXPIRY_INTERVAL"`
+	TrashPurgeInterval       time.Duration `mapstructure:"TRASH_PURGE_INTERVAL"`
+	ShareExpiryInterval      time.Duration `mapstructure:"SHARE_EXPIRY_INTERVAL"`
+
+	// ShareExpiryWarning is how long before a folder or note share expires
+	// its grantee and granter are warned.
+	ShareExpiryWarning time.Duration `mapstructure:"SHARE_EXPIRY_WARNING"`
+
+	// TrashRetention is how long deleted folders and notes stay in the trash
+	// before they are purged.
+	TrashRetention time.Duration `mapstructure:"TRASH_RETENTION"`
+
+	// NoteRevisionRetention is how many revisions to keep per note; 0 keeps
+	// them all.
+	NoteRevisionRetention int `mapstructure:"NOTE_REVISION_RETENTION"`
+
+	// MarkdownCacheSize is how many rendered note bodies to keep in memory;
+	// 0 disables the cache.
+	MarkdownCacheSize int `mapstructure:"MARKDOWN_CACHE_SIZE"`
+
+	// Attachments
+	AttachmentMaxSize int    `mapstructure:"ATTACHMENT_MAX_SIZE"` // bytes per file
+	StorageBackend    string `mapstructure:"STORAGE_BACKEND"`     // "local" or "s3"
+	StorageLocalDir   string `mapstructure:"STORAGE_LOCAL_DIR"`
+	S3Endpoint        string `mapstructure:"S3_ENDPOINT"`
+	S3AccessKey       string `mapstructure:"S3_ACCESS_KEY"`
+	S3SecretKey       string `mapstructure:"S3_SECRET_KEY"`
+	S3Bucket          string `mapstructure:"S3_BUCKET"`
+	S3Region          string `mapstructure:"S3_REGION"`
+	S3UseSSL          bool   `mapstructure:"S3_USE_SSL"`
+
+	// Archive imports
+	ImportMaxSize         int `mapstructure:"IMPORT_MAX_SIZE"`         // bytes per uploaded archive
+	ImportMaxEntries      int `mapstructure:"IMPORT_MAX_ENTRIES"`      // files and directories per archive
+	ImportMaxUncompressed int `mapstructure:"IMPORT_MAX_UNCOMPRESSED"` // bytes of notes after decompression
+
+	// Subscription events
+	PubSubBackend string `mapstructure:"PUBSUB_BACKEND"` // "local" or "postgres"
+	PubSubChannel string `mapstructure:"PUBSUB_CHANNEL"` // NOTIFY channel shared by the replicas
+
+	// CollabSnapshotInterval is how often a collaborative editing session
+	// writes its body back to the note.
+	CollabSnapshotInterval time.Duration `mapstructure:"COLLAB_SNAPSHOT_INTERVAL"`
+
+	// ShareLinkRateLimit is how many times a minute one client can open one
+	// public link; 0 disables the limit.
+	ShareLinkRateLimit int `mapstructure:"SHARE_LINK_RATE_LIMIT"`
 }
 
 func LoadConfig() *Config {
@@ -36,10 +89,92 @@ func LoadConfig() *Config {
 		return nil
 	}
 
-	return &Config{
+	var env envReader
+	cfg := &Config{
 		DatabaseURL: databaseUrl,
 		JWTSecret:   jwt,
 		Port:        port,
 		Production:  production,
+
+		MembershipExpiryInterval: env.getDuration("MEMBERSHIP_EXPIRY_INTERVAL", time.Minute),
+		TrashPurgeInterval:       env.getDuration("TRASH_PURGE_INTERVAL", time.Hour),
+		ShareExpiryInterval:      env.getDuration("SHARE_EXPIRY_INTERVAL", time.Minute),
+
+		ShareExpiryWarning: env.getDuration("SHARE_EXPIRY_WARNING", 72*time.Hour),
+
+		TrashRetention: env.getDuration("TRASH_RETENTION", 30*24*time.Hour),
+
+		NoteRevisionRetention: env.getInt("NOTE_REVISION_RETENTION", 50),
+		MarkdownCacheSize:     env.getInt("MARKDOWN_CACHE_SIZE", 1000),
+
+		AttachmentMaxSize: env.getInt("ATTACHMENT_MAX_SIZE", 25<<20),
+		StorageBackend:    env.getString("STORAGE_BACKEND", "local"),
+		StorageLocalDir:   env.getString("STORAGE_LOCAL_DIR", "data/attachments"),
+		S3Endpoint:        os.Getenv("S3_ENDPOINT"),
+		S3AccessKey:       os.Getenv("S3_ACCESS_KEY"),
+		S3SecretKey:       os.Getenv("S3_SECRET_KEY"),
+		S3Bucket:          env.getString("S3_BUCKET", "attachments"),
+		S3Region:          os.Getenv("S3_REGION"),
+		S3UseSSL:          os.Getenv("S3_USE_SSL") == "true",
+
+		ImportMaxSize:         env.getInt("IMPORT_MAX_SIZE", 50<<20),
+		ImportMaxEntries:      env.getInt("IMPORT_MAX_ENTRIES", 10000),
+		ImportMaxUncompressed: env.getInt("IMPORT_MAX_UNCOMPRESSED", 200<<20),
+
+		PubSubBackend: env.getString("PUBSUB_BACKEND", "local"),
+		PubSubChannel: env.getString("PUBSUB_CHANNEL", "app_events"),
+
+		CollabSnapshotInterval: env.getDuration("COLLAB_SNAPSHOT_INTERVAL", 30*time.Second),
+
+		ShareLinkRateLimit: env.getInt("SHARE_LINK_RATE_LIMIT", 60),
+	}
+	if err := errors.Join(env.errs...); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+		return nil
+	}
+	return cfg
+}
+
+// envReader reads optional settings, collecting the malformed ones so that
+// LoadConfig can report them all at once.
+type envReader struct {
+	errs []error
+}
+
+// getDuration reads an optional positive duration such as "30s" or "24h",
+// falling back to def when it is unset.
+func (e *envReader) getDuration(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		e.errs = append(e.errs, fmt.Errorf("%s must be a positive duration, got %q", key, value))
+		return def
+	}
+	return d
+}
+
+// getInt reads an optional non-negative integer, falling back to def when it
+// is unset.
+func (e *envReader) getInt(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		e.errs = append(e.errs, fmt.Errorf("%s must be a non-negative integer, got %q", key, value))
+		return def
+	}
+	return n
+}
+
+// getString reads an optional string, falling back to def when it is unset.
+func (e *envReader) getString(key string, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
 	}
+	return def
 }
@@ -4,13 +4,16 @@ import (
 	"time"
 
 	"go-training-system/internal/model"
+	"go-training-system/pkg/ot"
+	"go-training-system/pkg/textdiff"
 
 	"github.com/google/uuid"
 )
 
 type CreateFolderRequest struct {
-	Name        string `json:"name" validate:"required,min=1,max=255"`
-	Description string `json:"description" validate:"max=1000"`
+	Name        string     `json:"name" validate:"required,min=1,max=255"`
+	Description string     `json:"description" validate:"max=1000"`
+	ParentID    *uuid.UUID `json:"parent_id"`
 }
 
 type UpdateFolderRequest struct {
@@ -19,12 +22,70 @@ type UpdateFolderRequest struct {
 }
 
 type FolderResponse struct {
-	ID          uuid.UUID `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	OwnerID     uuid.UUID `json:"owner_id"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          uuid.UUID  `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	ParentID    *uuid.UUID `json:"parent_id"`
+	OwnerID     uuid.UUID  `json:"owner_id"`
+	Version     int64      `json:"version"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// ListRequest pages through the folders or notes the caller can see.
+// Without FolderID those are the ones they own and the ones shared with them;
+// with it, everything directly in that folder. Scope is "all", "owned" or
+// "shared". Sort is "updated_at", the default, "created_at" or "name" (title
+// for notes), and Order "asc" or "desc", by default desc for the times and
+// asc for names.
+// Cursor is the NextCursor of the previous page, and Tags, for notes only, is
+// a tag expression such as "go AND (testing OR review)".
+type ListRequest struct {
+	Cursor       string
+	Limit        int
+	Sort         string
+	Order        string
+	Scope        string
+	FolderID     *uuid.UUID
+	UpdatedSince *time.Time
+	Tags         string
+}
+
+// FolderPage is one page of a folder list. Total counts the folders on all
+// pages, and NextCursor is empty on the last page.
+type FolderPage struct {
+	Items      []FolderResponse `json:"items"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+	Total      int64            `json:"total"`
+}
+
+// MoveFolderRequest moves a folder under ParentID, or to the top level when
+// ParentID is null. DropShares removes the shares granted on the folder
+// instead of carrying them along; only the owner can drop them.
+type MoveFolderRequest struct {
+	ParentID   *uuid.UUID `json:"parent_id"`
+	DropShares bool       `json:"drop_shares"`
+}
+
+// CopyFolderRequest deep copies a folder under ParentID, or to the top level
+// when ParentID is null.
+type CopyFolderRequest struct {
+	ParentID *uuid.UUID `json:"parent_id"`
+}
+
+// FolderTreeNode is a folder with its subfolders nested below it.
+type FolderTreeNode struct {
+	ID       uuid.UUID        `json:"id"`
+	Name     string           `json:"name"`
+	ParentID *uuid.UUID       `json:"parent_id"`
+	OwnerID  uuid.UUID        `json:"owner_id"`
+	Children []FolderTreeNode `json:"children"`
+}
+
+// FolderBreadcrumb is one step of the path from a top-level folder.
+type FolderBreadcrumb struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
 }
 
 type CreateNoteRequest struct {
@@ -38,17 +99,480 @@ type UpdateNoteRequest struct {
 	Body  string `json:"body"`
 }
 
+// MoveNotesRequest moves the selected notes into FolderID. DropShares
+// removes the notes' own shares instead of carrying them along; only the
+// owner of every selected note can drop them.
+type MoveNotesRequest struct {
+	NoteIDs    []uuid.UUID `json:"note_ids" validate:"required,min=1,max=100"`
+	FolderID   uuid.UUID   `json:"folder_id" validate:"required"`
+	DropShares bool        `json:"drop_shares"`
+}
+
+// CopyNotesRequest copies the selected notes into FolderID.
+type CopyNotesRequest struct {
+	NoteIDs  []uuid.UUID `json:"note_ids" validate:"required,min=1,max=100"`
+	FolderID uuid.UUID   `json:"folder_id" validate:"required"`
+}
+
 type NoteResponse struct {
 	ID        uuid.UUID `json:"id"`
 	Title     string    `json:"title"`
 	Body      string    `json:"body"`
 	FolderID  uuid.UUID `json:"folder_id"`
 	OwnerID   uuid.UUID `json:"owner_id"`
+	Version   int64     `json:"version"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+	// BodyHTML is the body rendered to sanitized HTML, filled in only when
+	// the client asks for ?format=html.
+	BodyHTML string `json:"body_html,omitempty"`
+}
+
+// NotePage is one page of a note list, like FolderPage.
+type NotePage struct {
+	Items      []NoteResponse `json:"items"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+	Total      int64          `json:"total"`
+}
+
+// NoteSearchRequest is a full-text search over the notes the caller can
+// read. FolderID also matches notes in its subfolders; the update time range
+// includes UpdatedFrom and excludes UpdatedTo. Tags is an optional tag
+// expression such as "go AND (testing OR review)".
+type NoteSearchRequest struct {
+	Query       string
+	Tags        string
+	FolderID    *uuid.UUID
+	OwnerID     *uuid.UUID
+	UpdatedFrom *time.Time
+	UpdatedTo   *time.Time
+	Limit       int
+	Offset      int
+}
+
+// NoteSearchResult is a matching note, best match first. Snippet is an
+// HTML-escaped excerpt of the body with the matched terms wrapped in <mark>
+// tags.
+type NoteSearchResult struct {
+	ID        uuid.UUID `json:"id"`
+	Title     string    `json:"title"`
+	FolderID  uuid.UUID `json:"folder_id"`
+	OwnerID   uuid.UUID `json:"owner_id"`
+	Snippet   string    `json:"snippet"`
+	Rank      float64   `json:"rank"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type NoteRevisionSummary struct {
+	Revision   int       `json:"revision"`
+	Title      string    `json:"title"`
+	AuthorID   uuid.UUID `json:"author_id"`
+	AuthorName string    `json:"author_name"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type NoteRevisionResponse struct {
+	NoteRevisionSummary
+	Body string `json:"body"`
+}
+
+// NoteRevisionDiff is the line diff of the body from revision From to To,
+// plus both titles.
+type NoteRevisionDiff struct {
+	From      int             `json:"from"`
+	To        int             `json:"to"`
+	FromTitle string          `json:"from_title"`
+	ToTitle   string          `json:"to_title"`
+	Lines     []textdiff.Line `json:"lines"`
 }
 
+// ShareRequest shares a resource with one user. AllowReshare may only be set
+// by the owner or a manager. Override is for note shares only: it replaces
+// the access the user inherits from the folder, and with access "none" denies
+// the note. Access "manage" and ExpiresAt are for folder and note shares; a
+// nil ExpiresAt never expires.
 type ShareRequest struct {
-	UserID uuid.UUID           `json:"user_id" validate:"required"`
-	Access model.AccessLevel   `json:"access" validate:"required,oneof=read write"`
+	UserID       uuid.UUID         `json:"user_id" validate:"required"`
+	Access       model.AccessLevel `json:"access" validate:"required,oneof=read write manage none"`
+	AllowReshare bool              `json:"allow_reshare"`
+	Override     bool              `json:"override"`
+	ExpiresAt    *time.Time        `json:"expires_at"`
+}
+
+// UpdateShareRequest changes an existing share. Nil flags are left
+// unchanged, and so is the expiry unless ExpiresAt is set or RemoveExpiry
+// makes the share permanent.
+type UpdateShareRequest struct {
+	Access       model.AccessLevel `json:"access" validate:"required,oneof=read write manage none"`
+	AllowReshare *bool             `json:"allow_reshare"`
+	Override     *bool             `json:"override"`
+	ExpiresAt    *time.Time        `json:"expires_at"`
+	RemoveExpiry bool              `json:"remove_expiry"`
+}
+
+type ShareResponse struct {
+	UserID       uuid.UUID         `json:"user_id"`
+	Username     string            `json:"username"`
+	Access       model.AccessLevel `json:"access"`
+	AllowReshare bool              `json:"allow_reshare"`
+	Override     bool              `json:"override"`
+	ExpiresAt    *time.Time        `json:"expires_at,omitempty"`
+	SharedByID   uuid.UUID         `json:"shared_by_id"`
+	SharedAt     time.Time         `json:"shared_at"`
+}
+
+// TeamShareRequest grants a resource to every current member of a team, or
+// only to its managers when ManagersOnly is set.
+type TeamShareRequest struct {
+	TeamID       uuid.UUID         `json:"team_id" validate:"required"`
+	Access       model.AccessLevel `json:"access" validate:"required,oneof=read write"`
+	ManagersOnly bool              `json:"managers_only"`
+}
+
+type TeamShareResponse struct {
+	TeamID       uuid.UUID         `json:"team_id"`
+	TeamName     string            `json:"team_name"`
+	Access       model.AccessLevel `json:"access"`
+	ManagersOnly bool              `json:"managers_only"`
+	SharedByID   uuid.UUID         `json:"shared_by_id"`
+	SharedAt     time.Time         `json:"shared_at"`
+}
+
+// CreateShareLinkRequest creates a public link. An empty Password leaves the
+// link open to anyone holding it, and a nil ExpiresAt never expires.
+type CreateShareLinkRequest struct {
+	Password  string     `json:"password" validate:"max=72"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// ShareLinkResponse describes a public link. Token is only returned when the
+// link is created; afterwards it cannot be recovered.
+type ShareLinkResponse struct {
+	ID             uuid.UUID  `json:"id"`
+	Token          string     `json:"token,omitempty"`
+	FolderID       *uuid.UUID `json:"folder_id,omitempty"`
+	NoteID         *uuid.UUID `json:"note_id,omitempty"`
+	HasPassword    bool       `json:"has_password"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+	AccessCount    int64      `json:"access_count"`
+	LastAccessedAt *time.Time `json:"last_accessed_at"`
+	CreatedByID    uuid.UUID  `json:"created_by_id"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// PublicLinkRequest opens a public link. Client identifies the caller, such
+// as by IP address, for rate limiting.
+type PublicLinkRequest struct {
+	Token    string
+	Password string
+	Client   string
+}
+
+// PublicLinkResponse is what a public link shows: a note, or a folder with
+// its subfolders and notes. Owners and shares are left out.
+type PublicLinkResponse struct {
+	Type      string                `json:"type"`
+	Folder    *PublicFolderResponse `json:"folder,omitempty"`
+	Note      *PublicNoteResponse   `json:"note,omitempty"`
+	ExpiresAt *time.Time            `json:"expires_at"`
+}
+
+type PublicFolderResponse struct {
+	ID          uuid.UUID           `json:"id"`
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	UpdatedAt   time.Time           `json:"updated_at"`
+	Folders     []PublicFolderEntry `json:"folders"`
+	Notes       []PublicNoteEntry   `json:"notes"`
+}
+
+type PublicFolderEntry struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+}
+
+type PublicNoteEntry struct {
+	ID        uuid.UUID `json:"id"`
+	Title     string    `json:"title"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type PublicNoteResponse struct {
+	ID        uuid.UUID `json:"id"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CreateTagRequest creates a personal tag, or a team tag when TeamID is set.
+type CreateTagRequest struct {
+	Name   string     `json:"name" validate:"required,min=1,max=100"`
+	TeamID *uuid.UUID `json:"team_id"`
+}
+
+type RenameTagRequest struct {
+	Name string `json:"name" validate:"required,min=1,max=100"`
+}
+
+// MergeTagRequest merges a tag into IntoTagID, which keeps its name.
+type MergeTagRequest struct {
+	IntoTagID uuid.UUID `json:"into_tag_id" validate:"required"`
+}
+
+type NoteTagRequest struct {
+	TagID uuid.UUID `json:"tag_id" validate:"required"`
+}
+
+// TagResponse is a tag with the number of notes carrying it. Team tags have
+// TeamID set.
+type TagResponse struct {
+	ID        uuid.UUID  `json:"id"`
+	Name      string     `json:"name"`
+	TeamID    *uuid.UUID `json:"team_id,omitempty"`
+	NoteCount int64      `json:"note_count"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type AttachmentResponse struct {
+	ID           uuid.UUID `json:"id"`
+	NoteID       uuid.UUID `json:"note_id"`
+	FileName     string    `json:"file_name"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size"`
+	SHA256       string    `json:"sha256"`
+	UploadedByID uuid.UUID `json:"uploaded_by_id"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// TrashItemResponse is a deleted folder or note. Type is "folder" or "note";
+// ParentID is a folder's parent or a note's folder. PurgeAt is when it will
+// be deleted for good.
+type TrashItemResponse struct {
+	Type      string     `json:"type"`
+	ID        uuid.UUID  `json:"id"`
+	Name      string     `json:"name"`
+	ParentID  *uuid.UUID `json:"parent_id,omitempty"`
+	DeletedAt time.Time  `json:"deleted_at"`
+	PurgeAt   time.Time  `json:"purge_at"`
+}
+
+// ImportRequest controls an archive import. FolderID is where the archive's
+// top-level folders and notes go; nil puts its folders at the top level.
+// OnConflict is "skip", the default, or "duplicate" to import conflicting
+// notes anyway. A dry run reports what would happen without changing
+// anything.
+type ImportRequest struct {
+	FolderID   *uuid.UUID
+	OnConflict string
+	DryRun     bool
+}
+
+// ImportConflict is a note in the archive that clashes with an existing one,
+// either because it was exported from it or because the folder already has a
+// note with the same title. NoteID is the existing note.
+type ImportConflict struct {
+	Path   string    `json:"path"`
+	Reason string    `json:"reason"`
+	NoteID uuid.UUID `json:"note_id"`
+}
+
+// ImportReport sums up an import. FoldersCreated and NotesCreated count what
+// was, or on a dry run would be, created; existing folders with the same name
+// are merged into rather than created. Ignored lists archive entries that are
+// not notes and Warnings anything else left out.
+type ImportReport struct {
+	DryRun         bool             `json:"dry_run"`
+	FoldersCreated int              `json:"folders_created"`
+	NotesCreated   int              `json:"notes_created"`
+	NotesSkipped   int              `json:"notes_skipped"`
+	Conflicts      []ImportConflict `json:"conflicts"`
+	Ignored        []string         `json:"ignored"`
+	Warnings       []string         `json:"warnings"`
+}
+
+// TemplateField is a custom placeholder of a template. Fields are listed in
+// the order they should be asked for.
+type TemplateField struct {
+	Name     string `json:"name" validate:"required,min=1,max=50"`
+	Label    string `json:"label" validate:"max=255"`
+	Default  string `json:"default"`
+	Required bool   `json:"required"`
+}
+
+// CreateTemplateRequest creates a personal template, or a team template when
+// TeamID is set. Title and Body may use {{...}} placeholders.
+type CreateTemplateRequest struct {
+	Name        string          `json:"name" validate:"required,min=1,max=255"`
+	Description string          `json:"description" validate:"max=1000"`
+	Title       string          `json:"title" validate:"required,min=1,max=255"`
+	Body        string          `json:"body"`
+	TeamID      *uuid.UUID      `json:"team_id"`
+	Fields      []TemplateField `json:"fields" validate:"max=50,dive"`
+}
+
+// UpdateTemplateRequest replaces a template's content and fields. Its team
+// cannot be changed.
+type UpdateTemplateRequest struct {
+	Name        string          `json:"name" validate:"required,min=1,max=255"`
+	Description string          `json:"description" validate:"max=1000"`
+	Title       string          `json:"title" validate:"required,min=1,max=255"`
+	Body        string          `json:"body"`
+	Fields      []TemplateField `json:"fields" validate:"max=50,dive"`
+}
+
+type TemplateResponse struct {
+	ID          uuid.UUID       `json:"id"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Title       string          `json:"title"`
+	Body        string          `json:"body"`
+	OwnerID     uuid.UUID       `json:"owner_id"`
+	TeamID      *uuid.UUID      `json:"team_id,omitempty"`
+	Fields      []TemplateField `json:"fields"`
+	Version     int64           `json:"version"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// CreateFromTemplateRequest creates a note in FolderID from a template.
+// Values fills in custom fields; fields left out get their default. Dates
+// and times are rendered in TimeZone, an IANA name, or in UTC when empty.
+type CreateFromTemplateRequest struct {
+	FolderID uuid.UUID         `json:"folder_id" validate:"required"`
+	TimeZone string            `json:"time_zone"`
+	Values   map[string]string `json:"values"`
+}
+
+// CreateCommentRequest adds a comment to a note. With ParentID it replies to
+// that comment's thread; otherwise it starts a thread, which may be anchored
+// to a range of the note body.
+type CreateCommentRequest struct {
+	Body     string         `json:"body" validate:"required,min=1,max=10000"`
+	ParentID *uuid.UUID     `json:"parent_id"`
+	Anchor   *CommentAnchor `json:"anchor"`
+}
+
+type UpdateCommentRequest struct {
+	Body string `json:"body" validate:"required,min=1,max=10000"`
+}
+
+type ResolveCommentRequest struct {
+	Resolved bool `json:"resolved"`
+}
+
+// CommentAnchor is a range of a note body, in characters, with End
+// exclusive. In responses Text is the commented text and NoteVersion the
+// note version the range refers to.
+type CommentAnchor struct {
+	Start       int    `json:"start"`
+	End         int    `json:"end"`
+	Text        string `json:"text,omitempty"`
+	NoteVersion int64  `json:"note_version,omitempty"`
+}
+
+type CommentMention struct {
+	UserID   uuid.UUID `json:"user_id"`
+	Username string    `json:"username"`
+}
+
+// CommentResponse is a comment. The first comment of a thread carries the
+// thread's replies, oldest first, and its resolution.
+type CommentResponse struct {
+	ID             uuid.UUID         `json:"id"`
+	NoteID         uuid.UUID         `json:"note_id"`
+	ParentID       *uuid.UUID        `json:"parent_id,omitempty"`
+	AuthorID       uuid.UUID         `json:"author_id"`
+	AuthorUsername string            `json:"author_username"`
+	Body           string            `json:"body"`
+	Anchor         *CommentAnchor    `json:"anchor,omitempty"`
+	Mentions       []CommentMention  `json:"mentions"`
+	Resolved       bool              `json:"resolved"`
+	ResolvedAt     *time.Time        `json:"resolved_at,omitempty"`
+	ResolvedByID   *uuid.UUID        `json:"resolved_by_id,omitempty"`
+	Replies        []CommentResponse `json:"replies,omitempty"`
+	Version        int64             `json:"version"`
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
+}
+
+// Collaborative editing message types. A client sends join first, then op
+// and cursor messages; the server answers join with sync and then sends
+// ack, op, presence and error messages.
+const (
+	CollabJoin     = "join"
+	CollabOp       = "op"
+	CollabCursor   = "cursor"
+	CollabSync     = "sync"
+	CollabAck      = "ack"
+	CollabPresence = "presence"
+	CollabError    = "error"
+)
+
+// CollabClientMessage is a message from a collaborative editing client.
+// join carries Token, unless the connection was made with an Authorization
+// header, and Revision when resuming after a reconnect. op carries
+// Operation and the Revision it was made against. cursor carries the
+// Selection at Revision, or no selection when the editor lost focus; it
+// should only be sent while no operation is waiting for its ack.
+type CollabClientMessage struct {
+	Type      string           `json:"type"`
+	Token     string           `json:"token,omitempty"`
+	Revision  *int64           `json:"revision,omitempty"`
+	Operation ot.Operation     `json:"operation,omitempty"`
+	Selection *CollabSelection `json:"selection,omitempty"`
+}
+
+// CollabSelection is a selection in characters of the note body; Anchor
+// equals Head for a plain cursor.
+type CollabSelection struct {
+	Anchor int `json:"anchor"`
+	Head   int `json:"head"`
+}
+
+// CollabSnapshot is the note body as stored at Revision.
+type CollabSnapshot struct {
+	Revision int64  `json:"revision"`
+	Body     string `json:"body"`
+}
+
+// CollabOperation is an operation turning the body at Revision-1 into the
+// body at Revision. AuthorID is empty for edits made outside the session.
+type CollabOperation struct {
+	Revision  int64        `json:"revision"`
+	Operation ot.Operation `json:"operation"`
+	AuthorID  *uuid.UUID   `json:"author_id,omitempty"`
+}
+
+// CollabCollaborator is someone in the session. Left is set once they leave.
+type CollabCollaborator struct {
+	ClientID  string           `json:"client_id"`
+	UserID    uuid.UUID        `json:"user_id"`
+	Username  string           `json:"username"`
+	Selection *CollabSelection `json:"selection"`
+	Left      bool             `json:"left,omitempty"`
+}
+
+// CollabServerMessage is a message to a collaborative editing client.
+// Revision is the revision the message brings the client to, or the one
+// selections refer to.
+//
+// sync starts the session: the client loads Snapshot, if any, and applies
+// Operations to reach Revision. Without a snapshot the operations follow
+// the revision the client resumed from. ack confirms the client's own
+// operation, stored at Revision; op is someone else's. error is followed by
+// the end of the connection when the client must resync.
+type CollabServerMessage struct {
+	Type          string               `json:"type"`
+	Revision      int64                `json:"revision"`
+	ClientID      string               `json:"client_id,omitempty"`
+	ReadOnly      bool                 `json:"read_only,omitempty"`
+	Snapshot      *CollabSnapshot      `json:"snapshot,omitempty"`
+	Operations    []CollabOperation    `json:"operations,omitempty"`
+	Collaborators []CollabCollaborator `json:"collaborators,omitempty"`
+	Operation     ot.Operation         `json:"operation,omitempty"`
+	AuthorID      *uuid.UUID           `json:"author_id,omitempty"`
+	Collaborator  *CollabCollaborator  `json:"collaborator,omitempty"`
+	Code          int                  `json:"code,omitempty"`
+	Message       string               `json:"message,omitempty"`
 }
@@ -1,7 +1,14 @@
 package dto
 
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
 type CreateTeamRequest struct {
 	TeamName string `json:"teamName"`
+	ParentID string `json:"parentId"`
 	Managers []struct {
 		ManagerID   string `json:"managerId"`
 		ManagerName string `json:"managerName"`
@@ -14,4 +21,116 @@ type CreateTeamRequest struct {
 
 type UserIDRequest struct {
 	UserID string `json:"user_id" binding:"required"`
+	// ExpiresAt makes the membership temporary
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// MoveTeamRequest re-parents a team. A null parentId makes it a root unit.
+type MoveTeamRequest struct {
+	ParentID *string `json:"parentId"`
+}
+
+type TeamMemberResponse struct {
+	UserID   uuid.UUID `json:"userId"`
+	Username string    `json:"username"`
+	Email    string    `json:"email"`
+	Role     string    `json:"role"`
+	TeamID   uuid.UUID `json:"teamId"`
+	TeamName string    `json:"teamName"`
+	// Inherited is true when the membership belongs to a descendant team
+	Inherited bool      `json:"inherited"`
+	AddedAt   time.Time `json:"addedAt"`
+}
+
+type TeamResponse struct {
+	TeamID      uuid.UUID            `json:"teamId"`
+	TeamName    string               `json:"teamName"`
+	ParentID    *uuid.UUID           `json:"parentId"`
+	CreatedByID uuid.UUID            `json:"createdById"`
+	Members     []TeamMemberResponse `json:"members"`
+	CreatedAt   time.Time            `json:"createdAt"`
+	UpdatedAt   time.Time            `json:"updatedAt"`
+}
+
+type TeamSummary struct {
+	TeamID   uuid.UUID  `json:"teamId"`
+	TeamName string     `json:"teamName"`
+	ParentID *uuid.UUID `json:"parentId"`
+}
+
+// TeamTreeNode is one unit of an organization subtree. MemberCount and
+// ManagerCount cover the team itself, SubtreeMemberCount adds up the
+// memberships of the team and all of its descendants.
+type TeamTreeNode struct {
+	TeamID             uuid.UUID       `json:"teamId"`
+	TeamName           string          `json:"teamName"`
+	ParentID           *uuid.UUID      `json:"parentId"`
+	Depth              int             `json:"depth"`
+	MemberCount        int64           `json:"memberCount"`
+	ManagerCount       int64           `json:"managerCount"`
+	SubtreeMemberCount int64           `json:"subtreeMemberCount"`
+	Children           []*TeamTreeNode `json:"children"`
+}
+
+type MembershipOperationType string
+
+const (
+	MembershipOpAdd        MembershipOperationType = "ADD"
+	MembershipOpRemove     MembershipOperationType = "REMOVE"
+	MembershipOpChangeRole MembershipOperationType = "CHANGE_ROLE"
+)
+
+// MembershipOperation is one item of a bulk membership change. Role is the
+// role to add the user with (defaults to MEMBER) or the new role for
+// CHANGE_ROLE; it is ignored for REMOVE.
+type MembershipOperation struct {
+	Op     MembershipOperationType `json:"op" binding:"required"`
+	TeamID string                  `json:"teamId" binding:"required"`
+	UserID string                  `json:"userId" binding:"required"`
+	Role   string                  `json:"role"`
+	// ExpiresAt makes an added membership temporary
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+// BulkMembershipRequest applies several membership operations at once. With
+// Atomic set, either every operation is applied or none is; otherwise each
+// operation is applied independently (best effort).
+type BulkMembershipRequest struct {
+	Operations []MembershipOperation `json:"operations" binding:"required,min=1,max=500,dive"`
+	Atomic     bool                  `json:"atomic"`
+}
+
+type MembershipOperationResult struct {
+	Index     int                     `json:"index"`
+	Op        MembershipOperationType `json:"op"`
+	TeamID    string                  `json:"teamId"`
+	UserID    string                  `json:"userId"`
+	Success   bool                    `json:"success"`
+	ErrorCode string                  `json:"errorCode,omitempty"`
+	Message   string                  `json:"message,omitempty"`
+}
+
+type BulkMembershipResult struct {
+	Atomic bool `json:"atomic"`
+	// Applied is false when an atomic batch was rolled back
+	Applied   bool                        `json:"applied"`
+	Succeeded int                         `json:"succeeded"`
+	Failed    int                         `json:"failed"`
+	Results   []MembershipOperationResult `json:"results"`
+}
+
+// TeamMembershipPeriod is one continuous stretch of a user holding a role in
+// a team. LeftAt is nil while the membership is still current.
+type TeamMembershipPeriod struct {
+	TeamID      uuid.UUID  `json:"teamId"`
+	TeamName    string     `json:"teamName,omitempty"`
+	UserID      uuid.UUID  `json:"userId"`
+	Username    string     `json:"username,omitempty"`
+	Role        string     `json:"role"`
+	JoinedAt    time.Time  `json:"joinedAt"`
+	LeftAt      *time.Time `json:"leftAt"`
+	ExpiresAt   *time.Time `json:"expiresAt"`
+	AddedByID   *uuid.UUID `json:"addedById"`
+	RemovedByID *uuid.UUID `json:"removedById"`
+	EndReason   string     `json:"endReason,omitempty"`
 }
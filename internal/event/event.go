@@ -0,0 +1,214 @@
+// Package event publishes changes to notes, folders, team memberships and
+// shares so GraphQL subscriptions on any replica can push them to clients. Events only
+// say what changed; subscribers load the current state themselves, which
+// also re-checks that the subscriber may still see it.
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"go-training-system/pkg/logger"
+	"go-training-system/pkg/pubsub"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type Kind string
+
+const (
+	KindCreated  Kind = "CREATED"
+	KindUpdated  Kind = "UPDATED"
+	KindMoved    Kind = "MOVED"
+	KindDeleted  Kind = "DELETED"
+	KindRestored Kind = "RESTORED"
+
+	KindMemberAdded   Kind = "ADDED"
+	KindMemberRemoved Kind = "REMOVED"
+	KindRoleChanged   Kind = "ROLE_CHANGED"
+	KindMemberExpired Kind = "EXPIRED"
+
+	KindShareExpiring Kind = "EXPIRING"
+	KindShareExpired  Kind = "EXPIRED"
+)
+
+type ItemType string
+
+const (
+	ItemFolder ItemType = "FOLDER"
+	ItemNote   ItemType = "NOTE"
+)
+
+// NoteEvent is published to the note's own topic.
+type NoteEvent struct {
+	NoteID   uuid.UUID `json:"noteId"`
+	FolderID uuid.UUID `json:"folderId"`
+	Kind     Kind      `json:"kind"`
+	Version  int64     `json:"version"`
+	ActorID  uuid.UUID `json:"actorId"`
+}
+
+// FolderEvent is published to a folder's topic when the folder itself or one
+// of the notes and subfolders directly in it changes. ItemID is the folder
+// itself or the item that changed.
+type FolderEvent struct {
+	FolderID uuid.UUID `json:"folderId"`
+	Kind     Kind      `json:"kind"`
+	ItemType ItemType  `json:"itemType"`
+	ItemID   uuid.UUID `json:"itemId"`
+	ActorID  uuid.UUID `json:"actorId"`
+}
+
+// MembershipEvent is published to a team's topic. ActorID is uuid.Nil for
+// memberships that expired.
+type MembershipEvent struct {
+	TeamID  uuid.UUID `json:"teamId"`
+	UserID  uuid.UUID `json:"userId"`
+	Kind    Kind      `json:"kind"`
+	Role    string    `json:"role,omitempty"`
+	ActorID uuid.UUID `json:"actorId"`
+}
+
+// ShareEvent is published to the topics of the user a folder or note share
+// was granted to and of the user who granted it, once when the share is about
+// to expire and again when it has.
+type ShareEvent struct {
+	ItemType   ItemType  `json:"itemType"`
+	ItemID     uuid.UUID `json:"itemId"`
+	UserID     uuid.UUID `json:"userId"`
+	SharedByID uuid.UUID `json:"sharedById"`
+	Kind       Kind      `json:"kind"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+// Bus publishes and subscribes to typed events over a pubsub.Broker. A nil
+// *Bus publishes nothing, so services work without one.
+type Bus struct {
+	broker *pubsub.Broker
+}
+
+func NewBus(broker *pubsub.Broker) *Bus {
+	return &Bus{broker: broker}
+}
+
+// NoteChanged publishes the event to the note's topic and, as a folder
+// event, to the note's folder. Moves are also published to the folder the
+// note left, given as from.
+func (b *Bus) NoteChanged(ctx context.Context, e NoteEvent, from ...uuid.UUID) {
+	b.publish(ctx, noteTopic(e.NoteID), e)
+	for i, folderID := range append([]uuid.UUID{e.FolderID}, from...) {
+		if i > 0 && folderID == e.FolderID {
+			continue
+		}
+		b.publish(ctx, folderTopic(folderID), FolderEvent{
+			FolderID: folderID,
+			Kind:     e.Kind,
+			ItemType: ItemNote,
+			ItemID:   e.NoteID,
+			ActorID:  e.ActorID,
+		})
+	}
+}
+
+// FolderChanged publishes a change of a folder to its own topic and to the
+// topics of its parents: the current one and, for moves, the previous one.
+// Top-level folders have no parent.
+func (b *Bus) FolderChanged(ctx context.Context, folderID uuid.UUID, kind Kind, actorID uuid.UUID, parents ...*uuid.UUID) {
+	b.publish(ctx, folderTopic(folderID), FolderEvent{
+		FolderID: folderID,
+		Kind:     kind,
+		ItemType: ItemFolder,
+		ItemID:   folderID,
+		ActorID:  actorID,
+	})
+	seen := make(map[uuid.UUID]bool, len(parents))
+	for _, parentID := range parents {
+		if parentID == nil || seen[*parentID] {
+			continue
+		}
+		seen[*parentID] = true
+		b.publish(ctx, folderTopic(*parentID), FolderEvent{
+			FolderID: *parentID,
+			Kind:     kind,
+			ItemType: ItemFolder,
+			ItemID:   folderID,
+			ActorID:  actorID,
+		})
+	}
+}
+
+func (b *Bus) MembershipChanged(ctx context.Context, e MembershipEvent) {
+	b.publish(ctx, teamTopic(e.TeamID), e)
+}
+
+func (b *Bus) ShareChanged(ctx context.Context, e ShareEvent) {
+	b.publish(ctx, userTopic(e.UserID), e)
+	if e.SharedByID != e.UserID {
+		b.publish(ctx, userTopic(e.SharedByID), e)
+	}
+}
+
+// NoteEvents returns the events of one note until ctx is cancelled.
+func (b *Bus) NoteEvents(ctx context.Context, noteID uuid.UUID) <-chan NoteEvent {
+	return subscribe[NoteEvent](ctx, b.broker, noteTopic(noteID))
+}
+
+// FolderEvents returns the events of one folder until ctx is cancelled.
+func (b *Bus) FolderEvents(ctx context.Context, folderID uuid.UUID) <-chan FolderEvent {
+	return subscribe[FolderEvent](ctx, b.broker, folderTopic(folderID))
+}
+
+// MembershipEvents returns the membership changes of one team until ctx is
+// cancelled.
+func (b *Bus) MembershipEvents(ctx context.Context, teamID uuid.UUID) <-chan MembershipEvent {
+	return subscribe[MembershipEvent](ctx, b.broker, teamTopic(teamID))
+}
+
+// ShareEvents returns the share events of one user, as grantee or granter,
+// until ctx is cancelled.
+func (b *Bus) ShareEvents(ctx context.Context, userID uuid.UUID) <-chan ShareEvent {
+	return subscribe[ShareEvent](ctx, b.broker, userTopic(userID))
+}
+
+// publish never fails the change that caused the event: by the time it runs
+// the change is committed, so a lost event is only logged.
+func (b *Bus) publish(ctx context.Context, topic string, e any) {
+	if b == nil {
+		return
+	}
+	payload, err := json.Marshal(e)
+	if err == nil {
+		err = b.broker.Publish(ctx, topic, payload)
+	}
+	if err != nil {
+		logger.Log.Warn("failed to publish event", zap.String("topic", topic), zap.Error(err))
+	}
+}
+
+func subscribe[T any](ctx context.Context, broker *pubsub.Broker, topic string) <-chan T {
+	events := make(chan T)
+	payloads := broker.Subscribe(ctx, topic)
+	go func() {
+		defer close(events)
+		for payload := range payloads {
+			var e T
+			if err := json.Unmarshal(payload, &e); err != nil {
+				logger.Log.Warn("ignoring malformed event", zap.String("topic", topic), zap.Error(err))
+				continue
+			}
+			select {
+			case events <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events
+}
+
+func noteTopic(id uuid.UUID) string   { return "note:" + id.String() }
+func folderTopic(id uuid.UUID) string { return "folder:" + id.String() }
+func teamTopic(id uuid.UUID) string   { return "team:" + id.String() }
+func userTopic(id uuid.UUID) string   { return "user:" + id.String() }
@@ -1,10 +1,99 @@
 package apperror
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
 	ErrEmailTaken   = errors.New("email is already taken")
 	ErrInvalidLogin = errors.New("invalid email or password")
 	ErrUserNotFound = errors.New("user not found")
 	ErrUnauthorized = errors.New("unauthorized access")
+	ErrAccessDenied = errors.New("access denied")
+
+	ErrTeamNotFound = errors.New("team not found")
+	ErrTeamCycle    = errors.New("team cannot be moved under itself or one of its descendants")
+
+	ErrAlreadyMember   = errors.New("user is already in the team")
+	ErrMemberNotFound  = errors.New("member not found in team")
+	ErrManagerNotFound = errors.New("manager not found in team")
+	ErrInvalidExpiry   = errors.New("membership expiry must be in the future")
+
+	ErrTeamShareNotFound = errors.New("team share not found")
+
+	ErrFolderNotFound = errors.New("folder not found")
+	ErrNoteNotFound   = errors.New("note not found")
+	ErrFolderCycle    = errors.New("folder cannot be moved into itself or one of its subfolders")
+	ErrFolderNotEmpty = errors.New("folder is not empty")
+	ErrParentInTrash  = errors.New("the parent folder is in another user's trash")
+
+	ErrInvalidNoteSelection = errors.New("select between 1 and 100 notes")
+
+	ErrRevisionNotFound = errors.New("note revision not found")
+	ErrEmptySearch      = errors.New("search query is required")
+	ErrVersionConflict  = errors.New("resource has been modified since it was read")
+
+	ErrInvalidCursor    = errors.New("invalid page cursor, or one from a list in another order")
+	ErrInvalidListSort  = errors.New("sort must be updated_at, created_at or name, in asc or desc order")
+	ErrInvalidListScope = errors.New("scope must be all, owned or shared")
+
+	ErrTagNotFound      = errors.New("tag not found")
+	ErrTagExists        = errors.New("a tag with this name already exists")
+	ErrInvalidTagName   = errors.New("tag names must be 1 to 100 characters, without double quotes")
+	ErrTagScopeMismatch = errors.New("tags must belong to the same user or team to be merged")
+	ErrInvalidTagFilter = errors.New("invalid tag filter")
+
+	ErrAttachmentNotFound = errors.New("attachment not found")
+	ErrAttachmentTooLarge = errors.New("attachment is too large")
+	ErrEmptyAttachment    = errors.New("attachment is empty")
+
+	ErrInvalidArchive      = errors.New("invalid note archive")
+	ErrArchiveTooLarge     = errors.New("archive exceeds the import limits")
+	ErrInvalidConflictMode = errors.New("on_conflict must be skip or duplicate")
+
+	ErrTemplateNotFound     = errors.New("template not found")
+	ErrInvalidTemplate      = errors.New("invalid template")
+	ErrTemplateValueMissing = errors.New("a required template field has no value")
+	ErrInvalidTimeZone      = errors.New("unknown time zone")
+
+	ErrCommentNotFound = errors.New("comment not found")
+	ErrInvalidComment  = errors.New("invalid comment")
+	ErrInvalidMention  = errors.New("mentioned users must exist and have access to the note")
+
+	ErrInvalidOperation = errors.New("invalid edit operation")
+	ErrStaleRevision    = errors.New("revision is too old or in the future, resync the note")
+	ErrRevisionTaken    = errors.New("another edit was stored at this revision first")
+
+	ErrShareNotFound  = errors.New("share not found")
+	ErrSelfShare      = errors.New("cannot share with yourself")
+	ErrShareWithOwner = errors.New("cannot share with the owner")
+
+	ErrShareLinkNotFound   = errors.New("share link not found or expired")
+	ErrLinkPassword        = errors.New("this link needs its password")
+	ErrInvalidLinkPassword = errors.New("link passwords must be at most 72 bytes")
+	ErrInvalidLinkExpiry   = errors.New("link expiry must be in the future")
+	ErrTooManyRequests     = errors.New("too many requests, try again later")
+
+	ErrInvalidAccessLevel = errors.New("access must be read or write")
+	ErrInvalidShareAccess = errors.New("access must be read, write or manage")
+	ErrInvalidShareExpiry = errors.New("share expiry must be in the future")
+	ErrExpiryNotSupported = errors.New("only folder and note shares can expire")
+	ErrInvalidTimestamp   = errors.New("timestamps must be RFC 3339")
+	ErrInvalidOverride    = errors.New("overrides are only supported on note shares")
 )
+
+// VersionConflictError is returned when a conditional write finds the
+// resource at a different version than expected. It matches
+// ErrVersionConflict with errors.Is.
+type VersionConflictError struct {
+	Current int64
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("%s (current version %d)", ErrVersionConflict, e.Current)
+}
+
+func (e *VersionConflictError) Is(target error) bool {
+	return target == ErrVersionConflict
+}
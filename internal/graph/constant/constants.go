@@ -4,11 +4,29 @@ const (
 	CodeSuccess       = "200"
 	CodeBadRequest    = "400"
 	CodeUnauthorized  = "401"
+	CodeForbidden     = "403"
+	CodeNotFound      = "404"
 	CodeConflict      = "409"
 	CodeInternalError = "500"
 
+	CodePreconditionFailed = "412"
+	CodePayloadTooLarge    = "413"
+	CodeTooManyRequests    = "429"
+
 	ErrEmailAlreadyTaken  = "EMAIL_ALREADY_TAKEN"
 	ErrInvalidCredentials = "INVALID_CREDENTIALS"
 	ErrUnknown            = "UNKNOWN_ERROR"
 	ErrUserNotFound       = "USER_NOT_FOUND"
+
+	// Team membership error codes
+	ErrTeamNotFound     = "TEAM_NOT_FOUND"
+	ErrAlreadyMember    = "ALREADY_MEMBER"
+	ErrNotMember        = "NOT_A_MEMBER"
+	ErrForbidden        = "FORBIDDEN"
+	ErrInvalidOperation = "INVALID_OPERATION"
+	ErrInvalidRole      = "INVALID_ROLE"
+	ErrInvalidExpiry    = "INVALID_EXPIRY"
+	ErrInvalidID        = "INVALID_ID"
+	ErrRolledBack       = "ROLLED_BACK"
+	ErrSkipped          = "SKIPPED"
 )
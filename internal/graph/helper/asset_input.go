@@ -0,0 +1,153 @@
+package helper
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go-training-system/internal/dto"
+	"go-training-system/internal/graph/apperror"
+	gqlmodel "go-training-system/internal/graph/model"
+	"go-training-system/internal/model"
+
+	"github.com/google/uuid"
+)
+
+// ListRequestFromInput converts the options of the folders and notes
+// queries. A nil input lists the first page in the default order.
+func ListRequestFromInput(input *gqlmodel.ListInput) (*dto.ListRequest, error) {
+	req := &dto.ListRequest{}
+	if input == nil {
+		return req, nil
+	}
+	if input.Cursor != nil {
+		req.Cursor = *input.Cursor
+	}
+	if input.Limit != nil {
+		req.Limit = int(*input.Limit)
+	}
+	if input.Sort != nil {
+		req.Sort = strings.ToLower(string(*input.Sort))
+	}
+	if input.Order != nil {
+		req.Order = strings.ToLower(string(*input.Order))
+	}
+	if input.Scope != nil {
+		req.Scope = strings.ToLower(string(*input.Scope))
+	}
+	if input.FolderID != nil {
+		folderID, err := uuid.Parse(*input.FolderID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid folderId: %w", err)
+		}
+		req.FolderID = &folderID
+	}
+	if input.UpdatedSince != nil {
+		updatedSince, err := time.Parse(time.RFC3339, *input.UpdatedSince)
+		if err != nil {
+			return nil, fmt.Errorf("updatedSince must be an RFC 3339 timestamp")
+		}
+		req.UpdatedSince = &updatedSince
+	}
+	if input.Tags != nil {
+		req.Tags = *input.Tags
+	}
+	return req, nil
+}
+
+// ShareRequestFromInput converts a user share. A malformed user ID reports
+// the user as not found.
+func ShareRequestFromInput(input *gqlmodel.ShareInput) (*dto.ShareRequest, error) {
+	userID, err := uuid.Parse(input.UserID)
+	if err != nil {
+		return nil, apperror.ErrUserNotFound
+	}
+	req := &dto.ShareRequest{
+		UserID: userID,
+		Access: accessLevelToModel(input.Access),
+	}
+	if input.AllowReshare != nil {
+		req.AllowReshare = *input.AllowReshare
+	}
+	if input.Override != nil {
+		req.Override = *input.Override
+	}
+	if req.ExpiresAt, err = parseTimestamp(input.ExpiresAt); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func UpdateShareRequestFromInput(input *gqlmodel.UpdateShareInput) (*dto.UpdateShareRequest, error) {
+	req := &dto.UpdateShareRequest{
+		Access:       accessLevelToModel(input.Access),
+		AllowReshare: input.AllowReshare,
+		Override:     input.Override,
+	}
+	if input.RemoveExpiry != nil {
+		req.RemoveExpiry = *input.RemoveExpiry
+	}
+	var err error
+	if req.ExpiresAt, err = parseTimestamp(input.ExpiresAt); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// TeamShareRequestFromInput converts a team share. A malformed team ID
+// reports the team as not found.
+func TeamShareRequestFromInput(input *gqlmodel.TeamShareInput) (*dto.TeamShareRequest, error) {
+	teamID, err := uuid.Parse(input.TeamID)
+	if err != nil {
+		return nil, apperror.ErrTeamNotFound
+	}
+	req := &dto.TeamShareRequest{
+		TeamID: teamID,
+		Access: accessLevelToModel(input.Access),
+	}
+	if input.ManagersOnly != nil {
+		req.ManagersOnly = *input.ManagersOnly
+	}
+	return req, nil
+}
+
+// ParseIDs parses a list of IDs, failing with notFound on the first
+// malformed one.
+func ParseIDs(ids []string, notFound error) ([]uuid.UUID, error) {
+	parsed := make([]uuid.UUID, len(ids))
+	for i, id := range ids {
+		var err error
+		if parsed[i], err = uuid.Parse(id); err != nil {
+			return nil, notFound
+		}
+	}
+	return parsed, nil
+}
+
+// ParseOptionalID parses an ID that may be omitted, failing with notFound
+// when it is malformed.
+func ParseOptionalID(id *string, notFound error) (*uuid.UUID, error) {
+	if id == nil {
+		return nil, nil
+	}
+	parsed, err := uuid.Parse(*id)
+	if err != nil {
+		return nil, notFound
+	}
+	return &parsed, nil
+}
+
+func accessLevelToModel(access gqlmodel.AccessLevel) model.AccessLevel {
+	return model.AccessLevel(strings.ToLower(string(access)))
+}
+
+func parseTimestamp(value *string) (*time.Time, error) {
+	if value == nil {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, *value)
+	if err != nil {
+		return nil, apperror.ErrInvalidTimestamp
+	}
+	return &t, nil
+}
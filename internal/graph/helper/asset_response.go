@@ -0,0 +1,373 @@
+package helper
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"go-training-system/internal/dto"
+	"go-training-system/internal/graph/apperror"
+	"go-training-system/internal/graph/constant"
+	gqlmodel "go-training-system/internal/graph/model"
+	"go-training-system/internal/model"
+)
+
+func FolderFromDTO(folder *dto.FolderResponse) *gqlmodel.Folder {
+	createdAt := folder.CreatedAt.Format(time.RFC3339)
+	updatedAt := folder.UpdatedAt.Format(time.RFC3339)
+	gqlFolder := &gqlmodel.Folder{
+		FolderID:    folder.ID.String(),
+		Name:        folder.Name,
+		Description: folder.Description,
+		OwnerID:     folder.OwnerID.String(),
+		Version:     int32(folder.Version),
+		CreatedAt:   &createdAt,
+		UpdatedAt:   &updatedAt,
+	}
+	if folder.ParentID != nil {
+		parentID := folder.ParentID.String()
+		gqlFolder.ParentID = &parentID
+	}
+	return gqlFolder
+}
+
+func NoteFromDTO(note *dto.NoteResponse) *gqlmodel.Note {
+	createdAt := note.CreatedAt.Format(time.RFC3339)
+	updatedAt := note.UpdatedAt.Format(time.RFC3339)
+	return &gqlmodel.Note{
+		NoteID:    note.ID.String(),
+		Title:     note.Title,
+		Body:      note.Body,
+		FolderID:  note.FolderID.String(),
+		OwnerID:   note.OwnerID.String(),
+		Version:   int32(note.Version),
+		CreatedAt: &createdAt,
+		UpdatedAt: &updatedAt,
+	}
+}
+
+func FolderPageFromDTO(page *dto.FolderPage) *gqlmodel.FolderPage {
+	gqlPage := &gqlmodel.FolderPage{
+		Items: make([]*gqlmodel.Folder, len(page.Items)),
+		Total: int32(page.Total),
+	}
+	for i := range page.Items {
+		gqlPage.Items[i] = FolderFromDTO(&page.Items[i])
+	}
+	if page.NextCursor != "" {
+		gqlPage.NextCursor = &page.NextCursor
+	}
+	return gqlPage
+}
+
+func NotePageFromDTO(page *dto.NotePage) *gqlmodel.NotePage {
+	gqlPage := &gqlmodel.NotePage{
+		Items: make([]*gqlmodel.Note, len(page.Items)),
+		Total: int32(page.Total),
+	}
+	for i := range page.Items {
+		gqlPage.Items[i] = NoteFromDTO(&page.Items[i])
+	}
+	if page.NextCursor != "" {
+		gqlPage.NextCursor = &page.NextCursor
+	}
+	return gqlPage
+}
+
+func FolderBreadcrumbFromDTO(crumb *dto.FolderBreadcrumb) *gqlmodel.FolderBreadcrumb {
+	return &gqlmodel.FolderBreadcrumb{
+		FolderID: crumb.ID.String(),
+		Name:     crumb.Name,
+	}
+}
+
+// AccessLevelFromModel converts a stored access level to the schema's upper
+// case one.
+func AccessLevelFromModel(access model.AccessLevel) gqlmodel.AccessLevel {
+	return gqlmodel.AccessLevel(strings.ToUpper(string(access)))
+}
+
+func ShareFromDTO(share *dto.ShareResponse) *gqlmodel.Share {
+	gqlShare := &gqlmodel.Share{
+		UserID:       share.UserID.String(),
+		Username:     share.Username,
+		Access:       AccessLevelFromModel(share.Access),
+		AllowReshare: share.AllowReshare,
+		Override:     share.Override,
+		SharedByID:   share.SharedByID.String(),
+		SharedAt:     share.SharedAt.Format(time.RFC3339),
+	}
+	if share.ExpiresAt != nil {
+		expiresAt := share.ExpiresAt.Format(time.RFC3339)
+		gqlShare.ExpiresAt = &expiresAt
+	}
+	return gqlShare
+}
+
+func TeamShareFromDTO(share *dto.TeamShareResponse) *gqlmodel.TeamShare {
+	return &gqlmodel.TeamShare{
+		TeamID:       share.TeamID.String(),
+		TeamName:     share.TeamName,
+		Access:       AccessLevelFromModel(share.Access),
+		ManagersOnly: share.ManagersOnly,
+		SharedByID:   share.SharedByID.String(),
+		SharedAt:     share.SharedAt.Format(time.RFC3339),
+	}
+}
+
+func NoteSearchResultFromDTO(result *dto.NoteSearchResult) *gqlmodel.NoteSearchResult {
+	createdAt := result.CreatedAt.Format(time.RFC3339)
+	updatedAt := result.UpdatedAt.Format(time.RFC3339)
+	return &gqlmodel.NoteSearchResult{
+		NoteID:    result.ID.String(),
+		Title:     result.Title,
+		FolderID:  result.FolderID.String(),
+		OwnerID:   result.OwnerID.String(),
+		Snippet:   result.Snippet,
+		Rank:      result.Rank,
+		CreatedAt: &createdAt,
+		UpdatedAt: &updatedAt,
+	}
+}
+
+func CommentFromDTO(comment *dto.CommentResponse) *gqlmodel.Comment {
+	createdAt := comment.CreatedAt.Format(time.RFC3339)
+	updatedAt := comment.UpdatedAt.Format(time.RFC3339)
+	gqlComment := &gqlmodel.Comment{
+		CommentID:      comment.ID.String(),
+		NoteID:         comment.NoteID.String(),
+		AuthorID:       comment.AuthorID.String(),
+		AuthorUsername: comment.AuthorUsername,
+		Body:           comment.Body,
+		Mentions:       make([]*gqlmodel.CommentMention, len(comment.Mentions)),
+		Resolved:       comment.Resolved,
+		Replies:        make([]*gqlmodel.Comment, len(comment.Replies)),
+		Version:        int32(comment.Version),
+		CreatedAt:      &createdAt,
+		UpdatedAt:      &updatedAt,
+	}
+	if comment.ParentID != nil {
+		parentID := comment.ParentID.String()
+		gqlComment.ParentID = &parentID
+	}
+	if comment.Anchor != nil {
+		gqlComment.Anchor = &gqlmodel.CommentAnchor{
+			Start:       int32(comment.Anchor.Start),
+			End:         int32(comment.Anchor.End),
+			Text:        comment.Anchor.Text,
+			NoteVersion: int32(comment.Anchor.NoteVersion),
+		}
+	}
+	if comment.ResolvedAt != nil {
+		resolvedAt := comment.ResolvedAt.Format(time.RFC3339)
+		gqlComment.ResolvedAt = &resolvedAt
+	}
+	if comment.ResolvedByID != nil {
+		resolvedByID := comment.ResolvedByID.String()
+		gqlComment.ResolvedByID = &resolvedByID
+	}
+	for i, mention := range comment.Mentions {
+		gqlComment.Mentions[i] = &gqlmodel.CommentMention{UserID: mention.UserID.String(), Username: mention.Username}
+	}
+	for i := range comment.Replies {
+		gqlComment.Replies[i] = CommentFromDTO(&comment.Replies[i])
+	}
+	return gqlComment
+}
+
+// FolderMutationSuccess reports a successful folder mutation. folder is nil
+// after a delete.
+func FolderMutationSuccess(folder *dto.FolderResponse) *gqlmodel.FolderMutationResponse {
+	msg := "Folder operation successful"
+	response := &gqlmodel.FolderMutationResponse{
+		Code:    constant.CodeSuccess,
+		Success: true,
+		Message: &msg,
+	}
+	if folder != nil {
+		response.Folder = FolderFromDTO(folder)
+	}
+	return response
+}
+
+// FolderMutationError reports a failed folder mutation. On a version conflict
+// it carries the folder's current version.
+func FolderMutationError(err error) *gqlmodel.FolderMutationResponse {
+	code, currentVersion := assetErrorCode(err)
+	msg := err.Error()
+	return &gqlmodel.FolderMutationResponse{
+		Code:           code,
+		Success:        false,
+		Message:        &msg,
+		CurrentVersion: currentVersion,
+	}
+}
+
+// NoteMutationSuccess reports a successful note mutation. note is nil after
+// a delete.
+func NoteMutationSuccess(note *dto.NoteResponse) *gqlmodel.NoteMutationResponse {
+	msg := "Note operation successful"
+	response := &gqlmodel.NoteMutationResponse{
+		Code:    constant.CodeSuccess,
+		Success: true,
+		Message: &msg,
+	}
+	if note != nil {
+		response.Note = NoteFromDTO(note)
+	}
+	return response
+}
+
+// NoteMutationError reports a failed note mutation. On a version conflict it
+// carries the note's current version.
+func NoteMutationError(err error) *gqlmodel.NoteMutationResponse {
+	code, currentVersion := assetErrorCode(err)
+	msg := err.Error()
+	return &gqlmodel.NoteMutationResponse{
+		Code:           code,
+		Success:        false,
+		Message:        &msg,
+		CurrentVersion: currentVersion,
+	}
+}
+
+func NotesMutationSuccess(notes []dto.NoteResponse) *gqlmodel.NotesMutationResponse {
+	msg := "Note operation successful"
+	response := &gqlmodel.NotesMutationResponse{
+		Code:    constant.CodeSuccess,
+		Success: true,
+		Message: &msg,
+		Notes:   make([]*gqlmodel.Note, len(notes)),
+	}
+	for i := range notes {
+		response.Notes[i] = NoteFromDTO(&notes[i])
+	}
+	return response
+}
+
+func NotesMutationError(err error) *gqlmodel.NotesMutationResponse {
+	code, _ := assetErrorCode(err)
+	msg := err.Error()
+	return &gqlmodel.NotesMutationResponse{
+		Code:    code,
+		Success: false,
+		Message: &msg,
+	}
+}
+
+func ShareMutationSuccess() *gqlmodel.ShareMutationResponse {
+	msg := "Share operation successful"
+	return &gqlmodel.ShareMutationResponse{
+		Code:    constant.CodeSuccess,
+		Success: true,
+		Message: &msg,
+	}
+}
+
+func ShareMutationError(err error) *gqlmodel.ShareMutationResponse {
+	code, _ := assetErrorCode(err)
+	msg := err.Error()
+	return &gqlmodel.ShareMutationResponse{
+		Code:    code,
+		Success: false,
+		Message: &msg,
+	}
+}
+
+// CommentMutationSuccess reports a successful comment mutation. comment is
+// nil after a delete.
+func CommentMutationSuccess(comment *dto.CommentResponse) *gqlmodel.CommentMutationResponse {
+	msg := "Comment operation successful"
+	response := &gqlmodel.CommentMutationResponse{
+		Code:    constant.CodeSuccess,
+		Success: true,
+		Message: &msg,
+	}
+	if comment != nil {
+		response.Comment = CommentFromDTO(comment)
+	}
+	return response
+}
+
+// CommentMutationError reports a failed comment mutation. On a version
+// conflict it carries the comment's current version.
+func CommentMutationError(err error) *gqlmodel.CommentMutationResponse {
+	code, currentVersion := assetErrorCode(err)
+	msg := err.Error()
+	return &gqlmodel.CommentMutationResponse{
+		Code:           code,
+		Success:        false,
+		Message:        &msg,
+		CurrentVersion: currentVersion,
+	}
+}
+
+// assetErrorCode maps folder and note service errors to response codes, the
+// same way the REST handlers map them to HTTP statuses.
+func assetErrorCode(err error) (string, *int32) {
+	var conflict *apperror.VersionConflictError
+	if errors.As(err, &conflict) {
+		current := int32(conflict.Current)
+		return constant.CodePreconditionFailed, &current
+	}
+
+	switch {
+	case errors.Is(err, apperror.ErrUnauthorized),
+		errors.Is(err, apperror.ErrLinkPassword):
+		return constant.CodeUnauthorized, nil
+	case errors.Is(err, apperror.ErrAccessDenied):
+		return constant.CodeForbidden, nil
+	case errors.Is(err, apperror.ErrFolderNotFound),
+		errors.Is(err, apperror.ErrNoteNotFound),
+		errors.Is(err, apperror.ErrUserNotFound),
+		errors.Is(err, apperror.ErrTeamNotFound),
+		errors.Is(err, apperror.ErrShareNotFound),
+		errors.Is(err, apperror.ErrTeamShareNotFound),
+		errors.Is(err, apperror.ErrRevisionNotFound),
+		errors.Is(err, apperror.ErrTagNotFound),
+		errors.Is(err, apperror.ErrAttachmentNotFound),
+		errors.Is(err, apperror.ErrTemplateNotFound),
+		errors.Is(err, apperror.ErrCommentNotFound),
+		errors.Is(err, apperror.ErrShareLinkNotFound):
+		return constant.CodeNotFound, nil
+	case errors.Is(err, apperror.ErrSelfShare),
+		errors.Is(err, apperror.ErrShareWithOwner),
+		errors.Is(err, apperror.ErrInvalidAccessLevel),
+		errors.Is(err, apperror.ErrInvalidShareAccess),
+		errors.Is(err, apperror.ErrInvalidShareExpiry),
+		errors.Is(err, apperror.ErrExpiryNotSupported),
+		errors.Is(err, apperror.ErrInvalidTimestamp),
+		errors.Is(err, apperror.ErrInvalidOverride),
+		errors.Is(err, apperror.ErrEmptySearch),
+		errors.Is(err, apperror.ErrInvalidCursor),
+		errors.Is(err, apperror.ErrInvalidListSort),
+		errors.Is(err, apperror.ErrInvalidListScope),
+		errors.Is(err, apperror.ErrInvalidTagName),
+		errors.Is(err, apperror.ErrInvalidTagFilter),
+		errors.Is(err, apperror.ErrTagScopeMismatch),
+		errors.Is(err, apperror.ErrEmptyAttachment),
+		errors.Is(err, apperror.ErrInvalidNoteSelection),
+		errors.Is(err, apperror.ErrInvalidArchive),
+		errors.Is(err, apperror.ErrInvalidConflictMode),
+		errors.Is(err, apperror.ErrInvalidTemplate),
+		errors.Is(err, apperror.ErrTemplateValueMissing),
+		errors.Is(err, apperror.ErrInvalidTimeZone),
+		errors.Is(err, apperror.ErrInvalidComment),
+		errors.Is(err, apperror.ErrInvalidMention),
+		errors.Is(err, apperror.ErrInvalidLinkPassword),
+		errors.Is(err, apperror.ErrInvalidLinkExpiry):
+		return constant.CodeBadRequest, nil
+	case errors.Is(err, apperror.ErrFolderCycle),
+		errors.Is(err, apperror.ErrFolderNotEmpty),
+		errors.Is(err, apperror.ErrParentInTrash),
+		errors.Is(err, apperror.ErrTagExists):
+		return constant.CodeConflict, nil
+	case errors.Is(err, apperror.ErrAttachmentTooLarge),
+		errors.Is(err, apperror.ErrArchiveTooLarge):
+		return constant.CodePayloadTooLarge, nil
+	case errors.Is(err, apperror.ErrTooManyRequests):
+		return constant.CodeTooManyRequests, nil
+	default:
+		return constant.CodeInternalError, nil
+	}
+}
@@ -0,0 +1,63 @@
+package helper
+
+import (
+	"time"
+
+	"go-training-system/internal/dto"
+	"go-training-system/internal/event"
+	gqlmodel "go-training-system/internal/graph/model"
+
+	"github.com/google/uuid"
+)
+
+// NoteChangeFromEvent builds a note change; note is nil once the note is
+// deleted.
+func NoteChangeFromEvent(e event.NoteEvent, note *dto.NoteResponse) *gqlmodel.NoteChange {
+	change := &gqlmodel.NoteChange{
+		NoteID:  e.NoteID.String(),
+		Kind:    gqlmodel.ChangeKind(e.Kind),
+		ActorID: e.ActorID.String(),
+	}
+	if note != nil {
+		change.Note = NoteFromDTO(note)
+	}
+	return change
+}
+
+func FolderChangeFromEvent(e event.FolderEvent) *gqlmodel.FolderChange {
+	return &gqlmodel.FolderChange{
+		FolderID: e.FolderID.String(),
+		Kind:     gqlmodel.ChangeKind(e.Kind),
+		ItemType: gqlmodel.FolderItemType(e.ItemType),
+		ItemID:   e.ItemID.String(),
+		ActorID:  e.ActorID.String(),
+	}
+}
+
+func MembershipChangeFromEvent(e event.MembershipEvent) *gqlmodel.TeamMembershipChange {
+	change := &gqlmodel.TeamMembershipChange{
+		TeamID: e.TeamID.String(),
+		UserID: e.UserID.String(),
+		Kind:   gqlmodel.MembershipChangeKind(e.Kind),
+	}
+	if e.Role != "" {
+		role := gqlmodel.UserType(e.Role)
+		change.Role = &role
+	}
+	if e.ActorID != uuid.Nil {
+		actorID := e.ActorID.String()
+		change.ActorID = &actorID
+	}
+	return change
+}
+
+func ShareExpiryFromEvent(e event.ShareEvent) *gqlmodel.ShareExpiry {
+	return &gqlmodel.ShareExpiry{
+		ItemType:   gqlmodel.FolderItemType(e.ItemType),
+		ItemID:     e.ItemID.String(),
+		UserID:     e.UserID.String(),
+		SharedByID: e.SharedByID.String(),
+		Kind:       gqlmodel.ShareExpiryKind(e.Kind),
+		ExpiresAt:  e.ExpiresAt.Format(time.RFC3339),
+	}
+}
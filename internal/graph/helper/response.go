@@ -1,9 +1,25 @@
 package helper
 
 import (
+	"time"
+
+	"go-training-system/internal/dto"
+	"go-training-system/internal/graph/constant"
 	gqlmodel "go-training-system/internal/graph/model"
+	"go-training-system/internal/model"
 )
 
+func UserFromModel(user *model.User) *gqlmodel.User {
+	createdAt := user.CreatedAt.Format(time.RFC3339)
+	return &gqlmodel.User{
+		UserID:    user.ID.String(),
+		Username:  user.Username,
+		Email:     user.Email,
+		Role:      gqlmodel.UserType(user.Role),
+		CreatedAt: &createdAt,
+	}
+}
+
 func NewUserMutationSuccess(user *gqlmodel.User) *gqlmodel.UserMutationResponse {
 	msg := "User operation successful"
 	return &gqlmodel.UserMutationResponse{
@@ -42,3 +58,45 @@ func AuthMutationError(code string, message string, errors []*string) *gqlmodel.
 		Errors:  errors,
 	}
 }
+
+func BulkMembershipResult(result *dto.BulkMembershipResult) *gqlmodel.BulkMembershipMutationResponse {
+	msg := "Membership changes applied"
+	if result.Failed > 0 {
+		msg = "Membership changes completed with errors"
+	}
+
+	results := make([]*gqlmodel.MembershipOperationResult, len(result.Results))
+	for i, item := range result.Results {
+		results[i] = &gqlmodel.MembershipOperationResult{
+			Index:   int32(item.Index),
+			Op:      gqlmodel.MembershipOperationType(item.Op),
+			TeamID:  item.TeamID,
+			UserID:  item.UserID,
+			Success: item.Success,
+		}
+		if item.ErrorCode != "" {
+			code, message := item.ErrorCode, item.Message
+			results[i].ErrorCode = &code
+			results[i].Message = &message
+		}
+	}
+
+	return &gqlmodel.BulkMembershipMutationResponse{
+		Code:      constant.CodeSuccess,
+		Success:   result.Failed == 0,
+		Message:   &msg,
+		Applied:   result.Applied,
+		Succeeded: int32(result.Succeeded),
+		Failed:    int32(result.Failed),
+		Results:   results,
+	}
+}
+
+func BulkMembershipError(code string, message *string) *gqlmodel.BulkMembershipMutationResponse {
+	return &gqlmodel.BulkMembershipMutationResponse{
+		Code:    code,
+		Success: false,
+		Message: message,
+		Results: []*gqlmodel.MembershipOperationResult{},
+	}
+}
@@ -0,0 +1,107 @@
+// Package loader batches the lookups behind the nested fields of folders and
+// notes, so that resolving a field across a list takes one service call
+// rather than one per item. The services apply the same access checks as to
+// single-item reads.
+package loader
+
+import (
+	"context"
+	"time"
+
+	"go-training-system/internal/dto"
+	"go-training-system/internal/graph/apperror"
+	"go-training-system/internal/model"
+	"go-training-system/internal/service"
+	"go-training-system/pkg/dataloader"
+	"go-training-system/pkg/middleware"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/google/uuid"
+)
+
+const (
+	// wait is how long a loader collects keys before fetching them. Sibling
+	// fields of a list are resolved concurrently, so they all arrive well
+	// within it.
+	wait     = time.Millisecond
+	maxBatch = 100
+)
+
+type contextKey struct{}
+
+// Loaders load on behalf of one caller. A key the caller may not see loads
+// as nil.
+type Loaders struct {
+	Users            *dataloader.Loader[uuid.UUID, *model.User]
+	Folders          *dataloader.Loader[uuid.UUID, *dto.FolderResponse]
+	FolderNotes      *dataloader.Loader[uuid.UUID, []dto.NoteResponse]
+	FolderShares     *dataloader.Loader[uuid.UUID, []dto.ShareResponse]
+	FolderTeamShares *dataloader.Loader[uuid.UUID, []dto.TeamShareResponse]
+	NoteShares       *dataloader.Loader[uuid.UUID, []dto.ShareResponse]
+	NoteTeamShares   *dataloader.Loader[uuid.UUID, []dto.TeamShareResponse]
+}
+
+// Middleware gives each response fresh loaders for the caller. It runs once
+// per response rather than per operation, so every event of a subscription
+// sees current data and access.
+func Middleware(users service.UserService, folders service.FolderService, notes service.NoteService) graphql.ResponseMiddleware {
+	return func(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+		principal, err := middleware.PrincipalFromContext(ctx)
+		if err != nil {
+			return next(ctx)
+		}
+		loaders := newLoaders(ctx, principal.UserID, users, folders, notes)
+		return next(context.WithValue(ctx, contextKey{}, loaders))
+	}
+}
+
+// For returns the caller's loaders, failing for anonymous callers.
+func For(ctx context.Context) (*Loaders, error) {
+	loaders, ok := ctx.Value(contextKey{}).(*Loaders)
+	if !ok {
+		return nil, apperror.ErrUnauthorized
+	}
+	return loaders, nil
+}
+
+func newLoaders(ctx context.Context, userID uuid.UUID, users service.UserService, folders service.FolderService, notes service.NoteService) *Loaders {
+	return &Loaders{
+		Users: dataloader.New(ctx, func(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*model.User, error) {
+			found, err := users.GetByIDs(ctx, ids)
+			if err != nil {
+				return nil, err
+			}
+			byID := make(map[uuid.UUID]*model.User, len(found))
+			for i := range found {
+				byID[found[i].ID] = &found[i]
+			}
+			return byID, nil
+		}, wait, maxBatch),
+		Folders: dataloader.New(ctx, func(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*dto.FolderResponse, error) {
+			found, err := folders.GetFolders(ctx, ids, userID)
+			if err != nil {
+				return nil, err
+			}
+			byID := make(map[uuid.UUID]*dto.FolderResponse, len(found))
+			for i := range found {
+				byID[found[i].ID] = &found[i]
+			}
+			return byID, nil
+		}, wait, maxBatch),
+		FolderNotes: dataloader.New(ctx, func(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID][]dto.NoteResponse, error) {
+			return notes.GetFoldersNotes(ctx, ids, userID)
+		}, wait, maxBatch),
+		FolderShares: dataloader.New(ctx, func(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID][]dto.ShareResponse, error) {
+			return folders.GetFoldersShares(ctx, ids, userID)
+		}, wait, maxBatch),
+		FolderTeamShares: dataloader.New(ctx, func(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID][]dto.TeamShareResponse, error) {
+			return folders.GetFoldersTeamShares(ctx, ids, userID)
+		}, wait, maxBatch),
+		NoteShares: dataloader.New(ctx, func(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID][]dto.ShareResponse, error) {
+			return notes.GetNotesShares(ctx, ids, userID)
+		}, wait, maxBatch),
+		NoteTeamShares: dataloader.New(ctx, func(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID][]dto.TeamShareResponse, error) {
+			return notes.GetNotesTeamShares(ctx, ids, userID)
+		}, wait, maxBatch),
+	}
+}
@@ -17,6 +17,14 @@ type MutationResponse interface {
 	GetErrors() []*string
 }
 
+// Adds a comment. With parentId it replies to that comment's thread; without
+// it, it starts a thread that may be anchored to a range of the note body.
+type AddCommentInput struct {
+	Body     string              `json:"body"`
+	ParentID *string             `json:"parentId,omitempty"`
+	Anchor   *CommentAnchorInput `json:"anchor,omitempty"`
+}
+
 type AuthMutationResponse struct {
 	Code         string    `json:"code"`
 	Success      bool      `json:"success"`
@@ -42,6 +50,126 @@ func (this AuthMutationResponse) GetErrors() []*string {
 	return interfaceSlice
 }
 
+type BulkMembershipInput struct {
+	Operations []*MembershipOperationInput `json:"operations"`
+	// Apply every operation or none of them. Defaults to best effort.
+	Atomic *bool `json:"atomic,omitempty"`
+}
+
+type BulkMembershipMutationResponse struct {
+	Code      string                       `json:"code"`
+	Success   bool                         `json:"success"`
+	Message   *string                      `json:"message,omitempty"`
+	Errors    []*string                    `json:"errors,omitempty"`
+	Applied   bool                         `json:"applied"`
+	Succeeded int32                        `json:"succeeded"`
+	Failed    int32                        `json:"failed"`
+	Results   []*MembershipOperationResult `json:"results"`
+}
+
+func (BulkMembershipMutationResponse) IsMutationResponse()      {}
+func (this BulkMembershipMutationResponse) GetCode() string     { return this.Code }
+func (this BulkMembershipMutationResponse) GetSuccess() bool    { return this.Success }
+func (this BulkMembershipMutationResponse) GetMessage() *string { return this.Message }
+func (this BulkMembershipMutationResponse) GetErrors() []*string {
+	if this.Errors == nil {
+		return nil
+	}
+	interfaceSlice := make([]*string, 0, len(this.Errors))
+	for _, concrete := range this.Errors {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+
+// A comment on a note. A comment without parentId starts a thread; only those
+// can be anchored and resolved, and they carry the thread's replies.
+type Comment struct {
+	CommentID      string         `json:"commentId"`
+	NoteID         string         `json:"noteId"`
+	ParentID       *string        `json:"parentId,omitempty"`
+	AuthorID       string         `json:"authorId"`
+	AuthorUsername string         `json:"authorUsername"`
+	Body           string         `json:"body"`
+	Anchor         *CommentAnchor `json:"anchor,omitempty"`
+	// Users @mentioned in the body.
+	Mentions     []*CommentMention `json:"mentions"`
+	Resolved     bool              `json:"resolved"`
+	ResolvedAt   *string           `json:"resolvedAt,omitempty"`
+	ResolvedByID *string           `json:"resolvedById,omitempty"`
+	// Replies, oldest first. Always empty on replies themselves.
+	Replies []*Comment `json:"replies"`
+	// Bumped on every change. Pass it as expectedVersion to update safely.
+	Version   int32   `json:"version"`
+	CreatedAt *string `json:"createdAt,omitempty"`
+	UpdatedAt *string `json:"updatedAt,omitempty"`
+}
+
+// A range of a note body, in characters, with end exclusive.
+type CommentAnchor struct {
+	Start int32 `json:"start"`
+	End   int32 `json:"end"`
+	// The commented text.
+	Text string `json:"text"`
+	// The note version the range refers to.
+	NoteVersion int32 `json:"noteVersion"`
+}
+
+type CommentAnchorInput struct {
+	Start int32 `json:"start"`
+	End   int32 `json:"end"`
+}
+
+type CommentMention struct {
+	UserID   string `json:"userId"`
+	Username string `json:"username"`
+}
+
+// Returned by comment mutations. On a version conflict, code is 412 and
+// currentVersion holds the comment's version on the server.
+type CommentMutationResponse struct {
+	Code           string    `json:"code"`
+	Success        bool      `json:"success"`
+	Message        *string   `json:"message,omitempty"`
+	Errors         []*string `json:"errors,omitempty"`
+	Comment        *Comment  `json:"comment,omitempty"`
+	CurrentVersion *int32    `json:"currentVersion,omitempty"`
+}
+
+func (CommentMutationResponse) IsMutationResponse()      {}
+func (this CommentMutationResponse) GetCode() string     { return this.Code }
+func (this CommentMutationResponse) GetSuccess() bool    { return this.Success }
+func (this CommentMutationResponse) GetMessage() *string { return this.Message }
+func (this CommentMutationResponse) GetErrors() []*string {
+	if this.Errors == nil {
+		return nil
+	}
+	interfaceSlice := make([]*string, 0, len(this.Errors))
+	for _, concrete := range this.Errors {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+
+// Copies up to 100 notes into folderId.
+type CopyNotesInput struct {
+	NoteIds  []string `json:"noteIds"`
+	FolderID string   `json:"folderId"`
+}
+
+// Creates a folder under parentId, or at the top level without it.
+type CreateFolderInput struct {
+	Name        string  `json:"name"`
+	Description *string `json:"description,omitempty"`
+	ParentID    *string `json:"parentId,omitempty"`
+}
+
+type CreateNoteInput struct {
+	Title    string  `json:"title"`
+	Body     *string `json:"body,omitempty"`
+	FolderID string  `json:"folderId"`
+}
+
 type CreateUserInput struct {
 	Username string   `json:"username"`
 	Email    string   `json:"email"`
@@ -49,6 +177,97 @@ type CreateUserInput struct {
 	Role     UserType `json:"role"`
 }
 
+type Folder struct {
+	FolderID    string  `json:"folderId"`
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	ParentID    *string `json:"parentId,omitempty"`
+	OwnerID     string  `json:"ownerId"`
+	// Bumped on every change. Pass it as expectedVersion to update safely.
+	Version   int32   `json:"version"`
+	CreatedAt *string `json:"createdAt,omitempty"`
+	UpdatedAt *string `json:"updatedAt,omitempty"`
+	// The folder above, null at the top level and when the caller cannot read it.
+	Parent *Folder `json:"parent,omitempty"`
+	Owner  *User   `json:"owner,omitempty"`
+	// The notes directly in the folder that the caller can read, oldest first.
+	Notes []*Note `json:"notes"`
+	// Null unless the caller may manage the folder's shares.
+	Shares []*Share `json:"shares,omitempty"`
+	// Null unless the caller has manage access.
+	TeamShares []*TeamShare `json:"teamShares,omitempty"`
+}
+
+// A step of the path from a top-level folder.
+type FolderBreadcrumb struct {
+	FolderID string `json:"folderId"`
+	Name     string `json:"name"`
+}
+
+// A change to a folder or to a note or subfolder directly in it. itemId is the
+// folder itself or the item that changed; a MOVED item may have moved in or out.
+type FolderChange struct {
+	FolderID string         `json:"folderId"`
+	Kind     ChangeKind     `json:"kind"`
+	ItemType FolderItemType `json:"itemType"`
+	ItemID   string         `json:"itemId"`
+	ActorID  string         `json:"actorId"`
+}
+
+// Returned by folder mutations. On a version conflict, code is 412 and
+// currentVersion holds the folder's version on the server.
+type FolderMutationResponse struct {
+	Code           string    `json:"code"`
+	Success        bool      `json:"success"`
+	Message        *string   `json:"message,omitempty"`
+	Errors         []*string `json:"errors,omitempty"`
+	Folder         *Folder   `json:"folder,omitempty"`
+	CurrentVersion *int32    `json:"currentVersion,omitempty"`
+}
+
+func (FolderMutationResponse) IsMutationResponse()      {}
+func (this FolderMutationResponse) GetCode() string     { return this.Code }
+func (this FolderMutationResponse) GetSuccess() bool    { return this.Success }
+func (this FolderMutationResponse) GetMessage() *string { return this.Message }
+func (this FolderMutationResponse) GetErrors() []*string {
+	if this.Errors == nil {
+		return nil
+	}
+	interfaceSlice := make([]*string, 0, len(this.Errors))
+	for _, concrete := range this.Errors {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+
+// One page of folders. total counts all pages; nextCursor is null on the last.
+type FolderPage struct {
+	Items      []*Folder `json:"items"`
+	NextCursor *string   `json:"nextCursor,omitempty"`
+	Total      int32     `json:"total"`
+}
+
+// Paging, sorting and filters of the folder and note lists. Without folderId
+// they list what the caller owns and what was shared with them; with it, the
+// subfolders or notes directly in that folder.
+type ListInput struct {
+	// The nextCursor of the previous page, made with the same sort and order.
+	Cursor *string `json:"cursor,omitempty"`
+	// Defaults to 50, at most 100.
+	Limit *int32 `json:"limit,omitempty"`
+	// Defaults to UPDATED_AT.
+	Sort *ListSort `json:"sort,omitempty"`
+	// Defaults to DESC for the time sorts and to ASC for NAME.
+	Order *SortOrder `json:"order,omitempty"`
+	// Defaults to ALL.
+	Scope    *ListScope `json:"scope,omitempty"`
+	FolderID *string    `json:"folderId,omitempty"`
+	// Keeps what was updated at or after this time.
+	UpdatedSince *string `json:"updatedSince,omitempty"`
+	// A tag expression, as in searchNotes. Only filters notes.
+	Tags *string `json:"tags,omitempty"`
+}
+
 type Manager struct {
 	UserID   string `json:"userId"`
 	Username string `json:"username"`
@@ -61,12 +280,230 @@ type Member struct {
 	Email    string `json:"email"`
 }
 
+type MembershipOperationInput struct {
+	Op     MembershipOperationType `json:"op"`
+	TeamID string                  `json:"teamId"`
+	UserID string                  `json:"userId"`
+	Role   *UserType               `json:"role,omitempty"`
+	// Makes an added membership temporary (RFC 3339).
+	ExpiresAt *string `json:"expiresAt,omitempty"`
+}
+
+type MembershipOperationResult struct {
+	Index     int32                   `json:"index"`
+	Op        MembershipOperationType `json:"op"`
+	TeamID    string                  `json:"teamId"`
+	UserID    string                  `json:"userId"`
+	Success   bool                    `json:"success"`
+	ErrorCode *string                 `json:"errorCode,omitempty"`
+	Message   *string                 `json:"message,omitempty"`
+}
+
+// Moves a folder under parentId, or to the top level without it. dropShares
+// removes the shares granted on the folder instead of carrying them along;
+// only the owner can drop them.
+type MoveFolderInput struct {
+	ParentID   *string `json:"parentId,omitempty"`
+	DropShares *bool   `json:"dropShares,omitempty"`
+}
+
+// Moves up to 100 notes into folderId. dropShares removes the notes' own
+// shares instead of carrying them along; only the owner of every note can
+// drop them.
+type MoveNotesInput struct {
+	NoteIds    []string `json:"noteIds"`
+	FolderID   string   `json:"folderId"`
+	DropShares *bool    `json:"dropShares,omitempty"`
+}
+
 type Mutation struct {
 }
 
+type Note struct {
+	NoteID   string `json:"noteId"`
+	Title    string `json:"title"`
+	Body     string `json:"body"`
+	FolderID string `json:"folderId"`
+	OwnerID  string `json:"ownerId"`
+	// Bumped on every change. Pass it as expectedVersion to update safely.
+	Version int32 `json:"version"`
+	// The body rendered from markdown to sanitized HTML, safe to embed in a page.
+	BodyHTML  string  `json:"bodyHtml"`
+	CreatedAt *string `json:"createdAt,omitempty"`
+	UpdatedAt *string `json:"updatedAt,omitempty"`
+	// Null when the note was shared with the caller but its folder was not.
+	Folder *Folder `json:"folder,omitempty"`
+	Owner  *User   `json:"owner,omitempty"`
+	// Null unless the caller may manage the note's shares.
+	Shares []*Share `json:"shares,omitempty"`
+	// Null unless the caller has manage access.
+	TeamShares []*TeamShare `json:"teamShares,omitempty"`
+}
+
+// A change to a note. note is the note as it is now, null once it is deleted.
+type NoteChange struct {
+	NoteID  string     `json:"noteId"`
+	Kind    ChangeKind `json:"kind"`
+	ActorID string     `json:"actorId"`
+	Note    *Note      `json:"note,omitempty"`
+}
+
+// Returned by note mutations. On a version conflict, code is 412 and
+// currentVersion holds the note's version on the server.
+type NoteMutationResponse struct {
+	Code           string    `json:"code"`
+	Success        bool      `json:"success"`
+	Message        *string   `json:"message,omitempty"`
+	Errors         []*string `json:"errors,omitempty"`
+	Note           *Note     `json:"note,omitempty"`
+	CurrentVersion *int32    `json:"currentVersion,omitempty"`
+}
+
+func (NoteMutationResponse) IsMutationResponse()      {}
+func (this NoteMutationResponse) GetCode() string     { return this.Code }
+func (this NoteMutationResponse) GetSuccess() bool    { return this.Success }
+func (this NoteMutationResponse) GetMessage() *string { return this.Message }
+func (this NoteMutationResponse) GetErrors() []*string {
+	if this.Errors == nil {
+		return nil
+	}
+	interfaceSlice := make([]*string, 0, len(this.Errors))
+	for _, concrete := range this.Errors {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+
+// One page of notes. total counts all pages; nextCursor is null on the last.
+type NotePage struct {
+	Items      []*Note `json:"items"`
+	NextCursor *string `json:"nextCursor,omitempty"`
+	Total      int32   `json:"total"`
+}
+
+// Full-text search over the notes the caller can read. query uses web search
+// syntax: quoted phrases, "or", and "-" to exclude a word. folderId also
+// matches notes in its subfolders; the update range includes updatedFrom and
+// excludes updatedTo. tags is a tag expression such as
+// "go AND (testing OR review)".
+type NoteSearchInput struct {
+	Query       string  `json:"query"`
+	Tags        *string `json:"tags,omitempty"`
+	FolderID    *string `json:"folderId,omitempty"`
+	OwnerID     *string `json:"ownerId,omitempty"`
+	UpdatedFrom *string `json:"updatedFrom,omitempty"`
+	UpdatedTo   *string `json:"updatedTo,omitempty"`
+	// Defaults to 20, at most 100.
+	Limit  *int32 `json:"limit,omitempty"`
+	Offset *int32 `json:"offset,omitempty"`
+}
+
+// A matching note. snippet is HTML-escaped and wraps the matched terms in <mark> tags.
+type NoteSearchResult struct {
+	NoteID    string  `json:"noteId"`
+	Title     string  `json:"title"`
+	FolderID  string  `json:"folderId"`
+	OwnerID   string  `json:"ownerId"`
+	Snippet   string  `json:"snippet"`
+	Rank      float64 `json:"rank"`
+	CreatedAt *string `json:"createdAt,omitempty"`
+	UpdatedAt *string `json:"updatedAt,omitempty"`
+}
+
+// Returned by note mutations that act on several notes at once.
+type NotesMutationResponse struct {
+	Code    string    `json:"code"`
+	Success bool      `json:"success"`
+	Message *string   `json:"message,omitempty"`
+	Errors  []*string `json:"errors,omitempty"`
+	Notes   []*Note   `json:"notes,omitempty"`
+}
+
+func (NotesMutationResponse) IsMutationResponse()      {}
+func (this NotesMutationResponse) GetCode() string     { return this.Code }
+func (this NotesMutationResponse) GetSuccess() bool    { return this.Success }
+func (this NotesMutationResponse) GetMessage() *string { return this.Message }
+func (this NotesMutationResponse) GetErrors() []*string {
+	if this.Errors == nil {
+		return nil
+	}
+	interfaceSlice := make([]*string, 0, len(this.Errors))
+	for _, concrete := range this.Errors {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+
 type Query struct {
 }
 
+// A folder or note shared with a user. allowReshare lets a WRITE holder share
+// it on; override is only set on note shares that replace what the user
+// inherits from the folder.
+type Share struct {
+	UserID       string      `json:"userId"`
+	Username     string      `json:"username"`
+	Access       AccessLevel `json:"access"`
+	AllowReshare bool        `json:"allowReshare"`
+	Override     bool        `json:"override"`
+	// Null for shares that never expire.
+	ExpiresAt  *string `json:"expiresAt,omitempty"`
+	SharedByID string  `json:"sharedById"`
+	SharedAt   string  `json:"sharedAt"`
+}
+
+// A folder or note share granted to or by the subscriber that is about to
+// expire, or has expired and was removed.
+type ShareExpiry struct {
+	ItemType   FolderItemType  `json:"itemType"`
+	ItemID     string          `json:"itemId"`
+	UserID     string          `json:"userId"`
+	SharedByID string          `json:"sharedById"`
+	Kind       ShareExpiryKind `json:"kind"`
+	ExpiresAt  string          `json:"expiresAt"`
+}
+
+// Shares a folder or note with a user, replacing any share they already have.
+// Only the owner and MANAGE holders can grant MANAGE, and override and NONE
+// only apply to notes.
+type ShareInput struct {
+	UserID       string      `json:"userId"`
+	Access       AccessLevel `json:"access"`
+	AllowReshare *bool       `json:"allowReshare,omitempty"`
+	Override     *bool       `json:"override,omitempty"`
+	// Null for a share that never expires.
+	ExpiresAt *string `json:"expiresAt,omitempty"`
+}
+
+// Returned by the mutations that grant, change or revoke shares.
+type ShareMutationResponse struct {
+	Code    string    `json:"code"`
+	Success bool      `json:"success"`
+	Message *string   `json:"message,omitempty"`
+	Errors  []*string `json:"errors,omitempty"`
+}
+
+func (ShareMutationResponse) IsMutationResponse()      {}
+func (this ShareMutationResponse) GetCode() string     { return this.Code }
+func (this ShareMutationResponse) GetSuccess() bool    { return this.Success }
+func (this ShareMutationResponse) GetMessage() *string { return this.Message }
+func (this ShareMutationResponse) GetErrors() []*string {
+	if this.Errors == nil {
+		return nil
+	}
+	interfaceSlice := make([]*string, 0, len(this.Errors))
+	for _, concrete := range this.Errors {
+		interfaceSlice = append(interfaceSlice, concrete)
+	}
+	return interfaceSlice
+}
+
+// Subscriptions are served over a websocket on the GraphQL endpoint. Send the
+// access token in the connection_init payload as Authorization. A subscription
+// ends once the caller can no longer see what it watches.
+type Subscription struct {
+}
+
 type Team struct {
 	TeamID        string     `json:"teamId"`
 	TeamName      string     `json:"teamName"`
@@ -78,6 +515,54 @@ type Team struct {
 	UpdatedAt     *string    `json:"updatedAt,omitempty"`
 }
 
+// actorId is null for memberships that expired. role is unknown for bulk removals.
+type TeamMembershipChange struct {
+	TeamID  string               `json:"teamId"`
+	UserID  string               `json:"userId"`
+	Kind    MembershipChangeKind `json:"kind"`
+	Role    *UserType            `json:"role,omitempty"`
+	ActorID *string              `json:"actorId,omitempty"`
+}
+
+// A folder or note shared with a team, or only with its managers.
+type TeamShare struct {
+	TeamID       string      `json:"teamId"`
+	TeamName     string      `json:"teamName"`
+	Access       AccessLevel `json:"access"`
+	ManagersOnly bool        `json:"managersOnly"`
+	SharedByID   string      `json:"sharedById"`
+	SharedAt     string      `json:"sharedAt"`
+}
+
+// Shares a folder or note with a team, READ or WRITE only.
+type TeamShareInput struct {
+	TeamID       string      `json:"teamId"`
+	Access       AccessLevel `json:"access"`
+	ManagersOnly *bool       `json:"managersOnly,omitempty"`
+}
+
+// Replaces the folder's name and description.
+type UpdateFolderInput struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Replaces the note's title and body.
+type UpdateNoteInput struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+// Changes an existing share. Omitted flags are left unchanged, and so is the
+// expiry unless expiresAt is given or removeExpiry makes the share permanent.
+type UpdateShareInput struct {
+	Access       AccessLevel `json:"access"`
+	AllowReshare *bool       `json:"allowReshare,omitempty"`
+	Override     *bool       `json:"override,omitempty"`
+	ExpiresAt    *string     `json:"expiresAt,omitempty"`
+	RemoveExpiry *bool       `json:"removeExpiry,omitempty"`
+}
+
 type UpdateUserInput struct {
 	Username *string   `json:"username,omitempty"`
 	Email    *string   `json:"email,omitempty"`
@@ -120,6 +605,525 @@ func (this UserMutationResponse) GetErrors() []*string {
 	return interfaceSlice
 }
 
+// An access level. MANAGE adds the right to manage shares to WRITE and can only
+// be granted to users. NONE is only valid on a note share that overrides what
+// the user inherits from the folder.
+type AccessLevel string
+
+const (
+	AccessLevelNone   AccessLevel = "NONE"
+	AccessLevelRead   AccessLevel = "READ"
+	AccessLevelWrite  AccessLevel = "WRITE"
+	AccessLevelManage AccessLevel = "MANAGE"
+)
+
+var AllAccessLevel = []AccessLevel{
+	AccessLevelNone,
+	AccessLevelRead,
+	AccessLevelWrite,
+	AccessLevelManage,
+}
+
+func (e AccessLevel) IsValid() bool {
+	switch e {
+	case AccessLevelNone, AccessLevelRead, AccessLevelWrite, AccessLevelManage:
+		return true
+	}
+	return false
+}
+
+func (e AccessLevel) String() string {
+	return string(e)
+}
+
+func (e *AccessLevel) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = AccessLevel(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid AccessLevel", str)
+	}
+	return nil
+}
+
+func (e AccessLevel) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *AccessLevel) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e AccessLevel) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type ChangeKind string
+
+const (
+	ChangeKindCreated  ChangeKind = "CREATED"
+	ChangeKindUpdated  ChangeKind = "UPDATED"
+	ChangeKindMoved    ChangeKind = "MOVED"
+	ChangeKindDeleted  ChangeKind = "DELETED"
+	ChangeKindRestored ChangeKind = "RESTORED"
+)
+
+var AllChangeKind = []ChangeKind{
+	ChangeKindCreated,
+	ChangeKindUpdated,
+	ChangeKindMoved,
+	ChangeKindDeleted,
+	ChangeKindRestored,
+}
+
+func (e ChangeKind) IsValid() bool {
+	switch e {
+	case ChangeKindCreated, ChangeKindUpdated, ChangeKindMoved, ChangeKindDeleted, ChangeKindRestored:
+		return true
+	}
+	return false
+}
+
+func (e ChangeKind) String() string {
+	return string(e)
+}
+
+func (e *ChangeKind) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ChangeKind(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ChangeKind", str)
+	}
+	return nil
+}
+
+func (e ChangeKind) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *ChangeKind) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e ChangeKind) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type FolderItemType string
+
+const (
+	FolderItemTypeFolder FolderItemType = "FOLDER"
+	FolderItemTypeNote   FolderItemType = "NOTE"
+)
+
+var AllFolderItemType = []FolderItemType{
+	FolderItemTypeFolder,
+	FolderItemTypeNote,
+}
+
+func (e FolderItemType) IsValid() bool {
+	switch e {
+	case FolderItemTypeFolder, FolderItemTypeNote:
+		return true
+	}
+	return false
+}
+
+func (e FolderItemType) String() string {
+	return string(e)
+}
+
+func (e *FolderItemType) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = FolderItemType(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid FolderItemType", str)
+	}
+	return nil
+}
+
+func (e FolderItemType) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *FolderItemType) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e FolderItemType) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type ListScope string
+
+const (
+	ListScopeAll    ListScope = "ALL"
+	ListScopeOwned  ListScope = "OWNED"
+	ListScopeShared ListScope = "SHARED"
+)
+
+var AllListScope = []ListScope{
+	ListScopeAll,
+	ListScopeOwned,
+	ListScopeShared,
+}
+
+func (e ListScope) IsValid() bool {
+	switch e {
+	case ListScopeAll, ListScopeOwned, ListScopeShared:
+		return true
+	}
+	return false
+}
+
+func (e ListScope) String() string {
+	return string(e)
+}
+
+func (e *ListScope) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ListScope(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ListScope", str)
+	}
+	return nil
+}
+
+func (e ListScope) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *ListScope) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e ListScope) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type ListSort string
+
+const (
+	ListSortUpdatedAt ListSort = "UPDATED_AT"
+	ListSortCreatedAt ListSort = "CREATED_AT"
+	// By folder name or note title.
+	ListSortName ListSort = "NAME"
+)
+
+var AllListSort = []ListSort{
+	ListSortUpdatedAt,
+	ListSortCreatedAt,
+	ListSortName,
+}
+
+func (e ListSort) IsValid() bool {
+	switch e {
+	case ListSortUpdatedAt, ListSortCreatedAt, ListSortName:
+		return true
+	}
+	return false
+}
+
+func (e ListSort) String() string {
+	return string(e)
+}
+
+func (e *ListSort) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ListSort(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ListSort", str)
+	}
+	return nil
+}
+
+func (e ListSort) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *ListSort) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e ListSort) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type MembershipChangeKind string
+
+const (
+	MembershipChangeKindAdded       MembershipChangeKind = "ADDED"
+	MembershipChangeKindRemoved     MembershipChangeKind = "REMOVED"
+	MembershipChangeKindRoleChanged MembershipChangeKind = "ROLE_CHANGED"
+	MembershipChangeKindExpired     MembershipChangeKind = "EXPIRED"
+)
+
+var AllMembershipChangeKind = []MembershipChangeKind{
+	MembershipChangeKindAdded,
+	MembershipChangeKindRemoved,
+	MembershipChangeKindRoleChanged,
+	MembershipChangeKindExpired,
+}
+
+func (e MembershipChangeKind) IsValid() bool {
+	switch e {
+	case MembershipChangeKindAdded, MembershipChangeKindRemoved, MembershipChangeKindRoleChanged, MembershipChangeKindExpired:
+		return true
+	}
+	return false
+}
+
+func (e MembershipChangeKind) String() string {
+	return string(e)
+}
+
+func (e *MembershipChangeKind) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = MembershipChangeKind(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid MembershipChangeKind", str)
+	}
+	return nil
+}
+
+func (e MembershipChangeKind) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *MembershipChangeKind) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e MembershipChangeKind) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type MembershipOperationType string
+
+const (
+	MembershipOperationTypeAdd        MembershipOperationType = "ADD"
+	MembershipOperationTypeRemove     MembershipOperationType = "REMOVE"
+	MembershipOperationTypeChangeRole MembershipOperationType = "CHANGE_ROLE"
+)
+
+var AllMembershipOperationType = []MembershipOperationType{
+	MembershipOperationTypeAdd,
+	MembershipOperationTypeRemove,
+	MembershipOperationTypeChangeRole,
+}
+
+func (e MembershipOperationType) IsValid() bool {
+	switch e {
+	case MembershipOperationTypeAdd, MembershipOperationTypeRemove, MembershipOperationTypeChangeRole:
+		return true
+	}
+	return false
+}
+
+func (e MembershipOperationType) String() string {
+	return string(e)
+}
+
+func (e *MembershipOperationType) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = MembershipOperationType(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid MembershipOperationType", str)
+	}
+	return nil
+}
+
+func (e MembershipOperationType) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *MembershipOperationType) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e MembershipOperationType) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type ShareExpiryKind string
+
+const (
+	ShareExpiryKindExpiring ShareExpiryKind = "EXPIRING"
+	ShareExpiryKindExpired  ShareExpiryKind = "EXPIRED"
+)
+
+var AllShareExpiryKind = []ShareExpiryKind{
+	ShareExpiryKindExpiring,
+	ShareExpiryKindExpired,
+}
+
+func (e ShareExpiryKind) IsValid() bool {
+	switch e {
+	case ShareExpiryKindExpiring, ShareExpiryKindExpired:
+		return true
+	}
+	return false
+}
+
+func (e ShareExpiryKind) String() string {
+	return string(e)
+}
+
+func (e *ShareExpiryKind) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ShareExpiryKind(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ShareExpiryKind", str)
+	}
+	return nil
+}
+
+func (e ShareExpiryKind) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *ShareExpiryKind) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e ShareExpiryKind) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type SortOrder string
+
+const (
+	SortOrderAsc  SortOrder = "ASC"
+	SortOrderDesc SortOrder = "DESC"
+)
+
+var AllSortOrder = []SortOrder{
+	SortOrderAsc,
+	SortOrderDesc,
+}
+
+func (e SortOrder) IsValid() bool {
+	switch e {
+	case SortOrderAsc, SortOrderDesc:
+		return true
+	}
+	return false
+}
+
+func (e SortOrder) String() string {
+	return string(e)
+}
+
+func (e *SortOrder) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = SortOrder(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid SortOrder", str)
+	}
+	return nil
+}
+
+func (e SortOrder) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *SortOrder) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e SortOrder) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
 type UserType string
 
 const (
@@ -5,10 +5,16 @@ package graph
 // It serves as dependency injection for your app, add any dependencies you require here.
 
 import (
+	"go-training-system/internal/event"
 	"go-training-system/internal/service"
 )
 
 type Resolver struct {
-	UserService service.UserService
-	JWTSecret   string
+	UserService    service.UserService
+	TeamService    service.TeamService
+	FolderService  service.FolderService
+	NoteService    service.NoteService
+	CommentService service.CommentService
+	Events         *event.Bus
+	JWTSecret      string
 }
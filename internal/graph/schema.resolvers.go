@@ -9,12 +9,124 @@ import (
 	"fmt"
 	"time"
 
+	"go-training-system/internal/dto"
+	"go-training-system/internal/event"
 	"go-training-system/internal/graph/apperror"
+	"go-training-system/internal/graph/constant"
 	"go-training-system/internal/graph/helper"
+	"go-training-system/internal/graph/loader"
 	"go-training-system/internal/graph/model"
 	"go-training-system/pkg/jwt"
+	"go-training-system/pkg/middleware"
+
+	"github.com/google/uuid"
 )
 
+// Parent is the resolver for the parent field.
+func (r *folderResolver) Parent(ctx context.Context, obj *model.Folder) (*model.Folder, error) {
+	if obj.ParentID == nil {
+		return nil, nil
+	}
+	loaders, err := loader.For(ctx)
+	if err != nil {
+		return nil, err
+	}
+	id, err := uuid.Parse(*obj.ParentID)
+	if err != nil {
+		return nil, err
+	}
+
+	parent, err := loaders.Folders.Load(ctx, id)
+	if err != nil || parent == nil {
+		return nil, err
+	}
+	return helper.FolderFromDTO(parent), nil
+}
+
+// Owner is the resolver for the owner field.
+func (r *folderResolver) Owner(ctx context.Context, obj *model.Folder) (*model.User, error) {
+	loaders, err := loader.For(ctx)
+	if err != nil {
+		return nil, err
+	}
+	id, err := uuid.Parse(obj.OwnerID)
+	if err != nil {
+		return nil, err
+	}
+
+	owner, err := loaders.Users.Load(ctx, id)
+	if err != nil || owner == nil {
+		return nil, err
+	}
+	return helper.UserFromModel(owner), nil
+}
+
+// Notes is the resolver for the notes field.
+func (r *folderResolver) Notes(ctx context.Context, obj *model.Folder) ([]*model.Note, error) {
+	loaders, err := loader.For(ctx)
+	if err != nil {
+		return nil, err
+	}
+	id, err := uuid.Parse(obj.FolderID)
+	if err != nil {
+		return nil, err
+	}
+
+	notes, err := loaders.FolderNotes.Load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	gqlNotes := make([]*model.Note, len(notes))
+	for i := range notes {
+		gqlNotes[i] = helper.NoteFromDTO(&notes[i])
+	}
+	return gqlNotes, nil
+}
+
+// Shares is the resolver for the shares field.
+func (r *folderResolver) Shares(ctx context.Context, obj *model.Folder) ([]*model.Share, error) {
+	loaders, err := loader.For(ctx)
+	if err != nil {
+		return nil, err
+	}
+	id, err := uuid.Parse(obj.FolderID)
+	if err != nil {
+		return nil, err
+	}
+
+	shares, err := loaders.FolderShares.Load(ctx, id)
+	if err != nil || shares == nil {
+		return nil, err
+	}
+	gqlShares := make([]*model.Share, len(shares))
+	for i := range shares {
+		gqlShares[i] = helper.ShareFromDTO(&shares[i])
+	}
+	return gqlShares, nil
+}
+
+// TeamShares is the resolver for the teamShares field.
+func (r *folderResolver) TeamShares(ctx context.Context, obj *model.Folder) ([]*model.TeamShare, error) {
+	loaders, err := loader.For(ctx)
+	if err != nil {
+		return nil, err
+	}
+	id, err := uuid.Parse(obj.FolderID)
+	if err != nil {
+		return nil, err
+	}
+
+	shares, err := loaders.FolderTeamShares.Load(ctx, id)
+	if err != nil || shares == nil {
+		return nil, err
+	}
+	gqlShares := make([]*model.TeamShare, len(shares))
+	for i := range shares {
+		gqlShares[i] = helper.TeamShareFromDTO(&shares[i])
+	}
+	return gqlShares, nil
+}
+
 // CreateUser is the resolver for the createUser field.
 func (r *mutationResolver) CreateUser(ctx context.Context, input model.CreateUserInput) (*model.UserMutationResponse, error) {
 	user, err := r.UserService.Register(ctx, &input)
@@ -94,10 +206,655 @@ func (r *mutationResolver) Logout(ctx context.Context) (bool, error) {
 	panic(fmt.Errorf("not implemented: Logout - logout"))
 }
 
+// BulkUpdateTeamMembers is the resolver for the bulkUpdateTeamMembers field.
+func (r *mutationResolver) BulkUpdateTeamMembers(ctx context.Context, input model.BulkMembershipInput) (*model.BulkMembershipMutationResponse, error) {
+	principal, err := middleware.PrincipalFromContext(ctx)
+	if err != nil {
+		return nil, apperror.ErrUnauthorized
+	}
+
+	req := &dto.BulkMembershipRequest{
+		Operations: make([]dto.MembershipOperation, len(input.Operations)),
+	}
+	if input.Atomic != nil {
+		req.Atomic = *input.Atomic
+	}
+	for i, op := range input.Operations {
+		req.Operations[i] = dto.MembershipOperation{
+			Op:     dto.MembershipOperationType(op.Op),
+			TeamID: op.TeamID,
+			UserID: op.UserID,
+		}
+		if op.Role != nil {
+			req.Operations[i].Role = string(*op.Role)
+		}
+		if op.ExpiresAt != nil {
+			expiresAt, err := time.Parse(time.RFC3339, *op.ExpiresAt)
+			if err != nil {
+				msg := "expiresAt must be an RFC 3339 timestamp"
+				return helper.BulkMembershipError(constant.CodeBadRequest, &msg), nil
+			}
+			req.Operations[i].ExpiresAt = &expiresAt
+		}
+	}
+
+	result, err := r.TeamService.BulkUpdateMembers(ctx, req, principal.UserID)
+	if err != nil {
+		msg := err.Error()
+		return helper.BulkMembershipError(constant.CodeInternalError, &msg), nil
+	}
+	return helper.BulkMembershipResult(result), nil
+}
+
+// CreateFolder is the resolver for the createFolder field.
+func (r *mutationResolver) CreateFolder(ctx context.Context, input model.CreateFolderInput) (*model.FolderMutationResponse, error) {
+	principal, err := middleware.PrincipalFromContext(ctx)
+	if err != nil {
+		return helper.FolderMutationError(apperror.ErrUnauthorized), nil
+	}
+	parentID, err := helper.ParseOptionalID(input.ParentID, apperror.ErrFolderNotFound)
+	if err != nil {
+		return helper.FolderMutationError(err), nil
+	}
+
+	req := &dto.CreateFolderRequest{Name: input.Name, ParentID: parentID}
+	if input.Description != nil {
+		req.Description = *input.Description
+	}
+	folder, err := r.FolderService.CreateFolder(ctx, req, principal.UserID)
+	if err != nil {
+		return helper.FolderMutationError(err), nil
+	}
+	return helper.FolderMutationSuccess(folder), nil
+}
+
+// UpdateFolder is the resolver for the updateFolder field.
+func (r *mutationResolver) UpdateFolder(ctx context.Context, folderID string, input model.UpdateFolderInput, expectedVersion int32) (*model.FolderMutationResponse, error) {
+	principal, err := middleware.PrincipalFromContext(ctx)
+	if err != nil {
+		return helper.FolderMutationError(apperror.ErrUnauthorized), nil
+	}
+	id, err := uuid.Parse(folderID)
+	if err != nil {
+		return helper.FolderMutationError(apperror.ErrFolderNotFound), nil
+	}
+
+	req := &dto.UpdateFolderRequest{Name: input.Name, Description: input.Description}
+	folder, err := r.FolderService.UpdateFolder(ctx, id, req, principal.UserID, int64(expectedVersion))
+	if err != nil {
+		return helper.FolderMutationError(err), nil
+	}
+	return helper.FolderMutationSuccess(folder), nil
+}
+
+// DeleteFolder is the resolver for the deleteFolder field.
+func (r *mutationResolver) DeleteFolder(ctx context.Context, folderID string, recursive *bool, expectedVersion int32) (*model.FolderMutationResponse, error) {
+	principal, err := middleware.PrincipalFromContext(ctx)
+	if err != nil {
+		return helper.FolderMutationError(apperror.ErrUnauthorized), nil
+	}
+	id, err := uuid.Parse(folderID)
+	if err != nil {
+		return helper.FolderMutationError(apperror.ErrFolderNotFound), nil
+	}
+
+	err = r.FolderService.DeleteFolder(ctx, id, principal.UserID, recursive != nil && *recursive, int64(expectedVersion))
+	if err != nil {
+		return helper.FolderMutationError(err), nil
+	}
+	return helper.FolderMutationSuccess(nil), nil
+}
+
+// MoveFolder is the resolver for the moveFolder field.
+func (r *mutationResolver) MoveFolder(ctx context.Context, folderID string, input model.MoveFolderInput, expectedVersion int32) (*model.FolderMutationResponse, error) {
+	principal, err := middleware.PrincipalFromContext(ctx)
+	if err != nil {
+		return helper.FolderMutationError(apperror.ErrUnauthorized), nil
+	}
+	id, err := uuid.Parse(folderID)
+	if err != nil {
+		return helper.FolderMutationError(apperror.ErrFolderNotFound), nil
+	}
+	parentID, err := helper.ParseOptionalID(input.ParentID, apperror.ErrFolderNotFound)
+	if err != nil {
+		return helper.FolderMutationError(err), nil
+	}
+
+	req := &dto.MoveFolderRequest{ParentID: parentID}
+	if input.DropShares != nil {
+		req.DropShares = *input.DropShares
+	}
+	folder, err := r.FolderService.MoveFolder(ctx, id, req, principal.UserID, int64(expectedVersion))
+	if err != nil {
+		return helper.FolderMutationError(err), nil
+	}
+	return helper.FolderMutationSuccess(folder), nil
+}
+
+// CopyFolder is the resolver for the copyFolder field.
+func (r *mutationResolver) CopyFolder(ctx context.Context, folderID string, parentID *string) (*model.FolderMutationResponse, error) {
+	principal, err := middleware.PrincipalFromContext(ctx)
+	if err != nil {
+		return helper.FolderMutationError(apperror.ErrUnauthorized), nil
+	}
+	id, err := uuid.Parse(folderID)
+	if err != nil {
+		return helper.FolderMutationError(apperror.ErrFolderNotFound), nil
+	}
+	target, err := helper.ParseOptionalID(parentID, apperror.ErrFolderNotFound)
+	if err != nil {
+		return helper.FolderMutationError(err), nil
+	}
+
+	req := &dto.CopyFolderRequest{ParentID: target}
+	folder, err := r.FolderService.CopyFolder(ctx, id, req, principal.UserID)
+	if err != nil {
+		return helper.FolderMutationError(err), nil
+	}
+	return helper.FolderMutationSuccess(folder), nil
+}
+
+// ShareFolder is the resolver for the shareFolder field.
+func (r *mutationResolver) ShareFolder(ctx context.Context, folderID string, input model.ShareInput) (*model.ShareMutationResponse, error) {
+	principal, err := middleware.PrincipalFromContext(ctx)
+	if err != nil {
+		return helper.ShareMutationError(apperror.ErrUnauthorized), nil
+	}
+	id, err := uuid.Parse(folderID)
+	if err != nil {
+		return helper.ShareMutationError(apperror.ErrFolderNotFound), nil
+	}
+	req, err := helper.ShareRequestFromInput(&input)
+	if err != nil {
+		return helper.ShareMutationError(err), nil
+	}
+
+	if err := r.FolderService.ShareFolder(ctx, id, req, principal.UserID); err != nil {
+		return helper.ShareMutationError(err), nil
+	}
+	return helper.ShareMutationSuccess(), nil
+}
+
+// UpdateFolderShare is the resolver for the updateFolderShare field.
+func (r *mutationResolver) UpdateFolderShare(ctx context.Context, folderID string, userID string, input model.UpdateShareInput) (*model.ShareMutationResponse, error) {
+	principal, err := middleware.PrincipalFromContext(ctx)
+	if err != nil {
+		return helper.ShareMutationError(apperror.ErrUnauthorized), nil
+	}
+	id, err := uuid.Parse(folderID)
+	if err != nil {
+		return helper.ShareMutationError(apperror.ErrFolderNotFound), nil
+	}
+	grantee, err := uuid.Parse(userID)
+	if err != nil {
+		return helper.ShareMutationError(apperror.ErrShareNotFound), nil
+	}
+	req, err := helper.UpdateShareRequestFromInput(&input)
+	if err != nil {
+		return helper.ShareMutationError(err), nil
+	}
+
+	if err := r.FolderService.UpdateFolderShare(ctx, id, grantee, req, principal.UserID); err != nil {
+		return helper.ShareMutationError(err), nil
+	}
+	return helper.ShareMutationSuccess(), nil
+}
+
+// RevokeFolderShare is the resolver for the revokeFolderShare field.
+func (r *mutationResolver) RevokeFolderShare(ctx context.Context, folderID string, userID string) (*model.ShareMutationResponse, error) {
+	principal, err := middleware.PrincipalFromContext(ctx)
+	if err != nil {
+		return helper.ShareMutationError(apperror.ErrUnauthorized), nil
+	}
+	id, err := uuid.Parse(folderID)
+	if err != nil {
+		return helper.ShareMutationError(apperror.ErrFolderNotFound), nil
+	}
+	grantee, err := uuid.Parse(userID)
+	if err != nil {
+		return helper.ShareMutationError(apperror.ErrShareNotFound), nil
+	}
+
+	if err := r.FolderService.RevokeFolderShare(ctx, id, grantee, principal.UserID); err != nil {
+		return helper.ShareMutationError(err), nil
+	}
+	return helper.ShareMutationSuccess(), nil
+}
+
+// ShareFolderWithTeam is the resolver for the shareFolderWithTeam field.
+func (r *mutationResolver) ShareFolderWithTeam(ctx context.Context, folderID string, input model.TeamShareInput) (*model.ShareMutationResponse, error) {
+	principal, err := middleware.PrincipalFromContext(ctx)
+	if err != nil {
+		return helper.ShareMutationError(apperror.ErrUnauthorized), nil
+	}
+	id, err := uuid.Parse(folderID)
+	if err != nil {
+		return helper.ShareMutationError(apperror.ErrFolderNotFound), nil
+	}
+	req, err := helper.TeamShareRequestFromInput(&input)
+	if err != nil {
+		return helper.ShareMutationError(err), nil
+	}
+
+	if err := r.FolderService.ShareFolderWithTeam(ctx, id, req, principal.UserID); err != nil {
+		return helper.ShareMutationError(err), nil
+	}
+	return helper.ShareMutationSuccess(), nil
+}
+
+// RevokeFolderTeamShare is the resolver for the revokeFolderTeamShare field.
+func (r *mutationResolver) RevokeFolderTeamShare(ctx context.Context, folderID string, teamID string) (*model.ShareMutationResponse, error) {
+	principal, err := middleware.PrincipalFromContext(ctx)
+	if err != nil {
+		return helper.ShareMutationError(apperror.ErrUnauthorized), nil
+	}
+	id, err := uuid.Parse(folderID)
+	if err != nil {
+		return helper.ShareMutationError(apperror.ErrFolderNotFound), nil
+	}
+	team, err := uuid.Parse(teamID)
+	if err != nil {
+		return helper.ShareMutationError(apperror.ErrTeamShareNotFound), nil
+	}
+
+	if err := r.FolderService.RevokeFolderTeamShare(ctx, id, team, principal.UserID); err != nil {
+		return helper.ShareMutationError(err), nil
+	}
+	return helper.ShareMutationSuccess(), nil
+}
+
+// CreateNote is the resolver for the createNote field.
+func (r *mutationResolver) CreateNote(ctx context.Context, input model.CreateNoteInput) (*model.NoteMutationResponse, error) {
+	principal, err := middleware.PrincipalFromContext(ctx)
+	if err != nil {
+		return helper.NoteMutationError(apperror.ErrUnauthorized), nil
+	}
+	folderID, err := uuid.Parse(input.FolderID)
+	if err != nil {
+		return helper.NoteMutationError(apperror.ErrFolderNotFound), nil
+	}
+
+	req := &dto.CreateNoteRequest{Title: input.Title, FolderID: folderID}
+	if input.Body != nil {
+		req.Body = *input.Body
+	}
+	note, err := r.NoteService.CreateNote(ctx, req, principal.UserID)
+	if err != nil {
+		return helper.NoteMutationError(err), nil
+	}
+	return helper.NoteMutationSuccess(note), nil
+}
+
+// UpdateNote is the resolver for the updateNote field.
+func (r *mutationResolver) UpdateNote(ctx context.Context, noteID string, input model.UpdateNoteInput, expectedVersion int32) (*model.NoteMutationResponse, error) {
+	principal, err := middleware.PrincipalFromContext(ctx)
+	if err != nil {
+		return helper.NoteMutationError(apperror.ErrUnauthorized), nil
+	}
+	id, err := uuid.Parse(noteID)
+	if err != nil {
+		return helper.NoteMutationError(apperror.ErrNoteNotFound), nil
+	}
+
+	req := &dto.UpdateNoteRequest{Title: input.Title, Body: input.Body}
+	note, err := r.NoteService.UpdateNote(ctx, id, req, principal.UserID, int64(expectedVersion))
+	if err != nil {
+		return helper.NoteMutationError(err), nil
+	}
+	return helper.NoteMutationSuccess(note), nil
+}
+
+// DeleteNote is the resolver for the deleteNote field.
+func (r *mutationResolver) DeleteNote(ctx context.Context, noteID string, expectedVersion int32) (*model.NoteMutationResponse, error) {
+	principal, err := middleware.PrincipalFromContext(ctx)
+	if err != nil {
+		return helper.NoteMutationError(apperror.ErrUnauthorized), nil
+	}
+	id, err := uuid.Parse(noteID)
+	if err != nil {
+		return helper.NoteMutationError(apperror.ErrNoteNotFound), nil
+	}
+
+	if err := r.NoteService.DeleteNote(ctx, id, principal.UserID, int64(expectedVersion)); err != nil {
+		return helper.NoteMutationError(err), nil
+	}
+	return helper.NoteMutationSuccess(nil), nil
+}
+
+// MoveNotes is the resolver for the moveNotes field.
+func (r *mutationResolver) MoveNotes(ctx context.Context, input model.MoveNotesInput) (*model.NotesMutationResponse, error) {
+	principal, err := middleware.PrincipalFromContext(ctx)
+	if err != nil {
+		return helper.NotesMutationError(apperror.ErrUnauthorized), nil
+	}
+	folderID, err := uuid.Parse(input.FolderID)
+	if err != nil {
+		return helper.NotesMutationError(apperror.ErrFolderNotFound), nil
+	}
+	noteIDs, err := helper.ParseIDs(input.NoteIds, apperror.ErrNoteNotFound)
+	if err != nil {
+		return helper.NotesMutationError(err), nil
+	}
+
+	req := &dto.MoveNotesRequest{NoteIDs: noteIDs, FolderID: folderID}
+	if input.DropShares != nil {
+		req.DropShares = *input.DropShares
+	}
+	notes, err := r.NoteService.MoveNotes(ctx, req, principal.UserID)
+	if err != nil {
+		return helper.NotesMutationError(err), nil
+	}
+	return helper.NotesMutationSuccess(notes), nil
+}
+
+// CopyNotes is the resolver for the copyNotes field.
+func (r *mutationResolver) CopyNotes(ctx context.Context, input model.CopyNotesInput) (*model.NotesMutationResponse, error) {
+	principal, err := middleware.PrincipalFromContext(ctx)
+	if err != nil {
+		return helper.NotesMutationError(apperror.ErrUnauthorized), nil
+	}
+	folderID, err := uuid.Parse(input.FolderID)
+	if err != nil {
+		return helper.NotesMutationError(apperror.ErrFolderNotFound), nil
+	}
+	noteIDs, err := helper.ParseIDs(input.NoteIds, apperror.ErrNoteNotFound)
+	if err != nil {
+		return helper.NotesMutationError(err), nil
+	}
+
+	req := &dto.CopyNotesRequest{NoteIDs: noteIDs, FolderID: folderID}
+	notes, err := r.NoteService.CopyNotes(ctx, req, principal.UserID)
+	if err != nil {
+		return helper.NotesMutationError(err), nil
+	}
+	return helper.NotesMutationSuccess(notes), nil
+}
+
+// ShareNote is the resolver for the shareNote field.
+func (r *mutationResolver) ShareNote(ctx context.Context, noteID string, input model.ShareInput) (*model.ShareMutationResponse, error) {
+	principal, err := middleware.PrincipalFromContext(ctx)
+	if err != nil {
+		return helper.ShareMutationError(apperror.ErrUnauthorized), nil
+	}
+	id, err := uuid.Parse(noteID)
+	if err != nil {
+		return helper.ShareMutationError(apperror.ErrNoteNotFound), nil
+	}
+	req, err := helper.ShareRequestFromInput(&input)
+	if err != nil {
+		return helper.ShareMutationError(err), nil
+	}
+
+	if err := r.NoteService.ShareNote(ctx, id, req, principal.UserID); err != nil {
+		return helper.ShareMutationError(err), nil
+	}
+	return helper.ShareMutationSuccess(), nil
+}
+
+// UpdateNoteShare is the resolver for the updateNoteShare field.
+func (r *mutationResolver) UpdateNoteShare(ctx context.Context, noteID string, userID string, input model.UpdateShareInput) (*model.ShareMutationResponse, error) {
+	principal, err := middleware.PrincipalFromContext(ctx)
+	if err != nil {
+		return helper.ShareMutationError(apperror.ErrUnauthorized), nil
+	}
+	id, err := uuid.Parse(noteID)
+	if err != nil {
+		return helper.ShareMutationError(apperror.ErrNoteNotFound), nil
+	}
+	grantee, err := uuid.Parse(userID)
+	if err != nil {
+		return helper.ShareMutationError(apperror.ErrShareNotFound), nil
+	}
+	req, err := helper.UpdateShareRequestFromInput(&input)
+	if err != nil {
+		return helper.ShareMutationError(err), nil
+	}
+
+	if err := r.NoteService.UpdateNoteShare(ctx, id, grantee, req, principal.UserID); err != nil {
+		return helper.ShareMutationError(err), nil
+	}
+	return helper.ShareMutationSuccess(), nil
+}
+
+// RevokeNoteShare is the resolver for the revokeNoteShare field.
+func (r *mutationResolver) RevokeNoteShare(ctx context.Context, noteID string, userID string) (*model.ShareMutationResponse, error) {
+	principal, err := middleware.PrincipalFromContext(ctx)
+	if err != nil {
+		return helper.ShareMutationError(apperror.ErrUnauthorized), nil
+	}
+	id, err := uuid.Parse(noteID)
+	if err != nil {
+		return helper.ShareMutationError(apperror.ErrNoteNotFound), nil
+	}
+	grantee, err := uuid.Parse(userID)
+	if err != nil {
+		return helper.ShareMutationError(apperror.ErrShareNotFound), nil
+	}
+
+	if err := r.NoteService.RevokeNoteShare(ctx, id, grantee, principal.UserID); err != nil {
+		return helper.ShareMutationError(err), nil
+	}
+	return helper.ShareMutationSuccess(), nil
+}
+
+// ShareNoteWithTeam is the resolver for the shareNoteWithTeam field.
+func (r *mutationResolver) ShareNoteWithTeam(ctx context.Context, noteID string, input model.TeamShareInput) (*model.ShareMutationResponse, error) {
+	principal, err := middleware.PrincipalFromContext(ctx)
+	if err != nil {
+		return helper.ShareMutationError(apperror.ErrUnauthorized), nil
+	}
+	id, err := uuid.Parse(noteID)
+	if err != nil {
+		return helper.ShareMutationError(apperror.ErrNoteNotFound), nil
+	}
+	req, err := helper.TeamShareRequestFromInput(&input)
+	if err != nil {
+		return helper.ShareMutationError(err), nil
+	}
+
+	if err := r.NoteService.ShareNoteWithTeam(ctx, id, req, principal.UserID); err != nil {
+		return helper.ShareMutationError(err), nil
+	}
+	return helper.ShareMutationSuccess(), nil
+}
+
+// RevokeNoteTeamShare is the resolver for the revokeNoteTeamShare field.
+func (r *mutationResolver) RevokeNoteTeamShare(ctx context.Context, noteID string, teamID string) (*model.ShareMutationResponse, error) {
+	principal, err := middleware.PrincipalFromContext(ctx)
+	if err != nil {
+		return helper.ShareMutationError(apperror.ErrUnauthorized), nil
+	}
+	id, err := uuid.Parse(noteID)
+	if err != nil {
+		return helper.ShareMutationError(apperror.ErrNoteNotFound), nil
+	}
+	team, err := uuid.Parse(teamID)
+	if err != nil {
+		return helper.ShareMutationError(apperror.ErrTeamShareNotFound), nil
+	}
+
+	if err := r.NoteService.RevokeNoteTeamShare(ctx, id, team, principal.UserID); err != nil {
+		return helper.ShareMutationError(err), nil
+	}
+	return helper.ShareMutationSuccess(), nil
+}
+
+// AddComment is the resolver for the addComment field.
+func (r *mutationResolver) AddComment(ctx context.Context, noteID string, input model.AddCommentInput) (*model.CommentMutationResponse, error) {
+	principal, err := middleware.PrincipalFromContext(ctx)
+	if err != nil {
+		return helper.CommentMutationError(apperror.ErrUnauthorized), nil
+	}
+	id, err := uuid.Parse(noteID)
+	if err != nil {
+		return helper.CommentMutationError(apperror.ErrNoteNotFound), nil
+	}
+
+	req := &dto.CreateCommentRequest{Body: input.Body}
+	if input.ParentID != nil {
+		parentID, err := uuid.Parse(*input.ParentID)
+		if err != nil {
+			return helper.CommentMutationError(apperror.ErrCommentNotFound), nil
+		}
+		req.ParentID = &parentID
+	}
+	if input.Anchor != nil {
+		req.Anchor = &dto.CommentAnchor{Start: int(input.Anchor.Start), End: int(input.Anchor.End)}
+	}
+	comment, err := r.CommentService.CreateComment(ctx, id, req, principal.UserID)
+	if err != nil {
+		return helper.CommentMutationError(err), nil
+	}
+	return helper.CommentMutationSuccess(comment), nil
+}
+
+// UpdateComment is the resolver for the updateComment field.
+func (r *mutationResolver) UpdateComment(ctx context.Context, commentID string, body string, expectedVersion int32) (*model.CommentMutationResponse, error) {
+	principal, err := middleware.PrincipalFromContext(ctx)
+	if err != nil {
+		return helper.CommentMutationError(apperror.ErrUnauthorized), nil
+	}
+	id, err := uuid.Parse(commentID)
+	if err != nil {
+		return helper.CommentMutationError(apperror.ErrCommentNotFound), nil
+	}
+
+	req := &dto.UpdateCommentRequest{Body: body}
+	comment, err := r.CommentService.UpdateComment(ctx, id, req, principal.UserID, int64(expectedVersion))
+	if err != nil {
+		return helper.CommentMutationError(err), nil
+	}
+	return helper.CommentMutationSuccess(comment), nil
+}
+
+// ResolveComment is the resolver for the resolveComment field.
+func (r *mutationResolver) ResolveComment(ctx context.Context, commentID string, resolved bool) (*model.CommentMutationResponse, error) {
+	principal, err := middleware.PrincipalFromContext(ctx)
+	if err != nil {
+		return helper.CommentMutationError(apperror.ErrUnauthorized), nil
+	}
+	id, err := uuid.Parse(commentID)
+	if err != nil {
+		return helper.CommentMutationError(apperror.ErrCommentNotFound), nil
+	}
+
+	req := &dto.ResolveCommentRequest{Resolved: resolved}
+	comment, err := r.CommentService.ResolveComment(ctx, id, req, principal.UserID)
+	if err != nil {
+		return helper.CommentMutationError(err), nil
+	}
+	return helper.CommentMutationSuccess(comment), nil
+}
+
+// DeleteComment is the resolver for the deleteComment field.
+func (r *mutationResolver) DeleteComment(ctx context.Context, commentID string, expectedVersion int32) (*model.CommentMutationResponse, error) {
+	principal, err := middleware.PrincipalFromContext(ctx)
+	if err != nil {
+		return helper.CommentMutationError(apperror.ErrUnauthorized), nil
+	}
+	id, err := uuid.Parse(commentID)
+	if err != nil {
+		return helper.CommentMutationError(apperror.ErrCommentNotFound), nil
+	}
+
+	if err := r.CommentService.DeleteComment(ctx, id, principal.UserID, int64(expectedVersion)); err != nil {
+		return helper.CommentMutationError(err), nil
+	}
+	return helper.CommentMutationSuccess(nil), nil
+}
+
+// BodyHTML is the resolver for the bodyHtml field.
+func (r *noteResolver) BodyHTML(ctx context.Context, obj *model.Note) (string, error) {
+	id, err := uuid.Parse(obj.NoteID)
+	if err != nil {
+		return "", err
+	}
+	return r.NoteService.RenderNoteHTML(id, int64(obj.Version), obj.Body), nil
+}
+
+// Folder is the resolver for the folder field.
+func (r *noteResolver) Folder(ctx context.Context, obj *model.Note) (*model.Folder, error) {
+	loaders, err := loader.For(ctx)
+	if err != nil {
+		return nil, err
+	}
+	id, err := uuid.Parse(obj.FolderID)
+	if err != nil {
+		return nil, err
+	}
+
+	folder, err := loaders.Folders.Load(ctx, id)
+	if err != nil || folder == nil {
+		return nil, err
+	}
+	return helper.FolderFromDTO(folder), nil
+}
+
+// Owner is the resolver for the owner field.
+func (r *noteResolver) Owner(ctx context.Context, obj *model.Note) (*model.User, error) {
+	loaders, err := loader.For(ctx)
+	if err != nil {
+		return nil, err
+	}
+	id, err := uuid.Parse(obj.OwnerID)
+	if err != nil {
+		return nil, err
+	}
+
+	owner, err := loaders.Users.Load(ctx, id)
+	if err != nil || owner == nil {
+		return nil, err
+	}
+	return helper.UserFromModel(owner), nil
+}
+
+// Shares is the resolver for the shares field.
+func (r *noteResolver) Shares(ctx context.Context, obj *model.Note) ([]*model.Share, error) {
+	loaders, err := loader.For(ctx)
+	if err != nil {
+		return nil, err
+	}
+	id, err := uuid.Parse(obj.NoteID)
+	if err != nil {
+		return nil, err
+	}
+
+	shares, err := loaders.NoteShares.Load(ctx, id)
+	if err != nil || shares == nil {
+		return nil, err
+	}
+	gqlShares := make([]*model.Share, len(shares))
+	for i := range shares {
+		gqlShares[i] = helper.ShareFromDTO(&shares[i])
+	}
+	return gqlShares, nil
+}
+
+// TeamShares is the resolver for the teamShares field.
+func (r *noteResolver) TeamShares(ctx context.Context, obj *model.Note) ([]*model.TeamShare, error) {
+	loaders, err := loader.For(ctx)
+	if err != nil {
+		return nil, err
+	}
+	id, err := uuid.Parse(obj.NoteID)
+	if err != nil {
+		return nil, err
+	}
+
+	shares, err := loaders.NoteTeamShares.Load(ctx, id)
+	if err != nil || shares == nil {
+		return nil, err
+	}
+	gqlShares := make([]*model.TeamShare, len(shares))
+	for i := range shares {
+		gqlShares[i] = helper.TeamShareFromDTO(&shares[i])
+	}
+	return gqlShares, nil
+}
+
 // Users is the resolver for the users field.
 func (r *queryResolver) Users(ctx context.Context, role *model.UserType) ([]*model.User, error) {
-	userID, ok := ctx.Value("user_id").(string)
-	if !ok || userID == "" {
+	if _, err := middleware.PrincipalFromContext(ctx); err != nil {
 		return nil, apperror.ErrUnauthorized
 	}
 
@@ -139,26 +896,334 @@ func (r *queryResolver) MyTeams(ctx context.Context) ([]*model.Team, error) {
 	panic(fmt.Errorf("not implemented: MyTeams - myTeams"))
 }
 
+// SearchNotes is the resolver for the searchNotes field.
+func (r *queryResolver) SearchNotes(ctx context.Context, input model.NoteSearchInput) ([]*model.NoteSearchResult, error) {
+	principal, err := middleware.PrincipalFromContext(ctx)
+	if err != nil {
+		return nil, apperror.ErrUnauthorized
+	}
+
+	req := &dto.NoteSearchRequest{Query: input.Query}
+	if input.Tags != nil {
+		req.Tags = *input.Tags
+	}
+	if input.FolderID != nil {
+		folderID, err := uuid.Parse(*input.FolderID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid folderId: %w", err)
+		}
+		req.FolderID = &folderID
+	}
+	if input.OwnerID != nil {
+		ownerID, err := uuid.Parse(*input.OwnerID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ownerId: %w", err)
+		}
+		req.OwnerID = &ownerID
+	}
+	if input.UpdatedFrom != nil {
+		updatedFrom, err := time.Parse(time.RFC3339, *input.UpdatedFrom)
+		if err != nil {
+			return nil, fmt.Errorf("updatedFrom must be an RFC 3339 timestamp")
+		}
+		req.UpdatedFrom = &updatedFrom
+	}
+	if input.UpdatedTo != nil {
+		updatedTo, err := time.Parse(time.RFC3339, *input.UpdatedTo)
+		if err != nil {
+			return nil, fmt.Errorf("updatedTo must be an RFC 3339 timestamp")
+		}
+		req.UpdatedTo = &updatedTo
+	}
+	if input.Limit != nil {
+		req.Limit = int(*input.Limit)
+	}
+	if input.Offset != nil {
+		req.Offset = int(*input.Offset)
+	}
+
+	results, err := r.NoteService.SearchNotes(ctx, req, principal.UserID)
+	if err != nil {
+		return nil, err
+	}
+	gqlResults := make([]*model.NoteSearchResult, len(results))
+	for i := range results {
+		gqlResults[i] = helper.NoteSearchResultFromDTO(&results[i])
+	}
+	return gqlResults, nil
+}
+
+// NoteComments is the resolver for the noteComments field.
+func (r *queryResolver) NoteComments(ctx context.Context, noteID string) ([]*model.Comment, error) {
+	principal, err := middleware.PrincipalFromContext(ctx)
+	if err != nil {
+		return nil, apperror.ErrUnauthorized
+	}
+	id, err := uuid.Parse(noteID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid noteId: %w", err)
+	}
+
+	threads, err := r.CommentService.ListComments(ctx, id, principal.UserID)
+	if err != nil {
+		return nil, err
+	}
+	gqlThreads := make([]*model.Comment, len(threads))
+	for i := range threads {
+		gqlThreads[i] = helper.CommentFromDTO(&threads[i])
+	}
+	return gqlThreads, nil
+}
+
+// Folder is the resolver for the folder field.
+func (r *queryResolver) Folder(ctx context.Context, folderID string) (*model.Folder, error) {
+	principal, err := middleware.PrincipalFromContext(ctx)
+	if err != nil {
+		return nil, apperror.ErrUnauthorized
+	}
+	id, err := uuid.Parse(folderID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid folderId: %w", err)
+	}
+
+	folder, err := r.FolderService.GetFolder(ctx, id, principal.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return helper.FolderFromDTO(folder), nil
+}
+
+// Folders is the resolver for the folders field.
+func (r *queryResolver) Folders(ctx context.Context, input *model.ListInput) (*model.FolderPage, error) {
+	principal, err := middleware.PrincipalFromContext(ctx)
+	if err != nil {
+		return nil, apperror.ErrUnauthorized
+	}
+	req, err := helper.ListRequestFromInput(input)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := r.FolderService.GetUserFolders(ctx, req, principal.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return helper.FolderPageFromDTO(page), nil
+}
+
+// FolderPath is the resolver for the folderPath field.
+func (r *queryResolver) FolderPath(ctx context.Context, folderID string) ([]*model.FolderBreadcrumb, error) {
+	principal, err := middleware.PrincipalFromContext(ctx)
+	if err != nil {
+		return nil, apperror.ErrUnauthorized
+	}
+	id, err := uuid.Parse(folderID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid folderId: %w", err)
+	}
+
+	path, err := r.FolderService.GetFolderPath(ctx, id, principal.UserID)
+	if err != nil {
+		return nil, err
+	}
+	gqlPath := make([]*model.FolderBreadcrumb, len(path))
+	for i := range path {
+		gqlPath[i] = helper.FolderBreadcrumbFromDTO(&path[i])
+	}
+	return gqlPath, nil
+}
+
+// Note is the resolver for the note field.
+func (r *queryResolver) Note(ctx context.Context, noteID string) (*model.Note, error) {
+	principal, err := middleware.PrincipalFromContext(ctx)
+	if err != nil {
+		return nil, apperror.ErrUnauthorized
+	}
+	id, err := uuid.Parse(noteID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid noteId: %w", err)
+	}
+
+	note, err := r.NoteService.GetNote(ctx, id, principal.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return helper.NoteFromDTO(note), nil
+}
+
+// Notes is the resolver for the notes field.
+func (r *queryResolver) Notes(ctx context.Context, input *model.ListInput) (*model.NotePage, error) {
+	principal, err := middleware.PrincipalFromContext(ctx)
+	if err != nil {
+		return nil, apperror.ErrUnauthorized
+	}
+	req, err := helper.ListRequestFromInput(input)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := r.NoteService.GetUserNotes(ctx, req, principal.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return helper.NotePageFromDTO(page), nil
+}
+
+// NoteUpdated is the resolver for the noteUpdated field.
+func (r *subscriptionResolver) NoteUpdated(ctx context.Context, noteID string) (<-chan *model.NoteChange, error) {
+	principal, err := middleware.PrincipalFromContext(ctx)
+	if err != nil {
+		return nil, apperror.ErrUnauthorized
+	}
+	id, err := uuid.Parse(noteID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid noteId: %w", err)
+	}
+	if _, err := r.NoteService.GetNote(ctx, id, principal.UserID); err != nil {
+		return nil, err
+	}
+
+	events := r.Events.NoteEvents(ctx, id)
+	changes := make(chan *model.NoteChange)
+	go func() {
+		defer close(changes)
+		for e := range events {
+			// Reloading the note re-checks access; losing it ends the
+			// subscription
+			var note *dto.NoteResponse
+			if e.Kind != event.KindDeleted {
+				current, err := r.NoteService.GetNote(ctx, id, principal.UserID)
+				if err != nil {
+					return
+				}
+				note = current
+			}
+			select {
+			case changes <- helper.NoteChangeFromEvent(e, note):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return changes, nil
+}
+
+// FolderChanged is the resolver for the folderChanged field.
+func (r *subscriptionResolver) FolderChanged(ctx context.Context, folderID string) (<-chan *model.FolderChange, error) {
+	principal, err := middleware.PrincipalFromContext(ctx)
+	if err != nil {
+		return nil, apperror.ErrUnauthorized
+	}
+	id, err := uuid.Parse(folderID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid folderId: %w", err)
+	}
+	if _, err := r.FolderService.GetFolder(ctx, id, principal.UserID); err != nil {
+		return nil, err
+	}
+
+	events := r.Events.FolderEvents(ctx, id)
+	changes := make(chan *model.FolderChange)
+	go func() {
+		defer close(changes)
+		for e := range events {
+			deleted := e.Kind == event.KindDeleted && e.ItemID == id
+			if !deleted {
+				if _, err := r.FolderService.GetFolder(ctx, id, principal.UserID); err != nil {
+					return
+				}
+			}
+			select {
+			case changes <- helper.FolderChangeFromEvent(e):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return changes, nil
+}
+
+// TeamMembershipChanged is the resolver for the teamMembershipChanged field.
+func (r *subscriptionResolver) TeamMembershipChanged(ctx context.Context, teamID string) (<-chan *model.TeamMembershipChange, error) {
+	principal, err := middleware.PrincipalFromContext(ctx)
+	if err != nil {
+		return nil, apperror.ErrUnauthorized
+	}
+	id, err := uuid.Parse(teamID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid teamId: %w", err)
+	}
+	allowed, err := r.TeamService.CanViewTeam(ctx, id, principal.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, apperror.ErrAccessDenied
+	}
+
+	events := r.Events.MembershipEvents(ctx, id)
+	changes := make(chan *model.TeamMembershipChange)
+	go func() {
+		defer close(changes)
+		for e := range events {
+			// Users who just left the team still hear about it, then the
+			// subscription ends
+			allowed, err := r.TeamService.CanViewTeam(ctx, id, principal.UserID)
+			if err != nil || !allowed && e.UserID != principal.UserID {
+				return
+			}
+			select {
+			case changes <- helper.MembershipChangeFromEvent(e):
+			case <-ctx.Done():
+				return
+			}
+			if !allowed {
+				return
+			}
+		}
+	}()
+	return changes, nil
+}
+
+// ShareExpiry is the resolver for the shareExpiry field.
+func (r *subscriptionResolver) ShareExpiry(ctx context.Context) (<-chan *model.ShareExpiry, error) {
+	principal, err := middleware.PrincipalFromContext(ctx)
+	if err != nil {
+		return nil, apperror.ErrUnauthorized
+	}
+
+	events := r.Events.ShareEvents(ctx, principal.UserID)
+	changes := make(chan *model.ShareExpiry)
+	go func() {
+		defer close(changes)
+		for e := range events {
+			select {
+			case changes <- helper.ShareExpiryFromEvent(e):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return changes, nil
+}
+
+// Folder returns FolderResolver implementation.
+func (r *Resolver) Folder() FolderResolver { return &folderResolver{r} }
+
 // Mutation returns MutationResolver implementation.
 func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
 
+// Note returns NoteResolver implementation.
+func (r *Resolver) Note() NoteResolver { return &noteResolver{r} }
+
 // Query returns QueryResolver implementation.
 func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
 
+// Subscription returns SubscriptionResolver implementation.
+func (r *Resolver) Subscription() SubscriptionResolver { return &subscriptionResolver{r} }
+
+type folderResolver struct{ *Resolver }
 type mutationResolver struct{ *Resolver }
+type noteResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
-
-// !!! WARNING !!!
-// The code below was going to be deleted when updating resolvers. It has been copied here so you have
-// one last chance to move it out of harms way if you want. There are two reasons this happens:
-//  - When renaming or deleting a resolver the old code will be put in here. You can safely delete
-//    it when you're done.
-//  - You have helper methods in this file. Move them out to keep these resolver files clean.
-/*
-	func (r *mutationResolver) CreateTodo(ctx context.Context, input model.NewTodo) (*model.Todo, error) {
-	panic(fmt.Errorf("not implemented: CreateTodo - createTodo"))
-}
-func (r *queryResolver) Todos(ctx context.Context) ([]*model.Todo, error) {
-	panic(fmt.Errorf("not implemented: Todos - todos"))
-}
-*/
+type subscriptionResolver struct{ *Resolver }
@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	"go-training-system/internal/dto"
+	"go-training-system/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type ArchiveHandler struct {
+	archiveService service.ArchiveService
+}
+
+func NewArchiveHandler(archiveService service.ArchiveService) *ArchiveHandler {
+	return &ArchiveHandler{
+		archiveService: archiveService,
+	}
+}
+
+// ExportFolder streams a folder and everything below it as a zip archive of
+// Markdown notes.
+func (h *ArchiveHandler) ExportFolder(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid folder ID"})
+		return
+	}
+
+	uid, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	export, err := h.archiveService.ExportFolder(c.Request.Context(), id, uid)
+	if err != nil {
+		respondAssetError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": export.Name + ".zip"}))
+	c.Status(http.StatusOK)
+
+	// The archive is already on its way, so a failure can only cut it short;
+	// the missing central directory makes it unreadable rather than silently
+	// incomplete.
+	if err := export.Write(c.Request.Context(), c.Writer); err != nil {
+		_ = c.Error(err)
+		c.Abort()
+	}
+}
+
+// ImportArchive handles a multipart/form-data upload of an archive in the
+// "file" field. Query parameters: folder_id, the destination; on_conflict,
+// "skip" or "duplicate"; and dry_run=true to only report what would happen.
+func (h *ArchiveHandler) ImportArchive(c *gin.Context) {
+	req := dto.ImportRequest{
+		OnConflict: c.Query("on_conflict"),
+		DryRun:     c.Query("dry_run") == "true",
+	}
+	if value := c.Query("folder_id"); value != "" {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid folder_id"})
+			return
+		}
+		req.FolderID = &id
+	}
+
+	uid, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "request must be multipart/form-data"})
+		return
+	}
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "file field is required"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if part.FormName() != "file" {
+			continue
+		}
+
+		report, err := h.archiveService.ImportArchive(c.Request.Context(), part, &req, uid)
+		if err != nil {
+			respondAssetError(c, err)
+			return
+		}
+
+		status := http.StatusCreated
+		if report.DryRun {
+			status = http.StatusOK
+		}
+		c.JSON(status, report)
+		return
+	}
+}
@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-training-system/internal/dto"
+	"go-training-system/internal/graph/apperror"
+	"go-training-system/pkg/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// requirePrincipal returns the authenticated user's ID for the folder and
+// note handlers, writing a 401 when the request carries none.
+func requirePrincipal(c *gin.Context) (uuid.UUID, bool) {
+	principal, err := middleware.GetPrincipal(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return uuid.Nil, false
+	}
+	return principal.UserID, true
+}
+
+// setETag sets the ETag header to a folder or note version.
+func setETag(c *gin.Context, version int64) {
+	c.Header("ETag", strconv.Quote(strconv.FormatInt(version, 10)))
+}
+
+// requireIfMatch returns the version a write is conditional on, taken from
+// the If-Match header. It writes a 428 when the header is missing and a 400
+// when it is not a single version ETag. "*" matches any version and yields 0.
+func requireIfMatch(c *gin.Context) (int64, bool) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" {
+		c.JSON(http.StatusPreconditionRequired, gin.H{"error": "If-Match header is required"})
+		return 0, false
+	}
+	if header == "*" {
+		return 0, true
+	}
+	version, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(header, "W/"), `"`), 10, 64)
+	if err != nil || version <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid If-Match header"})
+		return 0, false
+	}
+	return version, true
+}
+
+// listRequest reads the paging, sorting and filter options of the folder and
+// note lists from the query string, writing a 400 when one is malformed.
+// Unknown sort and scope values are left to the service to reject.
+func listRequest(c *gin.Context) (*dto.ListRequest, bool) {
+	req := &dto.ListRequest{
+		Cursor: c.Query("cursor"),
+		Sort:   c.Query("sort"),
+		Order:  c.Query("order"),
+		Scope:  c.Query("scope"),
+		Tags:   c.Query("tags"),
+	}
+
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return nil, false
+		}
+		req.Limit = limit
+	}
+	if value := c.Query("folder_id"); value != "" {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid folder_id"})
+			return nil, false
+		}
+		req.FolderID = &id
+	}
+	if value := c.Query("updated_since"); value != "" {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "updated_since must be an RFC 3339 timestamp"})
+			return nil, false
+		}
+		req.UpdatedSince = &t
+	}
+	return req, true
+}
+
+// respondAssetError maps folder and note service errors to HTTP statuses.
+// Version conflicts answer 412 with the current version, so clients can
+// refetch and merge.
+func respondAssetError(c *gin.Context, err error) {
+	var conflict *apperror.VersionConflictError
+	if errors.As(err, &conflict) {
+		setETag(c, conflict.Current)
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error(), "current_version": conflict.Current})
+		return
+	}
+
+	switch {
+	case errors.Is(err, apperror.ErrLinkPassword):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, apperror.ErrAccessDenied):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, apperror.ErrFolderNotFound),
+		errors.Is(err, apperror.ErrNoteNotFound),
+		errors.Is(err, apperror.ErrUserNotFound),
+		errors.Is(err, apperror.ErrTeamNotFound),
+		errors.Is(err, apperror.ErrShareNotFound),
+		errors.Is(err, apperror.ErrTeamShareNotFound),
+		errors.Is(err, apperror.ErrRevisionNotFound),
+		errors.Is(err, apperror.ErrTagNotFound),
+		errors.Is(err, apperror.ErrAttachmentNotFound),
+		errors.Is(err, apperror.ErrTemplateNotFound),
+		errors.Is(err, apperror.ErrCommentNotFound),
+		errors.Is(err, apperror.ErrShareLinkNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, apperror.ErrSelfShare),
+		errors.Is(err, apperror.ErrShareWithOwner),
+		errors.Is(err, apperror.ErrInvalidAccessLevel),
+		errors.Is(err, apperror.ErrInvalidShareAccess),
+		errors.Is(err, apperror.ErrInvalidShareExpiry),
+		errors.Is(err, apperror.ErrExpiryNotSupported),
+		errors.Is(err, apperror.ErrInvalidTimestamp),
+		errors.Is(err, apperror.ErrInvalidOverride),
+		errors.Is(err, apperror.ErrEmptySearch),
+		errors.Is(err, apperror.ErrInvalidCursor),
+		errors.Is(err, apperror.ErrInvalidListSort),
+		errors.Is(err, apperror.ErrInvalidListScope),
+		errors.Is(err, apperror.ErrInvalidTagName),
+		errors.Is(err, apperror.ErrInvalidTagFilter),
+		errors.Is(err, apperror.ErrTagScopeMismatch),
+		errors.Is(err, apperror.ErrEmptyAttachment),
+		errors.Is(err, apperror.ErrInvalidNoteSelection),
+		errors.Is(err, apperror.ErrInvalidArchive),
+		errors.Is(err, apperror.ErrInvalidConflictMode),
+		errors.Is(err, apperror.ErrInvalidTemplate),
+		errors.Is(err, apperror.ErrTemplateValueMissing),
+		errors.Is(err, apperror.ErrInvalidTimeZone),
+		errors.Is(err, apperror.ErrInvalidComment),
+		errors.Is(err, apperror.ErrInvalidMention),
+		errors.Is(err, apperror.ErrInvalidLinkPassword),
+		errors.Is(err, apperror.ErrInvalidLinkExpiry):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, apperror.ErrFolderCycle),
+		errors.Is(err, apperror.ErrFolderNotEmpty),
+		errors.Is(err, apperror.ErrParentInTrash),
+		errors.Is(err, apperror.ErrTagExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, apperror.ErrAttachmentTooLarge),
+		errors.Is(err, apperror.ErrArchiveTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, apperror.ErrTooManyRequests):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"go-training-system/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// inlineContentTypes are the attachment types browsers may display in the
+// page. Everything else is served as a download, so an uploaded HTML or SVG
+// file can never run script in the API's origin.
+var inlineContentTypes = map[string]bool{
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+}
+
+type AttachmentHandler struct {
+	attachmentService service.AttachmentService
+}
+
+func NewAttachmentHandler(attachmentService service.AttachmentService) *AttachmentHandler {
+	return &AttachmentHandler{
+		attachmentService: attachmentService,
+	}
+}
+
+// UploadAttachment handles a multipart/form-data upload with the file in the
+// "file" field. The file is streamed straight to storage rather than
+// buffered by the form parser.
+func (h *AttachmentHandler) UploadAttachment(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid note ID"})
+		return
+	}
+
+	uid, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "request must be multipart/form-data"})
+		return
+	}
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "file field is required"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if part.FormName() != "file" {
+			continue
+		}
+
+		attachment, err := h.attachmentService.UploadAttachment(c.Request.Context(), id, part.FileName(), part, uid)
+		if err != nil {
+			respondAssetError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, attachment)
+		return
+	}
+}
+
+func (h *AttachmentHandler) ListAttachments(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid note ID"})
+		return
+	}
+
+	uid, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	attachments, err := h.attachmentService.ListAttachments(c.Request.Context(), id, uid)
+	if err != nil {
+		respondAssetError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, attachments)
+}
+
+// DownloadAttachment serves an attachment's content. It honours Range and
+// If-Range requests, and uses the content hash as a strong ETag.
+func (h *AttachmentHandler) DownloadAttachment(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid note ID"})
+		return
+	}
+
+	attachmentID, err := uuid.Parse(c.Param("attachment_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid attachment ID"})
+		return
+	}
+
+	uid, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	attachment, content, err := h.attachmentService.OpenAttachment(c.Request.Context(), id, attachmentID, uid)
+	if err != nil {
+		respondAssetError(c, err)
+		return
+	}
+	defer content.Close()
+
+	disposition := "attachment"
+	if inlineContentTypes[attachment.ContentType] {
+		disposition = "inline"
+	}
+	c.Header("Content-Type", attachment.ContentType)
+	c.Header("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.FileName}))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("ETag", strconv.Quote(attachment.SHA256))
+	c.Header("Cache-Control", "private, max-age=0, must-revalidate")
+
+	http.ServeContent(c.Writer, c.Request, attachment.FileName, attachment.CreatedAt, content)
+}
+
+func (h *AttachmentHandler) DeleteAttachment(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid note ID"})
+		return
+	}
+
+	attachmentID, err := uuid.Parse(c.Param("attachment_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid attachment ID"})
+		return
+	}
+
+	uid, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	if err := h.attachmentService.DeleteAttachment(c.Request.Context(), id, attachmentID, uid); err != nil {
+		respondAssetError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go-training-system/internal/dto"
+	"go-training-system/internal/graph/apperror"
+	"go-training-system/internal/service"
+	"go-training-system/pkg/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	collabMaxMessage  = 1 << 20
+	collabJoinTimeout = 10 * time.Second
+	collabWriteWait   = 10 * time.Second
+	collabPongWait    = 60 * time.Second
+	collabPingPeriod  = collabPongWait * 9 / 10
+)
+
+type CollabHandler struct {
+	collabService service.CollabService
+	jwtSecret     string
+	upgrader      websocket.Upgrader
+}
+
+func NewCollabHandler(collabService service.CollabService, jwtSecret string) *CollabHandler {
+	return &CollabHandler{
+		collabService: collabService,
+		jwtSecret:     jwtSecret,
+		upgrader: websocket.Upgrader{
+			// Connect takes the caller from the Authorization header, which
+			// only non-browser clients can set on a websocket, or else from
+			// the token in the join message. A page on another origin has
+			// neither unless it already holds the token, so any origin may
+			// connect
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+	}
+}
+
+// Connect upgrades to a websocket for editing the note's body together with
+// others. Browsers cannot set headers on websockets, so the first message,
+// join, may carry the access token instead of the Authorization header.
+func (h *CollabHandler) Connect(c *gin.Context) {
+	noteID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid note ID"})
+		return
+	}
+
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// The upgrader already answered
+		return
+	}
+	defer conn.Close()
+	conn.SetReadLimit(collabMaxMessage)
+
+	conn.SetReadDeadline(time.Now().Add(collabJoinTimeout))
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		return
+	}
+	var join dto.CollabClientMessage
+	if err := json.Unmarshal(data, &join); err != nil || join.Type != dto.CollabJoin {
+		writeCollabMessage(conn, service.CollabErrorMessage(fmt.Errorf("%w: expected join", apperror.ErrInvalidOperation)))
+		return
+	}
+	principal, err := middleware.GetPrincipal(c)
+	if err != nil {
+		principal, err = middleware.PrincipalFromToken(join.Token, h.jwtSecret)
+	}
+	if err != nil {
+		writeCollabMessage(conn, dto.CollabServerMessage{Type: dto.CollabError, Code: http.StatusUnauthorized, Message: err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	client, err := h.collabService.Join(ctx, noteID, principal.UserID, join.Revision)
+	if err != nil {
+		writeCollabMessage(conn, service.CollabErrorMessage(err))
+		return
+	}
+
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		writeCollabMessages(conn, client)
+	}()
+
+	conn.SetReadDeadline(time.Now().Add(collabPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(collabPongWait))
+	})
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			break
+		}
+		if err := handleCollabMessage(ctx, client, data); err != nil {
+			client.Disconnect(err)
+			break
+		}
+	}
+	client.Leave()
+	<-written
+}
+
+// handleCollabMessage passes an op or cursor message on to the session. A
+// failed operation has already disconnected the client.
+func handleCollabMessage(ctx context.Context, client *service.CollabClient, data []byte) error {
+	var msg dto.CollabClientMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return fmt.Errorf("%w: %v", apperror.ErrInvalidOperation, err)
+	}
+
+	switch msg.Type {
+	case dto.CollabOp:
+		if msg.Revision == nil {
+			return fmt.Errorf("%w: revision is required", apperror.ErrInvalidOperation)
+		}
+		client.Submit(ctx, *msg.Revision, msg.Operation)
+	case dto.CollabCursor:
+		if msg.Revision != nil {
+			client.MoveCursor(*msg.Revision, msg.Selection)
+		}
+	default:
+		return fmt.Errorf("%w: unknown message type %q", apperror.ErrInvalidOperation, msg.Type)
+	}
+	return nil
+}
+
+func writeCollabMessage(conn *websocket.Conn, msg dto.CollabServerMessage) error {
+	conn.SetWriteDeadline(time.Now().Add(collabWriteWait))
+	return conn.WriteJSON(msg)
+}
+
+// writeCollabMessages sends the client's messages and keeps the connection
+// alive until the client is disconnected, then closes the connection.
+func writeCollabMessages(conn *websocket.Conn, client *service.CollabClient) {
+	defer conn.Close()
+	ping := time.NewTicker(collabPingPeriod)
+	defer ping.Stop()
+
+	for {
+		select {
+		case msg, ok := <-client.Messages():
+			if !ok {
+				conn.SetWriteDeadline(time.Now().Add(collabWriteWait))
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+				return
+			}
+			if err := writeCollabMessage(conn, msg); err != nil {
+				client.Leave()
+				return
+			}
+		case <-ping.C:
+			conn.SetWriteDeadline(time.Now().Add(collabWriteWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				client.Leave()
+				return
+			}
+		}
+	}
+}
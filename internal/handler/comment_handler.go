@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"net/http"
+
+	"go-training-system/internal/dto"
+	"go-training-system/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type CommentHandler struct {
+	commentService service.CommentService
+}
+
+func NewCommentHandler(commentService service.CommentService) *CommentHandler {
+	return &CommentHandler{
+		commentService: commentService,
+	}
+}
+
+func (h *CommentHandler) ListComments(c *gin.Context) {
+	noteID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid note ID"})
+		return
+	}
+
+	uid, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	comments, err := h.commentService.ListComments(c.Request.Context(), noteID, uid)
+	if err != nil {
+		respondAssetError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, comments)
+}
+
+func (h *CommentHandler) CreateComment(c *gin.Context) {
+	noteID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid note ID"})
+		return
+	}
+
+	var req dto.CreateCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	uid, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	comment, err := h.commentService.CreateComment(c.Request.Context(), noteID, &req, uid)
+	if err != nil {
+		respondAssetError(c, err)
+		return
+	}
+
+	setETag(c, comment.Version)
+	c.JSON(http.StatusCreated, comment)
+}
+
+func (h *CommentHandler) UpdateComment(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid comment ID"})
+		return
+	}
+
+	var req dto.UpdateCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	uid, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	version, ok := requireIfMatch(c)
+	if !ok {
+		return
+	}
+
+	comment, err := h.commentService.UpdateComment(c.Request.Context(), id, &req, uid, version)
+	if err != nil {
+		respondAssetError(c, err)
+		return
+	}
+
+	setETag(c, comment.Version)
+	c.JSON(http.StatusOK, comment)
+}
+
+// ResolveComment resolves a thread, or reopens it with resolved=false.
+func (h *CommentHandler) ResolveComment(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid comment ID"})
+		return
+	}
+
+	var req dto.ResolveCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	uid, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	comment, err := h.commentService.ResolveComment(c.Request.Context(), id, &req, uid)
+	if err != nil {
+		respondAssetError(c, err)
+		return
+	}
+
+	setETag(c, comment.Version)
+	c.JSON(http.StatusOK, comment)
+}
+
+// DeleteComment deletes a comment, and the whole thread when it is the
+// thread's first comment.
+func (h *CommentHandler) DeleteComment(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid comment ID"})
+		return
+	}
+
+	uid, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	version, ok := requireIfMatch(c)
+	if !ok {
+		return
+	}
+
+	if err := h.commentService.DeleteComment(c.Request.Context(), id, uid, version); err != nil {
+		respondAssetError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
@@ -27,25 +27,18 @@ func (h *FolderHandler) CreateFolder(c *gin.Context) {
 		return
 	}
 
-	// Get user ID from context (assuming you have auth middleware)
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
-		return
-	}
-
-	ownerID, ok := userID.(uuid.UUID)
+	ownerID, ok := requirePrincipal(c)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user ID"})
 		return
 	}
 
 	folder, err := h.folderService.CreateFolder(c.Request.Context(), &req, ownerID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondAssetError(c, err)
 		return
 	}
 
+	setETag(c, folder.Version)
 	c.JSON(http.StatusCreated, folder)
 }
 
@@ -57,51 +50,41 @@ func (h *FolderHandler) GetFolder(c *gin.Context) {
 		return
 	}
 
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
-		return
-	}
-
-	uid, ok := userID.(uuid.UUID)
+	uid, ok := requirePrincipal(c)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user ID"})
 		return
 	}
 
 	folder, err := h.folderService.GetFolder(c.Request.Context(), id, uid)
 	if err != nil {
-		if err.Error() == "access denied" {
-			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondAssetError(c, err)
 		return
 	}
 
+	setETag(c, folder.Version)
 	c.JSON(http.StatusOK, folder)
 }
 
+// GetUserFolders lists the caller's folders a page at a time. See
+// listRequest for the options.
 func (h *FolderHandler) GetUserFolders(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+	req, ok := listRequest(c)
+	if !ok {
 		return
 	}
 
-	uid, ok := userID.(uuid.UUID)
+	uid, ok := requirePrincipal(c)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user ID"})
 		return
 	}
 
-	folders, err := h.folderService.GetUserFolders(c.Request.Context(), uid)
+	page, err := h.folderService.GetUserFolders(c.Request.Context(), req, uid)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondAssetError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, folders)
+	c.JSON(http.StatusOK, page)
 }
 
 func (h *FolderHandler) UpdateFolder(c *gin.Context) {
@@ -118,31 +101,28 @@ func (h *FolderHandler) UpdateFolder(c *gin.Context) {
 		return
 	}
 
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+	uid, ok := requirePrincipal(c)
+	if !ok {
 		return
 	}
 
-	uid, ok := userID.(uuid.UUID)
+	version, ok := requireIfMatch(c)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user ID"})
 		return
 	}
 
-	folder, err := h.folderService.UpdateFolder(c.Request.Context(), id, &req, uid)
+	folder, err := h.folderService.UpdateFolder(c.Request.Context(), id, &req, uid, version)
 	if err != nil {
-		if err.Error() == "access denied" {
-			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondAssetError(c, err)
 		return
 	}
 
+	setETag(c, folder.Version)
 	c.JSON(http.StatusOK, folder)
 }
 
+// DeleteFolder soft deletes an empty folder. Pass recursive=true to delete
+// its subfolders and notes along with it.
 func (h *FolderHandler) DeleteFolder(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := uuid.Parse(idParam)
@@ -151,31 +131,144 @@ func (h *FolderHandler) DeleteFolder(c *gin.Context) {
 		return
 	}
 
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+	uid, ok := requirePrincipal(c)
+	if !ok {
 		return
 	}
 
-	uid, ok := userID.(uuid.UUID)
+	version, ok := requireIfMatch(c)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user ID"})
 		return
 	}
 
-	err = h.folderService.DeleteFolder(c.Request.Context(), id, uid)
+	recursive := c.Query("recursive") == "true"
+	err = h.folderService.DeleteFolder(c.Request.Context(), id, uid, recursive, version)
 	if err != nil {
-		if err.Error() == "access denied" {
-			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondAssetError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusNoContent, nil)
 }
 
+func (h *FolderHandler) GetFolderChildren(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid folder ID"})
+		return
+	}
+
+	uid, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	children, err := h.folderService.GetFolderChildren(c.Request.Context(), id, uid)
+	if err != nil {
+		respondAssetError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, children)
+}
+
+func (h *FolderHandler) GetFolderTree(c *gin.Context) {
+	uid, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	tree, err := h.folderService.GetFolderTree(c.Request.Context(), uid)
+	if err != nil {
+		respondAssetError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tree)
+}
+
+func (h *FolderHandler) GetFolderPath(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid folder ID"})
+		return
+	}
+
+	uid, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	path, err := h.folderService.GetFolderPath(c.Request.Context(), id, uid)
+	if err != nil {
+		respondAssetError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, path)
+}
+
+func (h *FolderHandler) MoveFolder(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid folder ID"})
+		return
+	}
+
+	var req dto.MoveFolderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	uid, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	version, ok := requireIfMatch(c)
+	if !ok {
+		return
+	}
+
+	folder, err := h.folderService.MoveFolder(c.Request.Context(), id, &req, uid, version)
+	if err != nil {
+		respondAssetError(c, err)
+		return
+	}
+
+	setETag(c, folder.Version)
+	c.JSON(http.StatusOK, folder)
+}
+
+func (h *FolderHandler) CopyFolder(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid folder ID"})
+		return
+	}
+
+	var req dto.CopyFolderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	uid, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	folder, err := h.folderService.CopyFolder(c.Request.Context(), id, &req, uid)
+	if err != nil {
+		respondAssetError(c, err)
+		return
+	}
+
+	setETag(c, folder.Version)
+	c.JSON(http.StatusCreated, folder)
+}
+
 func (h *FolderHandler) ShareFolder(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := uuid.Parse(idParam)
@@ -190,27 +283,168 @@ func (h *FolderHandler) ShareFolder(c *gin.Context) {
 		return
 	}
 
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+	uid, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	err = h.folderService.ShareFolder(c.Request.Context(), id, &req, uid)
+	if err != nil {
+		respondAssetError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "folder shared successfully"})
+}
+
+func (h *FolderHandler) GetFolderShares(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid folder ID"})
+		return
+	}
+
+	uid, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	shares, err := h.folderService.GetFolderShares(c.Request.Context(), id, uid)
+	if err != nil {
+		respondAssetError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, shares)
+}
+
+func (h *FolderHandler) UpdateFolderShare(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid folder ID"})
+		return
+	}
+
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	var req dto.UpdateShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	uid, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	if err := h.folderService.UpdateFolderShare(c.Request.Context(), id, userID, &req, uid); err != nil {
+		respondAssetError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "share updated successfully"})
+}
+
+func (h *FolderHandler) RevokeFolderShare(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid folder ID"})
 		return
 	}
 
-	uid, ok := userID.(uuid.UUID)
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	uid, ok := requirePrincipal(c)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user ID"})
 		return
 	}
 
-	err = h.folderService.ShareFolder(c.Request.Context(), id, &req, uid)
+	if err := h.folderService.RevokeFolderShare(c.Request.Context(), id, userID, uid); err != nil {
+		respondAssetError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+func (h *FolderHandler) ShareFolderWithTeam(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		if err.Error() == "access denied" {
-			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid folder ID"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "folder shared successfully"})
+	var req dto.TeamShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	uid, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	if err := h.folderService.ShareFolderWithTeam(c.Request.Context(), id, &req, uid); err != nil {
+		respondAssetError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "folder shared with team successfully"})
+}
+
+func (h *FolderHandler) GetFolderTeamShares(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid folder ID"})
+		return
+	}
+
+	uid, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	shares, err := h.folderService.GetFolderTeamShares(c.Request.Context(), id, uid)
+	if err != nil {
+		respondAssetError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, shares)
+}
+
+func (h *FolderHandler) RevokeFolderTeamShare(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid folder ID"})
+		return
+	}
+
+	teamID, err := uuid.Parse(c.Param("team_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid team ID"})
+		return
+	}
+
+	uid, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	if err := h.folderService.RevokeFolderTeamShare(c.Request.Context(), id, teamID, uid); err != nil {
+		respondAssetError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
 }
@@ -2,6 +2,8 @@ package handler
 
 import (
 	"net/http"
+	"strconv"
+	"time"
 
 	"go-training-system/internal/dto"
 	"go-training-system/internal/service"
@@ -27,24 +29,26 @@ func (h *NoteHandler) CreateNote(c *gin.Context) {
 		return
 	}
 
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+	asHTML, ok := htmlFormat(c)
+	if !ok {
 		return
 	}
 
-	ownerID, ok := userID.(uuid.UUID)
+	ownerID, ok := requirePrincipal(c)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user ID"})
 		return
 	}
 
 	note, err := h.noteService.CreateNote(c.Request.Context(), &req, ownerID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondAssetError(c, err)
 		return
 	}
 
+	if asHTML {
+		h.renderHTML(note)
+	}
+	setETag(c, note.Version)
 	c.JSON(http.StatusCreated, note)
 }
 
@@ -56,83 +60,93 @@ func (h *NoteHandler) GetNote(c *gin.Context) {
 		return
 	}
 
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+	asHTML, ok := htmlFormat(c)
+	if !ok {
 		return
 	}
 
-	uid, ok := userID.(uuid.UUID)
+	uid, ok := requirePrincipal(c)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user ID"})
 		return
 	}
 
 	note, err := h.noteService.GetNote(c.Request.Context(), id, uid)
 	if err != nil {
-		if err.Error() == "access denied" {
-			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondAssetError(c, err)
 		return
 	}
 
+	if asHTML {
+		h.renderHTML(note)
+	}
+	setETag(c, note.Version)
 	c.JSON(http.StatusOK, note)
 }
 
+// GetUserNotes lists the caller's notes a page at a time. See listRequest
+// for the options; ?tags= filters them with a tag expression such as
+// "go AND (testing OR review)".
 func (h *NoteHandler) GetUserNotes(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+	asHTML, ok := htmlFormat(c)
+	if !ok {
+		return
+	}
+
+	req, ok := listRequest(c)
+	if !ok {
 		return
 	}
 
-	uid, ok := userID.(uuid.UUID)
+	uid, ok := requirePrincipal(c)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user ID"})
 		return
 	}
 
-	notes, err := h.noteService.GetUserNotes(c.Request.Context(), uid)
+	page, err := h.noteService.GetUserNotes(c.Request.Context(), req, uid)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondAssetError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, notes)
+	if asHTML {
+		for i := range page.Items {
+			h.renderHTML(&page.Items[i])
+		}
+	}
+
+	c.JSON(http.StatusOK, page)
 }
 
 func (h *NoteHandler) GetFolderNotes(c *gin.Context) {
-	folderIDParam := c.Param("folder_id")
+	folderIDParam := c.Param("id")
 	folderID, err := uuid.Parse(folderIDParam)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid folder ID"})
 		return
 	}
 
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+	asHTML, ok := htmlFormat(c)
+	if !ok {
 		return
 	}
 
-	uid, ok := userID.(uuid.UUID)
+	uid, ok := requirePrincipal(c)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user ID"})
 		return
 	}
 
-	notes, err := h.noteService.GetFolderNotes(c.Request.Context(), folderID, uid)
+	notes, err := h.noteService.GetFolderNotes(c.Request.Context(), folderID, uid, c.Query("tags"))
 	if err != nil {
-		if err.Error() == "access denied to folder" {
-			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondAssetError(c, err)
 		return
 	}
 
+	if asHTML {
+		for i := range notes {
+			h.renderHTML(&notes[i])
+		}
+	}
+
 	c.JSON(http.StatusOK, notes)
 }
 
@@ -150,28 +164,31 @@ func (h *NoteHandler) UpdateNote(c *gin.Context) {
 		return
 	}
 
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+	asHTML, ok := htmlFormat(c)
+	if !ok {
 		return
 	}
 
-	uid, ok := userID.(uuid.UUID)
+	uid, ok := requirePrincipal(c)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user ID"})
 		return
 	}
 
-	note, err := h.noteService.UpdateNote(c.Request.Context(), id, &req, uid)
+	version, ok := requireIfMatch(c)
+	if !ok {
+		return
+	}
+
+	note, err := h.noteService.UpdateNote(c.Request.Context(), id, &req, uid, version)
 	if err != nil {
-		if err.Error() == "write access denied" {
-			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondAssetError(c, err)
 		return
 	}
 
+	if asHTML {
+		h.renderHTML(note)
+	}
+	setETag(c, note.Version)
 	c.JSON(http.StatusOK, note)
 }
 
@@ -183,31 +200,67 @@ func (h *NoteHandler) DeleteNote(c *gin.Context) {
 		return
 	}
 
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+	uid, ok := requirePrincipal(c)
+	if !ok {
 		return
 	}
 
-	uid, ok := userID.(uuid.UUID)
+	version, ok := requireIfMatch(c)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user ID"})
 		return
 	}
 
-	err = h.noteService.DeleteNote(c.Request.Context(), id, uid)
+	err = h.noteService.DeleteNote(c.Request.Context(), id, uid, version)
 	if err != nil {
-		if err.Error() == "access denied" {
-			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondAssetError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusNoContent, nil)
 }
 
+func (h *NoteHandler) MoveNotes(c *gin.Context) {
+	var req dto.MoveNotesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	uid, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	notes, err := h.noteService.MoveNotes(c.Request.Context(), &req, uid)
+	if err != nil {
+		respondAssetError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, notes)
+}
+
+func (h *NoteHandler) CopyNotes(c *gin.Context) {
+	var req dto.CopyNotesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	uid, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	notes, err := h.noteService.CopyNotes(c.Request.Context(), &req, uid)
+	if err != nil {
+		respondAssetError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, notes)
+}
+
 func (h *NoteHandler) ShareNote(c *gin.Context) {
 	idParam := c.Param("id")
 	id, err := uuid.Parse(idParam)
@@ -222,27 +275,366 @@ func (h *NoteHandler) ShareNote(c *gin.Context) {
 		return
 	}
 
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+	uid, ok := requirePrincipal(c)
+	if !ok {
 		return
 	}
 
-	uid, ok := userID.(uuid.UUID)
+	err = h.noteService.ShareNote(c.Request.Context(), id, &req, uid)
+	if err != nil {
+		respondAssetError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "note shared successfully"})
+}
+
+func (h *NoteHandler) GetNoteShares(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid note ID"})
+		return
+	}
+
+	uid, ok := requirePrincipal(c)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid user ID"})
 		return
 	}
 
-	err = h.noteService.ShareNote(c.Request.Context(), id, &req, uid)
+	shares, err := h.noteService.GetNoteShares(c.Request.Context(), id, uid)
+	if err != nil {
+		respondAssetError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, shares)
+}
+
+func (h *NoteHandler) UpdateNoteShare(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid note ID"})
+		return
+	}
+
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	var req dto.UpdateShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	uid, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	if err := h.noteService.UpdateNoteShare(c.Request.Context(), id, userID, &req, uid); err != nil {
+		respondAssetError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "share updated successfully"})
+}
+
+func (h *NoteHandler) RevokeNoteShare(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid note ID"})
+		return
+	}
+
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	uid, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	if err := h.noteService.RevokeNoteShare(c.Request.Context(), id, userID, uid); err != nil {
+		respondAssetError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+func (h *NoteHandler) ShareNoteWithTeam(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid note ID"})
+		return
+	}
+
+	var req dto.TeamShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	uid, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	if err := h.noteService.ShareNoteWithTeam(c.Request.Context(), id, &req, uid); err != nil {
+		respondAssetError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "note shared with team successfully"})
+}
+
+func (h *NoteHandler) GetNoteTeamShares(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid note ID"})
+		return
+	}
+
+	uid, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	shares, err := h.noteService.GetNoteTeamShares(c.Request.Context(), id, uid)
 	if err != nil {
-		if err.Error() == "access denied" {
-			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
-			return
+		respondAssetError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, shares)
+}
+
+func (h *NoteHandler) RevokeNoteTeamShare(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid note ID"})
+		return
+	}
+
+	teamID, err := uuid.Parse(c.Param("team_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid team ID"})
+		return
+	}
+
+	uid, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	if err := h.noteService.RevokeNoteTeamShare(c.Request.Context(), id, teamID, uid); err != nil {
+		respondAssetError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusNoContent, nil)
+}
+
+func (h *NoteHandler) ListNoteRevisions(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid note ID"})
+		return
+	}
+
+	uid, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	revisions, err := h.noteService.ListNoteRevisions(c.Request.Context(), id, uid)
+	if err != nil {
+		respondAssetError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, revisions)
+}
+
+func (h *NoteHandler) GetNoteRevision(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid note ID"})
+		return
+	}
+
+	revision, err := strconv.Atoi(c.Param("revision"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid revision"})
+		return
+	}
+
+	uid, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	rev, err := h.noteService.GetNoteRevision(c.Request.Context(), id, revision, uid)
+	if err != nil {
+		respondAssetError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rev)
+}
+
+// DiffNoteRevisions handles GET /notes/:id/revisions/diff?from=&to=.
+func (h *NoteHandler) DiffNoteRevisions(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid note ID"})
+		return
+	}
+
+	from, err := strconv.Atoi(c.Query("from"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from revision"})
+		return
+	}
+	to, err := strconv.Atoi(c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to revision"})
+		return
+	}
+
+	uid, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	diff, err := h.noteService.DiffNoteRevisions(c.Request.Context(), id, from, to, uid)
+	if err != nil {
+		respondAssetError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, diff)
+}
+
+func (h *NoteHandler) RestoreNoteRevision(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid note ID"})
+		return
+	}
+
+	revision, err := strconv.Atoi(c.Param("revision"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid revision"})
+		return
+	}
+
+	asHTML, ok := htmlFormat(c)
+	if !ok {
+		return
+	}
+
+	uid, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	note, err := h.noteService.RestoreNoteRevision(c.Request.Context(), id, revision, uid)
+	if err != nil {
+		respondAssetError(c, err)
+		return
+	}
+
+	if asHTML {
+		h.renderHTML(note)
+	}
+	setETag(c, note.Version)
+	c.JSON(http.StatusOK, note)
+}
+
+// SearchNotes handles GET /notes/search?q=. It also takes folder_id,
+// owner_id, updated_from and updated_to (RFC 3339), a tags expression, limit
+// and offset.
+func (h *NoteHandler) SearchNotes(c *gin.Context) {
+	req := dto.NoteSearchRequest{Query: c.Query("q"), Tags: c.Query("tags")}
+
+	for param, target := range map[string]**uuid.UUID{
+		"folder_id": &req.FolderID,
+		"owner_id":  &req.OwnerID,
+	} {
+		if value := c.Query(param); value != "" {
+			id, err := uuid.Parse(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + param})
+				return
+			}
+			*target = &id
+		}
+	}
+
+	for param, target := range map[string]**time.Time{
+		"updated_from": &req.UpdatedFrom,
+		"updated_to":   &req.UpdatedTo,
+	} {
+		if value := c.Query(param); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": param + " must be an RFC 3339 timestamp"})
+				return
+			}
+			*target = &t
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+
+	for param, target := range map[string]*int{
+		"limit":  &req.Limit,
+		"offset": &req.Offset,
+	} {
+		if value := c.Query(param); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + param})
+				return
+			}
+			*target = n
+		}
+	}
+
+	uid, ok := requirePrincipal(c)
+	if !ok {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "note shared successfully"})
+	results, err := h.noteService.SearchNotes(c.Request.Context(), &req, uid)
+	if err != nil {
+		respondAssetError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+// htmlFormat reads the ?format= option of the note endpoints: "markdown", the
+// default, returns bodies as written and "html" also renders them to
+// sanitized HTML in body_html.
+func htmlFormat(c *gin.Context) (asHTML bool, ok bool) {
+	switch c.Query("format") {
+	case "", "markdown":
+		return false, true
+	case "html":
+		return true, true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be markdown or html"})
+		return false, false
+	}
+}
+
+func (h *NoteHandler) renderHTML(note *dto.NoteResponse) {
+	note.BodyHTML = h.noteService.RenderNoteHTML(note.ID, note.Version, note.Body)
 }
@@ -0,0 +1,200 @@
+package handler
+
+import (
+	"net/http"
+
+	"go-training-system/internal/dto"
+	"go-training-system/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// linkPasswordHeader carries a public link's password, which is kept out of
+// the URL so it does not end up in logs and browser history.
+const linkPasswordHeader = "X-Link-Password"
+
+type ShareLinkHandler struct {
+	shareLinkService service.ShareLinkService
+}
+
+func NewShareLinkHandler(shareLinkService service.ShareLinkService) *ShareLinkHandler {
+	return &ShareLinkHandler{
+		shareLinkService: shareLinkService,
+	}
+}
+
+func (h *ShareLinkHandler) CreateFolderLink(c *gin.Context) {
+	folderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid folder ID"})
+		return
+	}
+
+	var req dto.CreateShareLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	uid, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	link, err := h.shareLinkService.CreateFolderLink(c.Request.Context(), folderID, &req, uid)
+	if err != nil {
+		respondAssetError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, link)
+}
+
+func (h *ShareLinkHandler) GetFolderLinks(c *gin.Context) {
+	folderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid folder ID"})
+		return
+	}
+
+	uid, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	links, err := h.shareLinkService.GetFolderLinks(c.Request.Context(), folderID, uid)
+	if err != nil {
+		respondAssetError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, links)
+}
+
+func (h *ShareLinkHandler) CreateNoteLink(c *gin.Context) {
+	noteID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid note ID"})
+		return
+	}
+
+	var req dto.CreateShareLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	uid, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	link, err := h.shareLinkService.CreateNoteLink(c.Request.Context(), noteID, &req, uid)
+	if err != nil {
+		respondAssetError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, link)
+}
+
+func (h *ShareLinkHandler) GetNoteLinks(c *gin.Context) {
+	noteID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid note ID"})
+		return
+	}
+
+	uid, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	links, err := h.shareLinkService.GetNoteLinks(c.Request.Context(), noteID, uid)
+	if err != nil {
+		respondAssetError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, links)
+}
+
+func (h *ShareLinkHandler) RevokeLink(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid link ID"})
+		return
+	}
+
+	uid, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	if err := h.shareLinkService.RevokeLink(c.Request.Context(), id, uid); err != nil {
+		respondAssetError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "link revoked successfully"})
+}
+
+// OpenLink serves what a public link points at. It needs no account.
+func (h *ShareLinkHandler) OpenLink(c *gin.Context) {
+	response, err := h.shareLinkService.OpenLink(c.Request.Context(), publicLinkRequest(c))
+	if err != nil {
+		respondAssetError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// OpenLinkFolder serves a folder inside a public folder link.
+func (h *ShareLinkHandler) OpenLinkFolder(c *gin.Context) {
+	folderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid folder ID"})
+		return
+	}
+
+	folder, err := h.shareLinkService.OpenLinkFolder(c.Request.Context(), publicLinkRequest(c), folderID)
+	if err != nil {
+		respondAssetError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, folder)
+}
+
+// OpenLinkNote serves a note inside a public folder link, or a note link's
+// note.
+func (h *ShareLinkHandler) OpenLinkNote(c *gin.Context) {
+	noteID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid note ID"})
+		return
+	}
+
+	note, err := h.shareLinkService.OpenLinkNote(c.Request.Context(), publicLinkRequest(c), noteID)
+	if err != nil {
+		respondAssetError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, note)
+}
+
+// publicLinkRequest reads the link token and password of a public request.
+// The token is in the URL, so pages must not pass it on as a referrer, and
+// shared caches must not keep what it opens.
+func publicLinkRequest(c *gin.Context) *dto.PublicLinkRequest {
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Header("Cache-Control", "no-store")
+	return &dto.PublicLinkRequest{
+		Token:    c.Param("token"),
+		Password: c.GetHeader(linkPasswordHeader),
+		Client:   c.ClientIP(),
+	}
+}
@@ -1,10 +1,16 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
+	"time"
 
 	"go-training-system/internal/dto"
+	"go-training-system/internal/graph/apperror"
+	"go-training-system/internal/graph/constant"
 	"go-training-system/internal/service"
+	"go-training-system/pkg/helper"
+	"go-training-system/pkg/middleware"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -30,44 +36,20 @@ func (h *TeamHandler) CreateTeam(c *gin.Context) {
 		return
 	}
 
-	// Lấy userID từ context do middleware đã gán
-	userIDRaw, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"code":    "UNAUTHORIZED",
-			"success": false,
-			"message": "Unauthorized: userID not found in context",
-		})
-		return
+	if req.ParentID != "" {
+		if _, err := uuid.Parse(req.ParentID); err != nil {
+			helper.RespondError(c, http.StatusBadRequest, "INVALID_TEAM_ID", "Parent ID must be a valid UUID", nil)
+			return
+		}
 	}
 
-	userIDStr, ok := userIDRaw.(string)
+	createdBy, ok := currentUserID(c)
 	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code":    "INVALID_CONTEXT",
-			"success": false,
-			"message": "Invalid user ID format in context",
-		})
-		return
-	}
-
-	createdBy, err := uuid.Parse(userIDStr)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code":    "INVALID_UUID",
-			"success": false,
-			"message": "Cannot parse user ID to UUID",
-		})
 		return
 	}
 
 	if err := h.service.CreateTeam(c.Request.Context(), createdBy, &req); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code":    "CREATE_TEAM_FAILED",
-			"success": false,
-			"message": "Failed to create team",
-			"errors":  []string{err.Error()},
-		})
+		respondTeamError(c, err, "CREATE_TEAM_FAILED", "Failed to create team")
 		return
 	}
 
@@ -109,45 +91,14 @@ func (h *TeamHandler) AddMember(c *gin.Context) {
 		return
 	}
 
-	// Lấy user_id từ context để làm createdBy
-	userIDRaw, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"code":    "UNAUTHORIZED",
-			"success": false,
-			"message": "Unauthorized: userID not found in context",
-		})
-		return
-	}
-
-	userIDStr, ok := userIDRaw.(string)
+	createdBy, ok := currentUserID(c)
 	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code":    "INVALID_CONTEXT",
-			"success": false,
-			"message": "Invalid user ID format in context",
-		})
-		return
-	}
-
-	createdBy, err := uuid.Parse(userIDStr)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code":    "INVALID_UUID",
-			"success": false,
-			"message": "Cannot parse user ID to UUID",
-		})
 		return
 	}
 
-	err = h.service.AddMember(c.Request.Context(), teamID, memberID, createdBy)
+	err = h.service.AddMember(c.Request.Context(), teamID, memberID, createdBy, req.ExpiresAt)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code":    "ADD_MEMBER_FAILED",
-			"success": false,
-			"message": "Failed to add member",
-			"errors":  []string{err.Error()},
-		})
+		respondTeamError(c, err, "ADD_MEMBER_FAILED", "Failed to add member")
 		return
 	}
 	c.Status(http.StatusNoContent)
@@ -185,69 +136,315 @@ func (h *TeamHandler) AddManager(c *gin.Context) {
 		return
 	}
 
-	userIDRaw, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"code":    "UNAUTHORIZED",
-			"success": false,
-			"message": "Unauthorized: userID not found in context",
-		})
+	createdBy, ok := currentUserID(c)
+	if !ok {
 		return
 	}
 
-	userIDStr, ok := userIDRaw.(string)
-	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code":    "INVALID_CONTEXT",
-			"success": false,
-			"message": "Invalid user ID format in context",
-		})
+	err = h.service.AddManager(c.Request.Context(), teamID, managerID, createdBy, req.ExpiresAt)
+	if err != nil {
+		respondTeamError(c, err, "ADD_MANAGER_FAILED", "Failed to add manager")
 		return
 	}
+	c.Status(http.StatusNoContent)
+}
 
-	createdBy, err := uuid.Parse(userIDStr)
+func (h *TeamHandler) RemoveMember(c *gin.Context) {
+	teamID, ok := parseTeamID(c)
+	if !ok {
+		return
+	}
+	userID, err := uuid.Parse(c.Param("memberId"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code":    "INVALID_UUID",
-			"success": false,
-			"message": "Cannot parse user ID to UUID",
-		})
+		helper.RespondError(c, http.StatusBadRequest, "INVALID_USER_ID", "Member ID must be a valid UUID", nil)
 		return
 	}
 
-	err = h.service.AddManager(c.Request.Context(), teamID, managerID, createdBy)
+	removedBy, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.RemoveMember(c.Request.Context(), teamID, userID, removedBy); err != nil {
+		respondTeamError(c, err, "REMOVE_MEMBER_FAILED", "Failed to remove member")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *TeamHandler) RemoveManager(c *gin.Context) {
+	teamID, ok := parseTeamID(c)
+	if !ok {
+		return
+	}
+	userID, err := uuid.Parse(c.Param("managerId"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code":    "ADD_MANAGER_FAILED",
-			"success": false,
-			"message": "Failed to add manager",
-			"errors":  []string{err.Error()},
-		})
+		helper.RespondError(c, http.StatusBadRequest, "INVALID_USER_ID", "Manager ID must be a valid UUID", nil)
+		return
+	}
+
+	removedBy, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.RemoveManager(c.Request.Context(), teamID, userID, removedBy); err != nil {
+		respondTeamError(c, err, "REMOVE_MANAGER_FAILED", "Failed to remove manager")
 		return
 	}
 	c.Status(http.StatusNoContent)
 }
 
-func (h *TeamHandler) RemoveMember(c *gin.Context) {
-	teamID, _ := uuid.Parse(c.Param("teamId"))
-	userID, _ := uuid.Parse(c.Param("memberId"))
+// GetTeam returns a team and its members. Pass includeDescendants=true to
+// also list the members of every child team.
+func (h *TeamHandler) GetTeam(c *gin.Context) {
+	teamID, ok := parseTeamID(c)
+	if !ok {
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
 
-	err := h.service.RemoveMember(c.Request.Context(), teamID, userID)
+	includeDescendants := c.Query("includeDescendants") == "true"
+	team, err := h.service.GetTeam(c.Request.Context(), teamID, userID, includeDescendants)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to remove member"})
+		respondTeamError(c, err, "GET_TEAM_FAILED", "Failed to get team")
 		return
 	}
-	c.Status(http.StatusNoContent)
+
+	helper.RespondSuccess(c, http.StatusOK, "TEAM_FOUND", "Team retrieved successfully", team)
 }
 
-func (h *TeamHandler) RemoveManager(c *gin.Context) {
-	teamID, _ := uuid.Parse(c.Param("teamId"))
-	userID, _ := uuid.Parse(c.Param("managerId"))
+func (h *TeamHandler) GetAncestors(c *gin.Context) {
+	teamID, ok := parseTeamID(c)
+	if !ok {
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
 
-	err := h.service.RemoveManager(c.Request.Context(), teamID, userID)
+	teams, err := h.service.GetAncestors(c.Request.Context(), teamID, userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to remove manager"})
+		respondTeamError(c, err, "GET_ANCESTORS_FAILED", "Failed to get ancestor teams")
+		return
+	}
+
+	helper.RespondSuccess(c, http.StatusOK, "TEAMS_FOUND", "Ancestor teams retrieved successfully", teams)
+}
+
+func (h *TeamHandler) GetDescendants(c *gin.Context) {
+	teamID, ok := parseTeamID(c)
+	if !ok {
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	teams, err := h.service.GetDescendants(c.Request.Context(), teamID, userID)
+	if err != nil {
+		respondTeamError(c, err, "GET_DESCENDANTS_FAILED", "Failed to get descendant teams")
+		return
+	}
+
+	helper.RespondSuccess(c, http.StatusOK, "TEAMS_FOUND", "Descendant teams retrieved successfully", teams)
+}
+
+func (h *TeamHandler) GetSubtree(c *gin.Context) {
+	teamID, ok := parseTeamID(c)
+	if !ok {
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	tree, err := h.service.GetSubtree(c.Request.Context(), teamID, userID)
+	if err != nil {
+		respondTeamError(c, err, "GET_SUBTREE_FAILED", "Failed to get team subtree")
+		return
+	}
+
+	helper.RespondSuccess(c, http.StatusOK, "TEAM_TREE_FOUND", "Team subtree retrieved successfully", tree)
+}
+
+func (h *TeamHandler) MoveTeam(c *gin.Context) {
+	teamID, ok := parseTeamID(c)
+	if !ok {
+		return
+	}
+
+	var req dto.MoveTeamRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		helper.RespondError(c, http.StatusBadRequest, "INVALID_REQUEST", "Invalid body", err)
+		return
+	}
+
+	var parentID *uuid.UUID
+	if req.ParentID != nil {
+		id, err := uuid.Parse(*req.ParentID)
+		if err != nil {
+			helper.RespondError(c, http.StatusBadRequest, "INVALID_TEAM_ID", "Parent ID must be a valid UUID", nil)
+			return
+		}
+		parentID = &id
+	}
+
+	movedBy, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.MoveTeam(c.Request.Context(), teamID, parentID, movedBy); err != nil {
+		respondTeamError(c, err, "MOVE_TEAM_FAILED", "Failed to move team")
 		return
 	}
 	c.Status(http.StatusNoContent)
 }
+
+// BulkUpdateMembers applies a batch of membership operations. The response
+// is 200 whenever the batch was processed; per-item failures are reported in
+// the results with machine-readable error codes.
+func (h *TeamHandler) BulkUpdateMembers(c *gin.Context) {
+	var req dto.BulkMembershipRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		helper.RespondError(c, http.StatusBadRequest, "INVALID_REQUEST", "Invalid request payload", err)
+		return
+	}
+
+	actorID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.service.BulkUpdateMembers(c.Request.Context(), &req, actorID)
+	if err != nil {
+		helper.RespondError(c, http.StatusInternalServerError, "BULK_MEMBERSHIP_FAILED", "Failed to apply membership changes", err)
+		return
+	}
+
+	message := "Membership changes applied"
+	if result.Failed > 0 {
+		message = "Membership changes completed with errors"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    "BULK_MEMBERSHIP_PROCESSED",
+		"success": result.Failed == 0,
+		"message": message,
+		"data":    result,
+	})
+}
+
+// GetMembershipHistory lists the team's membership periods to its members
+// and managers. With an `at` query parameter (RFC 3339) only the people on
+// the team at that instant are returned.
+func (h *TeamHandler) GetMembershipHistory(c *gin.Context) {
+	teamID, ok := parseTeamID(c)
+	if !ok {
+		return
+	}
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var (
+		periods []dto.TeamMembershipPeriod
+		err     error
+	)
+	if atParam := c.Query("at"); atParam != "" {
+		at, parseErr := time.Parse(time.RFC3339, atParam)
+		if parseErr != nil {
+			helper.RespondError(c, http.StatusBadRequest, "INVALID_TIME", "at must be an RFC 3339 timestamp", nil)
+			return
+		}
+		periods, err = h.service.GetMembershipAt(c.Request.Context(), teamID, userID, at)
+	} else {
+		periods, err = h.service.GetTeamMembershipHistory(c.Request.Context(), teamID, userID)
+	}
+	if err != nil {
+		respondTeamError(c, err, "GET_MEMBERSHIP_HISTORY_FAILED", "Failed to get membership history")
+		return
+	}
+
+	helper.RespondSuccess(c, http.StatusOK, "MEMBERSHIP_HISTORY_FOUND", "Membership history retrieved successfully", periods)
+}
+
+// GetUserTeamHistory lists every team membership a user has held. Users can
+// read their own history; managers can read anyone's.
+func (h *TeamHandler) GetUserTeamHistory(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		helper.RespondError(c, http.StatusBadRequest, "INVALID_USER_ID", "User ID must be a valid UUID", nil)
+		return
+	}
+
+	caller, ok := currentPrincipal(c)
+	if !ok {
+		return
+	}
+	if caller.UserID != userID && !caller.IsManager() {
+		helper.RespondError(c, http.StatusForbidden, "FORBIDDEN", apperror.ErrAccessDenied.Error(), nil)
+		return
+	}
+
+	periods, err := h.service.GetUserTeamHistory(c.Request.Context(), userID)
+	if err != nil {
+		respondTeamError(c, err, "GET_TEAM_HISTORY_FAILED", "Failed to get team history")
+		return
+	}
+
+	helper.RespondSuccess(c, http.StatusOK, "TEAM_HISTORY_FOUND", "Team history retrieved successfully", periods)
+}
+
+func parseTeamID(c *gin.Context) (uuid.UUID, bool) {
+	teamID, err := uuid.Parse(c.Param("teamId"))
+	if err != nil {
+		helper.RespondError(c, http.StatusBadRequest, "INVALID_TEAM_ID", "Invalid team ID", nil)
+		return uuid.Nil, false
+	}
+	return teamID, true
+}
+
+// currentPrincipal reads the authenticated caller set by the auth middleware
+// and writes the error response itself when it is missing or malformed.
+func currentPrincipal(c *gin.Context) (middleware.Principal, bool) {
+	principal, err := middleware.GetPrincipal(c)
+	if err != nil {
+		helper.RespondError(c, http.StatusUnauthorized, "UNAUTHORIZED", "Unauthorized: "+err.Error(), nil)
+		return middleware.Principal{}, false
+	}
+	return principal, true
+}
+
+func currentUserID(c *gin.Context) (uuid.UUID, bool) {
+	principal, ok := currentPrincipal(c)
+	return principal.UserID, ok
+}
+
+func respondTeamError(c *gin.Context, err error, code string, message string) {
+	switch {
+	case errors.Is(err, apperror.ErrTeamNotFound):
+		helper.RespondError(c, http.StatusNotFound, "TEAM_NOT_FOUND", err.Error(), nil)
+	case errors.Is(err, apperror.ErrAccessDenied):
+		helper.RespondError(c, http.StatusForbidden, "FORBIDDEN", err.Error(), nil)
+	case errors.Is(err, apperror.ErrTeamCycle):
+		helper.RespondError(c, http.StatusConflict, "TEAM_CYCLE", err.Error(), nil)
+	case errors.Is(err, apperror.ErrUserNotFound):
+		helper.RespondError(c, http.StatusNotFound, constant.ErrUserNotFound, err.Error(), nil)
+	case errors.Is(err, apperror.ErrMemberNotFound), errors.Is(err, apperror.ErrManagerNotFound):
+		helper.RespondError(c, http.StatusNotFound, constant.ErrNotMember, err.Error(), nil)
+	case errors.Is(err, apperror.ErrAlreadyMember):
+		helper.RespondError(c, http.StatusConflict, constant.ErrAlreadyMember, err.Error(), nil)
+	case errors.Is(err, apperror.ErrInvalidExpiry):
+		helper.RespondError(c, http.StatusBadRequest, constant.ErrInvalidExpiry, err.Error(), nil)
+	default:
+		helper.RespondError(c, http.StatusInternalServerError, code, message, err)
+	}
+}
@@ -0,0 +1,44 @@
+package job
+
+import (
+	"context"
+	"time"
+
+	"go-training-system/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// Task is a unit of background work. It returns how many items it processed
+// so runs that did something can be logged.
+type Task func(ctx context.Context) (int, error)
+
+// Schedule runs task every interval in its own goroutine until ctx is
+// cancelled. Failures are logged and retried on the next tick.
+func Schedule(ctx context.Context, name string, interval time.Duration, task Task) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			run(ctx, name, task)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func run(ctx context.Context, name string, task Task) {
+	processed, err := task(ctx)
+	if err != nil {
+		logger.Log.Error("background job failed", zap.String("job", name), zap.Error(err))
+		return
+	}
+	if processed > 0 {
+		logger.Log.Info("background job completed", zap.String("job", name), zap.Int("processed", processed))
+	}
+}
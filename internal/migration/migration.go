@@ -7,7 +7,14 @@ import (
 )
 
 func RunMigrations(db *gorm.DB) error {
-	return db.AutoMigrate(
+	if err := dedupeUserShares(db); err != nil {
+		return err
+	}
+	if err := dropShareAccessChecks(db); err != nil {
+		return err
+	}
+
+	err := db.AutoMigrate(
 		&model.User{},
 		&model.Team{},
 		// &model.TeamMember{},
@@ -16,6 +23,127 @@ func RunMigrations(db *gorm.DB) error {
 		&model.Note{},
 		&model.FolderShare{},
 		&model.NoteShare{},
-        &model.TeamUser{},
+		&model.FolderTeamShare{},
+		&model.NoteTeamShare{},
+		&model.TeamUser{},
+		&model.TeamMembershipPeriod{},
+		&model.NoteRevision{},
+		&model.Tag{},
+		&model.NoteTag{},
+		&model.Attachment{},
+		&model.NoteTemplate{},
+		&model.TemplateField{},
+		&model.TemplateShare{},
+		&model.TemplateTeamShare{},
+		&model.Comment{},
+		&model.CommentMention{},
+		&model.NoteOperation{},
+		&model.NoteSnapshot{},
+		&model.ShareLink{},
 	)
+	if err != nil {
+		return err
+	}
+
+	if err := backfillMembershipPeriods(db); err != nil {
+		return err
+	}
+	if err := backfillNoteRevisions(db); err != nil {
+		return err
+	}
+	if err := addNoteSearchVector(db); err != nil {
+		return err
+	}
+	return addTagConstraints(db)
+}
+
+// backfillMembershipPeriods opens a history period for every current
+// membership that predates membership history.
+func backfillMembershipPeriods(db *gorm.DB) error {
+	return db.Exec(`
+		INSERT INTO team_membership_periods (id, team_id, user_id, role, joined_at, expires_at, added_by_id)
+		SELECT gen_random_uuid(), tu.team_id, tu.user_id, tu.role, tu.added_at, tu.expires_at, tu.added_by_id
+		FROM team_user tu
+		WHERE NOT EXISTS (
+			SELECT 1 FROM team_membership_periods p
+			WHERE p.team_id = tu.team_id AND p.user_id = tu.user_id AND p.left_at IS NULL
+		)`).Error
+}
+
+// backfillNoteRevisions records the current content of every note that
+// predates revision history as its first revision.
+func backfillNoteRevisions(db *gorm.DB) error {
+	return db.Exec(`
+		INSERT INTO note_revisions (id, note_id, revision, title, body, author_id, created_at)
+		SELECT gen_random_uuid(), n.id, 1, n.title, n.body, n.owner_id, n.updated_at
+		FROM notes n
+		WHERE NOT EXISTS (SELECT 1 FROM note_revisions r WHERE r.note_id = n.id)`).Error
+}
+
+// addNoteSearchVector adds the weighted full-text vector used by note search.
+// It is a generated column, so Postgres keeps it current on every insert and
+// update.
+func addNoteSearchVector(db *gorm.DB) error {
+	err := db.Exec(`
+		ALTER TABLE notes ADD COLUMN IF NOT EXISTS search_vector tsvector
+		GENERATED ALWAYS AS (
+			setweight(to_tsvector('english', COALESCE(title, '')), 'A') ||
+			setweight(to_tsvector('english', COALESCE(body, '')), 'B')
+		) STORED`).Error
+	if err != nil {
+		return err
+	}
+	return db.Exec(`CREATE INDEX IF NOT EXISTS idx_notes_search_vector ON notes USING GIN (search_vector)`).Error
+}
+
+// addTagConstraints makes every tag either personal or a team tag, and tag
+// names unique per user or team, ignoring case.
+func addTagConstraints(db *gorm.DB) error {
+	for _, stmt := range []string{
+		`ALTER TABLE tags DROP CONSTRAINT IF EXISTS chk_tags_scope`,
+		`ALTER TABLE tags ADD CONSTRAINT chk_tags_scope CHECK ((user_id IS NULL) <> (team_id IS NULL))`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_tags_user_name ON tags (user_id, LOWER(name)) WHERE team_id IS NULL`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_tags_team_name ON tags (team_id, LOWER(name)) WHERE team_id IS NOT NULL`,
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dedupeUserShares keeps only the latest share per (resource, user) so the
+// unique indexes on folder_shares and note_shares can be created.
+func dedupeUserShares(db *gorm.DB) error {
+	for _, t := range []struct{ table, column string }{
+		{"folder_shares", "folder_id"},
+		{"note_shares", "note_id"},
+	} {
+		if !db.Migrator().HasTable(t.table) {
+			continue
+		}
+		err := db.Exec(`
+			DELETE FROM ` + t.table + ` a USING ` + t.table + ` b
+			WHERE a.` + t.column + ` = b.` + t.column + ` AND a.user_id = b.user_id
+			AND (a.shared_at, a.id) < (b.shared_at, b.id)`).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropShareAccessChecks drops the old folder_shares and note_shares access
+// checks so AutoMigrate recreates them allowing 'manage', and 'none' for
+// note overrides.
+func dropShareAccessChecks(db *gorm.DB) error {
+	for _, table := range []string{"folder_shares", "note_shares"} {
+		if !db.Migrator().HasTable(table) {
+			continue
+		}
+		if err := db.Exec(`ALTER TABLE ` + table + ` DROP CONSTRAINT IF EXISTS chk_` + table + `_access`).Error; err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -13,67 +13,97 @@ type AccessLevel string
 const (
 	AccessLevelRead  AccessLevel = "read"
 	AccessLevelWrite AccessLevel = "write"
+	// AccessLevelManage is write access plus the owner's right to share
+	// the resource and change or revoke its shares. Team shares cannot
+	// grant it.
+	AccessLevelManage AccessLevel = "manage"
+	// AccessLevelNone is only stored on note share overrides, to deny a
+	// user access they would otherwise inherit from the folder.
+	AccessLevelNone AccessLevel = "none"
 )
 
-// Folder represents a folder that contains notes
+// Folder represents a folder that contains notes. Version is bumped on every
+// change, for optimistic concurrency control.
 type Folder struct {
 	ID          uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
 	Name        string         `json:"name" gorm:"not null"`
 	Description string         `json:"description"`
+	ParentID    *uuid.UUID     `json:"parent_id,omitempty" gorm:"type:uuid;index"`
 	OwnerID     uuid.UUID      `json:"owner_id" gorm:"type:uuid;not null"`
+	Version     int64          `json:"version" gorm:"not null;default:1"`
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
 
 	// Relationships
-	Owner  User          `json:"owner" gorm:"foreignKey:OwnerID"`
-	Notes  []Note        `json:"notes,omitempty" gorm:"foreignKey:FolderID"`
-	Shares []FolderShare `json:"shares,omitempty" gorm:"foreignKey:FolderID"`
+	Parent     *Folder           `json:"parent,omitempty" gorm:"foreignKey:ParentID"`
+	Children   []Folder          `json:"children,omitempty" gorm:"foreignKey:ParentID"`
+	Owner      User              `json:"owner" gorm:"foreignKey:OwnerID"`
+	Notes      []Note            `json:"notes,omitempty" gorm:"foreignKey:FolderID"`
+	Shares     []FolderShare     `json:"shares,omitempty" gorm:"foreignKey:FolderID"`
+	TeamShares []FolderTeamShare `json:"team_shares,omitempty" gorm:"foreignKey:FolderID"`
 }
 
-// Note represents a note within a folder
+// Note represents a note within a folder. Version is bumped on every change,
+// for optimistic concurrency control.
 type Note struct {
 	ID        uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
 	Title     string         `json:"title" gorm:"not null"`
 	Body      string         `json:"body"`
 	FolderID  uuid.UUID      `json:"folder_id" gorm:"type:uuid;not null"`
 	OwnerID   uuid.UUID      `json:"owner_id" gorm:"type:uuid;not null"`
+	Version   int64          `json:"version" gorm:"not null;default:1"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
 
 	// Relationships
-	Folder Folder      `json:"folder" gorm:"foreignKey:FolderID"`
-	Owner  User        `json:"owner" gorm:"foreignKey:OwnerID"`
-	Shares []NoteShare `json:"shares,omitempty" gorm:"foreignKey:NoteID"`
+	Folder     Folder          `json:"folder" gorm:"foreignKey:FolderID"`
+	Owner      User            `json:"owner" gorm:"foreignKey:OwnerID"`
+	Shares     []NoteShare     `json:"shares,omitempty" gorm:"foreignKey:NoteID"`
+	TeamShares []NoteTeamShare `json:"team_shares,omitempty" gorm:"foreignKey:NoteID"`
 }
 
 // FolderShare represents sharing permissions for folders
 type FolderShare struct {
 	ID         uuid.UUID   `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	FolderID   uuid.UUID   `json:"folder_id" gorm:"type:uuid;not null"`
-	UserID     uuid.UUID   `json:"user_id" gorm:"type:uuid;not null"`
-	Access     AccessLevel `json:"access" gorm:"type:varchar(10);not null;check:access IN ('read', 'write')"`
+	FolderID   uuid.UUID   `json:"folder_id" gorm:"type:uuid;not null;uniqueIndex:idx_folder_share"`
+	UserID     uuid.UUID   `json:"user_id" gorm:"type:uuid;not null;uniqueIndex:idx_folder_share;index"`
+	Access     AccessLevel `json:"access" gorm:"type:varchar(10);not null;check:access IN ('read', 'write', 'manage')"`
 	SharedAt   time.Time   `json:"shared_at" gorm:"default:CURRENT_TIMESTAMP"`
 	SharedByID uuid.UUID   `json:"shared_by_id" gorm:"type:uuid;not null"`
+	// AllowReshare lets a write-share holder share the folder onwards.
+	// Only the owner can set it.
+	AllowReshare bool `json:"allow_reshare" gorm:"not null;default:false"`
+	// ExpiresAt ends the share; it grants nothing from then on and is
+	// deleted by the expiry job. ExpiryWarnedAt records when the users
+	// involved were warned that it is about to expire.
+	ExpiresAt      *time.Time `json:"expires_at,omitempty" gorm:"index"`
+	ExpiryWarnedAt *time.Time `json:"-"`
 
 	// Relationships
 	Folder   Folder `json:"folder" gorm:"foreignKey:FolderID"`
 	User     User   `json:"user" gorm:"foreignKey:UserID"`
 	SharedBy User   `json:"shared_by" gorm:"foreignKey:SharedByID"`
-
-	// Unique constraint to prevent duplicate shares
-	// UniqueIndex is defined in the migration or through gorm tags
 }
 
 // NoteShare represents sharing permissions for individual notes
 type NoteShare struct {
 	ID         uuid.UUID   `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	NoteID     uuid.UUID   `json:"note_id" gorm:"type:uuid;not null"`
-	UserID     uuid.UUID   `json:"user_id" gorm:"type:uuid;not null"`
-	Access     AccessLevel `json:"access" gorm:"type:varchar(10);not null;check:access IN ('read', 'write')"`
+	NoteID     uuid.UUID   `json:"note_id" gorm:"type:uuid;not null;uniqueIndex:idx_note_share"`
+	UserID     uuid.UUID   `json:"user_id" gorm:"type:uuid;not null;uniqueIndex:idx_note_share;index"`
+	Access     AccessLevel `json:"access" gorm:"type:varchar(10);not null;check:access IN ('read', 'write', 'manage', 'none')"`
 	SharedAt   time.Time   `json:"shared_at" gorm:"default:CURRENT_TIMESTAMP"`
 	SharedByID uuid.UUID   `json:"shared_by_id" gorm:"type:uuid;not null"`
+	// AllowReshare lets a write-share holder share the note onwards. Only
+	// the owner can set it.
+	AllowReshare bool `json:"allow_reshare" gorm:"not null;default:false"`
+	// Override makes Access the user's exact access to the note, replacing
+	// whatever they inherit from the folder. With AccessLevelNone it denies.
+	Override bool `json:"override" gorm:"not null;default:false"`
+	// ExpiresAt and ExpiryWarnedAt work as on FolderShare.
+	ExpiresAt      *time.Time `json:"expires_at,omitempty" gorm:"index"`
+	ExpiryWarnedAt *time.Time `json:"-"`
 
 	// Relationships
 	Note     Note `json:"note" gorm:"foreignKey:NoteID"`
@@ -81,6 +111,40 @@ type NoteShare struct {
 	SharedBy User `json:"shared_by" gorm:"foreignKey:SharedByID"`
 }
 
+// FolderTeamShare grants a folder to every current member of a team. With
+// ManagersOnly set, only the team's managers get access.
+type FolderTeamShare struct {
+	ID           uuid.UUID   `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	FolderID     uuid.UUID   `json:"folder_id" gorm:"type:uuid;not null;uniqueIndex:idx_folder_team_share"`
+	TeamID       uuid.UUID   `json:"team_id" gorm:"type:uuid;not null;uniqueIndex:idx_folder_team_share;index"`
+	Access       AccessLevel `json:"access" gorm:"type:varchar(10);not null;check:access IN ('read', 'write')"`
+	ManagersOnly bool        `json:"managers_only" gorm:"not null;default:false"`
+	SharedAt     time.Time   `json:"shared_at" gorm:"default:CURRENT_TIMESTAMP"`
+	SharedByID   uuid.UUID   `json:"shared_by_id" gorm:"type:uuid;not null"`
+
+	// Relationships
+	Folder   Folder `json:"folder" gorm:"foreignKey:FolderID"`
+	Team     Team   `json:"team" gorm:"foreignKey:TeamID"`
+	SharedBy User   `json:"shared_by" gorm:"foreignKey:SharedByID"`
+}
+
+// NoteTeamShare grants a note to every current member of a team. With
+// ManagersOnly set, only the team's managers get access.
+type NoteTeamShare struct {
+	ID           uuid.UUID   `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	NoteID       uuid.UUID   `json:"note_id" gorm:"type:uuid;not null;uniqueIndex:idx_note_team_share"`
+	TeamID       uuid.UUID   `json:"team_id" gorm:"type:uuid;not null;uniqueIndex:idx_note_team_share;index"`
+	Access       AccessLevel `json:"access" gorm:"type:varchar(10);not null;check:access IN ('read', 'write')"`
+	ManagersOnly bool        `json:"managers_only" gorm:"not null;default:false"`
+	SharedAt     time.Time   `json:"shared_at" gorm:"default:CURRENT_TIMESTAMP"`
+	SharedByID   uuid.UUID   `json:"shared_by_id" gorm:"type:uuid;not null"`
+
+	// Relationships
+	Note     Note `json:"note" gorm:"foreignKey:NoteID"`
+	Team     Team `json:"team" gorm:"foreignKey:TeamID"`
+	SharedBy User `json:"shared_by" gorm:"foreignKey:SharedByID"`
+}
+
 func (FolderShare) TableName() string {
 	return "folder_shares"
 }
@@ -89,6 +153,14 @@ func (NoteShare) TableName() string {
 	return "note_shares"
 }
 
+func (FolderTeamShare) TableName() string {
+	return "folder_team_shares"
+}
+
+func (NoteTeamShare) TableName() string {
+	return "note_team_shares"
+}
+
 func (f *Folder) BeforeCreate(tx *gorm.DB) error {
 	if f.ID == uuid.Nil {
 		f.ID = uuid.New()
@@ -116,3 +188,17 @@ func (ns *NoteShare) BeforeCreate(tx *gorm.DB) error {
 	}
 	return nil
 }
+
+func (fs *FolderTeamShare) BeforeCreate(tx *gorm.DB) error {
+	if fs.ID == uuid.Nil {
+		fs.ID = uuid.New()
+	}
+	return nil
+}
+
+func (ns *NoteTeamShare) BeforeCreate(tx *gorm.DB) error {
+	if ns.ID == uuid.Nil {
+		ns.ID = uuid.New()
+	}
+	return nil
+}
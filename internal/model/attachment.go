@@ -0,0 +1,36 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Attachment is a file attached to a note. The content lives in blob storage
+// under its SHA-256, so identical files uploaded many times are stored once.
+type Attachment struct {
+	ID           uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	NoteID       uuid.UUID `json:"note_id" gorm:"type:uuid;not null;index"`
+	FileName     string    `json:"file_name" gorm:"not null"`
+	ContentType  string    `json:"content_type" gorm:"not null"`
+	Size         int64     `json:"size" gorm:"not null"`
+	SHA256       string    `json:"sha256" gorm:"column:sha256;type:char(64);not null;index"`
+	UploadedByID uuid.UUID `json:"uploaded_by_id" gorm:"type:uuid;not null"`
+	CreatedAt    time.Time `json:"created_at"`
+
+	// Relationships
+	Note       Note `json:"-" gorm:"foreignKey:NoteID"`
+	UploadedBy User `json:"uploaded_by" gorm:"foreignKey:UploadedByID"`
+}
+
+func (Attachment) TableName() string {
+	return "attachments"
+}
+
+func (a *Attachment) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return nil
+}
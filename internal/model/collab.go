@@ -0,0 +1,40 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// NoteOperation is one edit of a note's body made in a collaborative
+// editing session, stored in the ot.js JSON format. Revisions are numbered
+// from 1 per note and operation N applies to the body at revision N-1, so
+// the primary key also decides the order of concurrent edits.
+type NoteOperation struct {
+	NoteID    uuid.UUID `json:"note_id" gorm:"type:uuid;primaryKey"`
+	Revision  int64     `json:"revision" gorm:"primaryKey;autoIncrement:false"`
+	Operation string    `json:"operation" gorm:"type:text;not null"`
+	// AuthorID is nil for changes made to the note outside the session and
+	// merged into it.
+	AuthorID  *uuid.UUID `json:"author_id,omitempty" gorm:"type:uuid"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (NoteOperation) TableName() string {
+	return "note_operations"
+}
+
+// NoteSnapshot records that the note's body at NoteVersion is the session's
+// document at Revision. Body is kept here as well, so edits made to the note
+// outside the session can be told apart and merged as an operation.
+type NoteSnapshot struct {
+	NoteID      uuid.UUID `json:"note_id" gorm:"type:uuid;primaryKey"`
+	Revision    int64     `json:"revision" gorm:"not null"`
+	Body        string    `json:"body" gorm:"type:text"`
+	NoteVersion int64     `json:"note_version" gorm:"not null"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (NoteSnapshot) TableName() string {
+	return "note_snapshots"
+}
@@ -0,0 +1,62 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Comment is a comment on a note. A comment without ParentID starts a
+// thread, and replies point at it; threads are one level deep. Only the
+// first comment of a thread can be anchored to a range of the note body and
+// resolved. Version is bumped on every edit, for optimistic concurrency
+// control.
+type Comment struct {
+	ID       uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	NoteID   uuid.UUID  `json:"note_id" gorm:"type:uuid;not null;index"`
+	ParentID *uuid.UUID `json:"parent_id,omitempty" gorm:"type:uuid;index"`
+	AuthorID uuid.UUID  `json:"author_id" gorm:"type:uuid;not null"`
+	Body     string     `json:"body" gorm:"type:text;not null"`
+	// AnchorStart and AnchorEnd delimit the commented text, in characters of
+	// the note body at AnchorVersion. AnchorText is that text, so clients can
+	// find it again after the note changed.
+	AnchorStart   *int       `json:"anchor_start,omitempty"`
+	AnchorEnd     *int       `json:"anchor_end,omitempty"`
+	AnchorText    string     `json:"anchor_text,omitempty" gorm:"type:text"`
+	AnchorVersion *int64     `json:"anchor_version,omitempty"`
+	ResolvedAt    *time.Time `json:"resolved_at,omitempty"`
+	ResolvedByID  *uuid.UUID `json:"resolved_by_id,omitempty" gorm:"type:uuid"`
+	Version       int64      `json:"version" gorm:"not null;default:1"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+
+	// Relationships
+	Note     Note             `json:"-" gorm:"foreignKey:NoteID"`
+	Author   User             `json:"author" gorm:"foreignKey:AuthorID"`
+	Mentions []CommentMention `json:"mentions" gorm:"foreignKey:CommentID"`
+}
+
+// CommentMention records that a comment @mentions a user.
+type CommentMention struct {
+	CommentID uuid.UUID `json:"comment_id" gorm:"type:uuid;primary_key"`
+	UserID    uuid.UUID `json:"user_id" gorm:"type:uuid;primary_key;index"`
+
+	// Relationships
+	User User `json:"user" gorm:"foreignKey:UserID"`
+}
+
+func (Comment) TableName() string {
+	return "note_comments"
+}
+
+func (CommentMention) TableName() string {
+	return "comment_mentions"
+}
+
+func (c *Comment) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// NoteRevision is a full snapshot of a note's content, written every time the
+// note is created or updated. Revisions are numbered from 1 per note.
+type NoteRevision struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	NoteID    uuid.UUID `json:"note_id" gorm:"type:uuid;not null;uniqueIndex:idx_note_revision"`
+	Revision  int       `json:"revision" gorm:"not null;uniqueIndex:idx_note_revision"`
+	Title     string    `json:"title" gorm:"not null"`
+	Body      string    `json:"body" gorm:"type:text"`
+	AuthorID  uuid.UUID `json:"author_id" gorm:"type:uuid;not null"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Relationships
+	Note   Note `json:"-" gorm:"foreignKey:NoteID"`
+	Author User `json:"author" gorm:"foreignKey:AuthorID"`
+}
+
+func (NoteRevision) TableName() string {
+	return "note_revisions"
+}
+
+func (r *NoteRevision) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ShareLink gives anyone holding its token read-only access to a note, or to
+// a folder with everything in it, without an account. Exactly one of
+// FolderID and NoteID is set. Only a SHA-256 hash of the token is stored, and
+// PasswordHash is a bcrypt hash when the link has a password.
+type ShareLink struct {
+	ID             uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	TokenHash      string     `json:"-" gorm:"not null;uniqueIndex"`
+	FolderID       *uuid.UUID `json:"folder_id,omitempty" gorm:"type:uuid;index"`
+	NoteID         *uuid.UUID `json:"note_id,omitempty" gorm:"type:uuid;index"`
+	PasswordHash   string     `json:"-"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	AccessCount    int64      `json:"access_count" gorm:"not null;default:0"`
+	LastAccessedAt *time.Time `json:"last_accessed_at,omitempty"`
+	CreatedByID    uuid.UUID  `json:"created_by_id" gorm:"type:uuid;not null"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+func (ShareLink) TableName() string {
+	return "share_links"
+}
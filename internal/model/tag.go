@@ -0,0 +1,55 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Tag labels notes across folders. A tag is either personal, owned by
+// UserID, or belongs to a team, in which case TeamID is set and every current
+// member of the team can use it. Names are unique per owner or team, ignoring
+// case.
+type Tag struct {
+	ID          uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	Name        string     `json:"name" gorm:"not null"`
+	UserID      *uuid.UUID `json:"user_id,omitempty" gorm:"type:uuid;index"`
+	TeamID      *uuid.UUID `json:"team_id,omitempty" gorm:"type:uuid;index"`
+	CreatedByID uuid.UUID  `json:"created_by_id" gorm:"type:uuid;not null"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	// Relationships
+	User      *User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Team      *Team `json:"team,omitempty" gorm:"foreignKey:TeamID"`
+	CreatedBy User  `json:"created_by" gorm:"foreignKey:CreatedByID"`
+}
+
+// NoteTag attaches a tag to a note.
+type NoteTag struct {
+	NoteID     uuid.UUID `json:"note_id" gorm:"type:uuid;primary_key"`
+	TagID      uuid.UUID `json:"tag_id" gorm:"type:uuid;primary_key;index"`
+	TaggedByID uuid.UUID `json:"tagged_by_id" gorm:"type:uuid;not null"`
+	TaggedAt   time.Time `json:"tagged_at" gorm:"default:CURRENT_TIMESTAMP"`
+
+	// Relationships
+	Note     Note `json:"-" gorm:"foreignKey:NoteID"`
+	Tag      Tag  `json:"tag" gorm:"foreignKey:TagID;constraint:OnDelete:CASCADE"`
+	TaggedBy User `json:"tagged_by" gorm:"foreignKey:TaggedByID"`
+}
+
+func (Tag) TableName() string {
+	return "tags"
+}
+
+func (NoteTag) TableName() string {
+	return "note_tags"
+}
+
+func (t *Tag) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return nil
+}
@@ -10,13 +10,16 @@ import (
 type Team struct {
 	ID          uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
 	TeamName    string         `json:"team_name" gorm:"not null"`
+	ParentID    *uuid.UUID     `json:"parent_id,omitempty" gorm:"type:uuid;index"`
 	CreatedByID uuid.UUID      `json:"created_by_id" gorm:"type:uuid;not null"`
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
 
 	// Relationships
-	CreatedBy User   `json:"created_by" gorm:"foreignKey:CreatedByID"`
+	Parent    *Team      `json:"parent,omitempty" gorm:"foreignKey:ParentID"`
+	Children  []Team     `json:"children,omitempty" gorm:"foreignKey:ParentID"`
+	CreatedBy User       `json:"created_by" gorm:"foreignKey:CreatedByID"`
 	Users     []TeamUser `json:"users" gorm:"foreignKey:TeamID"`
 }
 
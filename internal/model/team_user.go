@@ -4,20 +4,63 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type TeamUser struct {
-	TeamID    uuid.UUID `json:"team_id" gorm:"type:uuid;primary_key"`
-	UserID    uuid.UUID `json:"user_id" gorm:"type:uuid;primary_key"`
-	AddedAt   time.Time `json:"added_at" gorm:"default:CURRENT_TIMESTAMP"`
-	AddedByID uuid.UUID `json:"added_by_id" gorm:"type:uuid"`
-    Role      UserRole  `json:"role" gorm:"type:varchar(20);not null;check:role IN ('MANAGER', 'MEMBER')"`
-
-    // Relationships
-    Team     Team `gorm:"foreignKey:TeamID"`
-	User     User `gorm:"foreignKey:UserID"`
+	TeamID    uuid.UUID  `json:"team_id" gorm:"type:uuid;primary_key"`
+	UserID    uuid.UUID  `json:"user_id" gorm:"type:uuid;primary_key"`
+	AddedAt   time.Time  `json:"added_at" gorm:"default:CURRENT_TIMESTAMP"`
+	AddedByID uuid.UUID  `json:"added_by_id" gorm:"type:uuid"`
+	Role      UserRole   `json:"role" gorm:"type:varchar(20);not null;check:role IN ('MANAGER', 'MEMBER')"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty" gorm:"index"`
+
+	// Relationships
+	Team    Team `gorm:"foreignKey:TeamID"`
+	User    User `gorm:"foreignKey:UserID"`
 	AddedBy User `json:"added_by" gorm:"foreignKey:AddedByID"`
 }
+
 func (TeamUser) TableName() string {
-    return "team_user"
+	return "team_user"
+}
+
+// MembershipEndReason explains why a membership period was closed
+type MembershipEndReason string
+
+const (
+	MembershipEndRemoved     MembershipEndReason = "removed"
+	MembershipEndRoleChanged MembershipEndReason = "role_changed"
+	MembershipEndExpired     MembershipEndReason = "expired"
+)
+
+// TeamMembershipPeriod records one continuous stretch of a user holding a
+// role in a team. TeamUser is the current state; periods are never deleted,
+// so past membership can be reconstructed for any point in time.
+type TeamMembershipPeriod struct {
+	ID          uuid.UUID           `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	TeamID      uuid.UUID           `json:"team_id" gorm:"type:uuid;not null;index:idx_membership_period_team"`
+	UserID      uuid.UUID           `json:"user_id" gorm:"type:uuid;not null;index:idx_membership_period_user"`
+	Role        UserRole            `json:"role" gorm:"type:varchar(20);not null"`
+	JoinedAt    time.Time           `json:"joined_at" gorm:"not null;index:idx_membership_period_team"`
+	LeftAt      *time.Time          `json:"left_at,omitempty" gorm:"index:idx_membership_period_team"`
+	ExpiresAt   *time.Time          `json:"expires_at,omitempty"`
+	AddedByID   *uuid.UUID          `json:"added_by_id,omitempty" gorm:"type:uuid"`
+	RemovedByID *uuid.UUID          `json:"removed_by_id,omitempty" gorm:"type:uuid"`
+	EndReason   MembershipEndReason `json:"end_reason,omitempty" gorm:"type:varchar(20)"`
+
+	// Relationships
+	Team Team `json:"team" gorm:"foreignKey:TeamID"`
+	User User `json:"user" gorm:"foreignKey:UserID"`
+}
+
+func (TeamMembershipPeriod) TableName() string {
+	return "team_membership_periods"
+}
+
+func (p *TeamMembershipPeriod) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	return nil
 }
@@ -0,0 +1,102 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// NoteTemplate is a reusable title and body for new notes, with {{...}}
+// placeholders filled in when a note is created from it. It belongs to
+// OwnerID and, when TeamID is set, to a team as well: every current member
+// can use it and the team's managers can edit it. Version is bumped on every
+// change, for optimistic concurrency control.
+type NoteTemplate struct {
+	ID          uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	Name        string     `json:"name" gorm:"not null"`
+	Description string     `json:"description"`
+	Title       string     `json:"title" gorm:"not null"`
+	Body        string     `json:"body"`
+	OwnerID     uuid.UUID  `json:"owner_id" gorm:"type:uuid;not null;index"`
+	TeamID      *uuid.UUID `json:"team_id,omitempty" gorm:"type:uuid;index"`
+	Version     int64      `json:"version" gorm:"not null;default:1"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	// Relationships
+	Fields     []TemplateField     `json:"fields" gorm:"foreignKey:TemplateID"`
+	Owner      User                `json:"owner" gorm:"foreignKey:OwnerID"`
+	Team       *Team               `json:"team,omitempty" gorm:"foreignKey:TeamID"`
+	Shares     []TemplateShare     `json:"shares,omitempty" gorm:"foreignKey:TemplateID"`
+	TeamShares []TemplateTeamShare `json:"team_shares,omitempty" gorm:"foreignKey:TemplateID"`
+}
+
+// TemplateField is a custom placeholder of a template, written {{name}} and
+// filled in by whoever creates a note from the template.
+type TemplateField struct {
+	TemplateID uuid.UUID `json:"template_id" gorm:"type:uuid;primary_key"`
+	Name       string    `json:"name" gorm:"primary_key"`
+	Label      string    `json:"label"`
+	Default    string    `json:"default"`
+	Required   bool      `json:"required" gorm:"not null;default:false"`
+	Position   int       `json:"position" gorm:"not null"`
+}
+
+// TemplateShare lets a user use a template (read) or also edit it (write).
+type TemplateShare struct {
+	ID         uuid.UUID   `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	TemplateID uuid.UUID   `json:"template_id" gorm:"type:uuid;not null;uniqueIndex:idx_template_share"`
+	UserID     uuid.UUID   `json:"user_id" gorm:"type:uuid;not null;uniqueIndex:idx_template_share;index"`
+	Access     AccessLevel `json:"access" gorm:"type:varchar(10);not null;check:access IN ('read', 'write')"`
+	SharedAt   time.Time   `json:"shared_at" gorm:"default:CURRENT_TIMESTAMP"`
+	SharedByID uuid.UUID   `json:"shared_by_id" gorm:"type:uuid;not null"`
+	// AllowReshare lets a write-share holder share the template onwards.
+	// Only the owner can set it.
+	AllowReshare bool `json:"allow_reshare" gorm:"not null;default:false"`
+
+	// Relationships
+	Template NoteTemplate `json:"-" gorm:"foreignKey:TemplateID"`
+	User     User         `json:"user" gorm:"foreignKey:UserID"`
+	SharedBy User         `json:"shared_by" gorm:"foreignKey:SharedByID"`
+}
+
+// TemplateTeamShare grants a template to every current member of a team.
+// With ManagersOnly set, only the team's managers get access.
+type TemplateTeamShare struct {
+	ID           uuid.UUID   `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	TemplateID   uuid.UUID   `json:"template_id" gorm:"type:uuid;not null;uniqueIndex:idx_template_team_share"`
+	TeamID       uuid.UUID   `json:"team_id" gorm:"type:uuid;not null;uniqueIndex:idx_template_team_share;index"`
+	Access       AccessLevel `json:"access" gorm:"type:varchar(10);not null;check:access IN ('read', 'write')"`
+	ManagersOnly bool        `json:"managers_only" gorm:"not null;default:false"`
+	SharedAt     time.Time   `json:"shared_at" gorm:"default:CURRENT_TIMESTAMP"`
+	SharedByID   uuid.UUID   `json:"shared_by_id" gorm:"type:uuid;not null"`
+
+	// Relationships
+	Template NoteTemplate `json:"-" gorm:"foreignKey:TemplateID"`
+	Team     Team         `json:"team" gorm:"foreignKey:TeamID"`
+	SharedBy User         `json:"shared_by" gorm:"foreignKey:SharedByID"`
+}
+
+func (NoteTemplate) TableName() string {
+	return "note_templates"
+}
+
+func (TemplateField) TableName() string {
+	return "template_fields"
+}
+
+func (TemplateShare) TableName() string {
+	return "template_shares"
+}
+
+func (TemplateTeamShare) TableName() string {
+	return "template_team_shares"
+}
+
+func (t *NoteTemplate) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"go-training-system/internal/graph/apperror"
+	"go-training-system/internal/model"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AttachmentRepository stores attachment metadata. Attachments with the same
+// content share one blob, so creating and deleting them is coordinated with
+// blob storage through callbacks that run under a per-content lock.
+type AttachmentRepository interface {
+	Create(ctx context.Context, attachment *model.Attachment, storeBlob func() error) error
+	GetByID(ctx context.Context, id uuid.UUID) (*model.Attachment, error)
+	GetByNoteID(ctx context.Context, noteID uuid.UUID) ([]model.Attachment, error)
+	GetByNoteIDs(ctx context.Context, noteIDs []uuid.UUID) ([]model.Attachment, error)
+	Delete(ctx context.Context, id uuid.UUID, deleteBlob func(sha256 string) error) error
+}
+
+type attachmentRepository struct {
+	db *gorm.DB
+}
+
+func NewAttachmentRepository(db *gorm.DB) AttachmentRepository {
+	return &attachmentRepository{db: db}
+}
+
+// Create runs storeBlob and inserts the attachment in one transaction. The
+// transaction holds a lock on the content hash, so a concurrent Delete of the
+// last attachment with the same content cannot remove the blob in between.
+func (r *attachmentRepository) Create(ctx context.Context, attachment *model.Attachment, storeBlob func() error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockContent(tx, attachment.SHA256); err != nil {
+			return err
+		}
+		if err := storeBlob(); err != nil {
+			return err
+		}
+		return tx.Create(attachment).Error
+	})
+}
+
+func (r *attachmentRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.Attachment, error) {
+	var attachment model.Attachment
+	err := r.db.WithContext(ctx).First(&attachment, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, apperror.ErrAttachmentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+func (r *attachmentRepository) GetByNoteID(ctx context.Context, noteID uuid.UUID) ([]model.Attachment, error) {
+	var attachments []model.Attachment
+	err := r.db.WithContext(ctx).Where("note_id = ?", noteID).Order("created_at").Find(&attachments).Error
+	return attachments, err
+}
+
+func (r *attachmentRepository) GetByNoteIDs(ctx context.Context, noteIDs []uuid.UUID) ([]model.Attachment, error) {
+	var attachments []model.Attachment
+	if len(noteIDs) == 0 {
+		return attachments, nil
+	}
+	err := r.db.WithContext(ctx).Where("note_id IN ?", noteIDs).Find(&attachments).Error
+	return attachments, err
+}
+
+// Delete removes an attachment and, when no other attachment shares its
+// content, calls deleteBlob with the content hash before committing.
+func (r *attachmentRepository) Delete(ctx context.Context, id uuid.UUID, deleteBlob func(sha256 string) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var attachment model.Attachment
+		err := tx.First(&attachment, "id = ?", id).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperror.ErrAttachmentNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := lockContent(tx, attachment.SHA256); err != nil {
+			return err
+		}
+		if err := tx.Delete(&model.Attachment{}, "id = ?", id).Error; err != nil {
+			return err
+		}
+
+		var remaining int64
+		if err := tx.Model(&model.Attachment{}).Where("sha256 = ?", attachment.SHA256).Count(&remaining).Error; err != nil {
+			return err
+		}
+		if remaining > 0 {
+			return nil
+		}
+		return deleteBlob(attachment.SHA256)
+	})
+}
+
+// lockContent takes a transaction-scoped advisory lock on a content hash.
+func lockContent(tx *gorm.DB, sha256 string) error {
+	return tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "attachment:"+sha256).Error
+}
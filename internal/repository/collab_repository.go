@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"go-training-system/internal/graph/apperror"
+	"go-training-system/internal/model"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CollabRepository stores the operations and snapshots of collaborative
+// editing sessions.
+type CollabRepository interface {
+	// GetSnapshot returns nil when the note has never been edited
+	// collaboratively.
+	GetSnapshot(ctx context.Context, noteID uuid.UUID) (*model.NoteSnapshot, error)
+	GetOperations(ctx context.Context, noteID uuid.UUID, after int64) ([]model.NoteOperation, error)
+	AppendOperation(ctx context.Context, op *model.NoteOperation) error
+	SaveSnapshot(ctx context.Context, note *model.Note, authorID uuid.UUID, expectedVersion int64, snapshot *model.NoteSnapshot) error
+	PruneOperations(ctx context.Context, noteID uuid.UUID, through int64) error
+}
+
+type collabRepository struct {
+	db *gorm.DB
+}
+
+func NewCollabRepository(db *gorm.DB) CollabRepository {
+	return &collabRepository{db: db}
+}
+
+func (r *collabRepository) GetSnapshot(ctx context.Context, noteID uuid.UUID) (*model.NoteSnapshot, error) {
+	var snapshot model.NoteSnapshot
+	err := r.db.WithContext(ctx).First(&snapshot, "note_id = ?", noteID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// GetOperations returns the note's operations after the given revision,
+// oldest first.
+func (r *collabRepository) GetOperations(ctx context.Context, noteID uuid.UUID, after int64) ([]model.NoteOperation, error) {
+	var ops []model.NoteOperation
+	err := r.db.WithContext(ctx).
+		Where("note_id = ? AND revision > ?", noteID, after).
+		Order("revision").
+		Find(&ops).Error
+	return ops, err
+}
+
+// AppendOperation stores op at its revision, failing with ErrRevisionTaken
+// when another server stored an operation there first.
+func (r *collabRepository) AppendOperation(ctx context.Context, op *model.NoteOperation) error {
+	res := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(op)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return apperror.ErrRevisionTaken
+	}
+	return nil
+}
+
+// SaveSnapshot writes the note's title and body as a new note revision
+// unless they are unchanged, then records the snapshot at the note's new
+// version. Both happen in one transaction, so the snapshot always describes
+// the stored body. A non-zero expectedVersion makes it fail with a
+// *apperror.VersionConflictError if the note was changed by someone else.
+func (r *collabRepository) SaveSnapshot(ctx context.Context, note *model.Note, authorID uuid.UUID, expectedVersion int64, snapshot *model.NoteSnapshot) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var current model.Note
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, "id = ?", note.ID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperror.ErrNoteNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if expectedVersion > 0 && current.Version != expectedVersion {
+			return &apperror.VersionConflictError{Current: current.Version}
+		}
+
+		note.FolderID = current.FolderID
+		if current.Title == note.Title && current.Body == note.Body {
+			note.Version = current.Version
+		} else {
+			err := updateVersioned(tx, note, note.ID, 0, map[string]interface{}{
+				"title": note.Title,
+				"body":  note.Body,
+			}, apperror.ErrNoteNotFound)
+			if err != nil {
+				return err
+			}
+			if err := addRevision(tx, note, authorID); err != nil {
+				return err
+			}
+		}
+
+		// A snapshot never replaces a later one
+		snapshot.NoteID = note.ID
+		snapshot.NoteVersion = note.Version
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "note_id"}},
+			Where:     clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "note_snapshots.revision <= excluded.revision"}}},
+			DoUpdates: clause.AssignmentColumns([]string{"revision", "body", "note_version", "updated_at"}),
+		}).Create(snapshot).Error
+	})
+}
+
+// PruneOperations deletes the note's operations up to and including the
+// given revision.
+func (r *collabRepository) PruneOperations(ctx context.Context, noteID uuid.UUID, through int64) error {
+	return r.db.WithContext(ctx).
+		Where("note_id = ? AND revision <= ?", noteID, through).
+		Delete(&model.NoteOperation{}).Error
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"go-training-system/internal/graph/apperror"
+	"go-training-system/internal/model"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// CommentRepository stores note comments and their mentions.
+type CommentRepository interface {
+	Create(ctx context.Context, comment *model.Comment) error
+	GetByID(ctx context.Context, id uuid.UUID) (*model.Comment, error)
+	GetByNoteID(ctx context.Context, noteID uuid.UUID) ([]model.Comment, error)
+	Update(ctx context.Context, comment *model.Comment, expectedVersion int64) error
+	SetResolved(ctx context.Context, comment *model.Comment) error
+	Delete(ctx context.Context, id uuid.UUID, expectedVersion int64) error
+}
+
+type commentRepository struct {
+	db *gorm.DB
+}
+
+func NewCommentRepository(db *gorm.DB) CommentRepository {
+	return &commentRepository{db: db}
+}
+
+// Create stores the comment with its mentions. A reply locks its thread's
+// first comment, so the thread cannot be deleted at the same time.
+func (r *commentRepository) Create(ctx context.Context, comment *model.Comment) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if comment.ParentID != nil {
+			if err := checkVersion(tx, &model.Comment{}, *comment.ParentID, 0, apperror.ErrCommentNotFound); err != nil {
+				return err
+			}
+		}
+		return tx.Create(comment).Error
+	})
+}
+
+func (r *commentRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.Comment, error) {
+	var comment model.Comment
+	err := r.db.WithContext(ctx).Preload("Author").Preload("Mentions.User").First(&comment, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, apperror.ErrCommentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// GetByNoteID returns every comment on the note, oldest first.
+func (r *commentRepository) GetByNoteID(ctx context.Context, noteID uuid.UUID) ([]model.Comment, error) {
+	var comments []model.Comment
+	err := r.db.WithContext(ctx).Preload("Author").Preload("Mentions.User").
+		Where("note_id = ?", noteID).Order("created_at, id").Find(&comments).Error
+	return comments, err
+}
+
+// Update saves the comment's body and replaces its mentions. A non-zero
+// expectedVersion makes it fail with a *apperror.VersionConflictError if the
+// comment has changed.
+func (r *commentRepository) Update(ctx context.Context, comment *model.Comment, expectedVersion int64) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := updateVersioned(tx, comment, comment.ID, expectedVersion, map[string]interface{}{
+			"body": comment.Body,
+		}, apperror.ErrCommentNotFound)
+		if err != nil {
+			return err
+		}
+		return replaceMentions(tx, comment)
+	})
+}
+
+// SetResolved saves whether the thread is resolved, and by whom.
+func (r *commentRepository) SetResolved(ctx context.Context, comment *model.Comment) error {
+	return updateVersioned(r.db.WithContext(ctx), comment, comment.ID, 0, map[string]interface{}{
+		"resolved_at":    comment.ResolvedAt,
+		"resolved_by_id": comment.ResolvedByID,
+	}, apperror.ErrCommentNotFound)
+}
+
+// Delete removes the comment and, for the first comment of a thread, all of
+// its replies.
+func (r *commentRepository) Delete(ctx context.Context, id uuid.UUID, expectedVersion int64) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := checkVersion(tx, &model.Comment{}, id, expectedVersion, apperror.ErrCommentNotFound); err != nil {
+			return err
+		}
+		thread := tx.Model(&model.Comment{}).Select("id").Where("id = ? OR parent_id = ?", id, id)
+		if err := tx.Where("comment_id IN (?)", thread).Delete(&model.CommentMention{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ? OR parent_id = ?", id, id).Delete(&model.Comment{}).Error
+	})
+}
+
+func replaceMentions(tx *gorm.DB, comment *model.Comment) error {
+	if err := tx.Where("comment_id = ?", comment.ID).Delete(&model.CommentMention{}).Error; err != nil {
+		return err
+	}
+	for i := range comment.Mentions {
+		comment.Mentions[i].CommentID = comment.ID
+	}
+	if len(comment.Mentions) == 0 {
+		return nil
+	}
+	return tx.Create(&comment.Mentions).Error
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"go-training-system/internal/model"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// copyNotes inserts a copy of each note, owned by ownerID and placed in the
+// folder folderOf picks for it. Each copy starts at its first revision and
+// gets the original's attachments. Copied attachments share the original
+// blobs, so their content is locked like for a new upload.
+func copyNotes(tx *gorm.DB, notes []model.Note, folderOf func(note model.Note) uuid.UUID, ownerID uuid.UUID) ([]model.Note, error) {
+	if len(notes) == 0 {
+		return nil, nil
+	}
+
+	sourceIDs := make([]uuid.UUID, len(notes))
+	for i, note := range notes {
+		sourceIDs[i] = note.ID
+	}
+
+	// Lock in a fixed order so two copies of overlapping content cannot
+	// deadlock, then read the attachments again: one deleted while waiting
+	// for its lock may have taken its blob with it.
+	var hashes []string
+	err := tx.Model(&model.Attachment{}).Distinct("sha256").Where("note_id IN ?", sourceIDs).
+		Order("sha256").Pluck("sha256", &hashes).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, sha256 := range hashes {
+		if err := lockContent(tx, sha256); err != nil {
+			return nil, err
+		}
+	}
+	var attachments []model.Attachment
+	if len(hashes) > 0 {
+		err := tx.Where("note_id IN ? AND sha256 IN ?", sourceIDs, hashes).Order("created_at").Find(&attachments).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	copies := make([]model.Note, len(notes))
+	copyOf := make(map[uuid.UUID]uuid.UUID, len(notes))
+	for i, note := range notes {
+		copies[i] = model.Note{
+			Title:    note.Title,
+			Body:     note.Body,
+			FolderID: folderOf(note),
+			OwnerID:  ownerID,
+		}
+		if err := tx.Create(&copies[i]).Error; err != nil {
+			return nil, err
+		}
+		if err := addRevision(tx, &copies[i], ownerID); err != nil {
+			return nil, err
+		}
+		copyOf[note.ID] = copies[i].ID
+	}
+
+	for _, attachment := range attachments {
+		err := tx.Create(&model.Attachment{
+			NoteID:       copyOf[attachment.NoteID],
+			FileName:     attachment.FileName,
+			ContentType:  attachment.ContentType,
+			Size:         attachment.Size,
+			SHA256:       attachment.SHA256,
+			UploadedByID: ownerID,
+		}).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	return copies, nil
+}
@@ -2,7 +2,11 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"strings"
+	"time"
 
+	"go-training-system/internal/graph/apperror"
 	"go-training-system/internal/model"
 
 	"github.com/google/uuid"
@@ -12,10 +16,19 @@ import (
 type FolderRepository interface {
 	Create(ctx context.Context, folder *model.Folder) error
 	GetByID(ctx context.Context, id uuid.UUID) (*model.Folder, error)
+	GetByIDs(ctx context.Context, ids []uuid.UUID) ([]model.Folder, error)
 	GetByOwnerID(ctx context.Context, ownerID uuid.UUID) ([]model.Folder, error)
-	Update(ctx context.Context, folder *model.Folder) error
-	Delete(ctx context.Context, id uuid.UUID) error
-	GetSharedWithUser(ctx context.Context, userID uuid.UUID) ([]model.Folder, error)
+	Update(ctx context.Context, folder *model.Folder, expectedVersion int64) error
+	Delete(ctx context.Context, id uuid.UUID, expectedVersion int64) error
+	List(ctx context.Context, query ListQuery) ([]model.Folder, int64, error)
+	GetChildren(ctx context.Context, parentID uuid.UUID) ([]model.Folder, error)
+	GetPath(ctx context.Context, id uuid.UUID) ([]model.Folder, error)
+	GetVisibleToUser(ctx context.Context, userID uuid.UUID) ([]model.Folder, error)
+	Move(ctx context.Context, folder *model.Folder, parentID *uuid.UUID, dropShares bool, expectedVersion int64) error
+	HasContents(ctx context.Context, id uuid.UUID) (bool, error)
+	DeleteRecursive(ctx context.Context, id uuid.UUID, expectedVersion int64) error
+	GetSubtree(ctx context.Context, id uuid.UUID) ([]model.Folder, error)
+	Copy(ctx context.Context, folders []model.Folder, notes []model.Note, parentID *uuid.UUID, ownerID uuid.UUID) (*model.Folder, error)
 }
 
 type folderRepository struct {
@@ -32,33 +45,292 @@ func (r *folderRepository) Create(ctx context.Context, folder *model.Folder) err
 
 func (r *folderRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.Folder, error) {
 	var folder model.Folder
-	err := r.db.WithContext(ctx).Preload("Owner").Preload("Notes").Preload("Shares").First(&folder, "id = ?", id).Error
+	err := r.db.WithContext(ctx).Preload("Owner").Preload("Shares").First(&folder, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, apperror.ErrFolderNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
 	return &folder, nil
 }
 
+// GetByIDs returns the folders with the given ids; missing ones are left out.
+func (r *folderRepository) GetByIDs(ctx context.Context, ids []uuid.UUID) ([]model.Folder, error) {
+	var folders []model.Folder
+	if len(ids) == 0 {
+		return folders, nil
+	}
+	err := r.db.WithContext(ctx).Where("id IN ?", ids).Find(&folders).Error
+	return folders, err
+}
+
 func (r *folderRepository) GetByOwnerID(ctx context.Context, ownerID uuid.UUID) ([]model.Folder, error) {
 	var folders []model.Folder
-	err := r.db.WithContext(ctx).Preload("Notes").Where("owner_id = ?", ownerID).Find(&folders).Error
+	err := r.db.WithContext(ctx).Where("owner_id = ?", ownerID).Find(&folders).Error
+	return folders, err
+}
+
+// Update saves the folder's name and description. A non-zero expectedVersion
+// makes it fail with a *apperror.VersionConflictError if the folder has
+// changed.
+func (r *folderRepository) Update(ctx context.Context, folder *model.Folder, expectedVersion int64) error {
+	return updateVersioned(r.db.WithContext(ctx), folder, folder.ID, expectedVersion, map[string]interface{}{
+		"name":        folder.Name,
+		"description": folder.Description,
+	}, apperror.ErrFolderNotFound)
+}
+
+func (r *folderRepository) Delete(ctx context.Context, id uuid.UUID, expectedVersion int64) error {
+	return deleteVersioned(r.db.WithContext(ctx), &model.Folder{}, id, expectedVersion, apperror.ErrFolderNotFound)
+}
+
+// List returns one page of folders and the number of folders on all pages.
+// Folders both owned and shared, or shared more than one way, are listed
+// once.
+func (r *folderRepository) List(ctx context.Context, query ListQuery) ([]model.Folder, int64, error) {
+	args := map[string]interface{}{"user": query.UserID, "limit": query.Limit}
+
+	var filters strings.Builder
+	if query.FolderID != nil {
+		filters.WriteString(` AND f.parent_id = @folder`)
+		args["folder"] = *query.FolderID
+	} else {
+		filters.WriteString(` AND (f.owner_id = @user
+			OR f.id IN (SELECT folder_id FROM folder_shares WHERE ` + userShareCondition + `)
+			OR f.id IN (
+				SELECT s.folder_id FROM folder_team_shares s
+				JOIN team_user tu ON tu.team_id = s.team_id
+				WHERE ` + teamGrantCondition + `))`)
+	}
+	switch query.Scope {
+	case ScopeOwned:
+		filters.WriteString(` AND f.owner_id = @user`)
+	case ScopeShared:
+		filters.WriteString(` AND f.owner_id <> @user`)
+	}
+	if query.UpdatedSince != nil {
+		filters.WriteString(` AND f.updated_at >= @updated_since`)
+		args["updated_since"] = *query.UpdatedSince
+	}
+
+	var total int64
+	db := r.db.WithContext(ctx)
+	if err := db.Raw(`SELECT COUNT(*) FROM folders f WHERE f.deleted_at IS NULL`+filters.String(), args).
+		Scan(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	column := string(query.Sort)
+	order, after := listOrder(query, "f", column, args)
+	var folders []model.Folder
+	err := db.Raw(`
+		SELECT f.id, f.name, f.description, f.parent_id, f.owner_id, f.version, f.created_at, f.updated_at
+		FROM folders f
+		WHERE f.deleted_at IS NULL`+filters.String()+after+order+`
+		LIMIT @limit`, args).
+		Scan(&folders).Error
+	return folders, total, err
+}
+
+// folderSubtreeCTE selects the folder identified by @root together with every
+// non-deleted descendant, tagging each row with its depth below the root.
+const folderSubtreeCTE = `WITH RECURSIVE subtree AS (
+	SELECT id, parent_id, 0 AS depth FROM folders WHERE id = @root AND deleted_at IS NULL
+	UNION ALL
+	SELECT f.id, f.parent_id, s.depth + 1 FROM folders f
+	JOIN subtree s ON f.parent_id = s.id
+	WHERE f.deleted_at IS NULL
+)`
+
+// folderChainCTE selects the folder identified by @root and all of its
+// ancestors up to the top-level folder.
+const folderChainCTE = `WITH RECURSIVE chain AS (
+	SELECT id, parent_id, 0 AS depth FROM folders WHERE id = @root AND deleted_at IS NULL
+	UNION ALL
+	SELECT f.id, f.parent_id, c.depth + 1 FROM folders f
+	JOIN chain c ON f.id = c.parent_id
+	WHERE f.deleted_at IS NULL
+)`
+
+// visibleFoldersCTE selects every folder @user owns or has been shared,
+// directly or through a team, together with all of their subfolders.
+const visibleFoldersCTE = `WITH RECURSIVE visible AS (
+	SELECT f.id FROM folders f
+	WHERE f.deleted_at IS NULL AND (
+		f.owner_id = @user
+		OR f.id IN (SELECT folder_id FROM folder_shares WHERE ` + userShareCondition + `)
+		OR f.id IN (
+			SELECT s.folder_id FROM folder_team_shares s
+			JOIN team_user tu ON tu.team_id = s.team_id
+			WHERE ` + teamGrantCondition + `))
+	UNION
+	SELECT c.id FROM folders c
+	JOIN visible v ON c.parent_id = v.id
+	WHERE c.deleted_at IS NULL
+)`
+
+func (r *folderRepository) GetChildren(ctx context.Context, parentID uuid.UUID) ([]model.Folder, error) {
+	var folders []model.Folder
+	err := r.db.WithContext(ctx).Where("parent_id = ?", parentID).Order("name").Find(&folders).Error
 	return folders, err
 }
 
-func (r *folderRepository) Update(ctx context.Context, folder *model.Folder) error {
-	return r.db.WithContext(ctx).Save(folder).Error
+// GetPath returns the folder and its ancestors, top-level folder first.
+func (r *folderRepository) GetPath(ctx context.Context, id uuid.UUID) ([]model.Folder, error) {
+	var folders []model.Folder
+	err := r.db.WithContext(ctx).Raw(folderChainCTE+`
+		SELECT f.* FROM chain c JOIN folders f ON f.id = c.id
+		ORDER BY c.depth DESC`, map[string]interface{}{"root": id}).
+		Scan(&folders).Error
+	return folders, err
+}
+
+// GetVisibleToUser returns every folder the user owns or has been shared,
+// directly or through a team, together with all of their subfolders.
+func (r *folderRepository) GetVisibleToUser(ctx context.Context, userID uuid.UUID) ([]model.Folder, error) {
+	var folders []model.Folder
+	err := r.db.WithContext(ctx).Raw(visibleFoldersCTE+`
+	SELECT f.* FROM folders f WHERE f.id IN (SELECT id FROM visible)
+	ORDER BY f.name`, map[string]interface{}{"user": userID}).
+		Scan(&folders).Error
+	return folders, err
+}
+
+// Move reparents a folder; a nil parentID makes it a top-level folder. Its
+// notes and subfolders move with it. With dropShares the user and team
+// shares granted on the folder itself are removed, so access comes only from
+// the new parent; shares inside the folder are kept.
+func (r *folderRepository) Move(ctx context.Context, folder *model.Folder, parentID *uuid.UUID, dropShares bool, expectedVersion int64) error {
+	id := folder.ID
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Serialize hierarchy changes so two concurrent moves cannot create a cycle
+		if err := lockHierarchy(tx); err != nil {
+			return err
+		}
+
+		if parentID != nil {
+			var inSubtree bool
+			err := tx.Raw(folderSubtreeCTE+` SELECT EXISTS (SELECT 1 FROM subtree WHERE id = @parent)`,
+				map[string]interface{}{"root": id, "parent": *parentID}).
+				Scan(&inSubtree).Error
+			if err != nil {
+				return err
+			}
+			if inSubtree {
+				return apperror.ErrFolderCycle
+			}
+		}
+
+		err := updateVersioned(tx, folder, id, expectedVersion, map[string]interface{}{
+			"parent_id": parentID,
+		}, apperror.ErrFolderNotFound)
+		if err != nil {
+			return err
+		}
+		folder.ParentID = parentID
+
+		if !dropShares {
+			return nil
+		}
+		for _, share := range []interface{}{&model.FolderShare{}, &model.FolderTeamShare{}} {
+			if err := tx.Where("folder_id = ?", id).Delete(share).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// HasContents reports whether the folder has any subfolders or notes.
+func (r *folderRepository) HasContents(ctx context.Context, id uuid.UUID) (bool, error) {
+	var exists bool
+	err := r.db.WithContext(ctx).Raw(`SELECT
+		EXISTS (SELECT 1 FROM folders WHERE parent_id = @id AND deleted_at IS NULL)
+		OR EXISTS (SELECT 1 FROM notes WHERE folder_id = @id AND deleted_at IS NULL)`,
+		map[string]interface{}{"id": id}).
+		Scan(&exists).Error
+	return exists, err
 }
 
-func (r *folderRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	return r.db.WithContext(ctx).Delete(&model.Folder{}, "id = ?", id).Error
+// DeleteRecursive soft deletes the folder, all of its subfolders and every
+// note in them, stamping them with the same deletion time. A non-zero
+// expectedVersion is checked against the folder itself.
+func (r *folderRepository) DeleteRecursive(ctx context.Context, id uuid.UUID, expectedVersion int64) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := checkVersion(tx, &model.Folder{}, id, expectedVersion, apperror.ErrFolderNotFound); err != nil {
+			return err
+		}
+
+		var ids []uuid.UUID
+		err := tx.Raw(folderSubtreeCTE+` SELECT id FROM subtree`, map[string]interface{}{"root": id}).
+			Scan(&ids).Error
+		if err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return apperror.ErrFolderNotFound
+		}
+
+		now := time.Now()
+		if err := tx.Model(&model.Note{}).Where("folder_id IN ?", ids).Update("deleted_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.Folder{}).Where("id IN ?", ids).Update("deleted_at", now).Error
+	})
 }
 
-func (r *folderRepository) GetSharedWithUser(ctx context.Context, userID uuid.UUID) ([]model.Folder, error) {
+// GetSubtree returns the folder and all of its subfolders, each one after
+// its parent.
+func (r *folderRepository) GetSubtree(ctx context.Context, id uuid.UUID) ([]model.Folder, error) {
 	var folders []model.Folder
-	err := r.db.WithContext(ctx).
-		Joins("JOIN folder_shares ON folders.id = folder_shares.folder_id").
-		Where("folder_shares.user_id = ?", userID).
-		Preload("Owner").
-		Find(&folders).Error
+	err := r.db.WithContext(ctx).Raw(folderSubtreeCTE+`
+		SELECT f.* FROM subtree s JOIN folders f ON f.id = s.id
+		ORDER BY s.depth, f.name`, map[string]interface{}{"root": id}).
+		Scan(&folders).Error
 	return folders, err
 }
+
+// Copy recreates a subtree read with GetSubtree under parentID, or at the top
+// level when parentID is nil, together with copies of the given notes from
+// its folders. Everything copied gets a new ID and is owned by ownerID. It
+// returns the copy of the subtree's root.
+func (r *folderRepository) Copy(ctx context.Context, folders []model.Folder, notes []model.Note, parentID *uuid.UUID, ownerID uuid.UUID) (*model.Folder, error) {
+	if len(folders) == 0 {
+		return nil, apperror.ErrFolderNotFound
+	}
+
+	copies := make([]model.Folder, len(folders))
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		copyOf := make(map[uuid.UUID]uuid.UUID, len(folders))
+		for i, folder := range folders {
+			copies[i] = model.Folder{
+				Name:        folder.Name,
+				Description: folder.Description,
+				ParentID:    parentID,
+				OwnerID:     ownerID,
+			}
+			if i > 0 {
+				parent := copyOf[*folder.ParentID]
+				copies[i].ParentID = &parent
+			}
+			if err := tx.Create(&copies[i]).Error; err != nil {
+				return err
+			}
+			copyOf[folder.ID] = copies[i].ID
+		}
+
+		_, err := copyNotes(tx, notes, func(note model.Note) uuid.UUID { return copyOf[note.FolderID] }, ownerID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &copies[0], nil
+}
+
+// lockHierarchy takes the transaction-scoped lock that serializes changes to
+// the shape of the folder tree.
+func lockHierarchy(tx *gorm.DB) error {
+	return tx.Exec("SELECT pg_advisory_xact_lock(hashtext('folders_hierarchy'))").Error
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"go-training-system/internal/model"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ImportSet is what an archive import creates. Folders come parents first
+// and, like the notes, already carry their new IDs. Tags maps a note ID to
+// the names of the personal tags to put on it.
+type ImportSet struct {
+	Folders []model.Folder
+	Notes   []model.Note
+	Tags    map[uuid.UUID][]string
+}
+
+// ImportRepository stores imported folders and notes.
+type ImportRepository interface {
+	Import(ctx context.Context, set *ImportSet, ownerID uuid.UUID) error
+}
+
+type importRepository struct {
+	db *gorm.DB
+}
+
+func NewImportRepository(db *gorm.DB) ImportRepository {
+	return &importRepository{db: db}
+}
+
+// Import creates everything in set in one transaction, owned by ownerID.
+// Each note gets a first revision. Tag names are matched against the owner's
+// personal tags ignoring case, and missing tags are created.
+func (r *importRepository) Import(ctx context.Context, set *ImportSet, ownerID uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for i := range set.Folders {
+			set.Folders[i].OwnerID = ownerID
+			if err := tx.Create(&set.Folders[i]).Error; err != nil {
+				return err
+			}
+		}
+
+		tagIDs := make(map[string]uuid.UUID)
+		for i := range set.Notes {
+			note := &set.Notes[i]
+			note.OwnerID = ownerID
+			if err := tx.Create(note).Error; err != nil {
+				return err
+			}
+			if err := addRevision(tx, note, ownerID); err != nil {
+				return err
+			}
+
+			for _, name := range set.Tags[note.ID] {
+				tagID, err := personalTag(tx, tagIDs, name, ownerID)
+				if err != nil {
+					return err
+				}
+				err = tx.Exec(`
+					INSERT INTO note_tags (note_id, tag_id, tagged_by_id, tagged_at)
+					VALUES (?, ?, ?, ?)
+					ON CONFLICT (note_id, tag_id) DO NOTHING`,
+					note.ID, tagID, ownerID, time.Now()).Error
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// personalTag returns the ID of the user's tag called name, creating the tag
+// if needed. known caches the tags already looked up, by lower-cased name.
+func personalTag(tx *gorm.DB, known map[string]uuid.UUID, name string, userID uuid.UUID) (uuid.UUID, error) {
+	key := strings.ToLower(name)
+	if id, ok := known[key]; ok {
+		return id, nil
+	}
+
+	var tag model.Tag
+	err := tx.Where("user_id = ? AND team_id IS NULL AND LOWER(name) = ?", userID, key).First(&tag).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		tag = model.Tag{Name: name, UserID: &userID, CreatedByID: userID}
+		err = tx.Create(&tag).Error
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+	known[key] = tag.ID
+	return tag.ID, nil
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"time"
+
+	"go-training-system/pkg/tagexpr"
+
+	"github.com/google/uuid"
+)
+
+// ListScope narrows a list to what the user owns or to what others shared
+// with them.
+type ListScope string
+
+const (
+	ScopeAll    ListScope = "all"
+	ScopeOwned  ListScope = "owned"
+	ScopeShared ListScope = "shared"
+)
+
+// ListSort is the column a list is ordered by. Ties are broken by id, so the
+// order is stable across pages. SortName orders notes by title.
+type ListSort string
+
+const (
+	SortUpdatedAt ListSort = "updated_at"
+	SortCreatedAt ListSort = "created_at"
+	SortName      ListSort = "name"
+)
+
+// ListPosition is where a page ends: the sort value and id of its last row.
+// Time holds the value for the time sorts and Name for SortName.
+type ListPosition struct {
+	Time time.Time
+	Name string
+	ID   uuid.UUID
+}
+
+// ListQuery pages through the folders or notes UserID can see. Without
+// FolderID those are the ones they own and the ones shared with them
+// directly or through a team; with it, everything directly in that folder,
+// which the caller must already have checked UserID can read. UpdatedSince
+// is inclusive, and a non-nil Tags keeps only notes matching the tag
+// expression. After is the position of the last row of the previous page;
+// nil starts from the beginning.
+type ListQuery struct {
+	UserID       uuid.UUID
+	Scope        ListScope
+	FolderID     *uuid.UUID
+	UpdatedSince *time.Time
+	Tags         tagexpr.Expr
+	Sort         ListSort
+	Descending   bool
+	After        *ListPosition
+	Limit        int
+}
+
+// listOrder returns the ORDER BY clause for the query and the keyset
+// condition that skips to the rows after q.After, with its value in args.
+// column is the sort column on alias, which is used for the id as well.
+func listOrder(q ListQuery, alias, column string, args map[string]interface{}) (order, after string) {
+	dir, cmp := "ASC", ">"
+	if q.Descending {
+		dir, cmp = "DESC", "<"
+	}
+	order = ` ORDER BY ` + alias + `.` + column + ` ` + dir + `, ` + alias + `.id ` + dir
+	if q.After == nil {
+		return order, ""
+	}
+	if q.Sort == SortName {
+		args["after_value"] = q.After.Name
+	} else {
+		args["after_value"] = q.After.Time
+	}
+	args["after_id"] = q.After.ID
+	after = ` AND (` + alias + `.` + column + `, ` + alias + `.id) ` + cmp + ` (@after_value, @after_id)`
+	return order, after
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestListOrder(t *testing.T) {
+	id := uuid.New()
+	at := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		query     ListQuery
+		column    string
+		wantOrder string
+		wantAfter string
+		wantValue interface{}
+	}{
+		{
+			name:      "first page ascending",
+			query:     ListQuery{Sort: SortName},
+			column:    "title",
+			wantOrder: ` ORDER BY n.title ASC, n.id ASC`,
+		},
+		{
+			name:      "first page descending",
+			query:     ListQuery{Sort: SortUpdatedAt, Descending: true},
+			column:    "updated_at",
+			wantOrder: ` ORDER BY n.updated_at DESC, n.id DESC`,
+		},
+		{
+			name:      "later page by time",
+			query:     ListQuery{Sort: SortCreatedAt, Descending: true, After: &ListPosition{Time: at, ID: id}},
+			column:    "created_at",
+			wantOrder: ` ORDER BY n.created_at DESC, n.id DESC`,
+			wantAfter: ` AND (n.created_at, n.id) < (@after_value, @after_id)`,
+			wantValue: at,
+		},
+		{
+			name:      "later page by name",
+			query:     ListQuery{Sort: SortName, After: &ListPosition{Name: "b", ID: id}},
+			column:    "title",
+			wantOrder: ` ORDER BY n.title ASC, n.id ASC`,
+			wantAfter: ` AND (n.title, n.id) > (@after_value, @after_id)`,
+			wantValue: "b",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := map[string]interface{}{}
+			order, after := listOrder(tt.query, "n", tt.column, args)
+			if order != tt.wantOrder {
+				t.Errorf("listOrder() order = %q, want %q", order, tt.wantOrder)
+			}
+			if after != tt.wantAfter {
+				t.Errorf("listOrder() after = %q, want %q", after, tt.wantAfter)
+			}
+			if tt.query.After == nil {
+				if len(args) != 0 {
+					t.Errorf("listOrder() args = %v, want none", args)
+				}
+				return
+			}
+			if args["after_value"] != tt.wantValue || args["after_id"] != id {
+				t.Errorf("listOrder() args = %v, want after_value %v and after_id %v", args, tt.wantValue, id)
+			}
+		})
+	}
+}
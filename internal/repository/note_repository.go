@@ -2,8 +2,16 @@ package repository
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"html"
+	"strings"
+	"time"
 
+	"go-training-system/internal/graph/apperror"
 	"go-training-system/internal/model"
+	"go-training-system/pkg/tagexpr"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -13,10 +21,47 @@ type NoteRepository interface {
 	Create(ctx context.Context, note *model.Note) error
 	GetByID(ctx context.Context, id uuid.UUID) (*model.Note, error)
 	GetByFolderID(ctx context.Context, folderID uuid.UUID) ([]model.Note, error)
-	GetByOwnerID(ctx context.Context, ownerID uuid.UUID) ([]model.Note, error)
-	Update(ctx context.Context, note *model.Note) error
-	Delete(ctx context.Context, id uuid.UUID) error
-	GetSharedWithUser(ctx context.Context, userID uuid.UUID) ([]model.Note, error)
+	Update(ctx context.Context, note *model.Note, authorID uuid.UUID, expectedVersion int64) error
+	Delete(ctx context.Context, id uuid.UUID, expectedVersion int64) error
+	List(ctx context.Context, query ListQuery) ([]model.Note, int64, error)
+	GetRevisions(ctx context.Context, noteID uuid.UUID) ([]model.NoteRevision, error)
+	GetRevision(ctx context.Context, noteID uuid.UUID, revision int) (*model.NoteRevision, error)
+	PruneRevisions(ctx context.Context, noteID uuid.UUID, keep int) error
+	Search(ctx context.Context, query NoteSearchQuery) ([]NoteSearchHit, error)
+	GetByIDs(ctx context.Context, ids []uuid.UUID) ([]model.Note, error)
+	GetByFolderIDs(ctx context.Context, folderIDs []uuid.UUID) ([]model.Note, error)
+	Move(ctx context.Context, ids []uuid.UUID, folderID uuid.UUID, dropShares bool) error
+	Copy(ctx context.Context, notes []model.Note, folderID uuid.UUID, ownerID uuid.UUID) ([]model.Note, error)
+}
+
+// NoteSearchQuery is a full-text search over the notes UserID can reach.
+// Query uses web search syntax: quoted phrases, "or" and "-" to exclude.
+// FolderID also matches notes in its subfolders, and the update time range
+// is inclusive of UpdatedFrom and exclusive of UpdatedTo. A non-nil Tags
+// keeps only notes matching the tag expression.
+type NoteSearchQuery struct {
+	Query       string
+	Tags        tagexpr.Expr
+	UserID      uuid.UUID
+	FolderID    *uuid.UUID
+	OwnerID     *uuid.UUID
+	UpdatedFrom *time.Time
+	UpdatedTo   *time.Time
+	Limit       int
+	Offset      int
+}
+
+// NoteSearchHit is a matching note with its rank and an HTML-escaped body
+// snippet in which the matched terms are wrapped in <mark> tags.
+type NoteSearchHit struct {
+	ID        uuid.UUID
+	Title     string
+	FolderID  uuid.UUID
+	OwnerID   uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Rank      float64
+	Snippet   string
 }
 
 type noteRepository struct {
@@ -27,13 +72,22 @@ func NewNoteRepository(db *gorm.DB) NoteRepository {
 	return &noteRepository{db: db}
 }
 
+// Create stores the note and its content as revision 1.
 func (r *noteRepository) Create(ctx context.Context, note *model.Note) error {
-	return r.db.WithContext(ctx).Create(note).Error
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(note).Error; err != nil {
+			return err
+		}
+		return addRevision(tx, note, note.OwnerID)
+	})
 }
 
 func (r *noteRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.Note, error) {
 	var note model.Note
 	err := r.db.WithContext(ctx).Preload("Owner").Preload("Folder").Preload("Shares").First(&note, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, apperror.ErrNoteNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -46,27 +100,277 @@ func (r *noteRepository) GetByFolderID(ctx context.Context, folderID uuid.UUID)
 	return notes, err
 }
 
-func (r *noteRepository) GetByOwnerID(ctx context.Context, ownerID uuid.UUID) ([]model.Note, error) {
+func (r *noteRepository) GetByIDs(ctx context.Context, ids []uuid.UUID) ([]model.Note, error) {
+	var notes []model.Note
+	err := r.db.WithContext(ctx).Where("id IN ?", ids).Order("created_at").Find(&notes).Error
+	return notes, err
+}
+
+func (r *noteRepository) GetByFolderIDs(ctx context.Context, folderIDs []uuid.UUID) ([]model.Note, error) {
 	var notes []model.Note
-	err := r.db.WithContext(ctx).Where("owner_id = ?", ownerID).Find(&notes).Error
+	if len(folderIDs) == 0 {
+		return notes, nil
+	}
+	err := r.db.WithContext(ctx).Where("folder_id IN ?", folderIDs).Order("created_at").Find(&notes).Error
 	return notes, err
 }
 
-func (r *noteRepository) Update(ctx context.Context, note *model.Note) error {
-	return r.db.WithContext(ctx).Save(note).Error
+// Update saves the note's title and body and records them as the next
+// revision, attributed to authorID. A non-zero expectedVersion makes the
+// update fail with a *apperror.VersionConflictError if the note has changed.
+func (r *noteRepository) Update(ctx context.Context, note *model.Note, authorID uuid.UUID, expectedVersion int64) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// The UPDATE locks the note row, so concurrent updates number their
+		// revisions one after the other.
+		err := updateVersioned(tx, note, note.ID, expectedVersion, map[string]interface{}{
+			"title": note.Title,
+			"body":  note.Body,
+		}, apperror.ErrNoteNotFound)
+		if err != nil {
+			return err
+		}
+		return addRevision(tx, note, authorID)
+	})
+}
+
+func addRevision(tx *gorm.DB, note *model.Note, authorID uuid.UUID) error {
+	var last int
+	err := tx.Model(&model.NoteRevision{}).Where("note_id = ?", note.ID).
+		Select("COALESCE(MAX(revision), 0)").Scan(&last).Error
+	if err != nil {
+		return err
+	}
+	return tx.Create(&model.NoteRevision{
+		NoteID:   note.ID,
+		Revision: last + 1,
+		Title:    note.Title,
+		Body:     note.Body,
+		AuthorID: authorID,
+	}).Error
+}
+
+func (r *noteRepository) Delete(ctx context.Context, id uuid.UUID, expectedVersion int64) error {
+	return deleteVersioned(r.db.WithContext(ctx), &model.Note{}, id, expectedVersion, apperror.ErrNoteNotFound)
 }
 
-func (r *noteRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	return r.db.WithContext(ctx).Delete(&model.Note{}, "id = ?", id).Error
+// Move puts the notes into folderID, bumping their versions. With dropShares
+// the notes' own user and team shares are removed; otherwise they keep
+// applying in the new folder. It fails without moving anything if one of the
+// notes is gone.
+func (r *noteRepository) Move(ctx context.Context, ids []uuid.UUID, folderID uuid.UUID, dropShares bool) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&model.Note{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"folder_id": folderID,
+			"version":   gorm.Expr("version + 1"),
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected != int64(len(ids)) {
+			return apperror.ErrNoteNotFound
+		}
+
+		if !dropShares {
+			return nil
+		}
+		for _, share := range []interface{}{&model.NoteShare{}, &model.NoteTeamShare{}} {
+			if err := tx.Where("note_id IN ?", ids).Delete(share).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Copy creates copies of the notes in folderID, owned by ownerID, with the
+// same attachments. Revisions, tags and shares are not copied.
+func (r *noteRepository) Copy(ctx context.Context, notes []model.Note, folderID uuid.UUID, ownerID uuid.UUID) ([]model.Note, error) {
+	var copies []model.Note
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		copies, err = copyNotes(tx, notes, func(model.Note) uuid.UUID { return folderID }, ownerID)
+		return err
+	})
+	return copies, err
 }
 
-func (r *noteRepository) GetSharedWithUser(ctx context.Context, userID uuid.UUID) ([]model.Note, error) {
+// List returns one page of notes and the number of notes on all pages.
+// Notes both owned and shared, or shared more than one way, are listed once.
+// Notes a per-note override denies the user are left out.
+func (r *noteRepository) List(ctx context.Context, query ListQuery) ([]model.Note, int64, error) {
+	args := map[string]interface{}{"user": query.UserID, "limit": query.Limit}
+
+	var filters strings.Builder
+	if query.FolderID != nil {
+		filters.WriteString(` AND n.folder_id = @folder`)
+		args["folder"] = *query.FolderID
+	} else {
+		filters.WriteString(` AND (n.owner_id = @user
+			OR n.id IN (SELECT note_id FROM note_shares WHERE ` + userShareCondition + `)
+			OR n.id IN (
+				SELECT s.note_id FROM note_team_shares s
+				JOIN team_user tu ON tu.team_id = s.team_id
+				WHERE ` + teamGrantCondition + `))`)
+	}
+	filters.WriteString(` AND NOT EXISTS (
+		SELECT 1 FROM note_shares ns
+		WHERE ns.note_id = n.id AND ns.user_id = @user AND ns.override AND ns.access = 'none'
+		AND (ns.expires_at IS NULL OR ns.expires_at > NOW()))`)
+	switch query.Scope {
+	case ScopeOwned:
+		filters.WriteString(` AND n.owner_id = @user`)
+	case ScopeShared:
+		filters.WriteString(` AND n.owner_id <> @user`)
+	}
+	if query.UpdatedSince != nil {
+		filters.WriteString(` AND n.updated_at >= @updated_since`)
+		args["updated_since"] = *query.UpdatedSince
+	}
+	if query.Tags != nil {
+		filters.WriteString(` AND ` + tagExprCondition(query.Tags, args))
+	}
+
+	var total int64
+	db := r.db.WithContext(ctx)
+	if err := db.Raw(`SELECT COUNT(*) FROM notes n WHERE n.deleted_at IS NULL`+filters.String(), args).
+		Scan(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	column := string(query.Sort)
+	if query.Sort == SortName {
+		column = "title"
+	}
+	order, after := listOrder(query, "n", column, args)
 	var notes []model.Note
-	err := r.db.WithContext(ctx).
-		Joins("JOIN note_shares ON notes.id = note_shares.note_id").
-		Where("note_shares.user_id = ?", userID).
-		Preload("Owner").
-		Preload("Folder").
-		Find(&notes).Error
-	return notes, err
+	err := db.Raw(`
+		SELECT n.id, n.title, n.body, n.folder_id, n.owner_id, n.version, n.created_at, n.updated_at
+		FROM notes n
+		WHERE n.deleted_at IS NULL`+filters.String()+after+order+`
+		LIMIT @limit`, args).
+		Scan(&notes).Error
+	return notes, total, err
+}
+
+// GetRevisions lists a note's revisions, newest first, without their bodies.
+func (r *noteRepository) GetRevisions(ctx context.Context, noteID uuid.UUID) ([]model.NoteRevision, error) {
+	var revisions []model.NoteRevision
+	err := r.db.WithContext(ctx).Omit("body").Preload("Author").
+		Where("note_id = ?", noteID).Order("revision DESC").Find(&revisions).Error
+	return revisions, err
+}
+
+func (r *noteRepository) GetRevision(ctx context.Context, noteID uuid.UUID, revision int) (*model.NoteRevision, error) {
+	var rev model.NoteRevision
+	err := r.db.WithContext(ctx).Preload("Author").
+		First(&rev, "note_id = ? AND revision = ?", noteID, revision).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, apperror.ErrRevisionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rev, nil
+}
+
+// PruneRevisions deletes all but the newest keep revisions of a note.
+func (r *noteRepository) PruneRevisions(ctx context.Context, noteID uuid.UUID, keep int) error {
+	return r.db.WithContext(ctx).Exec(`
+		DELETE FROM note_revisions
+		WHERE note_id = @note AND revision <= (
+			SELECT MAX(revision) - @keep FROM note_revisions WHERE note_id = @note
+		)`, map[string]interface{}{"note": noteID, "keep": keep}).Error
+}
+
+// Search ranks matching notes by relevance. It narrows the results to notes
+// the user owns, notes in folders they can see and notes shared with them;
+// per-note overrides are left to the permission resolver.
+func (r *noteRepository) Search(ctx context.Context, query NoteSearchQuery) ([]NoteSearchHit, error) {
+	startSel, err := newHighlightSentinel()
+	if err != nil {
+		return nil, err
+	}
+	stopSel, err := newHighlightSentinel()
+	if err != nil {
+		return nil, err
+	}
+	args := map[string]interface{}{
+		"query":     query.Query,
+		"user":      query.UserID,
+		"limit":     query.Limit,
+		"offset":    query.Offset,
+		"start_sel": startSel,
+		"stop_sel":  stopSel,
+	}
+
+	var filters strings.Builder
+	if query.FolderID != nil {
+		filters.WriteString(` AND n.folder_id IN (` + strings.ReplaceAll(folderSubtreeCTE, "@root", "@folder") + ` SELECT id FROM subtree)`)
+		args["folder"] = *query.FolderID
+	}
+	if query.OwnerID != nil {
+		filters.WriteString(` AND n.owner_id = @owner`)
+		args["owner"] = *query.OwnerID
+	}
+	if query.UpdatedFrom != nil {
+		filters.WriteString(` AND n.updated_at >= @updated_from`)
+		args["updated_from"] = *query.UpdatedFrom
+	}
+	if query.UpdatedTo != nil {
+		filters.WriteString(` AND n.updated_at < @updated_to`)
+		args["updated_to"] = *query.UpdatedTo
+	}
+	if query.Tags != nil {
+		filters.WriteString(` AND ` + tagExprCondition(query.Tags, args))
+	}
+
+	// The body is raw user text, so matches are marked with random sentinels
+	// and the snippet is escaped before they are swapped for <mark> tags
+	var hits []NoteSearchHit
+	err = r.db.WithContext(ctx).Raw(`
+		SELECT n.id, n.title, n.folder_id, n.owner_id, n.created_at, n.updated_at,
+			ts_rank(n.search_vector, q.query) AS rank,
+			ts_headline('english', n.body, q.query,
+				'StartSel=' || @start_sel || ', StopSel=' || @stop_sel ||
+				', MaxFragments=2, MaxWords=30, MinWords=10') AS snippet
+		FROM notes n
+		CROSS JOIN websearch_to_tsquery('english', @query) AS q(query)
+		WHERE n.deleted_at IS NULL AND n.search_vector @@ q.query
+		AND (
+			n.owner_id = @user
+			OR n.folder_id IN (`+visibleFoldersCTE+` SELECT id FROM visible)
+			OR n.id IN (SELECT note_id FROM note_shares WHERE `+userShareCondition+`)
+			OR n.id IN (
+				SELECT s.note_id FROM note_team_shares s
+				JOIN team_user tu ON tu.team_id = s.team_id
+				WHERE `+teamGrantCondition+`))`+filters.String()+`
+		ORDER BY rank DESC, n.updated_at DESC
+		LIMIT @limit OFFSET @offset`, args).
+		Scan(&hits).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range hits {
+		hits[i].Snippet = highlightSnippet(hits[i].Snippet, startSel, stopSel)
+	}
+	return hits, nil
+}
+
+// newHighlightSentinel returns a marker no note body will contain. It is
+// hex so that it survives both ts_headline's option parsing and HTML
+// escaping unchanged.
+func newHighlightSentinel() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// highlightSnippet escapes a ts_headline snippet and turns the sentinels
+// around its matches into <mark> tags.
+func highlightSnippet(snippet, startSel, stopSel string) string {
+	snippet = html.EscapeString(snippet)
+	snippet = strings.ReplaceAll(snippet, startSel, "<mark>")
+	return strings.ReplaceAll(snippet, stopSel, "</mark>")
 }
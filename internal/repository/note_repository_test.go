@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"html"
+	"testing"
+)
+
+func TestHighlightSnippet(t *testing.T) {
+	const start, stop = "8f1c0a", "d42e9b"
+
+	tests := []struct {
+		name    string
+		snippet string
+		want    string
+	}{
+		{
+			name:    "plain text",
+			snippet: "a " + start + "match" + stop + " here",
+			want:    "a <mark>match</mark> here",
+		},
+		{
+			name:    "script in the body",
+			snippet: "<script>alert(1)</script> " + start + "alert" + stop,
+			want:    "&lt;script&gt;alert(1)&lt;/script&gt; <mark>alert</mark>",
+		},
+		{
+			name:    "literal mark tags in the body",
+			snippet: `<mark onmouseover="x()">` + start + "go" + stop + "</mark>",
+			want:    "&lt;mark onmouseover=&#34;x()&#34;&gt;<mark>go</mark>&lt;/mark&gt;",
+		},
+		{
+			name:    "no matches",
+			snippet: "1 < 2 & 3 > 2",
+			want:    "1 &lt; 2 &amp; 3 &gt; 2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := highlightSnippet(tt.snippet, start, stop); got != tt.want {
+				t.Errorf("highlightSnippet() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewHighlightSentinel(t *testing.T) {
+	a, err := newHighlightSentinel()
+	if err != nil {
+		t.Fatalf("newHighlightSentinel() error = %v", err)
+	}
+	b, err := newHighlightSentinel()
+	if err != nil {
+		t.Fatalf("newHighlightSentinel() error = %v", err)
+	}
+	if a == b {
+		t.Errorf("newHighlightSentinel() returned %q twice", a)
+	}
+	for _, s := range []string{a, b} {
+		if got := html.EscapeString(s); got != s {
+			t.Errorf("escaping changed sentinel %q to %q", s, got)
+		}
+	}
+}
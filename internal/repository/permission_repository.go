@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go-training-system/internal/model"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AccessGrants is everything that can give one user access to a folder or
+// note. Folder grants include those on every ancestor folder, since access is
+// inherited down the tree. Levels the user has no grant for are empty, and
+// for folders the note fields are always empty.
+type AccessGrants struct {
+	ID      uuid.UUID
+	OwnerID uuid.UUID
+	// OwnsFolder is set when the user owns the folder (for a note, the
+	// containing folder) or any folder above it.
+	OwnsFolder bool
+	FolderUser model.AccessLevel
+	FolderTeam model.AccessLevel
+	NoteUser   model.AccessLevel
+	NoteTeam   model.AccessLevel
+	// NoteOverride marks NoteUser as an override of everything inherited.
+	NoteOverride bool
+}
+
+// PermissionRepository loads the grants the permission resolver combines,
+// one query per call regardless of how many notes are asked about.
+type PermissionRepository interface {
+	GetFolderGrants(ctx context.Context, folderID, userID uuid.UUID) (*AccessGrants, error)
+	GetFoldersGrants(ctx context.Context, folderIDs []uuid.UUID, userID uuid.UUID) ([]AccessGrants, error)
+	GetNoteGrants(ctx context.Context, noteIDs []uuid.UUID, userID uuid.UUID) ([]AccessGrants, error)
+}
+
+type permissionRepository struct {
+	db *gorm.DB
+}
+
+func NewPermissionRepository(db *gorm.DB) PermissionRepository {
+	return &permissionRepository{db: db}
+}
+
+// folderGrantsCTE walks from each folder in @folders up to its top-level
+// folder and folds the user's grants along the way into one folder_grants row
+// per starting folder. Access is ranked 3 for manage, 2 for write and 1 for
+// read. Expired shares grant nothing.
+const folderGrantsCTE = `WITH RECURSIVE chain AS (
+	SELECT f.id AS start_id, f.id, f.parent_id, f.owner_id FROM folders f
+	WHERE f.id IN @folders AND f.deleted_at IS NULL
+	UNION
+	SELECT c.start_id, p.id, p.parent_id, p.owner_id FROM folders p
+	JOIN chain c ON p.id = c.parent_id
+	WHERE p.deleted_at IS NULL
+),
+folder_grants AS (
+	SELECT c.start_id,
+		BOOL_OR(c.owner_id = @user) AS owns_folder,
+		COALESCE(MAX((
+			SELECT CASE fs.access WHEN 'manage' THEN 3 WHEN 'write' THEN 2 WHEN 'read' THEN 1 END
+			FROM folder_shares fs
+			WHERE fs.folder_id = c.id AND fs.user_id = @user
+			AND (fs.expires_at IS NULL OR fs.expires_at > NOW()))), 0) AS user_rank,
+		COALESCE(MAX((
+			SELECT MAX(CASE s.access WHEN 'write' THEN 2 WHEN 'read' THEN 1 END)
+			FROM folder_team_shares s
+			JOIN team_user tu ON tu.team_id = s.team_id
+			WHERE s.folder_id = c.id AND ` + teamGrantCondition + `)), 0) AS team_rank
+	FROM chain c
+	GROUP BY c.start_id
+)`
+
+const (
+	rankToAccess = `CASE %s WHEN 3 THEN 'manage' WHEN 2 THEN 'write' WHEN 1 THEN 'read' ELSE '' END`
+
+	noteTeamGrant = `COALESCE((
+		SELECT s.access FROM note_team_shares s
+		JOIN team_user tu ON tu.team_id = s.team_id
+		WHERE s.note_id = n.id AND ` + teamGrantCondition + `
+		ORDER BY CASE s.access WHEN 'write' THEN 0 ELSE 1 END LIMIT 1), '')`
+)
+
+// GetFolderGrants returns nil when the folder does not exist.
+func (r *permissionRepository) GetFolderGrants(ctx context.Context, folderID, userID uuid.UUID) (*AccessGrants, error) {
+	grants, err := r.GetFoldersGrants(ctx, []uuid.UUID{folderID}, userID)
+	if err != nil || len(grants) == 0 {
+		return nil, err
+	}
+	return &grants[0], nil
+}
+
+// GetFoldersGrants returns one entry per existing folder; missing folders
+// are left out.
+func (r *permissionRepository) GetFoldersGrants(ctx context.Context, folderIDs []uuid.UUID, userID uuid.UUID) ([]AccessGrants, error) {
+	var grants []AccessGrants
+	if len(folderIDs) == 0 {
+		return grants, nil
+	}
+	err := r.db.WithContext(ctx).Raw(folderGrantsCTE+`
+		SELECT f.id, f.owner_id, g.owns_folder,
+			`+fmt.Sprintf(rankToAccess, "g.user_rank")+` AS folder_user,
+			`+fmt.Sprintf(rankToAccess, "g.team_rank")+` AS folder_team
+		FROM folders f
+		JOIN folder_grants g ON g.start_id = f.id`,
+		map[string]interface{}{"folders": folderIDs, "user": userID}).
+		Scan(&grants).Error
+	return grants, err
+}
+
+// GetNoteGrants returns one entry per existing note; missing notes are left
+// out.
+func (r *permissionRepository) GetNoteGrants(ctx context.Context, noteIDs []uuid.UUID, userID uuid.UUID) ([]AccessGrants, error) {
+	var grants []AccessGrants
+	if len(noteIDs) == 0 {
+		return grants, nil
+	}
+	err := r.db.WithContext(ctx).Raw(strings.Replace(folderGrantsCTE, "@folders",
+		"(SELECT folder_id FROM notes WHERE id IN @notes)", 1)+`
+		SELECT n.id, n.owner_id, g.owns_folder,
+			`+fmt.Sprintf(rankToAccess, "g.user_rank")+` AS folder_user,
+			`+fmt.Sprintf(rankToAccess, "g.team_rank")+` AS folder_team,
+			COALESCE(ns.access, '') AS note_user,
+			COALESCE(ns.override, FALSE) AS note_override,
+			`+noteTeamGrant+` AS note_team
+		FROM notes n
+		JOIN folder_grants g ON g.start_id = n.folder_id
+		LEFT JOIN note_shares ns ON ns.note_id = n.id AND ns.user_id = @user
+			AND (ns.expires_at IS NULL OR ns.expires_at > NOW())
+		WHERE n.id IN @notes AND n.deleted_at IS NULL`,
+		map[string]interface{}{"notes": noteIDs, "user": userID}).
+		Scan(&grants).Error
+	return grants, err
+}
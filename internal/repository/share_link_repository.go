@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go-training-system/internal/graph/apperror"
+	"go-training-system/internal/model"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ShareLinkRepository interface {
+	Create(ctx context.Context, link *model.ShareLink) error
+	GetByID(ctx context.Context, id uuid.UUID) (*model.ShareLink, error)
+	GetByTokenHash(ctx context.Context, tokenHash string) (*model.ShareLink, error)
+	GetFolderLinks(ctx context.Context, folderID uuid.UUID) ([]model.ShareLink, error)
+	GetNoteLinks(ctx context.Context, noteID uuid.UUID) ([]model.ShareLink, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	RecordAccess(ctx context.Context, id uuid.UUID) error
+}
+
+type shareLinkRepository struct {
+	db *gorm.DB
+}
+
+func NewShareLinkRepository(db *gorm.DB) ShareLinkRepository {
+	return &shareLinkRepository{db: db}
+}
+
+func (r *shareLinkRepository) Create(ctx context.Context, link *model.ShareLink) error {
+	return r.db.WithContext(ctx).Create(link).Error
+}
+
+func (r *shareLinkRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.ShareLink, error) {
+	return r.first(ctx, "id = ?", id)
+}
+
+func (r *shareLinkRepository) GetByTokenHash(ctx context.Context, tokenHash string) (*model.ShareLink, error) {
+	return r.first(ctx, "token_hash = ?", tokenHash)
+}
+
+func (r *shareLinkRepository) first(ctx context.Context, query string, arg interface{}) (*model.ShareLink, error) {
+	var link model.ShareLink
+	err := r.db.WithContext(ctx).First(&link, query, arg).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, apperror.ErrShareLinkNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+func (r *shareLinkRepository) GetFolderLinks(ctx context.Context, folderID uuid.UUID) ([]model.ShareLink, error) {
+	var links []model.ShareLink
+	err := r.db.WithContext(ctx).Where("folder_id = ?", folderID).Order("created_at").Find(&links).Error
+	return links, err
+}
+
+func (r *shareLinkRepository) GetNoteLinks(ctx context.Context, noteID uuid.UUID) ([]model.ShareLink, error) {
+	var links []model.ShareLink
+	err := r.db.WithContext(ctx).Where("note_id = ?", noteID).Order("created_at").Find(&links).Error
+	return links, err
+}
+
+func (r *shareLinkRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	res := r.db.WithContext(ctx).Where("id = ?", id).Delete(&model.ShareLink{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return apperror.ErrShareLinkNotFound
+	}
+	return nil
+}
+
+// RecordAccess counts one use of the link. The count is incremented in the
+// database, so concurrent uses on several servers all add up.
+func (r *shareLinkRepository) RecordAccess(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Model(&model.ShareLink{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"access_count":     gorm.Expr("access_count + 1"),
+		"last_accessed_at": time.Now(),
+	}).Error
+}
@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go-training-system/internal/graph/apperror"
+	"go-training-system/internal/model"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// userShareCondition matches the unexpired shares of @user in a
+// folder_shares or note_shares query.
+const userShareCondition = `user_id = @user AND (expires_at IS NULL OR expires_at > NOW())`
+
+// ShareRepository stores per-user folder, note and template shares. There is
+// at most one share per (resource, user); sharing again updates it in place.
+type ShareRepository interface {
+	ShareFolder(ctx context.Context, share *model.FolderShare) error
+	ShareNote(ctx context.Context, share *model.NoteShare) error
+	GetFolderShare(ctx context.Context, folderID, userID uuid.UUID) (*model.FolderShare, error)
+	GetNoteShare(ctx context.Context, noteID, userID uuid.UUID) (*model.NoteShare, error)
+	GetFolderShares(ctx context.Context, folderID uuid.UUID) ([]model.FolderShare, error)
+	GetNoteShares(ctx context.Context, noteID uuid.UUID) ([]model.NoteShare, error)
+	GetFoldersShares(ctx context.Context, folderIDs []uuid.UUID) ([]model.FolderShare, error)
+	GetNotesShares(ctx context.Context, noteIDs []uuid.UUID) ([]model.NoteShare, error)
+	RevokeFolderShare(ctx context.Context, folderID, userID uuid.UUID) error
+	RevokeNoteShare(ctx context.Context, noteID, userID uuid.UUID) error
+	ExpireShares(ctx context.Context, now time.Time) ([]model.FolderShare, []model.NoteShare, error)
+	MarkExpiringShares(ctx context.Context, now, before time.Time) ([]model.FolderShare, []model.NoteShare, error)
+	ShareTemplate(ctx context.Context, share *model.TemplateShare) error
+	GetTemplateShare(ctx context.Context, templateID, userID uuid.UUID) (*model.TemplateShare, error)
+	GetTemplateShares(ctx context.Context, templateID uuid.UUID) ([]model.TemplateShare, error)
+	RevokeTemplateShare(ctx context.Context, templateID, userID uuid.UUID) error
+}
+
+type shareRepository struct {
+	db *gorm.DB
+}
+
+func NewShareRepository(db *gorm.DB) ShareRepository {
+	return &shareRepository{db: db}
+}
+
+func (r *shareRepository) ShareFolder(ctx context.Context, share *model.FolderShare) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "folder_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"access", "allow_reshare", "expires_at", "expiry_warned_at", "shared_by_id", "shared_at"}),
+	}).Create(share).Error
+}
+
+func (r *shareRepository) ShareNote(ctx context.Context, share *model.NoteShare) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "note_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"access", "allow_reshare", "override", "expires_at", "expiry_warned_at", "shared_by_id", "shared_at"}),
+	}).Create(share).Error
+}
+
+func (r *shareRepository) GetFolderShare(ctx context.Context, folderID, userID uuid.UUID) (*model.FolderShare, error) {
+	var share model.FolderShare
+	err := r.db.WithContext(ctx).First(&share, "folder_id = ? AND user_id = ?", folderID, userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, apperror.ErrShareNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &share, nil
+}
+
+func (r *shareRepository) GetNoteShare(ctx context.Context, noteID, userID uuid.UUID) (*model.NoteShare, error) {
+	var share model.NoteShare
+	err := r.db.WithContext(ctx).First(&share, "note_id = ? AND user_id = ?", noteID, userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, apperror.ErrShareNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &share, nil
+}
+
+func (r *shareRepository) GetFolderShares(ctx context.Context, folderID uuid.UUID) ([]model.FolderShare, error) {
+	var shares []model.FolderShare
+	err := r.db.WithContext(ctx).Preload("User").Where("folder_id = ?", folderID).Order("shared_at").Find(&shares).Error
+	return shares, err
+}
+
+func (r *shareRepository) GetNoteShares(ctx context.Context, noteID uuid.UUID) ([]model.NoteShare, error) {
+	var shares []model.NoteShare
+	err := r.db.WithContext(ctx).Preload("User").Where("note_id = ?", noteID).Order("shared_at").Find(&shares).Error
+	return shares, err
+}
+
+// GetFoldersShares returns the shares of several folders in one query.
+func (r *shareRepository) GetFoldersShares(ctx context.Context, folderIDs []uuid.UUID) ([]model.FolderShare, error) {
+	var shares []model.FolderShare
+	if len(folderIDs) == 0 {
+		return shares, nil
+	}
+	err := r.db.WithContext(ctx).Preload("User").Where("folder_id IN ?", folderIDs).Order("shared_at").Find(&shares).Error
+	return shares, err
+}
+
+// GetNotesShares returns the shares of several notes in one query.
+func (r *shareRepository) GetNotesShares(ctx context.Context, noteIDs []uuid.UUID) ([]model.NoteShare, error) {
+	var shares []model.NoteShare
+	if len(noteIDs) == 0 {
+		return shares, nil
+	}
+	err := r.db.WithContext(ctx).Preload("User").Where("note_id IN ?", noteIDs).Order("shared_at").Find(&shares).Error
+	return shares, err
+}
+
+func (r *shareRepository) RevokeFolderShare(ctx context.Context, folderID, userID uuid.UUID) error {
+	res := r.db.WithContext(ctx).Where("folder_id = ? AND user_id = ?", folderID, userID).Delete(&model.FolderShare{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return apperror.ErrShareNotFound
+	}
+	return nil
+}
+
+func (r *shareRepository) RevokeNoteShare(ctx context.Context, noteID, userID uuid.UUID) error {
+	res := r.db.WithContext(ctx).Where("note_id = ? AND user_id = ?", noteID, userID).Delete(&model.NoteShare{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return apperror.ErrShareNotFound
+	}
+	return nil
+}
+
+// ExpireShares deletes every folder and note share whose expiry has passed
+// and returns the shares it deleted.
+func (r *shareRepository) ExpireShares(ctx context.Context, now time.Time) ([]model.FolderShare, []model.NoteShare, error) {
+	var folderShares []model.FolderShare
+	var noteShares []model.NoteShare
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Returning{}).
+			Where("expires_at <= ?", now).
+			Delete(&folderShares).Error; err != nil {
+			return err
+		}
+		return tx.Clauses(clause.Returning{}).
+			Where("expires_at <= ?", now).
+			Delete(&noteShares).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return folderShares, noteShares, nil
+}
+
+// MarkExpiringShares stamps now as the warning time on every share that
+// expires before the given time and was not warned about yet, and returns
+// those shares. Each share is returned once, however often this runs.
+func (r *shareRepository) MarkExpiringShares(ctx context.Context, now, before time.Time) ([]model.FolderShare, []model.NoteShare, error) {
+	var folderShares []model.FolderShare
+	var noteShares []model.NoteShare
+	const expiring = "expires_at > ? AND expires_at <= ? AND expiry_warned_at IS NULL"
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&folderShares).Clauses(clause.Returning{}).
+			Where(expiring, now, before).
+			Update("expiry_warned_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Model(&noteShares).Clauses(clause.Returning{}).
+			Where(expiring, now, before).
+			Update("expiry_warned_at", now).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return folderShares, noteShares, nil
+}
+
+func (r *shareRepository) ShareTemplate(ctx context.Context, share *model.TemplateShare) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "template_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"access", "allow_reshare", "expires_at", "expiry_warned_at", "shared_by_id", "shared_at"}),
+	}).Create(share).Error
+}
+
+func (r *shareRepository) GetTemplateShare(ctx context.Context, templateID, userID uuid.UUID) (*model.TemplateShare, error) {
+	var share model.TemplateShare
+	err := r.db.WithContext(ctx).First(&share, "template_id = ? AND user_id = ?", templateID, userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, apperror.ErrShareNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &share, nil
+}
+
+func (r *shareRepository) GetTemplateShares(ctx context.Context, templateID uuid.UUID) ([]model.TemplateShare, error) {
+	var shares []model.TemplateShare
+	err := r.db.WithContext(ctx).Preload("User").Where("template_id = ?", templateID).Order("shared_at").Find(&shares).Error
+	return shares, err
+}
+
+func (r *shareRepository) RevokeTemplateShare(ctx context.Context, templateID, userID uuid.UUID) error {
+	res := r.db.WithContext(ctx).Where("template_id = ? AND user_id = ?", templateID, userID).Delete(&model.TemplateShare{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return apperror.ErrShareNotFound
+	}
+	return nil
+}
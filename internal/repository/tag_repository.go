@@ -0,0 +1,280 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go-training-system/internal/graph/apperror"
+	"go-training-system/internal/model"
+	"go-training-system/pkg/tagexpr"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// visibleTagCondition limits tags t to the ones @user can use: their own and
+// those of teams they currently belong to.
+const visibleTagCondition = `(t.user_id = @user OR t.team_id IN (
+	SELECT tu.team_id FROM team_user tu
+	WHERE tu.user_id = @user AND (tu.expires_at IS NULL OR tu.expires_at > NOW())))`
+
+// TagCount is a tag with the number of non-deleted notes carrying it.
+type TagCount struct {
+	Tag       model.Tag
+	NoteCount int64
+}
+
+type TagRepository interface {
+	Create(ctx context.Context, tag *model.Tag) error
+	GetByID(ctx context.Context, id uuid.UUID) (*model.Tag, error)
+	GetVisibleWithCounts(ctx context.Context, userID uuid.UUID) ([]TagCount, error)
+	Rename(ctx context.Context, tag *model.Tag, name string) error
+	Merge(ctx context.Context, sourceID, targetID uuid.UUID) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	AddToNote(ctx context.Context, noteTag *model.NoteTag) error
+	RemoveFromNote(ctx context.Context, noteID, tagID uuid.UUID) error
+	GetNoteTags(ctx context.Context, noteID, userID uuid.UUID) ([]model.Tag, error)
+	GetTagNames(ctx context.Context, noteIDs []uuid.UUID, userID uuid.UUID) (map[uuid.UUID][]string, error)
+	MatchNotes(ctx context.Context, noteIDs []uuid.UUID, expr tagexpr.Expr, userID uuid.UUID) ([]uuid.UUID, error)
+	IsTeamMember(ctx context.Context, teamID, userID uuid.UUID) (bool, error)
+}
+
+type tagRepository struct {
+	db *gorm.DB
+}
+
+func NewTagRepository(db *gorm.DB) TagRepository {
+	return &tagRepository{db: db}
+}
+
+// Create stores a tag, failing with ErrTagExists when its owner or team
+// already has a tag of that name.
+func (r *tagRepository) Create(ctx context.Context, tag *model.Tag) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := checkTagName(tx, tag, tag.Name); err != nil {
+			return err
+		}
+		return tx.Create(tag).Error
+	})
+}
+
+func (r *tagRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.Tag, error) {
+	var tag model.Tag
+	err := r.db.WithContext(ctx).First(&tag, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, apperror.ErrTagNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// GetVisibleWithCounts returns every tag the user can use, most used first.
+func (r *tagRepository) GetVisibleWithCounts(ctx context.Context, userID uuid.UUID) ([]TagCount, error) {
+	var rows []struct {
+		model.Tag
+		NoteCount int64
+	}
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT t.id, t.name, t.user_id, t.team_id, t.created_by_id, t.created_at, t.updated_at,
+			COUNT(n.id) AS note_count
+		FROM tags t
+		LEFT JOIN note_tags nt ON nt.tag_id = t.id
+		LEFT JOIN notes n ON n.id = nt.note_id AND n.deleted_at IS NULL
+		WHERE `+visibleTagCondition+`
+		GROUP BY t.id
+		ORDER BY note_count DESC, LOWER(t.name)`, map[string]interface{}{"user": userID}).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make([]TagCount, len(rows))
+	for i, row := range rows {
+		tags[i] = TagCount{Tag: row.Tag, NoteCount: row.NoteCount}
+	}
+	return tags, nil
+}
+
+// Rename changes a tag's name, failing with ErrTagExists when another tag in
+// the same scope already has it.
+func (r *tagRepository) Rename(ctx context.Context, tag *model.Tag, name string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := checkTagName(tx, tag, name); err != nil {
+			return err
+		}
+		if err := tx.Model(tag).Update("name", name).Error; err != nil {
+			return err
+		}
+		tag.Name = name
+		return nil
+	})
+}
+
+// Merge moves every note from the source tag to the target tag and deletes
+// the source, all in one transaction. Notes carrying both keep one.
+func (r *tagRepository) Merge(ctx context.Context, sourceID, targetID uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`
+			INSERT INTO note_tags (note_id, tag_id, tagged_by_id, tagged_at)
+			SELECT note_id, @target, tagged_by_id, tagged_at FROM note_tags WHERE tag_id = @source
+			ON CONFLICT (note_id, tag_id) DO NOTHING`,
+			map[string]interface{}{"source": sourceID, "target": targetID}).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("tag_id = ?", sourceID).Delete(&model.NoteTag{}).Error; err != nil {
+			return err
+		}
+		res := tx.Delete(&model.Tag{}, "id = ?", sourceID)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return apperror.ErrTagNotFound
+		}
+		return nil
+	})
+}
+
+func (r *tagRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("tag_id = ?", id).Delete(&model.NoteTag{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.Tag{}, "id = ?", id).Error
+	})
+}
+
+// AddToNote tags a note. Tagging a note twice with the same tag is a no-op.
+func (r *tagRepository) AddToNote(ctx context.Context, noteTag *model.NoteTag) error {
+	return r.db.WithContext(ctx).Exec(`
+		INSERT INTO note_tags (note_id, tag_id, tagged_by_id, tagged_at)
+		VALUES (?, ?, ?, NOW())
+		ON CONFLICT (note_id, tag_id) DO NOTHING`,
+		noteTag.NoteID, noteTag.TagID, noteTag.TaggedByID).Error
+}
+
+func (r *tagRepository) RemoveFromNote(ctx context.Context, noteID, tagID uuid.UUID) error {
+	res := r.db.WithContext(ctx).Where("note_id = ? AND tag_id = ?", noteID, tagID).Delete(&model.NoteTag{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return apperror.ErrTagNotFound
+	}
+	return nil
+}
+
+// GetNoteTags returns the tags on a note that the user can see.
+func (r *tagRepository) GetNoteTags(ctx context.Context, noteID, userID uuid.UUID) ([]model.Tag, error) {
+	var tags []model.Tag
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT t.* FROM tags t
+		JOIN note_tags nt ON nt.tag_id = t.id
+		WHERE nt.note_id = @note AND `+visibleTagCondition+`
+		ORDER BY LOWER(t.name)`, map[string]interface{}{"note": noteID, "user": userID}).
+		Scan(&tags).Error
+	return tags, err
+}
+
+// GetTagNames returns the names of the tags the user can see on each of the
+// notes, keyed by note ID.
+func (r *tagRepository) GetTagNames(ctx context.Context, noteIDs []uuid.UUID, userID uuid.UUID) (map[uuid.UUID][]string, error) {
+	names := make(map[uuid.UUID][]string)
+	if len(noteIDs) == 0 {
+		return names, nil
+	}
+	var rows []struct {
+		NoteID uuid.UUID
+		Name   string
+	}
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT nt.note_id, t.name FROM tags t
+		JOIN note_tags nt ON nt.tag_id = t.id
+		WHERE nt.note_id IN @notes AND `+visibleTagCondition+`
+		ORDER BY LOWER(t.name)`, map[string]interface{}{"notes": noteIDs, "user": userID}).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		names[row.NoteID] = append(names[row.NoteID], row.Name)
+	}
+	return names, nil
+}
+
+// MatchNotes returns the subset of noteIDs matching the tag expression, as
+// seen by the user: only tags they can use count.
+func (r *tagRepository) MatchNotes(ctx context.Context, noteIDs []uuid.UUID, expr tagexpr.Expr, userID uuid.UUID) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	if len(noteIDs) == 0 {
+		return ids, nil
+	}
+	args := map[string]interface{}{"notes": noteIDs, "user": userID}
+	err := r.db.WithContext(ctx).Raw(`SELECT n.id FROM notes n WHERE n.id IN @notes AND `+
+		tagExprCondition(expr, args), args).
+		Scan(&ids).Error
+	return ids, err
+}
+
+// IsTeamMember reports whether the user currently belongs to the team, in
+// any role.
+func (r *tagRepository) IsTeamMember(ctx context.Context, teamID, userID uuid.UUID) (bool, error) {
+	var member bool
+	err := r.db.WithContext(ctx).Raw(`SELECT EXISTS (
+		SELECT 1 FROM team_user tu
+		WHERE tu.team_id = ? AND tu.user_id = ? AND (tu.expires_at IS NULL OR tu.expires_at > NOW()))`,
+		teamID, userID).
+		Scan(&member).Error
+	return member, err
+}
+
+// tagExprCondition compiles a tag expression into a condition on notes n,
+// adding the tag names to args. Tag names match the tags @user can use,
+// ignoring case.
+func tagExprCondition(expr tagexpr.Expr, args map[string]interface{}) string {
+	switch e := expr.(type) {
+	case tagexpr.Tag:
+		name := fmt.Sprintf("tag%d", len(args))
+		args[name] = e.Name
+		return `EXISTS (SELECT 1 FROM note_tags nt JOIN tags t ON t.id = nt.tag_id
+			WHERE nt.note_id = n.id AND LOWER(t.name) = LOWER(@` + name + `) AND ` + visibleTagCondition + `)`
+	case tagexpr.And:
+		return joinConditions(e.Terms, " AND ", args)
+	case tagexpr.Or:
+		return joinConditions(e.Terms, " OR ", args)
+	default:
+		return "FALSE"
+	}
+}
+
+func joinConditions(terms []tagexpr.Expr, op string, args map[string]interface{}) string {
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		parts[i] = tagExprCondition(term, args)
+	}
+	return "(" + strings.Join(parts, op) + ")"
+}
+
+// checkTagName fails with ErrTagExists when another tag in the same scope as
+// tag is already called name, ignoring case.
+func checkTagName(tx *gorm.DB, tag *model.Tag, name string) error {
+	q := tx.Model(&model.Tag{}).Where("LOWER(name) = LOWER(?) AND id <> ?", name, tag.ID)
+	if tag.TeamID != nil {
+		q = q.Where("team_id = ?", *tag.TeamID)
+	} else {
+		q = q.Where("user_id = ? AND team_id IS NULL", tag.UserID)
+	}
+	var count int64
+	if err := q.Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return apperror.ErrTagExists
+	}
+	return nil
+}
@@ -2,12 +2,15 @@ package repository
 
 import (
 	"context"
-	"fmt"
+	"errors"
+	"time"
 
+	"go-training-system/internal/graph/apperror"
 	"go-training-system/internal/model"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type TeamRepository interface {
@@ -16,8 +19,31 @@ type TeamRepository interface {
 	GetTeamsByUserID(ctx context.Context, userID uuid.UUID) ([]model.Team, error)
 	AddMemberToTeam(ctx context.Context, teamID uuid.UUID, userID uuid.UUID, addedBy uuid.UUID) error
 	AddManagerToTeam(ctx context.Context, teamID uuid.UUID, userID uuid.UUID, addedBy uuid.UUID) error
-	RemoveMemberFromTeam(ctx context.Context, teamID uuid.UUID, userID uuid.UUID) error
-	RemoveManagerFromTeam(ctx context.Context, teamID uuid.UUID, userID uuid.UUID) error
+	AddUserToTeam(ctx context.Context, teamID uuid.UUID, userID uuid.UUID, role model.UserRole, addedBy uuid.UUID, expiresAt *time.Time) error
+	RemoveMemberFromTeam(ctx context.Context, teamID uuid.UUID, userID uuid.UUID, removedBy uuid.UUID) error
+	RemoveManagerFromTeam(ctx context.Context, teamID uuid.UUID, userID uuid.UUID, removedBy uuid.UUID) error
+	RemoveUserFromTeam(ctx context.Context, teamID uuid.UUID, userID uuid.UUID, removedBy uuid.UUID) error
+	ChangeMemberRole(ctx context.Context, teamID uuid.UUID, userID uuid.UUID, role model.UserRole, changedBy uuid.UUID) error
+	ExpireMemberships(ctx context.Context, now time.Time) ([]model.TeamUser, error)
+
+	// Membership history
+	GetMembershipAt(ctx context.Context, teamID uuid.UUID, at time.Time) ([]model.TeamMembershipPeriod, error)
+	GetTeamMembershipHistory(ctx context.Context, teamID uuid.UUID) ([]model.TeamMembershipPeriod, error)
+	GetUserMembershipHistory(ctx context.Context, userID uuid.UUID) ([]model.TeamMembershipPeriod, error)
+
+	// Hierarchy
+	GetAncestors(ctx context.Context, teamID uuid.UUID) ([]model.Team, error)
+	GetDescendants(ctx context.Context, teamID uuid.UUID) ([]model.Team, error)
+	GetSubtree(ctx context.Context, teamID uuid.UUID) ([]TeamTreeRow, error)
+	GetSubtreeMembers(ctx context.Context, teamID uuid.UUID) ([]model.TeamUser, error)
+	IsTeamManager(ctx context.Context, teamID uuid.UUID, userID uuid.UUID) (bool, error)
+	IsTeamMember(ctx context.Context, teamID uuid.UUID, userID uuid.UUID) (bool, error)
+	MoveTeam(ctx context.Context, teamID uuid.UUID, parentID *uuid.UUID) error
+
+	TeamExists(ctx context.Context, teamID uuid.UUID) (bool, error)
+	// Transaction runs fn against a repository bound to a single database
+	// transaction; returning an error from fn rolls everything back.
+	Transaction(ctx context.Context, fn func(repo TeamRepository) error) error
 }
 
 type teamRepository struct {
@@ -65,87 +91,365 @@ func (r *teamRepository) GetTeamsByUserID(ctx context.Context, userID uuid.UUID)
 }
 
 func (r *teamRepository) AddMemberToTeam(ctx context.Context, teamID uuid.UUID, userID uuid.UUID, addedBy uuid.UUID) error {
-    // Kiểm tra user có tồn tại không
-    var user model.User
-    if err := r.db.WithContext(ctx).First(&user, "id = ?", userID).Error; err != nil {
-        return fmt.Errorf("user not found")
-    }
+	return r.AddUserToTeam(ctx, teamID, userID, model.UserRoleMember, addedBy, nil)
+}
 
-    // Kiểm tra user đã trong team chưa (bất kể role gì)
-    var count int64
-    r.db.WithContext(ctx).Model(&model.TeamUser{}).
-        Where("team_id = ? AND user_id = ?", teamID, userID).
-        Count(&count)
+func (r *teamRepository) AddManagerToTeam(ctx context.Context, teamID uuid.UUID, userID uuid.UUID, addedBy uuid.UUID) error {
+	return r.AddUserToTeam(ctx, teamID, userID, model.UserRoleManager, addedBy, nil)
+}
 
-    if count > 0 {
-        return fmt.Errorf("user is already in the team")
-    }
+// AddUserToTeam adds a membership with the given role and opens its history
+// period. A non-nil expiresAt makes the membership temporary.
+func (r *teamRepository) AddUserToTeam(ctx context.Context, teamID uuid.UUID, userID uuid.UUID, role model.UserRole, addedBy uuid.UUID, expiresAt *time.Time) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Kiểm tra user có tồn tại không
+		var user model.User
+		if err := tx.First(&user, "id = ?", userID).Error; err != nil {
+			return apperror.ErrUserNotFound
+		}
 
-    // Thêm user vào team với role MEMBER
-    member := model.TeamUser{
-        TeamID:    teamID,
-        UserID:    userID,
-        Role:      model.UserRoleMember,
-        AddedByID: addedBy,
-    }
-    return r.db.WithContext(ctx).Create(&member).Error
+		// Kiểm tra user đã trong team chưa (bất kể role gì)
+		var count int64
+		if err := tx.Model(&model.TeamUser{}).
+			Where("team_id = ? AND user_id = ?", teamID, userID).
+			Count(&count).Error; err != nil {
+			return err
+		}
+
+		if count > 0 {
+			return apperror.ErrAlreadyMember
+		}
+
+		now := time.Now()
+		membership := model.TeamUser{
+			TeamID:    teamID,
+			UserID:    userID,
+			Role:      role,
+			AddedAt:   now,
+			AddedByID: addedBy,
+			ExpiresAt: expiresAt,
+		}
+		if err := tx.Create(&membership).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&model.TeamMembershipPeriod{
+			TeamID:    teamID,
+			UserID:    userID,
+			Role:      role,
+			JoinedAt:  now,
+			ExpiresAt: expiresAt,
+			AddedByID: &addedBy,
+		}).Error
+	})
 }
 
-func (r *teamRepository) AddManagerToTeam(ctx context.Context, teamID uuid.UUID, userID uuid.UUID, addedBy uuid.UUID) error {
-    // Kiểm tra user có tồn tại không
-    var user model.User
-    if err := r.db.WithContext(ctx).First(&user, "id = ?", userID).Error; err != nil {
-        return fmt.Errorf("user not found")
-    }
+func (r *teamRepository) RemoveMemberFromTeam(ctx context.Context, teamID uuid.UUID, userID uuid.UUID, removedBy uuid.UUID) error {
+	role := model.UserRoleMember
+	return r.removeFromTeam(ctx, teamID, userID, &role, &removedBy, model.MembershipEndRemoved, apperror.ErrMemberNotFound)
+}
 
-    // Kiểm tra user đã trong team chưa (bất kể role gì)
-    var count int64
-    r.db.WithContext(ctx).Model(&model.TeamUser{}).
-        Where("team_id = ? AND user_id = ?", teamID, userID).
-        Count(&count)
+func (r *teamRepository) RemoveManagerFromTeam(ctx context.Context, teamID uuid.UUID, userID uuid.UUID, removedBy uuid.UUID) error {
+	role := model.UserRoleManager
+	return r.removeFromTeam(ctx, teamID, userID, &role, &removedBy, model.MembershipEndRemoved, apperror.ErrManagerNotFound)
+}
 
-    if count > 0 {
-        return fmt.Errorf("user is already in the team")
-    }
+// RemoveUserFromTeam removes a membership regardless of its role.
+func (r *teamRepository) RemoveUserFromTeam(ctx context.Context, teamID uuid.UUID, userID uuid.UUID, removedBy uuid.UUID) error {
+	return r.removeFromTeam(ctx, teamID, userID, nil, &removedBy, model.MembershipEndRemoved, apperror.ErrMemberNotFound)
+}
 
-    // Thêm user vào team với role MANAGER
-    manager := model.TeamUser{
-        TeamID:    teamID,
-        UserID:    userID,
-        Role:      model.UserRoleManager,
-        AddedByID: addedBy,
-    }
-    return r.db.WithContext(ctx).Create(&manager).Error
+// removeFromTeam deletes the current membership and closes its history
+// period. A nil role matches any role, a nil removedBy marks a system removal.
+func (r *teamRepository) removeFromTeam(ctx context.Context, teamID uuid.UUID, userID uuid.UUID, role *model.UserRole, removedBy *uuid.UUID, reason model.MembershipEndReason, notFound error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		query := tx.Where("team_id = ? AND user_id = ?", teamID, userID)
+		if role != nil {
+			query = query.Where("role = ?", *role)
+		}
+
+		result := query.Delete(&model.TeamUser{})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return notFound
+		}
+
+		return closeMembershipPeriod(tx, teamID, userID, time.Now(), removedBy, reason)
+	})
 }
 
-func (r *teamRepository) RemoveMemberFromTeam(ctx context.Context, teamID uuid.UUID, userID uuid.UUID) error {
-    result := r.db.WithContext(ctx).
-        Where("team_id = ? AND user_id = ? AND role = ?", teamID, userID, model.UserRoleMember).
-        Delete(&model.TeamUser{})
+func (r *teamRepository) ChangeMemberRole(ctx context.Context, teamID uuid.UUID, userID uuid.UUID, role model.UserRole, changedBy uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var membership model.TeamUser
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&membership, "team_id = ? AND user_id = ?", teamID, userID).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return apperror.ErrMemberNotFound
+			}
+			return err
+		}
 
-    if result.Error != nil {
-        return result.Error
-    }
+		if membership.Role == role {
+			return nil
+		}
 
-    if result.RowsAffected == 0 {
-        return fmt.Errorf("member not found in team")
-    }
+		if err := tx.Model(&model.TeamUser{}).
+			Where("team_id = ? AND user_id = ?", teamID, userID).
+			Update("role", role).Error; err != nil {
+			return err
+		}
 
-    return nil
+		// A role change ends the current period and starts a new one
+		now := time.Now()
+		if err := closeMembershipPeriod(tx, teamID, userID, now, &changedBy, model.MembershipEndRoleChanged); err != nil {
+			return err
+		}
+		return tx.Create(&model.TeamMembershipPeriod{
+			TeamID:    teamID,
+			UserID:    userID,
+			Role:      role,
+			JoinedAt:  now,
+			ExpiresAt: membership.ExpiresAt,
+			AddedByID: &changedBy,
+		}).Error
+	})
 }
 
-func (r *teamRepository) RemoveManagerFromTeam(ctx context.Context, teamID uuid.UUID, userID uuid.UUID) error {
-    result := r.db.WithContext(ctx).
-        Where("team_id = ? AND user_id = ? AND role = ?", teamID, userID, model.UserRoleManager).
-        Delete(&model.TeamUser{})
+// ExpireMemberships removes every temporary membership whose expiry has
+// passed and returns the memberships it removed.
+func (r *teamRepository) ExpireMemberships(ctx context.Context, now time.Time) ([]model.TeamUser, error) {
+	var expired []model.TeamUser
+	if err := r.db.WithContext(ctx).
+		Where("expires_at IS NOT NULL AND expires_at <= ?", now).
+		Find(&expired).Error; err != nil {
+		return nil, err
+	}
 
-    if result.Error != nil {
-        return result.Error
-    }
+	var removed []model.TeamUser
+	for _, m := range expired {
+		err := r.removeFromTeam(ctx, m.TeamID, m.UserID, nil, nil, model.MembershipEndExpired, apperror.ErrMemberNotFound)
+		if err != nil {
+			// Removed concurrently by someone else
+			if errors.Is(err, apperror.ErrMemberNotFound) {
+				continue
+			}
+			return removed, err
+		}
+		removed = append(removed, m)
+	}
+	return removed, nil
+}
 
-    if result.RowsAffected == 0 {
-        return fmt.Errorf("manager not found in team")
-    }
+// GetMembershipAt returns the periods of everyone who was on the team at the
+// given instant.
+func (r *teamRepository) GetMembershipAt(ctx context.Context, teamID uuid.UUID, at time.Time) ([]model.TeamMembershipPeriod, error) {
+	var periods []model.TeamMembershipPeriod
+	err := r.db.WithContext(ctx).
+		Where("team_id = ? AND joined_at <= ? AND (left_at IS NULL OR left_at > ?)", teamID, at, at).
+		Preload("User").
+		Order("joined_at").
+		Find(&periods).Error
+	return periods, err
+}
+
+func (r *teamRepository) GetTeamMembershipHistory(ctx context.Context, teamID uuid.UUID) ([]model.TeamMembershipPeriod, error) {
+	var periods []model.TeamMembershipPeriod
+	err := r.db.WithContext(ctx).
+		Where("team_id = ?", teamID).
+		Preload("User").
+		Order("joined_at").
+		Find(&periods).Error
+	return periods, err
+}
+
+func (r *teamRepository) GetUserMembershipHistory(ctx context.Context, userID uuid.UUID) ([]model.TeamMembershipPeriod, error) {
+	var periods []model.TeamMembershipPeriod
+	err := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Preload("Team", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Order("joined_at").
+		Find(&periods).Error
+	return periods, err
+}
+
+func closeMembershipPeriod(tx *gorm.DB, teamID uuid.UUID, userID uuid.UUID, at time.Time, removedBy *uuid.UUID, reason model.MembershipEndReason) error {
+	return tx.Model(&model.TeamMembershipPeriod{}).
+		Where("team_id = ? AND user_id = ? AND left_at IS NULL", teamID, userID).
+		Updates(map[string]interface{}{
+			"left_at":       at,
+			"removed_by_id": removedBy,
+			"end_reason":    reason,
+		}).Error
+}
+
+// teamSubtreeCTE selects the team identified by @root together with every
+// non-deleted descendant, tagging each row with its depth below the root.
+const teamSubtreeCTE = `WITH RECURSIVE subtree AS (
+	SELECT id, parent_id, 0 AS depth FROM teams WHERE id = @root AND deleted_at IS NULL
+	UNION ALL
+	SELECT t.id, t.parent_id, s.depth + 1 FROM teams t
+	JOIN subtree s ON t.parent_id = s.id
+	WHERE t.deleted_at IS NULL
+)`
+
+// teamChainCTE selects the team identified by @root and all of its ancestors
+// up to the root of the hierarchy.
+const teamChainCTE = `WITH RECURSIVE chain AS (
+	SELECT id, parent_id, 0 AS depth FROM teams WHERE id = @root AND deleted_at IS NULL
+	UNION ALL
+	SELECT t.id, t.parent_id, c.depth + 1 FROM teams t
+	JOIN chain c ON t.id = c.parent_id
+	WHERE t.deleted_at IS NULL
+)`
+
+// TeamTreeRow is a single team of a subtree together with its direct
+// membership counts.
+type TeamTreeRow struct {
+	ID           uuid.UUID
+	ParentID     *uuid.UUID
+	TeamName     string
+	Depth        int
+	MemberCount  int64
+	ManagerCount int64
+}
+
+func (r *teamRepository) GetAncestors(ctx context.Context, teamID uuid.UUID) ([]model.Team, error) {
+	var teams []model.Team
+	err := r.db.WithContext(ctx).Raw(teamChainCTE+`
+		SELECT t.* FROM chain c JOIN teams t ON t.id = c.id
+		WHERE c.depth > 0
+		ORDER BY c.depth DESC`, map[string]interface{}{"root": teamID}).
+		Scan(&teams).Error
+	return teams, err
+}
+
+func (r *teamRepository) GetDescendants(ctx context.Context, teamID uuid.UUID) ([]model.Team, error) {
+	var teams []model.Team
+	err := r.db.WithContext(ctx).Raw(teamSubtreeCTE+`
+		SELECT t.* FROM subtree s JOIN teams t ON t.id = s.id
+		WHERE s.depth > 0
+		ORDER BY s.depth, t.team_name`, map[string]interface{}{"root": teamID}).
+		Scan(&teams).Error
+	return teams, err
+}
+
+func (r *teamRepository) GetSubtree(ctx context.Context, teamID uuid.UUID) ([]TeamTreeRow, error) {
+	var rows []TeamTreeRow
+	err := r.db.WithContext(ctx).Raw(teamSubtreeCTE+`
+		SELECT s.id, s.parent_id, t.team_name, s.depth,
+			COUNT(tu.user_id) FILTER (WHERE tu.role = 'MEMBER') AS member_count,
+			COUNT(tu.user_id) FILTER (WHERE tu.role = 'MANAGER') AS manager_count
+		FROM subtree s
+		JOIN teams t ON t.id = s.id
+		LEFT JOIN team_user tu ON tu.team_id = s.id
+		GROUP BY s.id, s.parent_id, t.team_name, s.depth
+		ORDER BY s.depth, t.team_name`, map[string]interface{}{"root": teamID}).
+		Scan(&rows).Error
+	return rows, err
+}
+
+func (r *teamRepository) GetSubtreeMembers(ctx context.Context, teamID uuid.UUID) ([]model.TeamUser, error) {
+	var teamIDs []uuid.UUID
+	err := r.db.WithContext(ctx).Raw(teamSubtreeCTE+` SELECT id FROM subtree`, map[string]interface{}{"root": teamID}).
+		Scan(&teamIDs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var members []model.TeamUser
+	if len(teamIDs) == 0 {
+		return members, nil
+	}
+	err = r.db.WithContext(ctx).
+		Where("team_id IN ?", teamIDs).
+		Preload("User").
+		Preload("Team").
+		Find(&members).Error
+	return members, err
+}
+
+// IsTeamManager reports whether userID created or currently manages the team
+// or any of its ancestors. Managers of a parent unit administer the whole
+// subtree.
+func (r *teamRepository) IsTeamManager(ctx context.Context, teamID uuid.UUID, userID uuid.UUID) (bool, error) {
+	var allowed bool
+	err := r.db.WithContext(ctx).Raw(teamChainCTE+`
+		SELECT EXISTS (
+			SELECT 1 FROM chain c
+			JOIN teams t ON t.id = c.id
+			LEFT JOIN team_user tu ON tu.team_id = c.id AND tu.user_id = @user AND tu.role = 'MANAGER'
+				AND (tu.expires_at IS NULL OR tu.expires_at > NOW())
+			WHERE t.created_by_id = @user OR tu.user_id IS NOT NULL
+		)`, map[string]interface{}{"root": teamID, "user": userID}).
+		Scan(&allowed).Error
+	return allowed, err
+}
+
+// IsTeamMember reports whether the user currently belongs to the team, in
+// any role.
+func (r *teamRepository) IsTeamMember(ctx context.Context, teamID uuid.UUID, userID uuid.UUID) (bool, error) {
+	var member bool
+	err := r.db.WithContext(ctx).Raw(`SELECT EXISTS (
+		SELECT 1 FROM team_user tu
+		WHERE tu.team_id = ? AND tu.user_id = ? AND (tu.expires_at IS NULL OR tu.expires_at > NOW()))`,
+		teamID, userID).
+		Scan(&member).Error
+	return member, err
+}
+
+// MoveTeam re-parents a team, carrying its whole subtree along. A nil
+// parentID turns the team into a root unit.
+func (r *teamRepository) MoveTeam(ctx context.Context, teamID uuid.UUID, parentID *uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Serialize hierarchy changes so two concurrent moves cannot create a cycle
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext('teams_hierarchy'))").Error; err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&model.Team{}).Where("id = ?", teamID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return apperror.ErrTeamNotFound
+		}
+
+		if parentID != nil {
+			if err := tx.Model(&model.Team{}).Where("id = ?", *parentID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				return apperror.ErrTeamNotFound
+			}
+
+			var inSubtree bool
+			err := tx.Raw(teamSubtreeCTE+` SELECT EXISTS (SELECT 1 FROM subtree WHERE id = @parent)`,
+				map[string]interface{}{"root": teamID, "parent": *parentID}).
+				Scan(&inSubtree).Error
+			if err != nil {
+				return err
+			}
+			if inSubtree {
+				return apperror.ErrTeamCycle
+			}
+		}
+
+		return tx.Model(&model.Team{}).Where("id = ?", teamID).Update("parent_id", parentID).Error
+	})
+}
+
+func (r *teamRepository) TeamExists(ctx context.Context, teamID uuid.UUID) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&model.Team{}).Where("id = ?", teamID).Count(&count).Error
+	return count > 0, err
+}
 
-    return nil
+func (r *teamRepository) Transaction(ctx context.Context, fn func(repo TeamRepository) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&teamRepository{db: tx})
+	})
 }
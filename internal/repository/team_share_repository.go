@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"go-training-system/internal/graph/apperror"
+	"go-training-system/internal/model"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// teamGrantCondition restricts a team share to the grantee team's current,
+// unexpired members, and to its managers when the share is managers-only.
+const teamGrantCondition = `tu.user_id = @user
+	AND (tu.expires_at IS NULL OR tu.expires_at > NOW())
+	AND (NOT s.managers_only OR tu.role = 'MANAGER')`
+
+// TeamShareRepository stores shares whose grantee is a whole team. Access is
+// resolved against team_user at query time (see PermissionRepository), so it
+// follows membership changes without rewriting share rows.
+type TeamShareRepository interface {
+	ShareFolder(ctx context.Context, share *model.FolderTeamShare) error
+	ShareNote(ctx context.Context, share *model.NoteTeamShare) error
+	GetFolderShares(ctx context.Context, folderID uuid.UUID) ([]model.FolderTeamShare, error)
+	GetNoteShares(ctx context.Context, noteID uuid.UUID) ([]model.NoteTeamShare, error)
+	GetFoldersShares(ctx context.Context, folderIDs []uuid.UUID) ([]model.FolderTeamShare, error)
+	GetNotesShares(ctx context.Context, noteIDs []uuid.UUID) ([]model.NoteTeamShare, error)
+	RevokeFolderShare(ctx context.Context, folderID, teamID uuid.UUID) error
+	RevokeNoteShare(ctx context.Context, noteID, teamID uuid.UUID) error
+	ShareTemplate(ctx context.Context, share *model.TemplateTeamShare) error
+	GetTemplateShares(ctx context.Context, templateID uuid.UUID) ([]model.TemplateTeamShare, error)
+	RevokeTemplateShare(ctx context.Context, templateID, teamID uuid.UUID) error
+}
+
+type teamShareRepository struct {
+	db *gorm.DB
+}
+
+func NewTeamShareRepository(db *gorm.DB) TeamShareRepository {
+	return &teamShareRepository{db: db}
+}
+
+// ShareFolder creates the share or, if the team already has one on the
+// folder, updates its access and role restriction.
+func (r *teamShareRepository) ShareFolder(ctx context.Context, share *model.FolderTeamShare) error {
+	if err := r.ensureTeam(ctx, share.TeamID); err != nil {
+		return err
+	}
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "folder_id"}, {Name: "team_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"access", "managers_only", "shared_by_id", "shared_at"}),
+	}).Create(share).Error
+}
+
+// ShareNote creates the share or, if the team already has one on the note,
+// updates its access and role restriction.
+func (r *teamShareRepository) ShareNote(ctx context.Context, share *model.NoteTeamShare) error {
+	if err := r.ensureTeam(ctx, share.TeamID); err != nil {
+		return err
+	}
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "note_id"}, {Name: "team_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"access", "managers_only", "shared_by_id", "shared_at"}),
+	}).Create(share).Error
+}
+
+func (r *teamShareRepository) GetFolderShares(ctx context.Context, folderID uuid.UUID) ([]model.FolderTeamShare, error) {
+	var shares []model.FolderTeamShare
+	err := r.db.WithContext(ctx).Preload("Team").Where("folder_id = ?", folderID).Order("shared_at").Find(&shares).Error
+	return shares, err
+}
+
+func (r *teamShareRepository) GetNoteShares(ctx context.Context, noteID uuid.UUID) ([]model.NoteTeamShare, error) {
+	var shares []model.NoteTeamShare
+	err := r.db.WithContext(ctx).Preload("Team").Where("note_id = ?", noteID).Order("shared_at").Find(&shares).Error
+	return shares, err
+}
+
+// GetFoldersShares returns the team shares of several folders in one query.
+func (r *teamShareRepository) GetFoldersShares(ctx context.Context, folderIDs []uuid.UUID) ([]model.FolderTeamShare, error) {
+	var shares []model.FolderTeamShare
+	if len(folderIDs) == 0 {
+		return shares, nil
+	}
+	err := r.db.WithContext(ctx).Preload("Team").Where("folder_id IN ?", folderIDs).Order("shared_at").Find(&shares).Error
+	return shares, err
+}
+
+// GetNotesShares returns the team shares of several notes in one query.
+func (r *teamShareRepository) GetNotesShares(ctx context.Context, noteIDs []uuid.UUID) ([]model.NoteTeamShare, error) {
+	var shares []model.NoteTeamShare
+	if len(noteIDs) == 0 {
+		return shares, nil
+	}
+	err := r.db.WithContext(ctx).Preload("Team").Where("note_id IN ?", noteIDs).Order("shared_at").Find(&shares).Error
+	return shares, err
+}
+
+func (r *teamShareRepository) RevokeFolderShare(ctx context.Context, folderID, teamID uuid.UUID) error {
+	res := r.db.WithContext(ctx).Where("folder_id = ? AND team_id = ?", folderID, teamID).Delete(&model.FolderTeamShare{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return apperror.ErrTeamShareNotFound
+	}
+	return nil
+}
+
+func (r *teamShareRepository) RevokeNoteShare(ctx context.Context, noteID, teamID uuid.UUID) error {
+	res := r.db.WithContext(ctx).Where("note_id = ? AND team_id = ?", noteID, teamID).Delete(&model.NoteTeamShare{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return apperror.ErrTeamShareNotFound
+	}
+	return nil
+}
+
+// ShareTemplate creates the share or, if the team already has one on the
+// template, updates its access and role restriction.
+func (r *teamShareRepository) ShareTemplate(ctx context.Context, share *model.TemplateTeamShare) error {
+	if err := r.ensureTeam(ctx, share.TeamID); err != nil {
+		return err
+	}
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "template_id"}, {Name: "team_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"access", "managers_only", "shared_by_id", "shared_at"}),
+	}).Create(share).Error
+}
+
+func (r *teamShareRepository) GetTemplateShares(ctx context.Context, templateID uuid.UUID) ([]model.TemplateTeamShare, error) {
+	var shares []model.TemplateTeamShare
+	err := r.db.WithContext(ctx).Preload("Team").Where("template_id = ?", templateID).Order("shared_at").Find(&shares).Error
+	return shares, err
+}
+
+func (r *teamShareRepository) RevokeTemplateShare(ctx context.Context, templateID, teamID uuid.UUID) error {
+	res := r.db.WithContext(ctx).Where("template_id = ? AND team_id = ?", templateID, teamID).Delete(&model.TemplateTeamShare{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return apperror.ErrTeamShareNotFound
+	}
+	return nil
+}
+
+func (r *teamShareRepository) ensureTeam(ctx context.Context, teamID uuid.UUID) error {
+	var team model.Team
+	err := r.db.WithContext(ctx).Select("id").First(&team, "id = ?", teamID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apperror.ErrTeamNotFound
+	}
+	return err
+}
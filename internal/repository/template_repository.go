@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"go-training-system/internal/graph/apperror"
+	"go-training-system/internal/model"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TemplateGrants is everything besides ownership and team management that
+// can give one user access to a template. Levels the user has no grant for
+// are empty.
+type TemplateGrants struct {
+	User model.AccessLevel
+	Team model.AccessLevel
+	// TeamMember is set when the template belongs to a team the user
+	// currently belongs to.
+	TeamMember bool
+}
+
+// TemplateRepository stores note templates together with their custom
+// fields. Template shares live in ShareRepository and TeamShareRepository.
+type TemplateRepository interface {
+	Create(ctx context.Context, template *model.NoteTemplate) error
+	GetByID(ctx context.Context, id uuid.UUID) (*model.NoteTemplate, error)
+	GetVisibleToUser(ctx context.Context, userID uuid.UUID) ([]model.NoteTemplate, error)
+	GetGrants(ctx context.Context, id, userID uuid.UUID) (*TemplateGrants, error)
+	Update(ctx context.Context, template *model.NoteTemplate, expectedVersion int64) error
+	Delete(ctx context.Context, id uuid.UUID, expectedVersion int64) error
+}
+
+type templateRepository struct {
+	db *gorm.DB
+}
+
+func NewTemplateRepository(db *gorm.DB) TemplateRepository {
+	return &templateRepository{db: db}
+}
+
+// Create stores the template and its fields in one transaction.
+func (r *templateRepository) Create(ctx context.Context, template *model.NoteTemplate) error {
+	return r.db.WithContext(ctx).Create(template).Error
+}
+
+func (r *templateRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.NoteTemplate, error) {
+	var template model.NoteTemplate
+	err := r.db.WithContext(ctx).
+		Preload("Fields", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		First(&template, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, apperror.ErrTemplateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// GetVisibleToUser returns every template the user owns, has been shared,
+// directly or through a team, or that belongs to a team they are in.
+func (r *templateRepository) GetVisibleToUser(ctx context.Context, userID uuid.UUID) ([]model.NoteTemplate, error) {
+	var templates []model.NoteTemplate
+	err := r.db.WithContext(ctx).
+		Preload("Fields", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		Where(`owner_id = @user
+			OR id IN (SELECT template_id FROM template_shares WHERE user_id = @user)
+			OR id IN (
+				SELECT s.template_id FROM template_team_shares s
+				JOIN team_user tu ON tu.team_id = s.team_id
+				WHERE `+teamGrantCondition+`)
+			OR team_id IN (
+				SELECT tu.team_id FROM team_user tu
+				WHERE tu.user_id = @user AND (tu.expires_at IS NULL OR tu.expires_at > NOW()))`,
+			map[string]interface{}{"user": userID}).
+		Order("name").
+		Find(&templates).Error
+	return templates, err
+}
+
+// GetGrants returns nil when the template does not exist.
+func (r *templateRepository) GetGrants(ctx context.Context, id, userID uuid.UUID) (*TemplateGrants, error) {
+	var grants []TemplateGrants
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT COALESCE(ts.access, '') AS "user",
+			COALESCE((
+				SELECT s.access FROM template_team_shares s
+				JOIN team_user tu ON tu.team_id = s.team_id
+				WHERE s.template_id = t.id AND `+teamGrantCondition+`
+				ORDER BY CASE s.access WHEN 'write' THEN 0 ELSE 1 END LIMIT 1), '') AS team,
+			EXISTS (
+				SELECT 1 FROM team_user tu
+				WHERE tu.team_id = t.team_id AND tu.user_id = @user
+					AND (tu.expires_at IS NULL OR tu.expires_at > NOW())) AS team_member
+		FROM note_templates t
+		LEFT JOIN template_shares ts ON ts.template_id = t.id AND ts.user_id = @user
+		WHERE t.id = @template`,
+		map[string]interface{}{"template": id, "user": userID}).
+		Scan(&grants).Error
+	if err != nil || len(grants) == 0 {
+		return nil, err
+	}
+	return &grants[0], nil
+}
+
+// Update saves the template's content and replaces its fields. A non-zero
+// expectedVersion makes it fail with a *apperror.VersionConflictError if the
+// template has changed.
+func (r *templateRepository) Update(ctx context.Context, template *model.NoteTemplate, expectedVersion int64) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := updateVersioned(tx, template, template.ID, expectedVersion, map[string]interface{}{
+			"name":        template.Name,
+			"description": template.Description,
+			"title":       template.Title,
+			"body":        template.Body,
+		}, apperror.ErrTemplateNotFound)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Where("template_id = ?", template.ID).Delete(&model.TemplateField{}).Error; err != nil {
+			return err
+		}
+		for i := range template.Fields {
+			template.Fields[i].TemplateID = template.ID
+		}
+		if len(template.Fields) == 0 {
+			return nil
+		}
+		return tx.Create(&template.Fields).Error
+	})
+}
+
+// Delete removes the template with its fields and shares.
+func (r *templateRepository) Delete(ctx context.Context, id uuid.UUID, expectedVersion int64) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := checkVersion(tx, &model.NoteTemplate{}, id, expectedVersion, apperror.ErrTemplateNotFound); err != nil {
+			return err
+		}
+		for _, dependent := range []interface{}{&model.TemplateField{}, &model.TemplateShare{}, &model.TemplateTeamShare{}} {
+			if err := tx.Where("template_id = ?", id).Delete(dependent).Error; err != nil {
+				return err
+			}
+		}
+		return deleteVersioned(tx, &model.NoteTemplate{}, id, 0, apperror.ErrTemplateNotFound)
+	})
+}
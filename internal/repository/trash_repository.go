@@ -0,0 +1,284 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go-training-system/internal/graph/apperror"
+	"go-training-system/internal/model"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// deletedChainCTE selects the folder identified by @root and its ancestors,
+// stopping at the first one that is not deleted. Without a deleted @root it
+// selects nothing.
+const deletedChainCTE = `WITH RECURSIVE chain AS (
+	SELECT id, parent_id, 0 AS depth FROM folders WHERE id = @root AND deleted_at IS NOT NULL
+	UNION ALL
+	SELECT f.id, f.parent_id, c.depth + 1 FROM folders f
+	JOIN chain c ON f.id = c.parent_id
+	WHERE f.deleted_at IS NOT NULL
+)`
+
+// deletedBatchCTE selects the deleted folder identified by @root and every
+// descendant deleted together with it, that is at the same time.
+const deletedBatchCTE = `WITH RECURSIVE batch AS (
+	SELECT id, deleted_at FROM folders WHERE id = @root AND deleted_at IS NOT NULL
+	UNION ALL
+	SELECT f.id, f.deleted_at FROM folders f
+	JOIN batch b ON f.parent_id = b.id
+	WHERE f.deleted_at = b.deleted_at
+)`
+
+// TrashItem is a deleted folder or note at the top of its owner's trash:
+// items deleted together with a parent folder of the same owner are listed
+// under that folder only. ParentID is a folder's parent or a note's folder.
+type TrashItem struct {
+	Type      string
+	ID        uuid.UUID
+	Name      string
+	ParentID  *uuid.UUID
+	DeletedAt time.Time
+}
+
+// PurgeSet lists folders and notes to remove for good.
+type PurgeSet struct {
+	FolderIDs []uuid.UUID
+	NoteIDs   []uuid.UUID
+}
+
+// TrashRepository reads, restores and purges soft-deleted folders and notes.
+type TrashRepository interface {
+	GetTrash(ctx context.Context, ownerID uuid.UUID) ([]TrashItem, error)
+	GetDeletedFolder(ctx context.Context, id uuid.UUID) (*model.Folder, error)
+	GetDeletedNote(ctx context.Context, id uuid.UUID) (*model.Note, error)
+	GetDeletedAncestors(ctx context.Context, folderID uuid.UUID) ([]model.Folder, error)
+	RestoreFolder(ctx context.Context, folder *model.Folder) error
+	RestoreNote(ctx context.Context, note *model.Note) error
+	FolderPurgeSet(ctx context.Context, folderID uuid.UUID) (*PurgeSet, error)
+	ExpiredPurgeSet(ctx context.Context, cutoff time.Time) (*PurgeSet, error)
+	Purge(ctx context.Context, set *PurgeSet) error
+}
+
+type trashRepository struct {
+	db *gorm.DB
+}
+
+func NewTrashRepository(db *gorm.DB) TrashRepository {
+	return &trashRepository{db: db}
+}
+
+// GetTrash returns the top of the user's trash, most recently deleted first.
+func (r *trashRepository) GetTrash(ctx context.Context, ownerID uuid.UUID) ([]TrashItem, error) {
+	var items []TrashItem
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT 'folder' AS type, f.id, f.name, f.parent_id, f.deleted_at FROM folders f
+		WHERE f.owner_id = @user AND f.deleted_at IS NOT NULL AND NOT EXISTS (
+			SELECT 1 FROM folders p
+			WHERE p.id = f.parent_id AND p.owner_id = @user AND p.deleted_at = f.deleted_at)
+		UNION ALL
+		SELECT 'note' AS type, n.id, n.title, n.folder_id, n.deleted_at FROM notes n
+		WHERE n.owner_id = @user AND n.deleted_at IS NOT NULL AND NOT EXISTS (
+			SELECT 1 FROM folders p
+			WHERE p.id = n.folder_id AND p.owner_id = @user AND p.deleted_at = n.deleted_at)
+		ORDER BY deleted_at DESC`, map[string]interface{}{"user": ownerID}).
+		Scan(&items).Error
+	return items, err
+}
+
+func (r *trashRepository) GetDeletedFolder(ctx context.Context, id uuid.UUID) (*model.Folder, error) {
+	var folder model.Folder
+	err := r.db.WithContext(ctx).Unscoped().First(&folder, "id = ? AND deleted_at IS NOT NULL", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, apperror.ErrFolderNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &folder, nil
+}
+
+func (r *trashRepository) GetDeletedNote(ctx context.Context, id uuid.UUID) (*model.Note, error) {
+	var note model.Note
+	err := r.db.WithContext(ctx).Unscoped().First(&note, "id = ? AND deleted_at IS NOT NULL", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, apperror.ErrNoteNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &note, nil
+}
+
+// GetDeletedAncestors returns the folder and its ancestors that have to be
+// restored for it to be reachable again, nearest first. It is empty when the
+// folder is not deleted.
+func (r *trashRepository) GetDeletedAncestors(ctx context.Context, folderID uuid.UUID) ([]model.Folder, error) {
+	var folders []model.Folder
+	err := r.db.WithContext(ctx).Raw(deletedChainCTE+`
+		SELECT f.* FROM chain c JOIN folders f ON f.id = c.id
+		ORDER BY c.depth`, map[string]interface{}{"root": folderID}).
+		Scan(&folders).Error
+	return folders, err
+}
+
+// RestoreFolder brings back a deleted folder with the subfolders and notes
+// deleted together with it, and any deleted ancestors. Shares were never
+// removed, so they apply again as before. Every restored row gets a new
+// version.
+func (r *trashRepository) RestoreFolder(ctx context.Context, folder *model.Folder) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockHierarchy(tx); err != nil {
+			return err
+		}
+		if folder.ParentID != nil {
+			if err := restoreChain(tx, *folder.ParentID); err != nil {
+				return err
+			}
+		}
+
+		args := map[string]interface{}{"root": folder.ID}
+		err := tx.Exec(deletedBatchCTE+`
+			UPDATE notes n SET deleted_at = NULL, version = n.version + 1
+			FROM batch b WHERE n.folder_id = b.id AND n.deleted_at = b.deleted_at`, args).Error
+		if err != nil {
+			return err
+		}
+		res := tx.Exec(deletedBatchCTE+`
+			UPDATE folders f SET deleted_at = NULL, version = f.version + 1
+			FROM batch b WHERE f.id = b.id`, args)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return apperror.ErrFolderNotFound
+		}
+		folder.DeletedAt = gorm.DeletedAt{}
+		folder.Version++
+		return nil
+	})
+}
+
+// RestoreNote brings back a deleted note, and its folder and that folder's
+// ancestors if they were deleted too.
+func (r *trashRepository) RestoreNote(ctx context.Context, note *model.Note) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockHierarchy(tx); err != nil {
+			return err
+		}
+		if err := restoreChain(tx, note.FolderID); err != nil {
+			return err
+		}
+
+		res := tx.Exec(`UPDATE notes SET deleted_at = NULL, version = version + 1
+			WHERE id = ? AND deleted_at IS NOT NULL`, note.ID)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return apperror.ErrNoteNotFound
+		}
+		note.DeletedAt = gorm.DeletedAt{}
+		note.Version++
+		return nil
+	})
+}
+
+// FolderPurgeSet returns a deleted folder, all of its subfolders and every
+// note in them.
+func (r *trashRepository) FolderPurgeSet(ctx context.Context, folderID uuid.UUID) (*PurgeSet, error) {
+	db := r.db.WithContext(ctx)
+	set := &PurgeSet{}
+	err := db.Raw(`WITH RECURSIVE subtree AS (
+			SELECT id FROM folders WHERE id = @root AND deleted_at IS NOT NULL
+			UNION ALL
+			SELECT f.id FROM folders f JOIN subtree s ON f.parent_id = s.id
+		)
+		SELECT id FROM subtree`, map[string]interface{}{"root": folderID}).
+		Scan(&set.FolderIDs).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(set.FolderIDs) == 0 {
+		return nil, apperror.ErrFolderNotFound
+	}
+	err = db.Model(&model.Note{}).Unscoped().Where("folder_id IN ?", set.FolderIDs).Pluck("id", &set.NoteIDs).Error
+	if err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// ExpiredPurgeSet returns the folders and notes deleted before cutoff,
+// together with everything inside those folders.
+func (r *trashRepository) ExpiredPurgeSet(ctx context.Context, cutoff time.Time) (*PurgeSet, error) {
+	db := r.db.WithContext(ctx)
+	set := &PurgeSet{}
+	err := db.Raw(`WITH RECURSIVE subtree AS (
+			SELECT id FROM folders WHERE deleted_at < @cutoff
+			UNION
+			SELECT f.id FROM folders f JOIN subtree s ON f.parent_id = s.id
+		)
+		SELECT id FROM subtree`, map[string]interface{}{"cutoff": cutoff}).
+		Scan(&set.FolderIDs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	q := db.Model(&model.Note{}).Unscoped().Where("deleted_at < ?", cutoff)
+	if len(set.FolderIDs) > 0 {
+		q = q.Or("folder_id IN ?", set.FolderIDs)
+	}
+	if err := q.Pluck("id", &set.NoteIDs).Error; err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// Purge permanently deletes the folders and notes in set together with their
+// shares, share links, revisions, tags, comments, collaborative editing
+// history and attachment records. Attachment content is left to the caller.
+func (r *trashRepository) Purge(ctx context.Context, set *PurgeSet) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if len(set.NoteIDs) > 0 {
+			comments := tx.Model(&model.Comment{}).Select("id").Where("note_id IN ?", set.NoteIDs)
+			if err := tx.Where("comment_id IN (?)", comments).Delete(&model.CommentMention{}).Error; err != nil {
+				return err
+			}
+			for _, dependent := range []interface{}{
+				&model.NoteShare{}, &model.NoteTeamShare{}, &model.NoteRevision{}, &model.NoteTag{}, &model.Attachment{},
+				&model.Comment{}, &model.NoteOperation{}, &model.NoteSnapshot{}, &model.ShareLink{},
+			} {
+				if err := tx.Where("note_id IN ?", set.NoteIDs).Delete(dependent).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Unscoped().Where("id IN ?", set.NoteIDs).Delete(&model.Note{}).Error; err != nil {
+				return err
+			}
+		}
+
+		if len(set.FolderIDs) > 0 {
+			for _, dependent := range []interface{}{&model.FolderShare{}, &model.FolderTeamShare{}, &model.ShareLink{}} {
+				if err := tx.Where("folder_id IN ?", set.FolderIDs).Delete(dependent).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Unscoped().Where("id IN ?", set.FolderIDs).Delete(&model.Folder{}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// restoreChain undeletes the folder and its deleted ancestors, leaving their
+// other contents in the trash.
+func restoreChain(tx *gorm.DB, folderID uuid.UUID) error {
+	return tx.Exec(deletedChainCTE+`
+		UPDATE folders f SET deleted_at = NULL, version = f.version + 1
+		FROM chain c WHERE f.id = c.id`, map[string]interface{}{"root": folderID}).Error
+}
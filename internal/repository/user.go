@@ -2,16 +2,21 @@ package repository
 
 import (
 	"context"
+	"errors"
 
+	"go-training-system/internal/graph/apperror"
 	gqlmodel "go-training-system/internal/graph/model"
 	"go-training-system/internal/model"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 type UserRepository interface {
 	FindByID(ctx context.Context, userID string) (*model.User, error)
 	FindByEmail(ctx context.Context, email string) (*model.User, error)
+	FindByIDs(ctx context.Context, ids []uuid.UUID) ([]model.User, error)
+	FindByUsernames(ctx context.Context, usernames []string) ([]model.User, error)
 	FetchAll(ctx context.Context, role *model.UserRole) ([]*model.User, error)
 	Create(ctx context.Context, user *model.User) error
 	Update(ctx context.Context, userID string, input *gqlmodel.UpdateUserInput) (*model.User, error)
@@ -28,7 +33,10 @@ func NewUserRepository(db *gorm.DB) UserRepository {
 
 func (r *userRepository) FindByID(ctx context.Context, userID string) (*model.User, error) {
 	var user model.User
-	err := r.db.WithContext(ctx).First(&user, "user_id = ?", userID).Error
+	err := r.db.WithContext(ctx).First(&user, "id = ?", userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, apperror.ErrUserNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -44,6 +52,27 @@ func (r *userRepository) FindByEmail(ctx context.Context, email string) (*model.
 	return &user, nil
 }
 
+// FindByIDs returns the users with the given ids; unknown ids are left out.
+func (r *userRepository) FindByIDs(ctx context.Context, ids []uuid.UUID) ([]model.User, error) {
+	var users []model.User
+	if len(ids) == 0 {
+		return users, nil
+	}
+	err := r.db.WithContext(ctx).Where("id IN ?", ids).Find(&users).Error
+	return users, err
+}
+
+// FindByUsernames returns the users with the given usernames; unknown names
+// are left out.
+func (r *userRepository) FindByUsernames(ctx context.Context, usernames []string) ([]model.User, error) {
+	var users []model.User
+	if len(usernames) == 0 {
+		return users, nil
+	}
+	err := r.db.WithContext(ctx).Where("username IN ?", usernames).Find(&users).Error
+	return users, err
+}
+
 func (r *userRepository) FetchAll(ctx context.Context, role *model.UserRole) ([]*model.User, error) {
 	var users []*model.User
 	tx := r.db.WithContext(ctx).Model(&model.User{})
@@ -68,7 +97,7 @@ func (r *userRepository) IsEmailTaken(ctx context.Context, email string) (bool,
 
 func (r *userRepository) Update(ctx context.Context, userID string, input *gqlmodel.UpdateUserInput) (*model.User, error) {
 	var user model.User
-	err := r.db.WithContext(ctx).First(&user, "user_id = ?", userID).Error
+	err := r.db.WithContext(ctx).First(&user, "id = ?", userID).Error
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"go-training-system/internal/graph/apperror"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// updateVersioned applies updates to the row of record and bumps its version,
+// reading the new version and update time back into record. A non-zero
+// expected makes the update conditional on the row still being at that
+// version.
+func updateVersioned(tx *gorm.DB, record interface{}, id uuid.UUID, expected int64, updates map[string]interface{}, notFound error) error {
+	updates["version"] = gorm.Expr("version + 1")
+
+	q := tx.Model(record).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "version"}, {Name: "updated_at"}}}).
+		Where("id = ?", id)
+	if expected > 0 {
+		q = q.Where("version = ?", expected)
+	}
+	res := q.Updates(updates)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return versionMismatch(tx, record, id, notFound)
+	}
+	return nil
+}
+
+// deleteVersioned soft deletes the row of record, conditional on its version
+// when expected is non-zero.
+func deleteVersioned(tx *gorm.DB, record interface{}, id uuid.UUID, expected int64, notFound error) error {
+	q := tx.Where("id = ?", id)
+	if expected > 0 {
+		q = q.Where("version = ?", expected)
+	}
+	res := q.Delete(record)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return versionMismatch(tx, record, id, notFound)
+	}
+	return nil
+}
+
+// checkVersion locks the row of record for the rest of the transaction and
+// fails unless it is at the expected version. A zero expected only checks
+// that the row exists.
+func checkVersion(tx *gorm.DB, record interface{}, id uuid.UUID, expected int64, notFound error) error {
+	var versions []int64
+	err := tx.Model(record).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", id).Pluck("version", &versions).Error
+	if err != nil {
+		return err
+	}
+	if len(versions) == 0 {
+		return notFound
+	}
+	if expected > 0 && versions[0] != expected {
+		return &apperror.VersionConflictError{Current: versions[0]}
+	}
+	return nil
+}
+
+// versionMismatch explains why a conditional write touched no rows: either
+// the row is gone, or it is at another version.
+func versionMismatch(tx *gorm.DB, record interface{}, id uuid.UUID, notFound error) error {
+	var versions []int64
+	if err := tx.Model(record).Where("id = ?", id).Pluck("version", &versions).Error; err != nil {
+		return err
+	}
+	if len(versions) == 0 {
+		return notFound
+	}
+	return &apperror.VersionConflictError{Current: versions[0]}
+}
@@ -0,0 +1,414 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"go-training-system/internal/dto"
+	"go-training-system/internal/graph/apperror"
+	"go-training-system/internal/model"
+	"go-training-system/internal/repository"
+	"go-training-system/pkg/notearchive"
+
+	"github.com/google/uuid"
+)
+
+const (
+	conflictSkip      = "skip"
+	conflictDuplicate = "duplicate"
+)
+
+// ArchiveService exports folders to zip archives of Markdown notes and
+// imports such archives back, as laid out in package notearchive.
+type ArchiveService interface {
+	ExportFolder(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*FolderExport, error)
+	ImportArchive(ctx context.Context, content io.Reader, req *dto.ImportRequest, userID uuid.UUID) (*dto.ImportReport, error)
+}
+
+type archiveService struct {
+	folderRepo  repository.FolderRepository
+	noteRepo    repository.NoteRepository
+	tagRepo     repository.TagRepository
+	importRepo  repository.ImportRepository
+	permissions PermissionResolver
+	// maxSize is the largest archive accepted for import, in bytes.
+	maxSize int64
+	limits  notearchive.Limits
+}
+
+func NewArchiveService(folderRepo repository.FolderRepository, noteRepo repository.NoteRepository, tagRepo repository.TagRepository, importRepo repository.ImportRepository, permissions PermissionResolver, maxSize int64, limits notearchive.Limits) ArchiveService {
+	return &archiveService{
+		folderRepo:  folderRepo,
+		noteRepo:    noteRepo,
+		tagRepo:     tagRepo,
+		importRepo:  importRepo,
+		permissions: permissions,
+		maxSize:     maxSize,
+		limits:      limits,
+	}
+}
+
+// FolderExport is a folder subtree the user is allowed to export. Name is
+// the folder's name.
+type FolderExport struct {
+	Name    string
+	folders []model.Folder
+	userID  uuid.UUID
+	service *archiveService
+}
+
+// ExportFolder checks that the user can read the folder and returns its
+// subtree, ready to be written.
+func (s *archiveService) ExportFolder(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*FolderExport, error) {
+	access, err := s.permissions.FolderAccess(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !canRead(access) {
+		return nil, apperror.ErrAccessDenied
+	}
+
+	folders, err := s.folderRepo.GetSubtree(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(folders) == 0 {
+		return nil, apperror.ErrFolderNotFound
+	}
+
+	return &FolderExport{Name: folders[0].Name, folders: folders, userID: userID, service: s}, nil
+}
+
+// Write streams the export to w as a zip archive, loading one folder's notes
+// at a time. Notes the user cannot read are left out, and only tags the user
+// can see are listed.
+func (e *FolderExport) Write(ctx context.Context, w io.Writer) error {
+	s := e.service
+	archive := notearchive.NewWriter(w)
+	dirs := make(map[uuid.UUID]string, len(e.folders))
+	for _, folder := range e.folders {
+		var parent string
+		if folder.ParentID != nil {
+			parent = dirs[*folder.ParentID]
+		}
+		dir, err := archive.Folder(parent, folder.Name)
+		if err != nil {
+			return err
+		}
+		dirs[folder.ID] = dir
+
+		notes, err := s.noteRepo.GetByFolderID(ctx, folder.ID)
+		if err != nil {
+			return err
+		}
+		ids := make([]uuid.UUID, len(notes))
+		for i, note := range notes {
+			ids[i] = note.ID
+		}
+		access, err := s.permissions.NotesAccess(ctx, ids, e.userID)
+		if err != nil {
+			return err
+		}
+		tags, err := s.tagRepo.GetTagNames(ctx, ids, e.userID)
+		if err != nil {
+			return err
+		}
+
+		for _, note := range notes {
+			if !canRead(access[note.ID]) {
+				continue
+			}
+			err := archive.Note(dir, notearchive.Meta{
+				ID:        note.ID.String(),
+				Title:     note.Title,
+				CreatedAt: note.CreatedAt.UTC(),
+				UpdatedAt: note.UpdatedAt.UTC(),
+				Tags:      tags[note.ID],
+			}, note.Body)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return archive.Close()
+}
+
+// ImportArchive recreates the folders and notes of an archive under the
+// requested folder, owned by the user. Archive folders are merged into
+// writable folders of the same name that already exist there. A note
+// conflicts when it was exported from a note the user can read or its folder
+// already has a note with the same title; conflicting notes are skipped
+// unless OnConflict is "duplicate". Imported notes always get new IDs.
+func (s *archiveService) ImportArchive(ctx context.Context, content io.Reader, req *dto.ImportRequest, userID uuid.UUID) (*dto.ImportReport, error) {
+	onConflict := req.OnConflict
+	if onConflict == "" {
+		onConflict = conflictSkip
+	}
+	if onConflict != conflictSkip && onConflict != conflictDuplicate {
+		return nil, apperror.ErrInvalidConflictMode
+	}
+	if req.FolderID != nil {
+		access, err := s.permissions.FolderAccess(ctx, *req.FolderID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !canWrite(access) {
+			return nil, apperror.ErrAccessDenied
+		}
+	}
+
+	archive, err := s.readArchive(content)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &dto.ImportReport{
+		DryRun:    req.DryRun,
+		Conflicts: []dto.ImportConflict{},
+		Ignored:   archive.Ignored,
+		Warnings:  []string{},
+	}
+	if report.Ignored == nil {
+		report.Ignored = []string{}
+	}
+
+	folders, err := s.planFolders(ctx, archive.Folders, req.FolderID, userID)
+	if err != nil {
+		return nil, err
+	}
+	set := &repository.ImportSet{Tags: make(map[uuid.UUID][]string)}
+	for _, p := range archive.Folders {
+		if folder := folders[p]; folder.create {
+			set.Folders = append(set.Folders, model.Folder{ID: folder.id, Name: path.Base(p), ParentID: folder.parentID})
+		}
+	}
+	report.FoldersCreated = len(set.Folders)
+
+	conflicts, err := s.findConflicts(ctx, archive.Notes, folders, req.FolderID, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, note := range archive.Notes {
+		folderID := req.FolderID
+		if note.Dir != "" {
+			id := folders[note.Dir].id
+			folderID = &id
+		}
+		if folderID == nil {
+			report.Warnings = append(report.Warnings, note.Path+": notes at the top of the archive need a destination folder")
+			report.NotesSkipped++
+			continue
+		}
+
+		if conflict, ok := conflicts[note.Path]; ok {
+			report.Conflicts = append(report.Conflicts, conflict)
+			if onConflict == conflictSkip {
+				report.NotesSkipped++
+				continue
+			}
+		}
+
+		imported := model.Note{
+			ID:        uuid.New(),
+			Title:     note.Meta.Title,
+			Body:      note.Body,
+			FolderID:  *folderID,
+			CreatedAt: note.Meta.CreatedAt,
+			UpdatedAt: note.Meta.UpdatedAt,
+		}
+		for _, tag := range note.Meta.Tags {
+			name, err := normalizeTagName(tag)
+			if err != nil {
+				report.Warnings = append(report.Warnings, fmt.Sprintf("%s: tag %q left out: %v", note.Path, tag, err))
+				continue
+			}
+			set.Tags[imported.ID] = append(set.Tags[imported.ID], name)
+		}
+		set.Notes = append(set.Notes, imported)
+	}
+	report.NotesCreated = len(set.Notes)
+
+	if req.DryRun || (len(set.Folders) == 0 && len(set.Notes) == 0) {
+		return report, nil
+	}
+	if err := s.importRepo.Import(ctx, set, userID); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// readArchive spools an uploaded archive to a temporary file, since zip
+// needs random access, and reads it within the import limits.
+func (s *archiveService) readArchive(content io.Reader) (*notearchive.Archive, error) {
+	tmp, err := os.CreateTemp("", "import-*.zip")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	size, err := io.Copy(tmp, io.LimitReader(content, s.maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if size > s.maxSize {
+		return nil, fmt.Errorf("%w: the limit is %d bytes", apperror.ErrArchiveTooLarge, s.maxSize)
+	}
+
+	archive, err := notearchive.Read(tmp, size, s.limits)
+	var archiveErr *notearchive.Error
+	if errors.As(err, &archiveErr) {
+		if archiveErr.Limit {
+			return nil, fmt.Errorf("%w: %s", apperror.ErrArchiveTooLarge, archiveErr.Reason)
+		}
+		return nil, fmt.Errorf("%w: %s", apperror.ErrInvalidArchive, archiveErr.Reason)
+	}
+	return archive, err
+}
+
+// importFolder is where an archive folder ends up: an existing folder, or a
+// new one to create under parentID.
+type importFolder struct {
+	id       uuid.UUID
+	parentID *uuid.UUID
+	create   bool
+}
+
+// planFolders maps every archive folder path, parents first, to an existing
+// folder it merges into or a new one.
+func (s *archiveService) planFolders(ctx context.Context, paths []string, rootID *uuid.UUID, userID uuid.UUID) (map[string]importFolder, error) {
+	planned := make(map[string]importFolder, len(paths))
+	for _, p := range paths {
+		parentID := rootID
+		existingParent := true
+		if dir := path.Dir(p); dir != "." {
+			parent := planned[dir]
+			parentID = &parent.id
+			existingParent = !parent.create
+		}
+
+		if existingParent {
+			existing, err := s.findWritableFolder(ctx, parentID, path.Base(p), userID)
+			if err != nil {
+				return nil, err
+			}
+			if existing != nil {
+				planned[p] = importFolder{id: *existing, parentID: parentID}
+				continue
+			}
+		}
+		planned[p] = importFolder{id: uuid.New(), parentID: parentID, create: true}
+	}
+	return planned, nil
+}
+
+// findWritableFolder looks for a folder called name that the user can write
+// to, under parentID or among the user's top-level folders.
+func (s *archiveService) findWritableFolder(ctx context.Context, parentID *uuid.UUID, name string, userID uuid.UUID) (*uuid.UUID, error) {
+	var candidates []model.Folder
+	if parentID != nil {
+		children, err := s.folderRepo.GetChildren(ctx, *parentID)
+		if err != nil {
+			return nil, err
+		}
+		candidates = children
+	} else {
+		owned, err := s.folderRepo.GetByOwnerID(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		for _, folder := range owned {
+			if folder.ParentID == nil {
+				candidates = append(candidates, folder)
+			}
+		}
+	}
+
+	for _, folder := range candidates {
+		if folder.Name != name {
+			continue
+		}
+		access, err := s.permissions.FolderAccess(ctx, folder.ID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if canWrite(access) {
+			return &folder.ID, nil
+		}
+	}
+	return nil, nil
+}
+
+// findConflicts returns the conflicting archive notes, keyed by path.
+func (s *archiveService) findConflicts(ctx context.Context, notes []notearchive.Note, folders map[string]importFolder, rootID *uuid.UUID, userID uuid.UUID) (map[string]dto.ImportConflict, error) {
+	conflicts := make(map[string]dto.ImportConflict)
+
+	// Notes exported from this system that the user can still read
+	var exportedIDs []uuid.UUID
+	for _, note := range notes {
+		if id, err := uuid.Parse(note.Meta.ID); err == nil {
+			exportedIDs = append(exportedIDs, id)
+		}
+	}
+	if len(exportedIDs) > 0 {
+		existing, err := s.noteRepo.GetByIDs(ctx, exportedIDs)
+		if err != nil {
+			return nil, err
+		}
+		ids := make([]uuid.UUID, len(existing))
+		for i, note := range existing {
+			ids[i] = note.ID
+		}
+		access, err := s.permissions.NotesAccess(ctx, ids, userID)
+		if err != nil {
+			return nil, err
+		}
+		for _, note := range notes {
+			id, err := uuid.Parse(note.Meta.ID)
+			if err == nil && canRead(access[id]) {
+				conflicts[note.Path] = dto.ImportConflict{Path: note.Path, Reason: "note already exists", NoteID: id}
+			}
+		}
+	}
+
+	// Titles already taken in existing folders
+	titles := make(map[uuid.UUID]map[string]uuid.UUID)
+	for _, note := range notes {
+		if _, ok := conflicts[note.Path]; ok {
+			continue
+		}
+		folderID := rootID
+		if note.Dir != "" {
+			folder := folders[note.Dir]
+			if folder.create {
+				continue
+			}
+			folderID = &folder.id
+		}
+		if folderID == nil {
+			continue
+		}
+
+		taken, ok := titles[*folderID]
+		if !ok {
+			existing, err := s.noteRepo.GetByFolderID(ctx, *folderID)
+			if err != nil {
+				return nil, err
+			}
+			taken = make(map[string]uuid.UUID, len(existing))
+			for _, note := range existing {
+				taken[strings.ToLower(note.Title)] = note.ID
+			}
+			titles[*folderID] = taken
+		}
+		if id, ok := taken[strings.ToLower(note.Meta.Title)]; ok {
+			conflicts[note.Path] = dto.ImportConflict{Path: note.Path, Reason: "folder already has a note with this title", NoteID: id}
+		}
+	}
+	return conflicts, nil
+}
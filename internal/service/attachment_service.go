@@ -0,0 +1,239 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go-training-system/internal/dto"
+	"go-training-system/internal/graph/apperror"
+	"go-training-system/internal/model"
+	"go-training-system/internal/repository"
+	"go-training-system/pkg/blobstore"
+
+	"github.com/google/uuid"
+)
+
+const maxFileNameLength = 255
+
+// AttachmentService manages files attached to notes. Anyone who can read a
+// note can list and download its attachments; adding and removing them
+// needs write access.
+type AttachmentService interface {
+	UploadAttachment(ctx context.Context, noteID uuid.UUID, fileName string, content io.Reader, userID uuid.UUID) (*dto.AttachmentResponse, error)
+	ListAttachments(ctx context.Context, noteID uuid.UUID, userID uuid.UUID) ([]dto.AttachmentResponse, error)
+	OpenAttachment(ctx context.Context, noteID uuid.UUID, attachmentID uuid.UUID, userID uuid.UUID) (*dto.AttachmentResponse, io.ReadSeekCloser, error)
+	DeleteAttachment(ctx context.Context, noteID uuid.UUID, attachmentID uuid.UUID, userID uuid.UUID) error
+}
+
+type attachmentService struct {
+	attachmentRepo repository.AttachmentRepository
+	noteRepo       repository.NoteRepository
+	permissions    PermissionResolver
+	blobs          blobstore.BlobStore
+	// maxSize is the largest file accepted, in bytes.
+	maxSize int64
+}
+
+func NewAttachmentService(attachmentRepo repository.AttachmentRepository, noteRepo repository.NoteRepository, permissions PermissionResolver, blobs blobstore.BlobStore, maxSize int64) AttachmentService {
+	return &attachmentService{
+		attachmentRepo: attachmentRepo,
+		noteRepo:       noteRepo,
+		permissions:    permissions,
+		blobs:          blobs,
+		maxSize:        maxSize,
+	}
+}
+
+// UploadAttachment streams content to a temporary file while hashing it,
+// then stores it in blob storage under its SHA-256 unless a blob with the
+// same content is already there.
+func (s *attachmentService) UploadAttachment(ctx context.Context, noteID uuid.UUID, fileName string, content io.Reader, userID uuid.UUID) (*dto.AttachmentResponse, error) {
+	if err := s.requireNoteAccess(ctx, noteID, userID, canWrite); err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp("", "attachment-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(content, s.maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if size > s.maxSize {
+		return nil, fmt.Errorf("%w: the limit is %d bytes", apperror.ErrAttachmentTooLarge, s.maxSize)
+	}
+	if size == 0 {
+		return nil, apperror.ErrEmptyAttachment
+	}
+
+	head := make([]byte, 512)
+	n, err := tmp.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	fileName = cleanFileName(fileName)
+
+	attachment := &model.Attachment{
+		NoteID:       noteID,
+		FileName:     fileName,
+		ContentType:  detectContentType(head[:n], fileName),
+		Size:         size,
+		SHA256:       hex.EncodeToString(hash.Sum(nil)),
+		UploadedByID: userID,
+	}
+
+	err = s.attachmentRepo.Create(ctx, attachment, func() error {
+		exists, err := s.blobs.Exists(ctx, attachment.SHA256)
+		if err != nil || exists {
+			return err
+		}
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		return s.blobs.Put(ctx, attachment.SHA256, tmp, size, attachment.ContentType)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toAttachmentResponse(attachment), nil
+}
+
+func (s *attachmentService) ListAttachments(ctx context.Context, noteID uuid.UUID, userID uuid.UUID) ([]dto.AttachmentResponse, error) {
+	if err := s.requireNoteAccess(ctx, noteID, userID, canRead); err != nil {
+		return nil, err
+	}
+
+	attachments, err := s.attachmentRepo.GetByNoteID(ctx, noteID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]dto.AttachmentResponse, len(attachments))
+	for i := range attachments {
+		response[i] = *toAttachmentResponse(&attachments[i])
+	}
+	return response, nil
+}
+
+// OpenAttachment returns an attachment's metadata and its content. The
+// caller must close the content.
+func (s *attachmentService) OpenAttachment(ctx context.Context, noteID uuid.UUID, attachmentID uuid.UUID, userID uuid.UUID) (*dto.AttachmentResponse, io.ReadSeekCloser, error) {
+	attachment, err := s.noteAttachment(ctx, noteID, attachmentID, userID, canRead)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	content, err := s.blobs.Get(ctx, attachment.SHA256)
+	if err != nil {
+		return nil, nil, err
+	}
+	return toAttachmentResponse(attachment), content, nil
+}
+
+// DeleteAttachment removes an attachment, and its blob once no attachment
+// refers to that content any more.
+func (s *attachmentService) DeleteAttachment(ctx context.Context, noteID uuid.UUID, attachmentID uuid.UUID, userID uuid.UUID) error {
+	if _, err := s.noteAttachment(ctx, noteID, attachmentID, userID, canWrite); err != nil {
+		return err
+	}
+
+	return s.attachmentRepo.Delete(ctx, attachmentID, func(sha256 string) error {
+		return s.blobs.Delete(ctx, sha256)
+	})
+}
+
+// noteAttachment loads an attachment of the note, checking the user's access
+// to the note with allowed.
+func (s *attachmentService) noteAttachment(ctx context.Context, noteID uuid.UUID, attachmentID uuid.UUID, userID uuid.UUID, allowed func(model.AccessLevel) bool) (*model.Attachment, error) {
+	if err := s.requireNoteAccess(ctx, noteID, userID, allowed); err != nil {
+		return nil, err
+	}
+
+	attachment, err := s.attachmentRepo.GetByID(ctx, attachmentID)
+	if err != nil {
+		return nil, err
+	}
+	if attachment.NoteID != noteID {
+		return nil, apperror.ErrAttachmentNotFound
+	}
+	return attachment, nil
+}
+
+func (s *attachmentService) requireNoteAccess(ctx context.Context, noteID uuid.UUID, userID uuid.UUID, allowed func(model.AccessLevel) bool) error {
+	if _, err := s.noteRepo.GetByID(ctx, noteID); err != nil {
+		return err
+	}
+	access, err := s.permissions.NoteAccess(ctx, noteID, userID)
+	if err != nil {
+		return err
+	}
+	if !allowed(access) {
+		return apperror.ErrAccessDenied
+	}
+	return nil
+}
+
+func toAttachmentResponse(attachment *model.Attachment) *dto.AttachmentResponse {
+	return &dto.AttachmentResponse{
+		ID:           attachment.ID,
+		NoteID:       attachment.NoteID,
+		FileName:     attachment.FileName,
+		ContentType:  attachment.ContentType,
+		Size:         attachment.Size,
+		SHA256:       attachment.SHA256,
+		UploadedByID: attachment.UploadedByID,
+		CreatedAt:    attachment.CreatedAt,
+	}
+}
+
+// detectContentType sniffs the content type from the first bytes of a file.
+// When sniffing only finds generic text or binary data, the file extension
+// decides, so formats like CSV or Markdown keep a useful type.
+func detectContentType(head []byte, fileName string) string {
+	sniffed := http.DetectContentType(head)
+	if sniffed != "application/octet-stream" && !strings.HasPrefix(sniffed, "text/plain") {
+		return sniffed
+	}
+	if byExt := mime.TypeByExtension(filepath.Ext(fileName)); byExt != "" {
+		return byExt
+	}
+	return sniffed
+}
+
+// cleanFileName keeps the base name of an uploaded file, without control
+// characters, and names unnamed uploads "attachment".
+func cleanFileName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == "/" {
+		return "attachment"
+	}
+	if len(name) > maxFileNameLength {
+		ext := filepath.Ext(name)
+		if len(ext) > 16 {
+			ext = ""
+		}
+		name = strings.ToValidUTF8(name[:maxFileNameLength-len(ext)], "") + ext
+	}
+	return name
+}
@@ -0,0 +1,963 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"go-training-system/internal/dto"
+	"go-training-system/internal/event"
+	"go-training-system/internal/graph/apperror"
+	"go-training-system/internal/model"
+	"go-training-system/internal/repository"
+	"go-training-system/pkg/logger"
+	"go-training-system/pkg/ot"
+	"go-training-system/pkg/pubsub"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	// collabHistory is how many operations a session keeps in memory beyond
+	// the last snapshot, for clients resuming or editing behind.
+	collabHistory = 1000
+	// collabKeepOperations is how many stored operations before the last
+	// snapshot are kept.
+	collabKeepOperations = 1000
+	// collabOutbox is how many messages a client can fall behind before it
+	// is disconnected.
+	collabOutbox = 256
+	// collabPresenceInterval is how often access is re-checked and presence
+	// re-announced to other servers. Collaborators on a server that has not
+	// announced them for three intervals are dropped.
+	collabPresenceInterval = 30 * time.Second
+	// collabCommitAttempts bounds how often an operation is rebased onto
+	// edits stored by other servers before giving up.
+	collabCommitAttempts = 5
+)
+
+var errClientTooSlow = errors.New("client is not keeping up with the session")
+
+// CollabService runs collaborative editing sessions for note bodies. The
+// server orders the edits: every operation is stored at the next revision
+// and rebased onto the operations before it, so all clients converge. The
+// body is written back to the note periodically and when everyone has left.
+// Sessions for the same note on several servers stay in step through the
+// database and the pubsub broker. Edits made to the note outside a session,
+// through the API, are merged into it as operations.
+type CollabService interface {
+	// Join checks that the user can read the note and adds them to its
+	// session. Users who cannot write can follow along but not edit. With a
+	// revision the client resumes from it when possible; otherwise it gets
+	// the latest snapshot.
+	Join(ctx context.Context, noteID uuid.UUID, userID uuid.UUID, revision *int64) (*CollabClient, error)
+}
+
+type collabService struct {
+	collabRepo  repository.CollabRepository
+	noteRepo    repository.NoteRepository
+	userRepo    repository.UserRepository
+	permissions PermissionResolver
+	broker      *pubsub.Broker
+	events      *event.Bus
+	// origin tells this server's broadcasts apart from other servers'.
+	origin string
+
+	snapshotInterval  time.Duration
+	revisionRetention int
+
+	mu       sync.Mutex
+	sessions map[uuid.UUID]*collabSession
+}
+
+func NewCollabService(collabRepo repository.CollabRepository, noteRepo repository.NoteRepository, userRepo repository.UserRepository, permissions PermissionResolver, broker *pubsub.Broker, events *event.Bus, snapshotInterval time.Duration, revisionRetention int) CollabService {
+	return &collabService{
+		collabRepo:  collabRepo,
+		noteRepo:    noteRepo,
+		userRepo:    userRepo,
+		permissions: permissions,
+		broker:      broker,
+		events:      events,
+		origin:      uuid.NewString(),
+
+		snapshotInterval:  snapshotInterval,
+		revisionRetention: revisionRetention,
+		sessions:          make(map[uuid.UUID]*collabSession),
+	}
+}
+
+func (s *collabService) Join(ctx context.Context, noteID uuid.UUID, userID uuid.UUID, revision *int64) (*CollabClient, error) {
+	access, err := s.permissions.NoteAccess(ctx, noteID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !canRead(access) {
+		return nil, apperror.ErrAccessDenied
+	}
+	user, err := s.userRepo.FindByID(ctx, userID.String())
+	if err != nil {
+		return nil, err
+	}
+
+	client := &CollabClient{
+		id:       uuid.NewString(),
+		userID:   userID,
+		username: user.Username,
+		readOnly: !canWrite(access),
+		outbox:   make(chan dto.CollabServerMessage, collabOutbox),
+	}
+	for {
+		session, err := s.session(ctx, noteID)
+		if err != nil {
+			return nil, err
+		}
+		if session.join(client, revision) {
+			return client, nil
+		}
+		// The session closed after the last client left; start another
+	}
+}
+
+// session returns the note's session, loading it if there is none.
+func (s *collabService) session(ctx context.Context, noteID uuid.UUID) (*collabSession, error) {
+	s.mu.Lock()
+	session := s.sessions[noteID]
+	if session == nil {
+		session = &collabSession{
+			svc:     s,
+			noteID:  noteID,
+			clients: make(map[string]*CollabClient),
+			remote:  make(map[string]*remoteCollaborator),
+		}
+		s.sessions[noteID] = session
+	}
+	s.mu.Unlock()
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if session.loaded || session.closed {
+		return session, nil
+	}
+	if err := session.load(ctx); err != nil {
+		session.closed = true
+		s.remove(session)
+		return nil, err
+	}
+	return session, nil
+}
+
+func (s *collabService) remove(session *collabSession) {
+	s.mu.Lock()
+	if s.sessions[session.noteID] == session {
+		delete(s.sessions, session.noteID)
+	}
+	s.mu.Unlock()
+}
+
+// CollabClient is one connection to a session. The connection reads its
+// messages from Messages until the channel is closed, which happens when
+// the client is disconnected by the server.
+type CollabClient struct {
+	id       string
+	userID   uuid.UUID
+	username string
+	session  *collabSession
+
+	// Guarded by session.mu
+	readOnly  bool
+	selection *dto.CollabSelection
+	outbox    chan dto.CollabServerMessage
+	gone      bool
+}
+
+func (c *CollabClient) Messages() <-chan dto.CollabServerMessage {
+	return c.outbox
+}
+
+// Submit applies an operation made against the given revision. Any failure
+// leaves the client out of step, so it is disconnected with an error and
+// has to resync.
+func (c *CollabClient) Submit(ctx context.Context, revision int64, op ot.Operation) error {
+	s := c.session
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c.gone {
+		return nil
+	}
+
+	err := c.submit(ctx, revision, op)
+	if err != nil {
+		s.disconnect(c, err)
+	}
+	return err
+}
+
+func (c *CollabClient) submit(ctx context.Context, revision int64, op ot.Operation) error {
+	s := c.session
+	if c.readOnly {
+		return apperror.ErrAccessDenied
+	}
+	// Edits other servers stored first are fetched and rebased onto
+	op, err := s.rebase(op, revision)
+	if err != nil {
+		return err
+	}
+	if op.IsNoop() {
+		s.send(c, dto.CollabServerMessage{Type: dto.CollabAck, Revision: s.revision})
+		return nil
+	}
+	return s.commit(ctx, op, &c.userID, c)
+}
+
+// MoveCursor updates the client's selection, made at the given revision,
+// and shows it to the others. A nil selection hides the cursor. Selections
+// too old to place are ignored.
+func (c *CollabClient) MoveCursor(revision int64, selection *dto.CollabSelection) {
+	s := c.session
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c.gone {
+		return
+	}
+
+	if selection != nil {
+		moved, ok := s.transformSelection(*selection, revision)
+		if !ok {
+			return
+		}
+		selection = &moved
+	}
+	c.selection = selection
+	s.announce(c, false)
+}
+
+// Disconnect removes the client from the session, telling it why first.
+func (c *CollabClient) Disconnect(err error) {
+	s := c.session
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.disconnect(c, err)
+}
+
+// Leave removes the client from the session once its connection is closed.
+func (c *CollabClient) Leave() {
+	c.Disconnect(nil)
+}
+
+// collabSession is the live state of one note's collaborative editing.
+type collabSession struct {
+	svc    *collabService
+	noteID uuid.UUID
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	loaded bool
+	closed bool
+	title  string
+	// doc is the body at revision; history holds the operations up to it,
+	// the last one at revision, going back to the last snapshot at least.
+	doc      string
+	revision int64
+	history  []dto.CollabOperation
+	// snapshot is the one stored last.
+	snapshot   model.NoteSnapshot
+	dirty      bool
+	lastAuthor uuid.UUID
+	clients    map[string]*CollabClient
+	remote     map[string]*remoteCollaborator
+}
+
+// remoteCollaborator is someone in the session on another server.
+type remoteCollaborator struct {
+	dto.CollabCollaborator
+	seen time.Time
+}
+
+// collabBroadcast is what sessions on different servers tell each other.
+// op says an operation was stored at Revision; presence carries a
+// collaborator, with their selection at Revision; hello asks the other
+// servers to announce their collaborators.
+type collabBroadcast struct {
+	Origin       string                  `json:"origin"`
+	Type         string                  `json:"type"`
+	Revision     int64                   `json:"revision"`
+	Collaborator *dto.CollabCollaborator `json:"collaborator,omitempty"`
+}
+
+const collabHello = "hello"
+
+// load reads the note, its snapshot and the operations after it, then
+// starts the session's background work. Callers hold s.mu.
+func (s *collabSession) load(ctx context.Context) (err error) {
+	// Listen before reading, so no change made meanwhile goes unnoticed
+	runCtx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		if err != nil {
+			cancel()
+		}
+	}()
+	remote := s.svc.broker.Subscribe(runCtx, s.topic())
+	notes := s.svc.events.NoteEvents(runCtx, s.noteID)
+
+	note, err := s.svc.noteRepo.GetByID(ctx, s.noteID)
+	if err != nil {
+		return err
+	}
+	snapshot, err := s.svc.collabRepo.GetSnapshot(ctx, s.noteID)
+	if err != nil {
+		return err
+	}
+	if snapshot == nil {
+		// Operations are only ever stored after a snapshot, which tells
+		// what they apply to
+		snapshot = &model.NoteSnapshot{Body: note.Body}
+		err := s.svc.collabRepo.SaveSnapshot(ctx, &model.Note{ID: note.ID, Title: note.Title, Body: note.Body}, note.OwnerID, note.Version, snapshot)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.title = note.Title
+	s.doc = snapshot.Body
+	s.revision = snapshot.Revision
+	s.snapshot = *snapshot
+	if err := s.catchUp(ctx); err != nil {
+		return err
+	}
+	if note.Version != snapshot.NoteVersion {
+		if err := s.reconcile(ctx); err != nil {
+			return err
+		}
+	}
+
+	s.cancel = cancel
+	s.loaded = true
+	go s.run(runCtx, remote, notes)
+	return nil
+}
+
+// run snapshots the session, re-checks access and keeps it in step with
+// other servers and with changes made to the note elsewhere. Broadcasts can
+// be lost, so stored operations are also checked for periodically.
+func (s *collabSession) run(ctx context.Context, remote <-chan []byte, notes <-chan event.NoteEvent) {
+	s.publish(ctx, collabBroadcast{Type: collabHello})
+
+	snapshotTicker := time.NewTicker(s.svc.snapshotInterval)
+	defer snapshotTicker.Stop()
+	presenceTicker := time.NewTicker(collabPresenceInterval)
+	defer presenceTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-snapshotTicker.C:
+			s.mu.Lock()
+			if !s.closed {
+				if err := s.saveSnapshot(ctx); err != nil {
+					s.fail(err)
+				}
+			}
+			s.mu.Unlock()
+		case <-presenceTicker.C:
+			s.recheckAccess(ctx)
+			s.refreshPresence(ctx)
+			s.mu.Lock()
+			if !s.closed {
+				if err := s.catchUp(ctx); err != nil {
+					s.fail(err)
+				}
+			}
+			s.mu.Unlock()
+		case payload, ok := <-remote:
+			if ok {
+				s.receive(ctx, payload)
+			}
+		case e, ok := <-notes:
+			if ok {
+				s.noteChanged(ctx, e)
+			}
+		}
+	}
+}
+
+// join adds the client and sends it the sync message. It fails once the
+// session has closed.
+func (s *collabSession) join(client *CollabClient, revision *int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+
+	client.session = s
+	sync := dto.CollabServerMessage{
+		Type:          dto.CollabSync,
+		Revision:      s.revision,
+		ClientID:      client.id,
+		ReadOnly:      client.readOnly,
+		Collaborators: s.collaborators(),
+	}
+	if revision != nil && s.covers(*revision) {
+		sync.Operations = s.operationsAfter(*revision)
+	} else {
+		sync.Snapshot = &dto.CollabSnapshot{Revision: s.snapshot.Revision, Body: s.snapshot.Body}
+		sync.Operations = s.operationsAfter(s.snapshot.Revision)
+	}
+	s.clients[client.id] = client
+	s.send(client, sync)
+	s.announce(client, false)
+	return true
+}
+
+// disconnect removes a client, sending it err first when there is one. The
+// last client to leave closes the session. Callers hold s.mu.
+func (s *collabSession) disconnect(client *CollabClient, err error) {
+	if client.gone {
+		return
+	}
+	if err != nil {
+		s.send(client, CollabErrorMessage(err))
+	}
+	client.gone = true
+	close(client.outbox)
+	delete(s.clients, client.id)
+	s.announce(client, true)
+
+	if len(s.clients) == 0 && !s.closed {
+		s.close()
+	}
+}
+
+// close saves the body and stops the session. Callers hold s.mu.
+func (s *collabSession) close() {
+	if err := s.saveSnapshot(context.Background()); err != nil {
+		logger.Log.Error("failed to save collaborative editing snapshot", zap.String("note", s.noteID.String()), zap.Error(err))
+	}
+	s.shutdown()
+}
+
+// shutdown disconnects the remaining clients and stops the session without
+// saving it. Callers hold s.mu.
+func (s *collabSession) shutdown() {
+	s.closed = true
+	for _, client := range s.clients {
+		client.gone = true
+		close(client.outbox)
+	}
+	s.clients = nil
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.svc.remove(s)
+}
+
+// send queues a message for a client, disconnecting clients that fall too
+// far behind. Callers hold s.mu.
+func (s *collabSession) send(client *CollabClient, msg dto.CollabServerMessage) {
+	if client.gone {
+		return
+	}
+	select {
+	case client.outbox <- msg:
+	default:
+		client.gone = true
+		close(client.outbox)
+		delete(s.clients, client.id)
+		s.announce(client, true)
+		logger.Log.Info("disconnected collaborative editing client", zap.String("note", s.noteID.String()), zap.Error(errClientTooSlow))
+	}
+}
+
+// broadcast sends a message to every client but one. Callers hold s.mu.
+func (s *collabSession) broadcast(msg dto.CollabServerMessage, except *CollabClient) {
+	for _, client := range s.clients {
+		if client != except {
+			s.send(client, msg)
+		}
+	}
+}
+
+// commit stores an operation on the current revision, applies it and sends
+// it to everyone. Should another server store an operation there first, op
+// is rebased onto it and tried again. The operation is tried on the document
+// before it is stored, since a stored operation that fails to apply would
+// break every later load of the note. Callers hold s.mu.
+func (s *collabSession) commit(ctx context.Context, op ot.Operation, authorID *uuid.UUID, origin *CollabClient) error {
+	for attempt := 1; ; attempt++ {
+		if op.BaseLen() != utf8.RuneCountInString(s.doc) {
+			return fmt.Errorf("%w: it does not span the document", apperror.ErrInvalidOperation)
+		}
+		if _, err := ot.Apply(s.doc, op); err != nil {
+			return fmt.Errorf("%w: %v", apperror.ErrInvalidOperation, err)
+		}
+		encoded, err := json.Marshal(op)
+		if err != nil {
+			return err
+		}
+		err = s.svc.collabRepo.AppendOperation(ctx, &model.NoteOperation{
+			NoteID:    s.noteID,
+			Revision:  s.revision + 1,
+			Operation: string(encoded),
+			AuthorID:  authorID,
+		})
+		if errors.Is(err, apperror.ErrRevisionTaken) && attempt < collabCommitAttempts {
+			base := s.revision
+			if err := s.catchUp(ctx); err != nil {
+				return err
+			}
+			if op, err = s.rebase(op, base); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := s.apply(dto.CollabOperation{Revision: s.revision + 1, Operation: op, AuthorID: authorID}, origin); err != nil {
+			return err
+		}
+		if origin != nil {
+			s.send(origin, dto.CollabServerMessage{Type: dto.CollabAck, Revision: s.revision})
+		}
+		s.publish(ctx, collabBroadcast{Type: dto.CollabOp, Revision: s.revision})
+		return nil
+	}
+}
+
+// apply advances the document by a stored operation and sends it to every
+// client but its author's. Callers hold s.mu.
+func (s *collabSession) apply(op dto.CollabOperation, origin *CollabClient) error {
+	doc, err := ot.Apply(s.doc, op.Operation)
+	if err != nil {
+		return err
+	}
+	s.doc = doc
+	s.revision = op.Revision
+	s.history = append(s.history, op)
+	s.dirty = true
+	if op.AuthorID != nil {
+		s.lastAuthor = *op.AuthorID
+	}
+
+	for _, client := range s.clients {
+		if client.selection != nil {
+			moved := transformSelection(*client.selection, op.Operation)
+			client.selection = &moved
+		}
+	}
+	for _, collaborator := range s.remote {
+		if collaborator.Selection != nil {
+			moved := transformSelection(*collaborator.Selection, op.Operation)
+			collaborator.Selection = &moved
+		}
+	}
+
+	s.broadcast(dto.CollabServerMessage{
+		Type:      dto.CollabOp,
+		Revision:  op.Revision,
+		Operation: op.Operation,
+		AuthorID:  op.AuthorID,
+	}, origin)
+	return nil
+}
+
+// catchUp applies the operations other servers stored after the current
+// revision. Callers hold s.mu.
+func (s *collabSession) catchUp(ctx context.Context) error {
+	stored, err := s.svc.collabRepo.GetOperations(ctx, s.noteID, s.revision)
+	if err != nil {
+		return err
+	}
+	for _, record := range stored {
+		if record.Revision != s.revision+1 {
+			return fmt.Errorf("operation %d of note %s is missing", s.revision+1, s.noteID)
+		}
+		var op ot.Operation
+		if err := json.Unmarshal([]byte(record.Operation), &op); err != nil {
+			return err
+		}
+		err := s.apply(dto.CollabOperation{Revision: record.Revision, Operation: op, AuthorID: record.AuthorID}, nil)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rebase transforms an operation made against an earlier revision so it
+// applies to the current one. Callers hold s.mu.
+func (s *collabSession) rebase(op ot.Operation, revision int64) (ot.Operation, error) {
+	if !s.covers(revision) {
+		return nil, apperror.ErrStaleRevision
+	}
+	for _, concurrent := range s.operationsAfter(revision) {
+		var err error
+		if op, _, err = ot.Transform(op, concurrent.Operation); err != nil {
+			return nil, fmt.Errorf("%w: %v", apperror.ErrInvalidOperation, err)
+		}
+	}
+	return op, nil
+}
+
+// covers reports whether the session can bring revision up to date.
+// Callers hold s.mu.
+func (s *collabSession) covers(revision int64) bool {
+	return revision <= s.revision && revision >= s.revision-int64(len(s.history))
+}
+
+// operationsAfter returns the operations following revision, which the
+// session must cover. Callers hold s.mu.
+func (s *collabSession) operationsAfter(revision int64) []dto.CollabOperation {
+	return s.history[len(s.history)-int(s.revision-revision):]
+}
+
+// transformSelection moves a selection made at revision to the current one,
+// failing when the revision is not covered or the selection is outside the
+// document. Callers hold s.mu.
+func (s *collabSession) transformSelection(selection dto.CollabSelection, revision int64) (dto.CollabSelection, bool) {
+	if !s.covers(revision) {
+		return selection, false
+	}
+	for _, op := range s.operationsAfter(revision) {
+		selection = transformSelection(selection, op.Operation)
+	}
+	length := utf8.RuneCountInString(s.doc)
+	if selection.Anchor < 0 || selection.Head < 0 || selection.Anchor > length || selection.Head > length {
+		return selection, false
+	}
+	return selection, true
+}
+
+func transformSelection(selection dto.CollabSelection, op ot.Operation) dto.CollabSelection {
+	return dto.CollabSelection{
+		Anchor: ot.TransformIndex(selection.Anchor, op),
+		Head:   ot.TransformIndex(selection.Head, op),
+	}
+}
+
+// collaborators lists everyone in the session. Callers hold s.mu.
+func (s *collabSession) collaborators() []dto.CollabCollaborator {
+	collaborators := make([]dto.CollabCollaborator, 0, len(s.clients)+len(s.remote))
+	for _, client := range s.clients {
+		collaborators = append(collaborators, collaboratorOf(client, false))
+	}
+	for _, collaborator := range s.remote {
+		collaborators = append(collaborators, collaborator.CollabCollaborator)
+	}
+	return collaborators
+}
+
+func collaboratorOf(client *CollabClient, left bool) dto.CollabCollaborator {
+	return dto.CollabCollaborator{
+		ClientID:  client.id,
+		UserID:    client.userID,
+		Username:  client.username,
+		Selection: client.selection,
+		Left:      left,
+	}
+}
+
+// announce tells everyone else, here and on other servers, where a client
+// is or that it left. Callers hold s.mu.
+func (s *collabSession) announce(client *CollabClient, left bool) {
+	collaborator := collaboratorOf(client, left)
+	s.broadcast(dto.CollabServerMessage{
+		Type:         dto.CollabPresence,
+		Revision:     s.revision,
+		Collaborator: &collaborator,
+	}, client)
+	s.publish(context.Background(), collabBroadcast{
+		Type:         dto.CollabPresence,
+		Revision:     s.revision,
+		Collaborator: &collaborator,
+	})
+}
+
+// receive handles a broadcast from a session on another server.
+func (s *collabSession) receive(ctx context.Context, payload []byte) {
+	var msg collabBroadcast
+	if err := json.Unmarshal(payload, &msg); err != nil || msg.Origin == s.svc.origin {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	if msg.Revision > s.revision {
+		if err := s.catchUp(ctx); err != nil {
+			s.fail(err)
+			return
+		}
+	}
+
+	switch msg.Type {
+	case collabHello:
+		for _, client := range s.clients {
+			s.announce(client, false)
+		}
+	case dto.CollabPresence:
+		collaborator := msg.Collaborator
+		if collaborator == nil {
+			return
+		}
+		if collaborator.Left {
+			delete(s.remote, collaborator.ClientID)
+		} else {
+			if collaborator.Selection != nil {
+				moved, ok := s.transformSelection(*collaborator.Selection, msg.Revision)
+				if !ok {
+					return
+				}
+				collaborator.Selection = &moved
+			}
+			s.remote[collaborator.ClientID] = &remoteCollaborator{CollabCollaborator: *collaborator, seen: time.Now()}
+		}
+		s.broadcast(dto.CollabServerMessage{
+			Type:         dto.CollabPresence,
+			Revision:     s.revision,
+			Collaborator: collaborator,
+		}, nil)
+	}
+}
+
+// refreshPresence re-announces the clients on this server and drops the
+// collaborators other servers stopped announcing.
+func (s *collabSession) refreshPresence(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, client := range s.clients {
+		collaborator := collaboratorOf(client, false)
+		s.publish(ctx, collabBroadcast{Type: dto.CollabPresence, Revision: s.revision, Collaborator: &collaborator})
+	}
+	for id, collaborator := range s.remote {
+		if time.Since(collaborator.seen) > 3*collabPresenceInterval {
+			delete(s.remote, id)
+			collaborator.Left = true
+			s.broadcast(dto.CollabServerMessage{
+				Type:         dto.CollabPresence,
+				Revision:     s.revision,
+				Collaborator: &collaborator.CollabCollaborator,
+			}, nil)
+		}
+	}
+}
+
+// recheckAccess disconnects clients that can no longer read the note and
+// updates who can edit it.
+func (s *collabSession) recheckAccess(ctx context.Context) {
+	s.mu.Lock()
+	clients := make([]*CollabClient, 0, len(s.clients))
+	for _, client := range s.clients {
+		clients = append(clients, client)
+	}
+	s.mu.Unlock()
+
+	access := make(map[*CollabClient]model.AccessLevel, len(clients))
+	for _, client := range clients {
+		level, err := s.svc.permissions.NoteAccess(ctx, s.noteID, client.userID)
+		if err != nil {
+			logger.Log.Error("failed to check collaborative editing access", zap.String("note", s.noteID.String()), zap.Error(err))
+			return
+		}
+		access[client] = level
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for client, level := range access {
+		if client.gone {
+			continue
+		}
+		if !canRead(level) {
+			s.disconnect(client, apperror.ErrAccessDenied)
+			continue
+		}
+		client.readOnly = !canWrite(level)
+	}
+}
+
+// noteChanged merges changes made to the note outside the session and ends
+// the session when the note is deleted.
+func (s *collabSession) noteChanged(ctx context.Context, e event.NoteEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+
+	switch {
+	case e.Kind == event.KindDeleted:
+		s.fail(apperror.ErrNoteNotFound)
+	case e.Version != s.snapshot.NoteVersion:
+		if err := s.reconcile(ctx); err != nil {
+			s.fail(err)
+			return
+		}
+		if err := s.saveSnapshot(ctx); err != nil {
+			s.fail(err)
+		}
+	}
+}
+
+// reconcile catches up with a note that changed since the last snapshot.
+// When another server's session stored the snapshot it is adopted;
+// otherwise the note was edited through the API, and the edit is merged as
+// an operation made against the snapshot. Callers hold s.mu.
+func (s *collabSession) reconcile(ctx context.Context) error {
+	note, err := s.svc.noteRepo.GetByID(ctx, s.noteID)
+	if err != nil {
+		return err
+	}
+	if note.Version == s.snapshot.NoteVersion {
+		return nil
+	}
+	snapshot, err := s.svc.collabRepo.GetSnapshot(ctx, s.noteID)
+	if err != nil {
+		return err
+	}
+	if snapshot == nil {
+		return apperror.ErrNoteNotFound
+	}
+	if snapshot.Revision > s.revision {
+		if err := s.catchUp(ctx); err != nil {
+			return err
+		}
+	}
+	s.title = note.Title
+	s.snapshot = *snapshot
+	if snapshot.NoteVersion == note.Version {
+		return nil
+	}
+
+	op, err := s.rebase(ot.Diff(snapshot.Body, note.Body), snapshot.Revision)
+	if err != nil {
+		return err
+	}
+	if !op.IsNoop() {
+		if err := s.commit(ctx, op, nil, nil); err != nil {
+			return err
+		}
+	}
+	// The next snapshot is made against the note as it is now
+	s.snapshot.NoteVersion = note.Version
+	s.dirty = true
+	return nil
+}
+
+// saveSnapshot writes the body back to the note if it changed, merging
+// edits made meanwhile outside the session first. Callers hold s.mu.
+func (s *collabSession) saveSnapshot(ctx context.Context) error {
+	if !s.dirty {
+		return nil
+	}
+
+	for attempt := 1; ; attempt++ {
+		author := s.lastAuthor
+		note := &model.Note{ID: s.noteID, Title: s.title, Body: s.doc}
+		snapshot := &model.NoteSnapshot{Revision: s.revision, Body: s.doc}
+		err := s.svc.collabRepo.SaveSnapshot(ctx, note, author, s.snapshot.NoteVersion, snapshot)
+		if errors.Is(err, apperror.ErrVersionConflict) && attempt < collabCommitAttempts {
+			if err := s.reconcile(ctx); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		changed := note.Version != s.snapshot.NoteVersion
+		s.snapshot = *snapshot
+		s.dirty = false
+		s.trimHistory()
+		if changed {
+			s.svc.events.NoteChanged(ctx, event.NoteEvent{
+				NoteID:   s.noteID,
+				FolderID: note.FolderID,
+				Kind:     event.KindUpdated,
+				Version:  note.Version,
+				ActorID:  author,
+			})
+		}
+		s.prune(ctx)
+		return nil
+	}
+}
+
+// prune deletes stored operations and note revisions that are no longer
+// needed. Callers hold s.mu.
+func (s *collabSession) prune(ctx context.Context) {
+	if through := s.snapshot.Revision - collabKeepOperations; through > 0 {
+		if err := s.svc.collabRepo.PruneOperations(ctx, s.noteID, through); err != nil {
+			logger.Log.Error("failed to prune collaborative editing operations", zap.String("note", s.noteID.String()), zap.Error(err))
+		}
+	}
+	if s.svc.revisionRetention > 0 {
+		if err := s.svc.noteRepo.PruneRevisions(ctx, s.noteID, s.svc.revisionRetention); err != nil {
+			logger.Log.Error("failed to prune note revisions", zap.String("note", s.noteID.String()), zap.Error(err))
+		}
+	}
+}
+
+// trimHistory drops operations from memory that are before the last
+// snapshot and more than collabHistory old. Callers hold s.mu.
+func (s *collabSession) trimHistory() {
+	keepFrom := min(s.snapshot.Revision, s.revision-collabHistory)
+	if drop := len(s.history) - int(s.revision-keepFrom); drop > 0 {
+		s.history = append([]dto.CollabOperation(nil), s.history[drop:]...)
+	}
+}
+
+// fail disconnects everyone after the session got out of step with the
+// stored note, so clients reconnect to a fresh session. Callers hold s.mu.
+func (s *collabSession) fail(err error) {
+	logger.Log.Warn("closing collaborative editing session", zap.String("note", s.noteID.String()), zap.Error(err))
+	for _, client := range s.clients {
+		s.send(client, CollabErrorMessage(err))
+	}
+	s.shutdown()
+}
+
+func (s *collabSession) publish(ctx context.Context, msg collabBroadcast) {
+	msg.Origin = s.svc.origin
+	payload, err := json.Marshal(msg)
+	if err == nil {
+		err = s.svc.broker.Publish(ctx, s.topic(), payload)
+	}
+	if err != nil {
+		logger.Log.Warn("failed to publish collaborative editing update", zap.String("note", s.noteID.String()), zap.Error(err))
+	}
+}
+
+func (s *collabSession) topic() string {
+	return "collab:" + s.noteID.String()
+}
+
+// CollabErrorMessage describes err to a collaborative editing client, with
+// the HTTP status a request failing the same way would get as its code.
+func CollabErrorMessage(err error) dto.CollabServerMessage {
+	code := http.StatusInternalServerError
+	message := "internal server error"
+	switch {
+	case errors.Is(err, apperror.ErrAccessDenied):
+		code, message = http.StatusForbidden, err.Error()
+	case errors.Is(err, apperror.ErrNoteNotFound):
+		code, message = http.StatusNotFound, err.Error()
+	case errors.Is(err, apperror.ErrInvalidOperation):
+		code, message = http.StatusBadRequest, err.Error()
+	case errors.Is(err, apperror.ErrStaleRevision):
+		code, message = http.StatusConflict, err.Error()
+	}
+	return dto.CollabServerMessage{Type: dto.CollabError, Code: code, Message: message}
+}
@@ -0,0 +1,325 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go-training-system/internal/dto"
+	"go-training-system/internal/graph/apperror"
+	"go-training-system/internal/model"
+	"go-training-system/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+const (
+	maxCommentLength = 10000
+	maxMentions      = 20
+)
+
+// mentionPattern matches @username where the @ does not follow a word
+// character, so email addresses are not read as mentions.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@(\w[\w.-]*)`)
+
+// CommentService manages threaded comments on notes. Anyone who can read a
+// note can read its comments, comment, reply and resolve threads. Comments
+// can be edited by their author and deleted by their author or anyone who
+// manages the note; deleting the first comment of a thread deletes the whole
+// thread.
+type CommentService interface {
+	ListComments(ctx context.Context, noteID uuid.UUID, userID uuid.UUID) ([]dto.CommentResponse, error)
+	CreateComment(ctx context.Context, noteID uuid.UUID, req *dto.CreateCommentRequest, userID uuid.UUID) (*dto.CommentResponse, error)
+	UpdateComment(ctx context.Context, id uuid.UUID, req *dto.UpdateCommentRequest, userID uuid.UUID, expectedVersion int64) (*dto.CommentResponse, error)
+	ResolveComment(ctx context.Context, id uuid.UUID, req *dto.ResolveCommentRequest, userID uuid.UUID) (*dto.CommentResponse, error)
+	DeleteComment(ctx context.Context, id uuid.UUID, userID uuid.UUID, expectedVersion int64) error
+}
+
+type commentService struct {
+	commentRepo repository.CommentRepository
+	noteRepo    repository.NoteRepository
+	userRepo    repository.UserRepository
+	permissions PermissionResolver
+}
+
+func NewCommentService(commentRepo repository.CommentRepository, noteRepo repository.NoteRepository, userRepo repository.UserRepository, permissions PermissionResolver) CommentService {
+	return &commentService{
+		commentRepo: commentRepo,
+		noteRepo:    noteRepo,
+		userRepo:    userRepo,
+		permissions: permissions,
+	}
+}
+
+// ListComments returns the note's threads, oldest first, each with its
+// replies.
+func (s *commentService) ListComments(ctx context.Context, noteID uuid.UUID, userID uuid.UUID) ([]dto.CommentResponse, error) {
+	if err := s.requireRead(ctx, noteID, userID); err != nil {
+		return nil, err
+	}
+
+	comments, err := s.commentRepo.GetByNoteID(ctx, noteID)
+	if err != nil {
+		return nil, err
+	}
+
+	replies := make(map[uuid.UUID][]dto.CommentResponse)
+	for i := range comments {
+		if parentID := comments[i].ParentID; parentID != nil {
+			replies[*parentID] = append(replies[*parentID], *toCommentResponse(&comments[i]))
+		}
+	}
+	threads := make([]dto.CommentResponse, 0)
+	for i := range comments {
+		if comments[i].ParentID == nil {
+			thread := toCommentResponse(&comments[i])
+			thread.Replies = replies[comments[i].ID]
+			threads = append(threads, *thread)
+		}
+	}
+	return threads, nil
+}
+
+// CreateComment starts a thread or, with a parent, replies to it. Replying
+// to a reply adds to the same thread.
+func (s *commentService) CreateComment(ctx context.Context, noteID uuid.UUID, req *dto.CreateCommentRequest, userID uuid.UUID) (*dto.CommentResponse, error) {
+	if err := s.requireRead(ctx, noteID, userID); err != nil {
+		return nil, err
+	}
+
+	body, err := normalizeCommentBody(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	comment := &model.Comment{NoteID: noteID, AuthorID: userID, Body: body}
+
+	if req.ParentID != nil {
+		if req.Anchor != nil {
+			return nil, fmt.Errorf("%w: only the first comment of a thread can be anchored", apperror.ErrInvalidComment)
+		}
+		parent, err := s.commentRepo.GetByID(ctx, *req.ParentID)
+		if err != nil {
+			return nil, err
+		}
+		if parent.NoteID != noteID {
+			return nil, apperror.ErrCommentNotFound
+		}
+		comment.ParentID = &parent.ID
+		if parent.ParentID != nil {
+			comment.ParentID = parent.ParentID
+		}
+	}
+
+	if req.Anchor != nil {
+		note, err := s.noteRepo.GetByID(ctx, noteID)
+		if err != nil {
+			return nil, err
+		}
+		text := []rune(note.Body)
+		if req.Anchor.Start < 0 || req.Anchor.End <= req.Anchor.Start || req.Anchor.End > len(text) {
+			return nil, fmt.Errorf("%w: anchor must be a non-empty range of the note body", apperror.ErrInvalidComment)
+		}
+		comment.AnchorStart = &req.Anchor.Start
+		comment.AnchorEnd = &req.Anchor.End
+		comment.AnchorText = string(text[req.Anchor.Start:req.Anchor.End])
+		comment.AnchorVersion = &note.Version
+	}
+
+	if comment.Mentions, err = s.mentions(ctx, noteID, body); err != nil {
+		return nil, err
+	}
+
+	if err := s.commentRepo.Create(ctx, comment); err != nil {
+		return nil, err
+	}
+
+	return s.getComment(ctx, comment.ID)
+}
+
+// UpdateComment replaces the body of the user's own comment.
+func (s *commentService) UpdateComment(ctx context.Context, id uuid.UUID, req *dto.UpdateCommentRequest, userID uuid.UUID, expectedVersion int64) (*dto.CommentResponse, error) {
+	comment, err := s.commentRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.requireRead(ctx, comment.NoteID, userID); err != nil {
+		return nil, err
+	}
+	if comment.AuthorID != userID {
+		return nil, apperror.ErrAccessDenied
+	}
+
+	if comment.Body, err = normalizeCommentBody(req.Body); err != nil {
+		return nil, err
+	}
+	if comment.Mentions, err = s.mentions(ctx, comment.NoteID, comment.Body); err != nil {
+		return nil, err
+	}
+
+	if err := s.commentRepo.Update(ctx, comment, expectedVersion); err != nil {
+		return nil, err
+	}
+
+	return s.getComment(ctx, id)
+}
+
+// ResolveComment resolves or reopens a thread.
+func (s *commentService) ResolveComment(ctx context.Context, id uuid.UUID, req *dto.ResolveCommentRequest, userID uuid.UUID) (*dto.CommentResponse, error) {
+	comment, err := s.commentRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.requireRead(ctx, comment.NoteID, userID); err != nil {
+		return nil, err
+	}
+	if comment.ParentID != nil {
+		return nil, fmt.Errorf("%w: only the first comment of a thread can be resolved", apperror.ErrInvalidComment)
+	}
+
+	if req.Resolved {
+		now := time.Now()
+		comment.ResolvedAt = &now
+		comment.ResolvedByID = &userID
+	} else {
+		comment.ResolvedAt = nil
+		comment.ResolvedByID = nil
+	}
+
+	if err := s.commentRepo.SetResolved(ctx, comment); err != nil {
+		return nil, err
+	}
+
+	return toCommentResponse(comment), nil
+}
+
+func (s *commentService) DeleteComment(ctx context.Context, id uuid.UUID, userID uuid.UUID, expectedVersion int64) error {
+	comment, err := s.commentRepo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	access, err := s.permissions.NoteAccess(ctx, comment.NoteID, userID)
+	if err != nil {
+		return err
+	}
+	if !canRead(access) || (comment.AuthorID != userID && !canManage(access)) {
+		return apperror.ErrAccessDenied
+	}
+
+	return s.commentRepo.Delete(ctx, id, expectedVersion)
+}
+
+func (s *commentService) requireRead(ctx context.Context, noteID uuid.UUID, userID uuid.UUID) error {
+	access, err := s.permissions.NoteAccess(ctx, noteID, userID)
+	if err != nil {
+		return err
+	}
+	if !canRead(access) {
+		return apperror.ErrAccessDenied
+	}
+	return nil
+}
+
+func (s *commentService) getComment(ctx context.Context, id uuid.UUID) (*dto.CommentResponse, error) {
+	comment, err := s.commentRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return toCommentResponse(comment), nil
+}
+
+// mentions resolves the @usernames in a comment body. Every one must name a
+// user who can read the note.
+func (s *commentService) mentions(ctx context.Context, noteID uuid.UUID, body string) ([]model.CommentMention, error) {
+	names := parseMentions(body)
+	if len(names) == 0 {
+		return nil, nil
+	}
+	if len(names) > maxMentions {
+		return nil, fmt.Errorf("%w: at most %d users can be mentioned", apperror.ErrInvalidComment, maxMentions)
+	}
+
+	users, err := s.userRepo.FindByUsernames(ctx, names)
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[string]bool, len(users))
+	for _, user := range users {
+		found[user.Username] = true
+	}
+	for _, name := range names {
+		if !found[name] {
+			return nil, fmt.Errorf("%w: @%s", apperror.ErrInvalidMention, name)
+		}
+	}
+
+	mentions := make([]model.CommentMention, len(users))
+	for i, user := range users {
+		access, err := s.permissions.NoteAccess(ctx, noteID, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		if !canRead(access) {
+			return nil, fmt.Errorf("%w: @%s", apperror.ErrInvalidMention, user.Username)
+		}
+		mentions[i] = model.CommentMention{UserID: user.ID}
+	}
+	return mentions, nil
+}
+
+// parseMentions returns the distinct usernames mentioned in a comment, in
+// order. Trailing dots and hyphens are taken as punctuation.
+func parseMentions(body string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		name := strings.TrimRight(match[1], ".-")
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func normalizeCommentBody(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	if body == "" || utf8.RuneCountInString(body) > maxCommentLength {
+		return "", fmt.Errorf("%w: body must be 1 to %d characters", apperror.ErrInvalidComment, maxCommentLength)
+	}
+	return body, nil
+}
+
+func toCommentResponse(comment *model.Comment) *dto.CommentResponse {
+	response := &dto.CommentResponse{
+		ID:             comment.ID,
+		NoteID:         comment.NoteID,
+		ParentID:       comment.ParentID,
+		AuthorID:       comment.AuthorID,
+		AuthorUsername: comment.Author.Username,
+		Body:           comment.Body,
+		Mentions:       make([]dto.CommentMention, len(comment.Mentions)),
+		Resolved:       comment.ResolvedAt != nil,
+		ResolvedAt:     comment.ResolvedAt,
+		ResolvedByID:   comment.ResolvedByID,
+		Version:        comment.Version,
+		CreatedAt:      comment.CreatedAt,
+		UpdatedAt:      comment.UpdatedAt,
+	}
+	if comment.AnchorStart != nil && comment.AnchorEnd != nil {
+		response.Anchor = &dto.CommentAnchor{
+			Start: *comment.AnchorStart,
+			End:   *comment.AnchorEnd,
+			Text:  comment.AnchorText,
+		}
+		if comment.AnchorVersion != nil {
+			response.Anchor.NoteVersion = *comment.AnchorVersion
+		}
+	}
+	for i, mention := range comment.Mentions {
+		response.Mentions[i] = dto.CommentMention{UserID: mention.UserID, Username: mention.User.Username}
+	}
+	return response
+}
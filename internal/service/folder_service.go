@@ -3,8 +3,11 @@ package service
 import (
 	"context"
 	"errors"
+	"time"
 
 	"go-training-system/internal/dto"
+	"go-training-system/internal/event"
+	"go-training-system/internal/graph/apperror"
 	"go-training-system/internal/model"
 	"go-training-system/internal/repository"
 
@@ -14,40 +17,79 @@ import (
 type FolderService interface {
 	CreateFolder(ctx context.Context, req *dto.CreateFolderRequest, ownerID uuid.UUID) (*dto.FolderResponse, error)
 	GetFolder(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*dto.FolderResponse, error)
-	GetUserFolders(ctx context.Context, userID uuid.UUID) ([]dto.FolderResponse, error)
-	UpdateFolder(ctx context.Context, id uuid.UUID, req *dto.UpdateFolderRequest, userID uuid.UUID) (*dto.FolderResponse, error)
-	DeleteFolder(ctx context.Context, id uuid.UUID, userID uuid.UUID) error
+	GetFolders(ctx context.Context, ids []uuid.UUID, userID uuid.UUID) ([]dto.FolderResponse, error)
+	GetUserFolders(ctx context.Context, req *dto.ListRequest, userID uuid.UUID) (*dto.FolderPage, error)
+	UpdateFolder(ctx context.Context, id uuid.UUID, req *dto.UpdateFolderRequest, userID uuid.UUID, expectedVersion int64) (*dto.FolderResponse, error)
+	DeleteFolder(ctx context.Context, id uuid.UUID, userID uuid.UUID, recursive bool, expectedVersion int64) error
+	GetFolderChildren(ctx context.Context, id uuid.UUID, userID uuid.UUID) ([]dto.FolderResponse, error)
+	GetFolderTree(ctx context.Context, userID uuid.UUID) ([]dto.FolderTreeNode, error)
+	GetFolderPath(ctx context.Context, id uuid.UUID, userID uuid.UUID) ([]dto.FolderBreadcrumb, error)
+	MoveFolder(ctx context.Context, id uuid.UUID, req *dto.MoveFolderRequest, userID uuid.UUID, expectedVersion int64) (*dto.FolderResponse, error)
+	CopyFolder(ctx context.Context, id uuid.UUID, req *dto.CopyFolderRequest, userID uuid.UUID) (*dto.FolderResponse, error)
 	ShareFolder(ctx context.Context, folderID uuid.UUID, req *dto.ShareRequest, sharedByID uuid.UUID) error
+	GetFolderShares(ctx context.Context, folderID uuid.UUID, userID uuid.UUID) ([]dto.ShareResponse, error)
+	GetFoldersShares(ctx context.Context, folderIDs []uuid.UUID, userID uuid.UUID) (map[uuid.UUID][]dto.ShareResponse, error)
+	UpdateFolderShare(ctx context.Context, folderID uuid.UUID, userID uuid.UUID, req *dto.UpdateShareRequest, updatedByID uuid.UUID) error
+	RevokeFolderShare(ctx context.Context, folderID uuid.UUID, userID uuid.UUID, revokedByID uuid.UUID) error
+	ShareFolderWithTeam(ctx context.Context, folderID uuid.UUID, req *dto.TeamShareRequest, sharedByID uuid.UUID) error
+	GetFolderTeamShares(ctx context.Context, folderID uuid.UUID, userID uuid.UUID) ([]dto.TeamShareResponse, error)
+	GetFoldersTeamShares(ctx context.Context, folderIDs []uuid.UUID, userID uuid.UUID) (map[uuid.UUID][]dto.TeamShareResponse, error)
+	RevokeFolderTeamShare(ctx context.Context, folderID uuid.UUID, teamID uuid.UUID, userID uuid.UUID) error
 }
 
 type folderService struct {
-	folderRepo repository.FolderRepository
-	userRepo   repository.UserRepository
+	folderRepo    repository.FolderRepository
+	noteRepo      repository.NoteRepository
+	userRepo      repository.UserRepository
+	shareRepo     repository.ShareRepository
+	teamShareRepo repository.TeamShareRepository
+	permissions   PermissionResolver
+	events        *event.Bus
 }
 
-func NewFolderService(folderRepo repository.FolderRepository, userRepo repository.UserRepository) FolderService {
+func NewFolderService(folderRepo repository.FolderRepository, noteRepo repository.NoteRepository, userRepo repository.UserRepository, shareRepo repository.ShareRepository, teamShareRepo repository.TeamShareRepository, permissions PermissionResolver, events *event.Bus) FolderService {
 	return &folderService{
-		folderRepo: folderRepo,
-		userRepo:   userRepo,
+		folderRepo:    folderRepo,
+		noteRepo:      noteRepo,
+		userRepo:      userRepo,
+		shareRepo:     shareRepo,
+		teamShareRepo: teamShareRepo,
+		permissions:   permissions,
+		events:        events,
 	}
 }
 
 func (s *folderService) CreateFolder(ctx context.Context, req *dto.CreateFolderRequest, ownerID uuid.UUID) (*dto.FolderResponse, error) {
+	// Creating a subfolder needs write access to the parent
+	if req.ParentID != nil {
+		access, err := s.permissions.FolderAccess(ctx, *req.ParentID, ownerID)
+		if err != nil {
+			return nil, err
+		}
+		if !canWrite(access) {
+			return nil, apperror.ErrAccessDenied
+		}
+	}
+
 	folder := &model.Folder{
 		Name:        req.Name,
 		Description: req.Description,
+		ParentID:    req.ParentID,
 		OwnerID:     ownerID,
 	}
 
 	if err := s.folderRepo.Create(ctx, folder); err != nil {
 		return nil, err
 	}
+	s.events.FolderChanged(ctx, folder.ID, event.KindCreated, ownerID, folder.ParentID)
 
 	return &dto.FolderResponse{
 		ID:          folder.ID,
 		Name:        folder.Name,
 		Description: folder.Description,
+		ParentID:    folder.ParentID,
 		OwnerID:     folder.OwnerID,
+		Version:     folder.Version,
 		CreatedAt:   folder.CreatedAt,
 		UpdatedAt:   folder.UpdatedAt,
 	}, nil
@@ -59,52 +101,54 @@ func (s *folderService) GetFolder(ctx context.Context, id uuid.UUID, userID uuid
 		return nil, err
 	}
 
-	// Check if user has access
-	if folder.OwnerID != userID {
-		hasAccess := false
-		for _, share := range folder.Shares {
-			if share.UserID == userID {
-				hasAccess = true
-				break
-			}
-		}
-		if !hasAccess {
-			return nil, errors.New("access denied")
-		}
+	access, err := s.permissions.FolderAccess(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !canRead(access) {
+		return nil, apperror.ErrAccessDenied
 	}
 
 	return &dto.FolderResponse{
 		ID:          folder.ID,
 		Name:        folder.Name,
 		Description: folder.Description,
+		ParentID:    folder.ParentID,
 		OwnerID:     folder.OwnerID,
+		Version:     folder.Version,
 		CreatedAt:   folder.CreatedAt,
 		UpdatedAt:   folder.UpdatedAt,
 	}, nil
 }
 
-func (s *folderService) GetUserFolders(ctx context.Context, userID uuid.UUID) ([]dto.FolderResponse, error) {
-	// Get owned folders
-	ownedFolders, err := s.folderRepo.GetByOwnerID(ctx, userID)
+// GetFolders is GetFolder for several folders at once. Folders that do not
+// exist or that the user cannot read are left out.
+func (s *folderService) GetFolders(ctx context.Context, ids []uuid.UUID, userID uuid.UUID) ([]dto.FolderResponse, error) {
+	access, err := s.permissions.FoldersAccess(ctx, ids, userID)
 	if err != nil {
 		return nil, err
 	}
+	readable := make([]uuid.UUID, 0, len(access))
+	for id, level := range access {
+		if canRead(level) {
+			readable = append(readable, id)
+		}
+	}
 
-	// Get shared folders
-	sharedFolders, err := s.folderRepo.GetSharedWithUser(ctx, userID)
+	folders, err := s.folderRepo.GetByIDs(ctx, readable)
 	if err != nil {
 		return nil, err
 	}
 
-	// Combine and convert to response
-	allFolders := append(ownedFolders, sharedFolders...)
-	response := make([]dto.FolderResponse, len(allFolders))
-	for i, folder := range allFolders {
+	response := make([]dto.FolderResponse, len(folders))
+	for i, folder := range folders {
 		response[i] = dto.FolderResponse{
 			ID:          folder.ID,
 			Name:        folder.Name,
 			Description: folder.Description,
+			ParentID:    folder.ParentID,
 			OwnerID:     folder.OwnerID,
+			Version:     folder.Version,
 			CreatedAt:   folder.CreatedAt,
 			UpdatedAt:   folder.UpdatedAt,
 		}
@@ -113,44 +157,373 @@ func (s *folderService) GetUserFolders(ctx context.Context, userID uuid.UUID) ([
 	return response, nil
 }
 
-func (s *folderService) UpdateFolder(ctx context.Context, id uuid.UUID, req *dto.UpdateFolderRequest, userID uuid.UUID) (*dto.FolderResponse, error) {
+// GetUserFolders lists one page of the folders the user owns or has been
+// shared, or of the subfolders of req.FolderID, which takes read access.
+func (s *folderService) GetUserFolders(ctx context.Context, req *dto.ListRequest, userID uuid.UUID) (*dto.FolderPage, error) {
+	query, err := listQuery(req, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.FolderID != nil {
+		access, err := s.permissions.FolderAccess(ctx, *req.FolderID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !canRead(access) {
+			return nil, apperror.ErrAccessDenied
+		}
+	}
+
+	folders, total, err := s.folderRepo.List(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	size, more := pageSize(query, len(folders))
+	page := &dto.FolderPage{Items: make([]dto.FolderResponse, size), Total: total}
+	for i, folder := range folders[:size] {
+		page.Items[i] = dto.FolderResponse{
+			ID:          folder.ID,
+			Name:        folder.Name,
+			Description: folder.Description,
+			ParentID:    folder.ParentID,
+			OwnerID:     folder.OwnerID,
+			Version:     folder.Version,
+			CreatedAt:   folder.CreatedAt,
+			UpdatedAt:   folder.UpdatedAt,
+		}
+	}
+	if more {
+		last := folders[size-1]
+		page.NextCursor = encodeCursor(query, listPosition(query, last.ID, last.Name, last.CreatedAt, last.UpdatedAt))
+	}
+
+	return page, nil
+}
+
+func (s *folderService) UpdateFolder(ctx context.Context, id uuid.UUID, req *dto.UpdateFolderRequest, userID uuid.UUID, expectedVersion int64) (*dto.FolderResponse, error) {
 	folder, err := s.folderRepo.GetByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
-	if folder.OwnerID != userID {
-		return nil, errors.New("access denied")
+	access, err := s.permissions.FolderAccess(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !canWrite(access) {
+		return nil, apperror.ErrAccessDenied
 	}
 
 	folder.Name = req.Name
 	folder.Description = req.Description
 
-	if err := s.folderRepo.Update(ctx, folder); err != nil {
+	if err := s.folderRepo.Update(ctx, folder, expectedVersion); err != nil {
 		return nil, err
 	}
+	s.events.FolderChanged(ctx, folder.ID, event.KindUpdated, userID, folder.ParentID)
 
 	return &dto.FolderResponse{
 		ID:          folder.ID,
 		Name:        folder.Name,
 		Description: folder.Description,
+		ParentID:    folder.ParentID,
 		OwnerID:     folder.OwnerID,
+		Version:     folder.Version,
 		CreatedAt:   folder.CreatedAt,
 		UpdatedAt:   folder.UpdatedAt,
 	}, nil
 }
 
-func (s *folderService) DeleteFolder(ctx context.Context, id uuid.UUID, userID uuid.UUID) error {
+// DeleteFolder soft deletes a folder, which needs manage access. Without
+// recursive the folder must be empty; with it, every subfolder and note below
+// is deleted too.
+func (s *folderService) DeleteFolder(ctx context.Context, id uuid.UUID, userID uuid.UUID, recursive bool, expectedVersion int64) error {
 	folder, err := s.folderRepo.GetByID(ctx, id)
 	if err != nil {
 		return err
 	}
 
-	if folder.OwnerID != userID {
-		return errors.New("access denied")
+	access, err := s.permissions.FolderAccess(ctx, id, userID)
+	if err != nil {
+		return err
+	}
+	if !canManage(access) {
+		return apperror.ErrAccessDenied
 	}
 
-	return s.folderRepo.Delete(ctx, id)
+	if !recursive {
+		hasContents, err := s.folderRepo.HasContents(ctx, id)
+		if err != nil {
+			return err
+		}
+		if hasContents {
+			return apperror.ErrFolderNotEmpty
+		}
+	}
+
+	if recursive {
+		err = s.folderRepo.DeleteRecursive(ctx, id, expectedVersion)
+	} else {
+		err = s.folderRepo.Delete(ctx, id, expectedVersion)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.events.FolderChanged(ctx, id, event.KindDeleted, userID, folder.ParentID)
+	return nil
+}
+
+func (s *folderService) GetFolderChildren(ctx context.Context, id uuid.UUID, userID uuid.UUID) ([]dto.FolderResponse, error) {
+	access, err := s.permissions.FolderAccess(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !canRead(access) {
+		return nil, apperror.ErrAccessDenied
+	}
+
+	children, err := s.folderRepo.GetChildren(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]dto.FolderResponse, len(children))
+	for i, folder := range children {
+		response[i] = dto.FolderResponse{
+			ID:          folder.ID,
+			Name:        folder.Name,
+			Description: folder.Description,
+			ParentID:    folder.ParentID,
+			OwnerID:     folder.OwnerID,
+			Version:     folder.Version,
+			CreatedAt:   folder.CreatedAt,
+			UpdatedAt:   folder.UpdatedAt,
+		}
+	}
+
+	return response, nil
+}
+
+// GetFolderTree returns every folder the user can see as a forest. Folders
+// whose parent the user cannot see, such as a shared subfolder, become roots.
+func (s *folderService) GetFolderTree(ctx context.Context, userID uuid.UUID) ([]dto.FolderTreeNode, error) {
+	folders, err := s.folderRepo.GetVisibleToUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	visible := make(map[uuid.UUID]bool, len(folders))
+	for _, folder := range folders {
+		visible[folder.ID] = true
+	}
+	children := make(map[uuid.UUID][]model.Folder)
+	var roots []model.Folder
+	for _, folder := range folders {
+		if folder.ParentID != nil && visible[*folder.ParentID] {
+			children[*folder.ParentID] = append(children[*folder.ParentID], folder)
+		} else {
+			roots = append(roots, folder)
+		}
+	}
+
+	var build func(folder model.Folder) dto.FolderTreeNode
+	build = func(folder model.Folder) dto.FolderTreeNode {
+		node := dto.FolderTreeNode{
+			ID:       folder.ID,
+			Name:     folder.Name,
+			ParentID: folder.ParentID,
+			OwnerID:  folder.OwnerID,
+			Children: make([]dto.FolderTreeNode, 0, len(children[folder.ID])),
+		}
+		for _, child := range children[folder.ID] {
+			node.Children = append(node.Children, build(child))
+		}
+		return node
+	}
+
+	tree := make([]dto.FolderTreeNode, 0, len(roots))
+	for _, root := range roots {
+		tree = append(tree, build(root))
+	}
+	return tree, nil
+}
+
+// GetFolderPath returns the breadcrumb from the top-level folder down to the
+// given one. Ancestors the user cannot read are left off, so a shared
+// subfolder's path starts at the highest folder the user was given.
+func (s *folderService) GetFolderPath(ctx context.Context, id uuid.UUID, userID uuid.UUID) ([]dto.FolderBreadcrumb, error) {
+	access, err := s.permissions.FolderAccess(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !canRead(access) {
+		return nil, apperror.ErrAccessDenied
+	}
+
+	path, err := s.folderRepo.GetPath(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Access is inherited downwards, so everything below the first readable
+	// ancestor is readable too.
+	start := len(path) - 1
+	for i := 0; i < len(path)-1; i++ {
+		access, err := s.permissions.FolderAccess(ctx, path[i].ID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if canRead(access) {
+			start = i
+			break
+		}
+	}
+
+	breadcrumbs := make([]dto.FolderBreadcrumb, 0, len(path)-start)
+	for _, folder := range path[start:] {
+		breadcrumbs = append(breadcrumbs, dto.FolderBreadcrumb{ID: folder.ID, Name: folder.Name})
+	}
+	return breadcrumbs, nil
+}
+
+// MoveFolder reparents a folder together with its notes and subfolders. The
+// user needs write access to the folder, its current parent and the new
+// parent. The folder may land in another owner's tree and keeps its owner,
+// but since the owners of the new parent and its ancestors gain manage over
+// the whole subtree, that move needs manage access to the folder. Only the
+// owner can move a folder to the top level or drop its shares.
+func (s *folderService) MoveFolder(ctx context.Context, id uuid.UUID, req *dto.MoveFolderRequest, userID uuid.UUID, expectedVersion int64) (*dto.FolderResponse, error) {
+	folder, err := s.folderRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	check := []uuid.UUID{id}
+	if folder.ParentID != nil {
+		check = append(check, *folder.ParentID)
+	}
+	if req.ParentID != nil {
+		check = append(check, *req.ParentID)
+	} else if folder.OwnerID != userID {
+		return nil, apperror.ErrAccessDenied
+	}
+	if req.DropShares && folder.OwnerID != userID {
+		return nil, apperror.ErrAccessDenied
+	}
+	var folderAccess model.AccessLevel
+	for _, folderID := range check {
+		access, err := s.permissions.FolderAccess(ctx, folderID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !canWrite(access) {
+			return nil, apperror.ErrAccessDenied
+		}
+		if folderID == id {
+			folderAccess = access
+		}
+	}
+	if req.ParentID != nil && !canManage(folderAccess) {
+		path, err := s.folderRepo.GetPath(ctx, *req.ParentID)
+		if err != nil {
+			return nil, err
+		}
+		for _, ancestor := range path {
+			if ancestor.OwnerID != folder.OwnerID {
+				return nil, apperror.ErrAccessDenied
+			}
+		}
+	}
+
+	previousParentID := folder.ParentID
+	if err := s.folderRepo.Move(ctx, folder, req.ParentID, req.DropShares, expectedVersion); err != nil {
+		return nil, err
+	}
+	s.events.FolderChanged(ctx, folder.ID, event.KindMoved, userID, folder.ParentID, previousParentID)
+
+	return &dto.FolderResponse{
+		ID:          folder.ID,
+		Name:        folder.Name,
+		Description: folder.Description,
+		ParentID:    folder.ParentID,
+		OwnerID:     folder.OwnerID,
+		Version:     folder.Version,
+		CreatedAt:   folder.CreatedAt,
+		UpdatedAt:   folder.UpdatedAt,
+	}, nil
+}
+
+// CopyFolder deep copies a folder with its subfolders and notes under a new
+// parent, or to the top level. The user needs read access to the folder and
+// write access to the new parent. Everything copied is owned by the user;
+// notes the user cannot read are left out, and shares are not copied.
+func (s *folderService) CopyFolder(ctx context.Context, id uuid.UUID, req *dto.CopyFolderRequest, userID uuid.UUID) (*dto.FolderResponse, error) {
+	access, err := s.permissions.FolderAccess(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !canRead(access) {
+		return nil, apperror.ErrAccessDenied
+	}
+	if req.ParentID != nil {
+		access, err := s.permissions.FolderAccess(ctx, *req.ParentID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !canWrite(access) {
+			return nil, apperror.ErrAccessDenied
+		}
+	}
+
+	folders, err := s.folderRepo.GetSubtree(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	folderIDs := make([]uuid.UUID, len(folders))
+	for i, folder := range folders {
+		folderIDs[i] = folder.ID
+	}
+
+	// Access to the subfolders is inherited from the folder, but a note can
+	// have an override that hides it from the user.
+	notes, err := s.noteRepo.GetByFolderIDs(ctx, folderIDs)
+	if err != nil {
+		return nil, err
+	}
+	noteIDs := make([]uuid.UUID, len(notes))
+	for i, note := range notes {
+		noteIDs[i] = note.ID
+	}
+	noteAccess, err := s.permissions.NotesAccess(ctx, noteIDs, userID)
+	if err != nil {
+		return nil, err
+	}
+	readable := notes[:0]
+	for _, note := range notes {
+		if canRead(noteAccess[note.ID]) {
+			readable = append(readable, note)
+		}
+	}
+
+	folder, err := s.folderRepo.Copy(ctx, folders, readable, req.ParentID, userID)
+	if err != nil {
+		return nil, err
+	}
+	s.events.FolderChanged(ctx, folder.ID, event.KindCreated, userID, folder.ParentID)
+
+	return &dto.FolderResponse{
+		ID:          folder.ID,
+		Name:        folder.Name,
+		Description: folder.Description,
+		ParentID:    folder.ParentID,
+		OwnerID:     folder.OwnerID,
+		Version:     folder.Version,
+		CreatedAt:   folder.CreatedAt,
+		UpdatedAt:   folder.UpdatedAt,
+	}, nil
 }
 
 func (s *folderService) ShareFolder(ctx context.Context, folderID uuid.UUID, req *dto.ShareRequest, sharedByID uuid.UUID) error {
@@ -159,11 +532,356 @@ func (s *folderService) ShareFolder(ctx context.Context, folderID uuid.UUID, req
 		return err
 	}
 
-	if folder.OwnerID != sharedByID {
-		return errors.New("access denied")
+	manages, err := s.authorizeFolderShare(ctx, folder, sharedByID)
+	if err != nil {
+		return err
+	}
+	if req.Override {
+		return apperror.ErrInvalidOverride
+	}
+	if err := validateShareAccess(req.Access); err != nil {
+		return err
+	}
+	if err := validateShareExpiry(req.ExpiresAt); err != nil {
+		return err
+	}
+	if req.UserID == sharedByID {
+		return apperror.ErrSelfShare
+	}
+	if req.UserID == folder.OwnerID {
+		return apperror.ErrShareWithOwner
+	}
+	if _, err := s.userRepo.FindByID(ctx, req.UserID.String()); err != nil {
+		return err
+	}
+
+	// Re-sharers can add new shares but not take over ones granted by
+	// someone else, and cannot pass the re-share right on or grant manage.
+	if !manages {
+		if req.AllowReshare || req.Access == model.AccessLevelManage {
+			return apperror.ErrAccessDenied
+		}
+		existing, err := s.shareRepo.GetFolderShare(ctx, folderID, req.UserID)
+		if err != nil && !errors.Is(err, apperror.ErrShareNotFound) {
+			return err
+		}
+		if existing != nil && existing.SharedByID != sharedByID {
+			return apperror.ErrAccessDenied
+		}
+	}
+
+	return s.shareRepo.ShareFolder(ctx, &model.FolderShare{
+		FolderID:     folderID,
+		UserID:       req.UserID,
+		Access:       req.Access,
+		AllowReshare: req.AllowReshare,
+		ExpiresAt:    req.ExpiresAt,
+		SharedAt:     time.Now(),
+		SharedByID:   sharedByID,
+	})
+}
+
+func (s *folderService) GetFolderShares(ctx context.Context, folderID uuid.UUID, userID uuid.UUID) ([]dto.ShareResponse, error) {
+	folder, err := s.folderRepo.GetByID(ctx, folderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.authorizeFolderShare(ctx, folder, userID); err != nil {
+		return nil, err
+	}
+
+	shares, err := s.shareRepo.GetFolderShares(ctx, folderID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]dto.ShareResponse, len(shares))
+	for i, share := range shares {
+		response[i] = dto.ShareResponse{
+			UserID:       share.UserID,
+			Username:     share.User.Username,
+			Access:       share.Access,
+			AllowReshare: share.AllowReshare,
+			ExpiresAt:    share.ExpiresAt,
+			SharedByID:   share.SharedByID,
+			SharedAt:     share.SharedAt,
+		}
+	}
+
+	return response, nil
+}
+
+// GetFoldersShares is GetFolderShares for several folders at once. Only the
+// folders whose shares the user may see are in the result, with an empty
+// list when they have none.
+func (s *folderService) GetFoldersShares(ctx context.Context, folderIDs []uuid.UUID, userID uuid.UUID) (map[uuid.UUID][]dto.ShareResponse, error) {
+	allowed, err := s.shareableFolders(ctx, folderIDs, userID, false)
+	if err != nil {
+		return nil, err
 	}
 
-	// Implementation for sharing logic would go here
-	// You'd need a FolderShareRepository for this
+	shares, err := s.shareRepo.GetFoldersShares(ctx, allowed)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make(map[uuid.UUID][]dto.ShareResponse, len(allowed))
+	for _, id := range allowed {
+		response[id] = []dto.ShareResponse{}
+	}
+	for _, share := range shares {
+		response[share.FolderID] = append(response[share.FolderID], dto.ShareResponse{
+			UserID:       share.UserID,
+			Username:     share.User.Username,
+			Access:       share.Access,
+			AllowReshare: share.AllowReshare,
+			ExpiresAt:    share.ExpiresAt,
+			SharedByID:   share.SharedByID,
+			SharedAt:     share.SharedAt,
+		})
+	}
+
+	return response, nil
+}
+
+func (s *folderService) UpdateFolderShare(ctx context.Context, folderID uuid.UUID, userID uuid.UUID, req *dto.UpdateShareRequest, updatedByID uuid.UUID) error {
+	folder, err := s.folderRepo.GetByID(ctx, folderID)
+	if err != nil {
+		return err
+	}
+
+	manages, err := s.authorizeFolderShare(ctx, folder, updatedByID)
+	if err != nil {
+		return err
+	}
+	if req.Override != nil && *req.Override {
+		return apperror.ErrInvalidOverride
+	}
+	if err := validateShareAccess(req.Access); err != nil {
+		return err
+	}
+	if err := validateShareExpiry(req.ExpiresAt); err != nil {
+		return err
+	}
+
+	share, err := s.shareRepo.GetFolderShare(ctx, folderID, userID)
+	if err != nil {
+		return err
+	}
+	if !manages && (share.SharedByID != updatedByID || req.AllowReshare != nil || req.Access == model.AccessLevelManage) {
+		return apperror.ErrAccessDenied
+	}
+
+	share.Access = req.Access
+	if req.AllowReshare != nil {
+		share.AllowReshare = *req.AllowReshare
+	}
+	// A changed expiry gets its own warning
+	if req.RemoveExpiry {
+		share.ExpiresAt, share.ExpiryWarnedAt = nil, nil
+	} else if req.ExpiresAt != nil {
+		share.ExpiresAt, share.ExpiryWarnedAt = req.ExpiresAt, nil
+	}
+	return s.shareRepo.ShareFolder(ctx, share)
+}
+
+// RevokeFolderShare removes a user's share. The owner and managers can revoke
+// any share, a re-sharer only the ones they granted, and any user can drop
+// their own.
+func (s *folderService) RevokeFolderShare(ctx context.Context, folderID uuid.UUID, userID uuid.UUID, revokedByID uuid.UUID) error {
+	folder, err := s.folderRepo.GetByID(ctx, folderID)
+	if err != nil {
+		return err
+	}
+
+	if userID != revokedByID {
+		manages, err := s.authorizeFolderShare(ctx, folder, revokedByID)
+		if err != nil {
+			return err
+		}
+		if !manages {
+			share, err := s.shareRepo.GetFolderShare(ctx, folderID, userID)
+			if err != nil {
+				return err
+			}
+			if share.SharedByID != revokedByID {
+				return apperror.ErrAccessDenied
+			}
+		}
+	}
+
+	return s.shareRepo.RevokeFolderShare(ctx, folderID, userID)
+}
+
+func (s *folderService) ShareFolderWithTeam(ctx context.Context, folderID uuid.UUID, req *dto.TeamShareRequest, sharedByID uuid.UUID) error {
+	folder, err := s.folderRepo.GetByID(ctx, folderID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.requireFolderManage(ctx, folder, sharedByID); err != nil {
+		return err
+	}
+
+	if err := validateAccess(req.Access); err != nil {
+		return err
+	}
+
+	return s.teamShareRepo.ShareFolder(ctx, &model.FolderTeamShare{
+		FolderID:     folderID,
+		TeamID:       req.TeamID,
+		Access:       req.Access,
+		ManagersOnly: req.ManagersOnly,
+		SharedAt:     time.Now(),
+		SharedByID:   sharedByID,
+	})
+}
+
+func (s *folderService) GetFolderTeamShares(ctx context.Context, folderID uuid.UUID, userID uuid.UUID) ([]dto.TeamShareResponse, error) {
+	folder, err := s.folderRepo.GetByID(ctx, folderID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.requireFolderManage(ctx, folder, userID); err != nil {
+		return nil, err
+	}
+
+	shares, err := s.teamShareRepo.GetFolderShares(ctx, folderID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]dto.TeamShareResponse, len(shares))
+	for i, share := range shares {
+		response[i] = dto.TeamShareResponse{
+			TeamID:       share.TeamID,
+			TeamName:     share.Team.TeamName,
+			Access:       share.Access,
+			ManagersOnly: share.ManagersOnly,
+			SharedByID:   share.SharedByID,
+			SharedAt:     share.SharedAt,
+		}
+	}
+
+	return response, nil
+}
+
+// GetFoldersTeamShares is GetFolderTeamShares for several folders at once.
+// Only the folders the user manages are in the result, with an empty list
+// when they have none.
+func (s *folderService) GetFoldersTeamShares(ctx context.Context, folderIDs []uuid.UUID, userID uuid.UUID) (map[uuid.UUID][]dto.TeamShareResponse, error) {
+	allowed, err := s.shareableFolders(ctx, folderIDs, userID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	shares, err := s.teamShareRepo.GetFoldersShares(ctx, allowed)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make(map[uuid.UUID][]dto.TeamShareResponse, len(allowed))
+	for _, id := range allowed {
+		response[id] = []dto.TeamShareResponse{}
+	}
+	for _, share := range shares {
+		response[share.FolderID] = append(response[share.FolderID], dto.TeamShareResponse{
+			TeamID:       share.TeamID,
+			TeamName:     share.Team.TeamName,
+			Access:       share.Access,
+			ManagersOnly: share.ManagersOnly,
+			SharedByID:   share.SharedByID,
+			SharedAt:     share.SharedAt,
+		})
+	}
+
+	return response, nil
+}
+
+func (s *folderService) RevokeFolderTeamShare(ctx context.Context, folderID uuid.UUID, teamID uuid.UUID, userID uuid.UUID) error {
+	folder, err := s.folderRepo.GetByID(ctx, folderID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.requireFolderManage(ctx, folder, userID); err != nil {
+		return err
+	}
+
+	return s.teamShareRepo.RevokeFolderShare(ctx, folderID, teamID)
+}
+
+// authorizeFolderShare reports whether the user may manage shares on the folder:
+// the owner and anyone with manage access always can, and an unexpired
+// write-share holder can when re-sharing was allowed. manages tells the two
+// apart.
+func (s *folderService) authorizeFolderShare(ctx context.Context, folder *model.Folder, userID uuid.UUID) (manages bool, err error) {
+	if folder.OwnerID == userID {
+		return true, nil
+	}
+	access, err := s.permissions.FolderAccess(ctx, folder.ID, userID)
+	if err != nil {
+		return false, err
+	}
+	if canManage(access) {
+		return true, nil
+	}
+	share, err := s.shareRepo.GetFolderShare(ctx, folder.ID, userID)
+	if errors.Is(err, apperror.ErrShareNotFound) {
+		return false, apperror.ErrAccessDenied
+	}
+	if err != nil {
+		return false, err
+	}
+	if share.Access != model.AccessLevelWrite || !share.AllowReshare || shareExpired(share.ExpiresAt) {
+		return false, apperror.ErrAccessDenied
+	}
+	return false, nil
+}
+
+// shareableFolders is authorizeFolderShare for several folders at once: it
+// returns those among folderIDs whose shares the user may manage, or with
+// manageOnly, only those the user manages.
+func (s *folderService) shareableFolders(ctx context.Context, folderIDs []uuid.UUID, userID uuid.UUID, manageOnly bool) ([]uuid.UUID, error) {
+	access, err := s.permissions.FoldersAccess(ctx, folderIDs, userID)
+	if err != nil {
+		return nil, err
+	}
+	allowed := make([]uuid.UUID, 0, len(access))
+	for id, level := range access {
+		if canManage(level) {
+			allowed = append(allowed, id)
+			continue
+		}
+		// Only a write-share holder can have been allowed to re-share
+		if manageOnly || level != model.AccessLevelWrite {
+			continue
+		}
+		share, err := s.shareRepo.GetFolderShare(ctx, id, userID)
+		if errors.Is(err, apperror.ErrShareNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if share.Access == model.AccessLevelWrite && share.AllowReshare && !shareExpired(share.ExpiresAt) {
+			allowed = append(allowed, id)
+		}
+	}
+	return allowed, nil
+}
+
+// requireFolderManage allows only the owner and manage holders, who alone can
+// manage team shares.
+func (s *folderService) requireFolderManage(ctx context.Context, folder *model.Folder, userID uuid.UUID) error {
+	manages, err := s.authorizeFolderShare(ctx, folder, userID)
+	if err != nil {
+		return err
+	}
+	if !manages {
+		return apperror.ErrAccessDenied
+	}
 	return nil
 }
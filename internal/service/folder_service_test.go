@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go-training-system/internal/dto"
+	"go-training-system/internal/graph/apperror"
+	"go-training-system/internal/model"
+	"go-training-system/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// fakeFolderRepository implements only what MoveFolder uses.
+type fakeFolderRepository struct {
+	repository.FolderRepository
+	folders map[uuid.UUID]model.Folder
+	moved   bool
+}
+
+func (f *fakeFolderRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.Folder, error) {
+	folder, ok := f.folders[id]
+	if !ok {
+		return nil, apperror.ErrFolderNotFound
+	}
+	return &folder, nil
+}
+
+func (f *fakeFolderRepository) GetPath(ctx context.Context, id uuid.UUID) ([]model.Folder, error) {
+	var path []model.Folder
+	for next := &id; next != nil; {
+		folder := f.folders[*next]
+		path = append([]model.Folder{folder}, path...)
+		next = folder.ParentID
+	}
+	return path, nil
+}
+
+func (f *fakeFolderRepository) Move(ctx context.Context, folder *model.Folder, parentID *uuid.UUID, dropShares bool, expectedVersion int64) error {
+	f.moved = true
+	folder.ParentID = parentID
+	return nil
+}
+
+func TestMoveFolderAccess(t *testing.T) {
+	user := uuid.New()
+	owner := uuid.New()
+	source := uuid.New()
+	ownerFolder := uuid.New()
+	userFolder := uuid.New()
+	ownerFolderInUserTree := uuid.New()
+
+	folders := map[uuid.UUID]model.Folder{
+		source:                {ID: source, OwnerID: owner},
+		ownerFolder:           {ID: ownerFolder, OwnerID: owner},
+		userFolder:            {ID: userFolder, OwnerID: user},
+		ownerFolderInUserTree: {ID: ownerFolderInUserTree, OwnerID: owner, ParentID: &userFolder},
+	}
+	parentGrants := map[uuid.UUID]repository.AccessGrants{
+		source:                {ID: source, OwnerID: owner, FolderUser: model.AccessLevelWrite},
+		ownerFolder:           {ID: ownerFolder, OwnerID: owner, FolderUser: model.AccessLevelWrite},
+		userFolder:            {ID: userFolder, OwnerID: user, OwnsFolder: true},
+		ownerFolderInUserTree: {ID: ownerFolderInUserTree, OwnerID: owner, OwnsFolder: true},
+	}
+
+	tests := []struct {
+		name    string
+		folder  repository.AccessGrants
+		parent  uuid.UUID
+		wantErr error
+	}{
+		{
+			name:   "write holder within the owner's tree",
+			folder: repository.AccessGrants{OwnerID: owner, FolderUser: model.AccessLevelWrite},
+			parent: ownerFolder,
+		},
+		{
+			name:    "write holder into their own folder",
+			folder:  repository.AccessGrants{OwnerID: owner, FolderUser: model.AccessLevelWrite},
+			parent:  userFolder,
+			wantErr: apperror.ErrAccessDenied,
+		},
+		{
+			name:    "write holder into the owner's folder inside their own tree",
+			folder:  repository.AccessGrants{OwnerID: owner, FolderUser: model.AccessLevelWrite},
+			parent:  ownerFolderInUserTree,
+			wantErr: apperror.ErrAccessDenied,
+		},
+		{
+			name:   "manage holder into their own folder",
+			folder: repository.AccessGrants{OwnerID: owner, FolderUser: model.AccessLevelManage},
+			parent: userFolder,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			folderID := uuid.New()
+			tt.folder.ID = folderID
+			repo := &fakeFolderRepository{folders: map[uuid.UUID]model.Folder{}}
+			for id, folder := range folders {
+				repo.folders[id] = folder
+			}
+			repo.folders[folderID] = model.Folder{ID: folderID, OwnerID: owner, ParentID: &source}
+
+			grants := map[uuid.UUID]repository.AccessGrants{folderID: tt.folder}
+			for id, g := range parentGrants {
+				grants[id] = g
+			}
+			s := &folderService{
+				folderRepo:  repo,
+				permissions: NewPermissionResolver(&fakePermissionRepository{folders: grants}),
+			}
+
+			_, err := s.MoveFolder(context.Background(), folderID, &dto.MoveFolderRequest{ParentID: &tt.parent}, user, 1)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("MoveFolder() error = %v, want %v", err, tt.wantErr)
+			}
+			if repo.moved != (tt.wantErr == nil) {
+				t.Errorf("folder moved = %v, want %v", repo.moved, tt.wantErr == nil)
+			}
+		})
+	}
+}
@@ -3,10 +3,17 @@ package service
 import (
 	"context"
 	"errors"
+	"strings"
+	"time"
 
 	"go-training-system/internal/dto"
+	"go-training-system/internal/event"
+	"go-training-system/internal/graph/apperror"
 	"go-training-system/internal/model"
 	"go-training-system/internal/repository"
+	"go-training-system/pkg/markdown"
+	"go-training-system/pkg/tagexpr"
+	"go-training-system/pkg/textdiff"
 
 	"github.com/google/uuid"
 )
@@ -14,44 +21,72 @@ import (
 type NoteService interface {
 	CreateNote(ctx context.Context, req *dto.CreateNoteRequest, ownerID uuid.UUID) (*dto.NoteResponse, error)
 	GetNote(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*dto.NoteResponse, error)
-	GetUserNotes(ctx context.Context, userID uuid.UUID) ([]dto.NoteResponse, error)
-	GetFolderNotes(ctx context.Context, folderID uuid.UUID, userID uuid.UUID) ([]dto.NoteResponse, error)
-	UpdateNote(ctx context.Context, id uuid.UUID, req *dto.UpdateNoteRequest, userID uuid.UUID) (*dto.NoteResponse, error)
-	DeleteNote(ctx context.Context, id uuid.UUID, userID uuid.UUID) error
+	GetUserNotes(ctx context.Context, req *dto.ListRequest, userID uuid.UUID) (*dto.NotePage, error)
+	GetFolderNotes(ctx context.Context, folderID uuid.UUID, userID uuid.UUID, tags string) ([]dto.NoteResponse, error)
+	GetFoldersNotes(ctx context.Context, folderIDs []uuid.UUID, userID uuid.UUID) (map[uuid.UUID][]dto.NoteResponse, error)
+	UpdateNote(ctx context.Context, id uuid.UUID, req *dto.UpdateNoteRequest, userID uuid.UUID, expectedVersion int64) (*dto.NoteResponse, error)
+	DeleteNote(ctx context.Context, id uuid.UUID, userID uuid.UUID, expectedVersion int64) error
+	MoveNotes(ctx context.Context, req *dto.MoveNotesRequest, userID uuid.UUID) ([]dto.NoteResponse, error)
+	CopyNotes(ctx context.Context, req *dto.CopyNotesRequest, userID uuid.UUID) ([]dto.NoteResponse, error)
 	ShareNote(ctx context.Context, noteID uuid.UUID, req *dto.ShareRequest, sharedByID uuid.UUID) error
+	GetNoteShares(ctx context.Context, noteID uuid.UUID, userID uuid.UUID) ([]dto.ShareResponse, error)
+	GetNotesShares(ctx context.Context, noteIDs []uuid.UUID, userID uuid.UUID) (map[uuid.UUID][]dto.ShareResponse, error)
+	UpdateNoteShare(ctx context.Context, noteID uuid.UUID, userID uuid.UUID, req *dto.UpdateShareRequest, updatedByID uuid.UUID) error
+	RevokeNoteShare(ctx context.Context, noteID uuid.UUID, userID uuid.UUID, revokedByID uuid.UUID) error
+	ShareNoteWithTeam(ctx context.Context, noteID uuid.UUID, req *dto.TeamShareRequest, sharedByID uuid.UUID) error
+	GetNoteTeamShares(ctx context.Context, noteID uuid.UUID, userID uuid.UUID) ([]dto.TeamShareResponse, error)
+	GetNotesTeamShares(ctx context.Context, noteIDs []uuid.UUID, userID uuid.UUID) (map[uuid.UUID][]dto.TeamShareResponse, error)
+	RevokeNoteTeamShare(ctx context.Context, noteID uuid.UUID, teamID uuid.UUID, userID uuid.UUID) error
+	ListNoteRevisions(ctx context.Context, noteID uuid.UUID, userID uuid.UUID) ([]dto.NoteRevisionSummary, error)
+	GetNoteRevision(ctx context.Context, noteID uuid.UUID, revision int, userID uuid.UUID) (*dto.NoteRevisionResponse, error)
+	DiffNoteRevisions(ctx context.Context, noteID uuid.UUID, from, to int, userID uuid.UUID) (*dto.NoteRevisionDiff, error)
+	RestoreNoteRevision(ctx context.Context, noteID uuid.UUID, revision int, userID uuid.UUID) (*dto.NoteResponse, error)
+	SearchNotes(ctx context.Context, req *dto.NoteSearchRequest, userID uuid.UUID) ([]dto.NoteSearchResult, error)
+	RenderNoteHTML(noteID uuid.UUID, version int64, body string) string
 }
 
 type noteService struct {
-	noteRepo   repository.NoteRepository
-	folderRepo repository.FolderRepository
+	noteRepo      repository.NoteRepository
+	folderRepo    repository.FolderRepository
+	userRepo      repository.UserRepository
+	shareRepo     repository.ShareRepository
+	teamShareRepo repository.TeamShareRepository
+	tagRepo       repository.TagRepository
+	permissions   PermissionResolver
+	renderer      *markdown.Renderer
+	events        *event.Bus
+	// revisionRetention is how many revisions to keep per note; 0 keeps all.
+	revisionRetention int
 }
 
-func NewNoteService(noteRepo repository.NoteRepository, folderRepo repository.FolderRepository) NoteService {
+// maxBulkNotes is how many notes one move or copy can select.
+const maxBulkNotes = 100
+
+func NewNoteService(noteRepo repository.NoteRepository, folderRepo repository.FolderRepository, userRepo repository.UserRepository, shareRepo repository.ShareRepository, teamShareRepo repository.TeamShareRepository, tagRepo repository.TagRepository, permissions PermissionResolver, renderer *markdown.Renderer, events *event.Bus, revisionRetention int) NoteService {
 	return &noteService{
-		noteRepo:   noteRepo,
-		folderRepo: folderRepo,
+		noteRepo:      noteRepo,
+		folderRepo:    folderRepo,
+		userRepo:      userRepo,
+		shareRepo:     shareRepo,
+		teamShareRepo: teamShareRepo,
+		tagRepo:       tagRepo,
+		permissions:   permissions,
+		renderer:      renderer,
+		events:        events,
+
+		revisionRetention: revisionRetention,
 	}
 }
 
 func (s *noteService) CreateNote(ctx context.Context, req *dto.CreateNoteRequest, ownerID uuid.UUID) (*dto.NoteResponse, error) {
-	// Check if user has access to the folder
-	folder, err := s.folderRepo.GetByID(ctx, req.FolderID)
+	// Check if user has write access to the folder
+	access, err := s.permissions.FolderAccess(ctx, req.FolderID, ownerID)
 	if err != nil {
 		return nil, err
 	}
 
-	hasAccess := folder.OwnerID == ownerID
-	if !hasAccess {
-		for _, share := range folder.Shares {
-			if share.UserID == ownerID && share.Access == model.AccessLevelWrite {
-				hasAccess = true
-				break
-			}
-		}
-	}
-
-	if !hasAccess {
-		return nil, errors.New("access denied to folder")
+	if !canWrite(access) {
+		return nil, apperror.ErrAccessDenied
 	}
 
 	note := &model.Note{
@@ -64,6 +99,7 @@ func (s *noteService) CreateNote(ctx context.Context, req *dto.CreateNoteRequest
 	if err := s.noteRepo.Create(ctx, note); err != nil {
 		return nil, err
 	}
+	s.noteChanged(ctx, note, event.KindCreated, ownerID)
 
 	return &dto.NoteResponse{
 		ID:        note.ID,
@@ -71,6 +107,7 @@ func (s *noteService) CreateNote(ctx context.Context, req *dto.CreateNoteRequest
 		Body:      note.Body,
 		FolderID:  note.FolderID,
 		OwnerID:   note.OwnerID,
+		Version:   note.Version,
 		CreatedAt: note.CreatedAt,
 		UpdatedAt: note.UpdatedAt,
 	}, nil
@@ -82,18 +119,12 @@ func (s *noteService) GetNote(ctx context.Context, id uuid.UUID, userID uuid.UUI
 		return nil, err
 	}
 
-	// Check access
-	if note.OwnerID != userID {
-		hasAccess := false
-		for _, share := range note.Shares {
-			if share.UserID == userID {
-				hasAccess = true
-				break
-			}
-		}
-		if !hasAccess {
-			return nil, errors.New("access denied")
-		}
+	access, err := s.permissions.NoteAccess(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !canRead(access) {
+		return nil, apperror.ErrAccessDenied
 	}
 
 	return &dto.NoteResponse{
@@ -102,58 +133,74 @@ func (s *noteService) GetNote(ctx context.Context, id uuid.UUID, userID uuid.UUI
 		Body:      note.Body,
 		FolderID:  note.FolderID,
 		OwnerID:   note.OwnerID,
+		Version:   note.Version,
 		CreatedAt: note.CreatedAt,
 		UpdatedAt: note.UpdatedAt,
 	}, nil
 }
 
-func (s *noteService) GetUserNotes(ctx context.Context, userID uuid.UUID) ([]dto.NoteResponse, error) {
-	ownedNotes, err := s.noteRepo.GetByOwnerID(ctx, userID)
+// GetUserNotes lists one page of the notes the user owns or has been shared,
+// or of the notes in req.FolderID, which takes read access.
+func (s *noteService) GetUserNotes(ctx context.Context, req *dto.ListRequest, userID uuid.UUID) (*dto.NotePage, error) {
+	query, err := listQuery(req, userID)
 	if err != nil {
 		return nil, err
 	}
+	if query.Tags, err = parseTagFilter(req.Tags); err != nil {
+		return nil, err
+	}
 
-	sharedNotes, err := s.noteRepo.GetSharedWithUser(ctx, userID)
+	if req.FolderID != nil {
+		access, err := s.permissions.FolderAccess(ctx, *req.FolderID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !canRead(access) {
+			return nil, apperror.ErrAccessDenied
+		}
+	}
+
+	notes, total, err := s.noteRepo.List(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 
-	allNotes := append(ownedNotes, sharedNotes...)
-	response := make([]dto.NoteResponse, len(allNotes))
-	for i, note := range allNotes {
-		response[i] = dto.NoteResponse{
+	size, more := pageSize(query, len(notes))
+	page := &dto.NotePage{Items: make([]dto.NoteResponse, size), Total: total}
+	for i, note := range notes[:size] {
+		page.Items[i] = dto.NoteResponse{
 			ID:        note.ID,
 			Title:     note.Title,
 			Body:      note.Body,
 			FolderID:  note.FolderID,
 			OwnerID:   note.OwnerID,
+			Version:   note.Version,
 			CreatedAt: note.CreatedAt,
 			UpdatedAt: note.UpdatedAt,
 		}
 	}
+	if more {
+		last := notes[size-1]
+		page.NextCursor = encodeCursor(query, listPosition(query, last.ID, last.Title, last.CreatedAt, last.UpdatedAt))
+	}
 
-	return response, nil
+	return page, nil
 }
 
-func (s *noteService) GetFolderNotes(ctx context.Context, folderID uuid.UUID, userID uuid.UUID) ([]dto.NoteResponse, error) {
-	// Check folder access first
-	folder, err := s.folderRepo.GetByID(ctx, folderID)
+func (s *noteService) GetFolderNotes(ctx context.Context, folderID uuid.UUID, userID uuid.UUID, tags string) ([]dto.NoteResponse, error) {
+	tagFilter, err := parseTagFilter(tags)
 	if err != nil {
 		return nil, err
 	}
 
-	hasAccess := folder.OwnerID == userID
-	if !hasAccess {
-		for _, share := range folder.Shares {
-			if share.UserID == userID {
-				hasAccess = true
-				break
-			}
-		}
+	// Check folder access first
+	access, err := s.permissions.FolderAccess(ctx, folderID, userID)
+	if err != nil {
+		return nil, err
 	}
 
-	if !hasAccess {
-		return nil, errors.New("access denied to folder")
+	if !canRead(access) {
+		return nil, apperror.ErrAccessDenied
 	}
 
 	notes, err := s.noteRepo.GetByFolderID(ctx, folderID)
@@ -161,6 +208,17 @@ func (s *noteService) GetFolderNotes(ctx context.Context, folderID uuid.UUID, us
 		return nil, err
 	}
 
+	// Per-note overrides can hide notes of a readable folder
+	notes, err = s.readableNotes(ctx, notes, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	notes, err = s.filterByTags(ctx, notes, tagFilter, userID)
+	if err != nil {
+		return nil, err
+	}
+
 	response := make([]dto.NoteResponse, len(notes))
 	for i, note := range notes {
 		response[i] = dto.NoteResponse{
@@ -169,6 +227,7 @@ func (s *noteService) GetFolderNotes(ctx context.Context, folderID uuid.UUID, us
 			Body:      note.Body,
 			FolderID:  note.FolderID,
 			OwnerID:   note.OwnerID,
+			Version:   note.Version,
 			CreatedAt: note.CreatedAt,
 			UpdatedAt: note.UpdatedAt,
 		}
@@ -177,33 +236,72 @@ func (s *noteService) GetFolderNotes(ctx context.Context, folderID uuid.UUID, us
 	return response, nil
 }
 
-func (s *noteService) UpdateNote(ctx context.Context, id uuid.UUID, req *dto.UpdateNoteRequest, userID uuid.UUID) (*dto.NoteResponse, error) {
+// GetFoldersNotes is GetFolderNotes for several folders at once, without a
+// tag filter. The notes are keyed by folder, and folders the user cannot
+// read are left out.
+func (s *noteService) GetFoldersNotes(ctx context.Context, folderIDs []uuid.UUID, userID uuid.UUID) (map[uuid.UUID][]dto.NoteResponse, error) {
+	access, err := s.permissions.FoldersAccess(ctx, folderIDs, userID)
+	if err != nil {
+		return nil, err
+	}
+	readable := make([]uuid.UUID, 0, len(access))
+	for id, level := range access {
+		if canRead(level) {
+			readable = append(readable, id)
+		}
+	}
+
+	notes, err := s.noteRepo.GetByFolderIDs(ctx, readable)
+	if err != nil {
+		return nil, err
+	}
+
+	// Per-note overrides can hide notes of a readable folder
+	notes, err = s.readableNotes(ctx, notes, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make(map[uuid.UUID][]dto.NoteResponse, len(readable))
+	for _, note := range notes {
+		response[note.FolderID] = append(response[note.FolderID], dto.NoteResponse{
+			ID:        note.ID,
+			Title:     note.Title,
+			Body:      note.Body,
+			FolderID:  note.FolderID,
+			OwnerID:   note.OwnerID,
+			Version:   note.Version,
+			CreatedAt: note.CreatedAt,
+			UpdatedAt: note.UpdatedAt,
+		})
+	}
+
+	return response, nil
+}
+
+func (s *noteService) UpdateNote(ctx context.Context, id uuid.UUID, req *dto.UpdateNoteRequest, userID uuid.UUID, expectedVersion int64) (*dto.NoteResponse, error) {
 	note, err := s.noteRepo.GetByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
 	// Check write access
-	hasWriteAccess := note.OwnerID == userID
-	if !hasWriteAccess {
-		for _, share := range note.Shares {
-			if share.UserID == userID && share.Access == model.AccessLevelWrite {
-				hasWriteAccess = true
-				break
-			}
-		}
+	access, err := s.permissions.NoteAccess(ctx, id, userID)
+	if err != nil {
+		return nil, err
 	}
 
-	if !hasWriteAccess {
-		return nil, errors.New("write access denied")
+	if !canWrite(access) {
+		return nil, apperror.ErrAccessDenied
 	}
 
 	note.Title = req.Title
 	note.Body = req.Body
 
-	if err := s.noteRepo.Update(ctx, note); err != nil {
+	if err := s.saveRevision(ctx, note, userID, expectedVersion); err != nil {
 		return nil, err
 	}
+	s.noteChanged(ctx, note, event.KindUpdated, userID)
 
 	return &dto.NoteResponse{
 		ID:        note.ID,
@@ -211,22 +309,147 @@ func (s *noteService) UpdateNote(ctx context.Context, id uuid.UUID, req *dto.Upd
 		Body:      note.Body,
 		FolderID:  note.FolderID,
 		OwnerID:   note.OwnerID,
+		Version:   note.Version,
 		CreatedAt: note.CreatedAt,
 		UpdatedAt: note.UpdatedAt,
 	}, nil
 }
 
-func (s *noteService) DeleteNote(ctx context.Context, id uuid.UUID, userID uuid.UUID) error {
+// DeleteNote soft deletes a note, which needs manage access.
+func (s *noteService) DeleteNote(ctx context.Context, id uuid.UUID, userID uuid.UUID, expectedVersion int64) error {
 	note, err := s.noteRepo.GetByID(ctx, id)
 	if err != nil {
 		return err
 	}
 
-	if note.OwnerID != userID {
-		return errors.New("access denied")
+	access, err := s.permissions.NoteAccess(ctx, id, userID)
+	if err != nil {
+		return err
+	}
+	if !canManage(access) {
+		return apperror.ErrAccessDenied
+	}
+
+	if err := s.noteRepo.Delete(ctx, id, expectedVersion); err != nil {
+		return err
+	}
+	s.renderer.Invalidate(id.String())
+	s.noteChanged(ctx, note, event.KindDeleted, userID)
+	return nil
+}
+
+// MoveNotes moves the selected notes into another folder, possibly one with
+// a different owner; the notes keep their owner. The user needs manage access
+// to every note, since the owner of the destination gains manage over it, and
+// write access to the folders they are in and to the destination. Either all
+// of the notes move or none do.
+func (s *noteService) MoveNotes(ctx context.Context, req *dto.MoveNotesRequest, userID uuid.UUID) ([]dto.NoteResponse, error) {
+	notes, err := s.selectNotes(ctx, req.NoteIDs, req.FolderID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uuid.UUID, len(notes))
+	sources := make(map[uuid.UUID]bool)
+	previous := make(map[uuid.UUID]uuid.UUID, len(notes))
+	for i, note := range notes {
+		if req.DropShares && note.OwnerID != userID {
+			return nil, apperror.ErrAccessDenied
+		}
+		ids[i] = note.ID
+		sources[note.FolderID] = true
+		previous[note.ID] = note.FolderID
+	}
+
+	access, err := s.permissions.NotesAccess(ctx, ids, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if !canManage(access[id]) {
+			return nil, apperror.ErrAccessDenied
+		}
+	}
+	for folderID := range sources {
+		access, err := s.permissions.FolderAccess(ctx, folderID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !canWrite(access) {
+			return nil, apperror.ErrAccessDenied
+		}
+	}
+
+	if err := s.noteRepo.Move(ctx, ids, req.FolderID, req.DropShares); err != nil {
+		return nil, err
+	}
+
+	moved, err := s.noteRepo.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	response := make([]dto.NoteResponse, len(moved))
+	for i, note := range moved {
+		s.events.NoteChanged(ctx, event.NoteEvent{
+			NoteID:   note.ID,
+			FolderID: note.FolderID,
+			Kind:     event.KindMoved,
+			Version:  note.Version,
+			ActorID:  userID,
+		}, previous[note.ID])
+		response[i] = dto.NoteResponse{
+			ID:        note.ID,
+			Title:     note.Title,
+			Body:      note.Body,
+			FolderID:  note.FolderID,
+			OwnerID:   note.OwnerID,
+			Version:   note.Version,
+			CreatedAt: note.CreatedAt,
+			UpdatedAt: note.UpdatedAt,
+		}
+	}
+	return response, nil
+}
+
+// CopyNotes copies the selected notes into a folder. The user needs read
+// access to every note and write access to the destination. The copies are
+// owned by the user and bring the attachments along, but not the revision
+// history, tags or shares.
+func (s *noteService) CopyNotes(ctx context.Context, req *dto.CopyNotesRequest, userID uuid.UUID) ([]dto.NoteResponse, error) {
+	notes, err := s.selectNotes(ctx, req.NoteIDs, req.FolderID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := len(notes)
+	notes, err = s.readableNotes(ctx, notes, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(notes) != selected {
+		return nil, apperror.ErrAccessDenied
 	}
 
-	return s.noteRepo.Delete(ctx, id)
+	copies, err := s.noteRepo.Copy(ctx, notes, req.FolderID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]dto.NoteResponse, len(copies))
+	for i, note := range copies {
+		s.noteChanged(ctx, &copies[i], event.KindCreated, userID)
+		response[i] = dto.NoteResponse{
+			ID:        note.ID,
+			Title:     note.Title,
+			Body:      note.Body,
+			FolderID:  note.FolderID,
+			OwnerID:   note.OwnerID,
+			Version:   note.Version,
+			CreatedAt: note.CreatedAt,
+			UpdatedAt: note.UpdatedAt,
+		}
+	}
+	return response, nil
 }
 
 func (s *noteService) ShareNote(ctx context.Context, noteID uuid.UUID, req *dto.ShareRequest, sharedByID uuid.UUID) error {
@@ -235,11 +458,680 @@ func (s *noteService) ShareNote(ctx context.Context, noteID uuid.UUID, req *dto.
 		return err
 	}
 
-	if note.OwnerID != sharedByID {
-		return errors.New("access denied")
+	manages, err := s.authorizeNoteShare(ctx, note, sharedByID)
+	if err != nil {
+		return err
+	}
+	if err := validateNoteShareAccess(req.Access, req.Override); err != nil {
+		return err
+	}
+	if err := validateShareExpiry(req.ExpiresAt); err != nil {
+		return err
+	}
+	if req.UserID == sharedByID {
+		return apperror.ErrSelfShare
+	}
+	if req.UserID == note.OwnerID {
+		return apperror.ErrShareWithOwner
+	}
+	if _, err := s.userRepo.FindByID(ctx, req.UserID.String()); err != nil {
+		return err
+	}
+
+	// Re-sharers can add new shares but not take over ones granted by
+	// someone else, and cannot pass the re-share right on, grant manage or
+	// set overrides.
+	if !manages {
+		if req.AllowReshare || req.Override || req.Access == model.AccessLevelManage {
+			return apperror.ErrAccessDenied
+		}
+		existing, err := s.shareRepo.GetNoteShare(ctx, noteID, req.UserID)
+		if err != nil && !errors.Is(err, apperror.ErrShareNotFound) {
+			return err
+		}
+		if existing != nil && existing.SharedByID != sharedByID {
+			return apperror.ErrAccessDenied
+		}
+	}
+
+	return s.shareRepo.ShareNote(ctx, &model.NoteShare{
+		NoteID:       noteID,
+		UserID:       req.UserID,
+		Access:       req.Access,
+		AllowReshare: req.AllowReshare,
+		Override:     req.Override,
+		ExpiresAt:    req.ExpiresAt,
+		SharedAt:     time.Now(),
+		SharedByID:   sharedByID,
+	})
+}
+
+func (s *noteService) GetNoteShares(ctx context.Context, noteID uuid.UUID, userID uuid.UUID) ([]dto.ShareResponse, error) {
+	note, err := s.noteRepo.GetByID(ctx, noteID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.authorizeNoteShare(ctx, note, userID); err != nil {
+		return nil, err
+	}
+
+	shares, err := s.shareRepo.GetNoteShares(ctx, noteID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]dto.ShareResponse, len(shares))
+	for i, share := range shares {
+		response[i] = dto.ShareResponse{
+			UserID:       share.UserID,
+			Username:     share.User.Username,
+			Access:       share.Access,
+			AllowReshare: share.AllowReshare,
+			Override:     share.Override,
+			ExpiresAt:    share.ExpiresAt,
+			SharedByID:   share.SharedByID,
+			SharedAt:     share.SharedAt,
+		}
 	}
 
-	// Implementation for sharing logic would go here
-	// You'd need a NoteShareRepository for this
+	return response, nil
+}
+
+// GetNotesShares is GetNoteShares for several notes at once. Only the notes
+// whose shares the user may see are in the result, with an empty list when
+// they have none.
+func (s *noteService) GetNotesShares(ctx context.Context, noteIDs []uuid.UUID, userID uuid.UUID) (map[uuid.UUID][]dto.ShareResponse, error) {
+	allowed, err := s.shareableNotes(ctx, noteIDs, userID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	shares, err := s.shareRepo.GetNotesShares(ctx, allowed)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make(map[uuid.UUID][]dto.ShareResponse, len(allowed))
+	for _, id := range allowed {
+		response[id] = []dto.ShareResponse{}
+	}
+	for _, share := range shares {
+		response[share.NoteID] = append(response[share.NoteID], dto.ShareResponse{
+			UserID:       share.UserID,
+			Username:     share.User.Username,
+			Access:       share.Access,
+			AllowReshare: share.AllowReshare,
+			Override:     share.Override,
+			ExpiresAt:    share.ExpiresAt,
+			SharedByID:   share.SharedByID,
+			SharedAt:     share.SharedAt,
+		})
+	}
+
+	return response, nil
+}
+
+func (s *noteService) UpdateNoteShare(ctx context.Context, noteID uuid.UUID, userID uuid.UUID, req *dto.UpdateShareRequest, updatedByID uuid.UUID) error {
+	note, err := s.noteRepo.GetByID(ctx, noteID)
+	if err != nil {
+		return err
+	}
+
+	manages, err := s.authorizeNoteShare(ctx, note, updatedByID)
+	if err != nil {
+		return err
+	}
+
+	share, err := s.shareRepo.GetNoteShare(ctx, noteID, userID)
+	if err != nil {
+		return err
+	}
+	if !manages && (share.SharedByID != updatedByID || req.AllowReshare != nil || req.Override != nil || req.Access == model.AccessLevelManage) {
+		return apperror.ErrAccessDenied
+	}
+
+	override := share.Override
+	if req.Override != nil {
+		override = *req.Override
+	}
+	if err := validateNoteShareAccess(req.Access, override); err != nil {
+		return err
+	}
+	if err := validateShareExpiry(req.ExpiresAt); err != nil {
+		return err
+	}
+
+	share.Access = req.Access
+	share.Override = override
+	if req.AllowReshare != nil {
+		share.AllowReshare = *req.AllowReshare
+	}
+	// A changed expiry gets its own warning
+	if req.RemoveExpiry {
+		share.ExpiresAt, share.ExpiryWarnedAt = nil, nil
+	} else if req.ExpiresAt != nil {
+		share.ExpiresAt, share.ExpiryWarnedAt = req.ExpiresAt, nil
+	}
+	return s.shareRepo.ShareNote(ctx, share)
+}
+
+// RevokeNoteShare removes a user's share. The owner and managers can revoke
+// any share, a re-sharer only the ones they granted, and any user can drop
+// their own.
+func (s *noteService) RevokeNoteShare(ctx context.Context, noteID uuid.UUID, userID uuid.UUID, revokedByID uuid.UUID) error {
+	note, err := s.noteRepo.GetByID(ctx, noteID)
+	if err != nil {
+		return err
+	}
+
+	if userID != revokedByID {
+		manages, err := s.authorizeNoteShare(ctx, note, revokedByID)
+		if err != nil {
+			return err
+		}
+		if !manages {
+			share, err := s.shareRepo.GetNoteShare(ctx, noteID, userID)
+			if err != nil {
+				return err
+			}
+			if share.SharedByID != revokedByID {
+				return apperror.ErrAccessDenied
+			}
+		}
+	}
+
+	return s.shareRepo.RevokeNoteShare(ctx, noteID, userID)
+}
+
+func (s *noteService) ShareNoteWithTeam(ctx context.Context, noteID uuid.UUID, req *dto.TeamShareRequest, sharedByID uuid.UUID) error {
+	note, err := s.noteRepo.GetByID(ctx, noteID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.requireNoteManage(ctx, note, sharedByID); err != nil {
+		return err
+	}
+
+	if err := validateAccess(req.Access); err != nil {
+		return err
+	}
+
+	return s.teamShareRepo.ShareNote(ctx, &model.NoteTeamShare{
+		NoteID:       noteID,
+		TeamID:       req.TeamID,
+		Access:       req.Access,
+		ManagersOnly: req.ManagersOnly,
+		SharedAt:     time.Now(),
+		SharedByID:   sharedByID,
+	})
+}
+
+func (s *noteService) GetNoteTeamShares(ctx context.Context, noteID uuid.UUID, userID uuid.UUID) ([]dto.TeamShareResponse, error) {
+	note, err := s.noteRepo.GetByID(ctx, noteID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.requireNoteManage(ctx, note, userID); err != nil {
+		return nil, err
+	}
+
+	shares, err := s.teamShareRepo.GetNoteShares(ctx, noteID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]dto.TeamShareResponse, len(shares))
+	for i, share := range shares {
+		response[i] = dto.TeamShareResponse{
+			TeamID:       share.TeamID,
+			TeamName:     share.Team.TeamName,
+			Access:       share.Access,
+			ManagersOnly: share.ManagersOnly,
+			SharedByID:   share.SharedByID,
+			SharedAt:     share.SharedAt,
+		}
+	}
+
+	return response, nil
+}
+
+// GetNotesTeamShares is GetNoteTeamShares for several notes at once. Only the
+// notes the user manages are in the result, with an empty list when they
+// have none.
+func (s *noteService) GetNotesTeamShares(ctx context.Context, noteIDs []uuid.UUID, userID uuid.UUID) (map[uuid.UUID][]dto.TeamShareResponse, error) {
+	allowed, err := s.shareableNotes(ctx, noteIDs, userID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	shares, err := s.teamShareRepo.GetNotesShares(ctx, allowed)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make(map[uuid.UUID][]dto.TeamShareResponse, len(allowed))
+	for _, id := range allowed {
+		response[id] = []dto.TeamShareResponse{}
+	}
+	for _, share := range shares {
+		response[share.NoteID] = append(response[share.NoteID], dto.TeamShareResponse{
+			TeamID:       share.TeamID,
+			TeamName:     share.Team.TeamName,
+			Access:       share.Access,
+			ManagersOnly: share.ManagersOnly,
+			SharedByID:   share.SharedByID,
+			SharedAt:     share.SharedAt,
+		})
+	}
+
+	return response, nil
+}
+
+func (s *noteService) RevokeNoteTeamShare(ctx context.Context, noteID uuid.UUID, teamID uuid.UUID, userID uuid.UUID) error {
+	note, err := s.noteRepo.GetByID(ctx, noteID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.requireNoteManage(ctx, note, userID); err != nil {
+		return err
+	}
+
+	return s.teamShareRepo.RevokeNoteShare(ctx, noteID, teamID)
+}
+
+func (s *noteService) ListNoteRevisions(ctx context.Context, noteID uuid.UUID, userID uuid.UUID) ([]dto.NoteRevisionSummary, error) {
+	if err := s.requireNoteRead(ctx, noteID, userID); err != nil {
+		return nil, err
+	}
+
+	revisions, err := s.noteRepo.GetRevisions(ctx, noteID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]dto.NoteRevisionSummary, len(revisions))
+	for i := range revisions {
+		response[i] = toRevisionSummary(&revisions[i])
+	}
+
+	return response, nil
+}
+
+func (s *noteService) GetNoteRevision(ctx context.Context, noteID uuid.UUID, revision int, userID uuid.UUID) (*dto.NoteRevisionResponse, error) {
+	if err := s.requireNoteRead(ctx, noteID, userID); err != nil {
+		return nil, err
+	}
+
+	rev, err := s.noteRepo.GetRevision(ctx, noteID, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.NoteRevisionResponse{
+		NoteRevisionSummary: toRevisionSummary(rev),
+		Body:                rev.Body,
+	}, nil
+}
+
+// DiffNoteRevisions returns the line diff of the body going from revision
+// from to revision to. Either may be the older one.
+func (s *noteService) DiffNoteRevisions(ctx context.Context, noteID uuid.UUID, from, to int, userID uuid.UUID) (*dto.NoteRevisionDiff, error) {
+	if err := s.requireNoteRead(ctx, noteID, userID); err != nil {
+		return nil, err
+	}
+
+	fromRev, err := s.noteRepo.GetRevision(ctx, noteID, from)
+	if err != nil {
+		return nil, err
+	}
+	toRev, err := s.noteRepo.GetRevision(ctx, noteID, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.NoteRevisionDiff{
+		From:      from,
+		To:        to,
+		FromTitle: fromRev.Title,
+		ToTitle:   toRev.Title,
+		Lines:     textdiff.Lines(fromRev.Body, toRev.Body),
+	}, nil
+}
+
+// RestoreNoteRevision puts an old revision's content back on the note. The
+// restore is itself recorded as a new revision, so nothing is lost.
+func (s *noteService) RestoreNoteRevision(ctx context.Context, noteID uuid.UUID, revision int, userID uuid.UUID) (*dto.NoteResponse, error) {
+	note, err := s.noteRepo.GetByID(ctx, noteID)
+	if err != nil {
+		return nil, err
+	}
+
+	access, err := s.permissions.NoteAccess(ctx, noteID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !canWrite(access) {
+		return nil, apperror.ErrAccessDenied
+	}
+
+	rev, err := s.noteRepo.GetRevision(ctx, noteID, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	note.Title = rev.Title
+	note.Body = rev.Body
+
+	if err := s.saveRevision(ctx, note, userID, 0); err != nil {
+		return nil, err
+	}
+	s.noteChanged(ctx, note, event.KindUpdated, userID)
+
+	return &dto.NoteResponse{
+		ID:        note.ID,
+		Title:     note.Title,
+		Body:      note.Body,
+		FolderID:  note.FolderID,
+		OwnerID:   note.OwnerID,
+		Version:   note.Version,
+		CreatedAt: note.CreatedAt,
+		UpdatedAt: note.UpdatedAt,
+	}, nil
+}
+
+// RenderNoteHTML renders a note body to sanitized HTML. The output is cached
+// per note and version, so callers must pass the version the body belongs to.
+func (s *noteService) RenderNoteHTML(noteID uuid.UUID, version int64, body string) string {
+	return s.renderer.RenderCached(noteID.String(), version, body)
+}
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// SearchNotes runs a ranked full-text search over the notes the user can
+// read. Searching within a folder needs read access to it.
+func (s *noteService) SearchNotes(ctx context.Context, req *dto.NoteSearchRequest, userID uuid.UUID) ([]dto.NoteSearchResult, error) {
+	query := strings.TrimSpace(req.Query)
+	if query == "" {
+		return nil, apperror.ErrEmptySearch
+	}
+
+	tagFilter, err := parseTagFilter(req.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.FolderID != nil {
+		access, err := s.permissions.FolderAccess(ctx, *req.FolderID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !canRead(access) {
+			return nil, apperror.ErrAccessDenied
+		}
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+	offset := req.Offset
+	if offset < 0 {
+		offset = 0
+	}
+
+	hits, err := s.noteRepo.Search(ctx, repository.NoteSearchQuery{
+		Query:       query,
+		Tags:        tagFilter,
+		UserID:      userID,
+		FolderID:    req.FolderID,
+		OwnerID:     req.OwnerID,
+		UpdatedFrom: req.UpdatedFrom,
+		UpdatedTo:   req.UpdatedTo,
+		Limit:       limit,
+		Offset:      offset,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The search only narrows by grants; overrides can still deny a hit
+	ids := make([]uuid.UUID, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.ID
+	}
+	access, err := s.permissions.NotesAccess(ctx, ids, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]dto.NoteSearchResult, 0, len(hits))
+	for _, hit := range hits {
+		if !canRead(access[hit.ID]) {
+			continue
+		}
+		response = append(response, dto.NoteSearchResult{
+			ID:        hit.ID,
+			Title:     hit.Title,
+			FolderID:  hit.FolderID,
+			OwnerID:   hit.OwnerID,
+			Snippet:   hit.Snippet,
+			Rank:      hit.Rank,
+			CreatedAt: hit.CreatedAt,
+			UpdatedAt: hit.UpdatedAt,
+		})
+	}
+
+	return response, nil
+}
+
+// saveRevision updates the note, recording a new revision, and prunes
+// revisions beyond the retention limit. A non-zero expectedVersion makes the
+// update conditional on the note's version.
+func (s *noteService) saveRevision(ctx context.Context, note *model.Note, authorID uuid.UUID, expectedVersion int64) error {
+	if err := s.noteRepo.Update(ctx, note, authorID, expectedVersion); err != nil {
+		return err
+	}
+	s.renderer.Invalidate(note.ID.String())
+	if s.revisionRetention > 0 {
+		return s.noteRepo.PruneRevisions(ctx, note.ID, s.revisionRetention)
+	}
+	return nil
+}
+
+// selectNotes loads the notes picked for a bulk move or copy, after checking
+// the selection's size and that the user can write to the destination
+// folder.
+func (s *noteService) selectNotes(ctx context.Context, noteIDs []uuid.UUID, folderID uuid.UUID, userID uuid.UUID) ([]model.Note, error) {
+	seen := make(map[uuid.UUID]bool, len(noteIDs))
+	ids := make([]uuid.UUID, 0, len(noteIDs))
+	for _, id := range noteIDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 || len(ids) > maxBulkNotes {
+		return nil, apperror.ErrInvalidNoteSelection
+	}
+
+	access, err := s.permissions.FolderAccess(ctx, folderID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !canWrite(access) {
+		return nil, apperror.ErrAccessDenied
+	}
+
+	notes, err := s.noteRepo.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	if len(notes) != len(ids) {
+		return nil, apperror.ErrNoteNotFound
+	}
+	return notes, nil
+}
+
+// requireNoteRead checks that the note exists and the user can read it.
+func (s *noteService) requireNoteRead(ctx context.Context, noteID uuid.UUID, userID uuid.UUID) error {
+	if _, err := s.noteRepo.GetByID(ctx, noteID); err != nil {
+		return err
+	}
+	access, err := s.permissions.NoteAccess(ctx, noteID, userID)
+	if err != nil {
+		return err
+	}
+	if !canRead(access) {
+		return apperror.ErrAccessDenied
+	}
+	return nil
+}
+
+func (s *noteService) noteChanged(ctx context.Context, note *model.Note, kind event.Kind, actorID uuid.UUID) {
+	s.events.NoteChanged(ctx, event.NoteEvent{
+		NoteID:   note.ID,
+		FolderID: note.FolderID,
+		Kind:     kind,
+		Version:  note.Version,
+		ActorID:  actorID,
+	})
+}
+
+func toRevisionSummary(rev *model.NoteRevision) dto.NoteRevisionSummary {
+	return dto.NoteRevisionSummary{
+		Revision:   rev.Revision,
+		Title:      rev.Title,
+		AuthorID:   rev.AuthorID,
+		AuthorName: rev.Author.Username,
+		CreatedAt:  rev.CreatedAt,
+	}
+}
+
+// authorizeNoteShare reports whether the user may manage shares on the note:
+// the owner and anyone with manage access always can, and an unexpired
+// write-share holder can when re-sharing was allowed. manages tells the two
+// apart.
+func (s *noteService) authorizeNoteShare(ctx context.Context, note *model.Note, userID uuid.UUID) (manages bool, err error) {
+	if note.OwnerID == userID {
+		return true, nil
+	}
+	access, err := s.permissions.NoteAccess(ctx, note.ID, userID)
+	if err != nil {
+		return false, err
+	}
+	if canManage(access) {
+		return true, nil
+	}
+	share, err := s.shareRepo.GetNoteShare(ctx, note.ID, userID)
+	if errors.Is(err, apperror.ErrShareNotFound) {
+		return false, apperror.ErrAccessDenied
+	}
+	if err != nil {
+		return false, err
+	}
+	if share.Access != model.AccessLevelWrite || !share.AllowReshare || shareExpired(share.ExpiresAt) {
+		return false, apperror.ErrAccessDenied
+	}
+	return false, nil
+}
+
+// shareableNotes is authorizeNoteShare for several notes at once: it returns
+// those among noteIDs whose shares the user may manage, or with manageOnly,
+// only those the user manages.
+func (s *noteService) shareableNotes(ctx context.Context, noteIDs []uuid.UUID, userID uuid.UUID, manageOnly bool) ([]uuid.UUID, error) {
+	access, err := s.permissions.NotesAccess(ctx, noteIDs, userID)
+	if err != nil {
+		return nil, err
+	}
+	allowed := make([]uuid.UUID, 0, len(access))
+	for id, level := range access {
+		if canManage(level) {
+			allowed = append(allowed, id)
+			continue
+		}
+		// Only a write-share holder can have been allowed to re-share
+		if manageOnly || level != model.AccessLevelWrite {
+			continue
+		}
+		share, err := s.shareRepo.GetNoteShare(ctx, id, userID)
+		if errors.Is(err, apperror.ErrShareNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if share.Access == model.AccessLevelWrite && share.AllowReshare && !shareExpired(share.ExpiresAt) {
+			allowed = append(allowed, id)
+		}
+	}
+	return allowed, nil
+}
+
+// requireNoteManage allows only the owner and manage holders, who alone can
+// manage team shares.
+func (s *noteService) requireNoteManage(ctx context.Context, note *model.Note, userID uuid.UUID) error {
+	manages, err := s.authorizeNoteShare(ctx, note, userID)
+	if err != nil {
+		return err
+	}
+	if !manages {
+		return apperror.ErrAccessDenied
+	}
 	return nil
 }
+
+// filterByTags keeps the notes matching the tag expression; a nil expression
+// keeps them all.
+func (s *noteService) filterByTags(ctx context.Context, notes []model.Note, expr tagexpr.Expr, userID uuid.UUID) ([]model.Note, error) {
+	if expr == nil {
+		return notes, nil
+	}
+	ids := make([]uuid.UUID, len(notes))
+	for i, note := range notes {
+		ids[i] = note.ID
+	}
+	matched, err := s.tagRepo.MatchNotes(ctx, ids, expr, userID)
+	if err != nil {
+		return nil, err
+	}
+	keep := make(map[uuid.UUID]bool, len(matched))
+	for _, id := range matched {
+		keep[id] = true
+	}
+	filtered := notes[:0]
+	for _, note := range notes {
+		if keep[note.ID] {
+			filtered = append(filtered, note)
+		}
+	}
+	return filtered, nil
+}
+
+// readableNotes filters notes down to the ones the user can read.
+func (s *noteService) readableNotes(ctx context.Context, notes []model.Note, userID uuid.UUID) ([]model.Note, error) {
+	ids := make([]uuid.UUID, len(notes))
+	for i, note := range notes {
+		ids[i] = note.ID
+	}
+	access, err := s.permissions.NotesAccess(ctx, ids, userID)
+	if err != nil {
+		return nil, err
+	}
+	readable := notes[:0]
+	for _, note := range notes {
+		if canRead(access[note.ID]) {
+			readable = append(readable, note)
+		}
+	}
+	return readable, nil
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go-training-system/internal/dto"
+	"go-training-system/internal/graph/apperror"
+	"go-training-system/internal/model"
+	"go-training-system/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// fakeNoteRepository implements only what MoveNotes uses.
+type fakeNoteRepository struct {
+	repository.NoteRepository
+	notes map[uuid.UUID]model.Note
+	moved bool
+}
+
+func (f *fakeNoteRepository) GetByIDs(ctx context.Context, ids []uuid.UUID) ([]model.Note, error) {
+	var notes []model.Note
+	for _, id := range ids {
+		if note, ok := f.notes[id]; ok {
+			notes = append(notes, note)
+		}
+	}
+	return notes, nil
+}
+
+func (f *fakeNoteRepository) Move(ctx context.Context, ids []uuid.UUID, folderID uuid.UUID, dropShares bool) error {
+	f.moved = true
+	for _, id := range ids {
+		note := f.notes[id]
+		note.FolderID = folderID
+		f.notes[id] = note
+	}
+	return nil
+}
+
+func TestMoveNotesAccess(t *testing.T) {
+	user := uuid.New()
+	owner := uuid.New()
+	source := uuid.New()
+	destination := uuid.New()
+
+	tests := []struct {
+		name    string
+		note    repository.AccessGrants
+		wantErr error
+	}{
+		{
+			name: "own note",
+			note: repository.AccessGrants{OwnerID: user},
+		},
+		{
+			name: "manage share",
+			note: repository.AccessGrants{OwnerID: owner, NoteUser: model.AccessLevelManage},
+		},
+		{
+			name:    "write share on the note",
+			note:    repository.AccessGrants{OwnerID: owner, NoteUser: model.AccessLevelWrite},
+			wantErr: apperror.ErrAccessDenied,
+		},
+		{
+			name:    "write share on the folder",
+			note:    repository.AccessGrants{OwnerID: owner, FolderUser: model.AccessLevelWrite},
+			wantErr: apperror.ErrAccessDenied,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			noteID := uuid.New()
+			tt.note.ID = noteID
+			notes := &fakeNoteRepository{notes: map[uuid.UUID]model.Note{
+				noteID: {ID: noteID, FolderID: source, OwnerID: tt.note.OwnerID},
+			}}
+			// The user owns the destination, so a moved note would be theirs
+			// to manage
+			permissions := NewPermissionResolver(&fakePermissionRepository{
+				folders: map[uuid.UUID]repository.AccessGrants{
+					source:      {ID: source, OwnerID: owner, FolderUser: model.AccessLevelWrite},
+					destination: {ID: destination, OwnerID: user},
+				},
+				notes: map[uuid.UUID]repository.AccessGrants{noteID: tt.note},
+			})
+			s := &noteService{noteRepo: notes, permissions: permissions}
+
+			_, err := s.MoveNotes(context.Background(), &dto.MoveNotesRequest{
+				NoteIDs:  []uuid.UUID{noteID},
+				FolderID: destination,
+			}, user)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("MoveNotes() error = %v, want %v", err, tt.wantErr)
+			}
+			if notes.moved != (tt.wantErr == nil) {
+				t.Errorf("note moved = %v, want %v", notes.moved, tt.wantErr == nil)
+			}
+		})
+	}
+}
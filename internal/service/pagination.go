@@ -0,0 +1,133 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"go-training-system/internal/dto"
+	"go-training-system/internal/graph/apperror"
+	"go-training-system/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 100
+)
+
+// listCursor is what a page cursor encodes: the order it was made for, so it
+// is not used with another, and the position of the last row on its page.
+type listCursor struct {
+	Sort       repository.ListSort `json:"s"`
+	Descending bool                `json:"d"`
+	Time       time.Time           `json:"t"`
+	Name       string              `json:"n,omitempty"`
+	ID         uuid.UUID           `json:"id"`
+}
+
+// listQuery checks the options of a list request and turns them into a
+// repository query, leaving out the tag filter. The query asks for one row
+// more than the page holds, so the caller can tell whether another follows.
+func listQuery(req *dto.ListRequest, userID uuid.UUID) (repository.ListQuery, error) {
+	query := repository.ListQuery{
+		UserID:       userID,
+		Scope:        repository.ScopeAll,
+		FolderID:     req.FolderID,
+		UpdatedSince: req.UpdatedSince,
+		Sort:         repository.SortUpdatedAt,
+	}
+
+	switch scope := repository.ListScope(req.Scope); scope {
+	case "", repository.ScopeAll:
+	case repository.ScopeOwned, repository.ScopeShared:
+		query.Scope = scope
+	default:
+		return query, apperror.ErrInvalidListScope
+	}
+
+	switch sort := repository.ListSort(req.Sort); sort {
+	case "":
+	case repository.SortUpdatedAt, repository.SortCreatedAt, repository.SortName:
+		query.Sort = sort
+	default:
+		return query, apperror.ErrInvalidListSort
+	}
+	switch req.Order {
+	case "":
+		query.Descending = query.Sort != repository.SortName
+	case "asc":
+	case "desc":
+		query.Descending = true
+	default:
+		return query, apperror.ErrInvalidListSort
+	}
+
+	query.Limit = req.Limit
+	if query.Limit <= 0 {
+		query.Limit = defaultListLimit
+	}
+	if query.Limit > maxListLimit {
+		query.Limit = maxListLimit
+	}
+	query.Limit++
+
+	if req.Cursor != "" {
+		after, err := decodeCursor(query, req.Cursor)
+		if err != nil {
+			return query, err
+		}
+		query.After = after
+	}
+	return query, nil
+}
+
+// pageSize is how many of the rows a query returned belong on the page, and
+// whether another page follows.
+func pageSize(query repository.ListQuery, rows int) (int, bool) {
+	if rows < query.Limit {
+		return rows, false
+	}
+	return query.Limit - 1, true
+}
+
+// listPosition is the position of a row in the query's order.
+func listPosition(query repository.ListQuery, id uuid.UUID, name string, createdAt, updatedAt time.Time) repository.ListPosition {
+	pos := repository.ListPosition{ID: id}
+	switch query.Sort {
+	case repository.SortName:
+		pos.Name = name
+	case repository.SortCreatedAt:
+		pos.Time = createdAt
+	default:
+		pos.Time = updatedAt
+	}
+	return pos
+}
+
+func encodeCursor(query repository.ListQuery, pos repository.ListPosition) string {
+	payload, _ := json.Marshal(listCursor{
+		Sort:       query.Sort,
+		Descending: query.Descending,
+		Time:       pos.Time,
+		Name:       pos.Name,
+		ID:         pos.ID,
+	})
+	return base64.RawURLEncoding.EncodeToString(payload)
+}
+
+func decodeCursor(query repository.ListQuery, cursor string) (*repository.ListPosition, error) {
+	payload, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, apperror.ErrInvalidCursor
+	}
+	var c listCursor
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return nil, apperror.ErrInvalidCursor
+	}
+	if c.Sort != query.Sort || c.Descending != query.Descending {
+		return nil, apperror.ErrInvalidCursor
+	}
+	return &repository.ListPosition{Time: c.Time, Name: c.Name, ID: c.ID}, nil
+}
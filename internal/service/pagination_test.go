@@ -0,0 +1,217 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go-training-system/internal/dto"
+	"go-training-system/internal/graph/apperror"
+	"go-training-system/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+func TestListQuery(t *testing.T) {
+	user := uuid.New()
+
+	tests := []struct {
+		name           string
+		req            dto.ListRequest
+		wantScope      repository.ListScope
+		wantSort       repository.ListSort
+		wantDescending bool
+		wantLimit      int
+		wantErr        error
+	}{
+		{
+			name:           "defaults",
+			wantScope:      repository.ScopeAll,
+			wantSort:       repository.SortUpdatedAt,
+			wantDescending: true,
+			wantLimit:      defaultListLimit + 1,
+		},
+		{
+			name:      "name sorts ascending by default",
+			req:       dto.ListRequest{Sort: "name", Scope: "owned", Limit: 10},
+			wantScope: repository.ScopeOwned,
+			wantSort:  repository.SortName,
+			wantLimit: 11,
+		},
+		{
+			name:      "explicit order",
+			req:       dto.ListRequest{Sort: "created_at", Order: "asc", Scope: "shared"},
+			wantScope: repository.ScopeShared,
+			wantSort:  repository.SortCreatedAt,
+			wantLimit: defaultListLimit + 1,
+		},
+		{
+			name:           "limit clamped to the maximum",
+			req:            dto.ListRequest{Limit: maxListLimit + 1},
+			wantScope:      repository.ScopeAll,
+			wantSort:       repository.SortUpdatedAt,
+			wantDescending: true,
+			wantLimit:      maxListLimit + 1,
+		},
+		{
+			name:           "negative limit uses the default",
+			req:            dto.ListRequest{Limit: -5},
+			wantScope:      repository.ScopeAll,
+			wantSort:       repository.SortUpdatedAt,
+			wantDescending: true,
+			wantLimit:      defaultListLimit + 1,
+		},
+		{
+			name:    "unknown scope",
+			req:     dto.ListRequest{Scope: "everyone"},
+			wantErr: apperror.ErrInvalidListScope,
+		},
+		{
+			name:    "unknown sort",
+			req:     dto.ListRequest{Sort: "size"},
+			wantErr: apperror.ErrInvalidListSort,
+		},
+		{
+			name:    "unknown order",
+			req:     dto.ListRequest{Order: "random"},
+			wantErr: apperror.ErrInvalidListSort,
+		},
+		{
+			name:    "malformed cursor",
+			req:     dto.ListRequest{Cursor: "not a cursor!"},
+			wantErr: apperror.ErrInvalidCursor,
+		},
+		{
+			name:    "cursor that is not JSON",
+			req:     dto.ListRequest{Cursor: "bm90IGpzb24"},
+			wantErr: apperror.ErrInvalidCursor,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := listQuery(&tt.req, user)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("listQuery() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if query.UserID != user || query.Scope != tt.wantScope || query.Sort != tt.wantSort ||
+				query.Descending != tt.wantDescending || query.Limit != tt.wantLimit || query.After != nil {
+				t.Errorf("listQuery() = %+v, want scope %s, sort %s, descending %v, limit %d",
+					query, tt.wantScope, tt.wantSort, tt.wantDescending, tt.wantLimit)
+			}
+		})
+	}
+}
+
+func TestListCursor(t *testing.T) {
+	id := uuid.New()
+	at := time.Date(2024, 5, 1, 10, 30, 0, 123456789, time.UTC)
+
+	tests := []struct {
+		name    string
+		made    dto.ListRequest
+		used    dto.ListRequest
+		pos     repository.ListPosition
+		wantErr error
+	}{
+		{
+			name: "updated at",
+			pos:  repository.ListPosition{Time: at, ID: id},
+		},
+		{
+			name: "name",
+			made: dto.ListRequest{Sort: "name"},
+			used: dto.ListRequest{Sort: "name"},
+			pos:  repository.ListPosition{Name: "Ünïcode \"title\"", ID: id},
+		},
+		{
+			name: "same order spelled out",
+			made: dto.ListRequest{Sort: "created_at"},
+			used: dto.ListRequest{Sort: "created_at", Order: "desc", Limit: 5},
+			pos:  repository.ListPosition{Time: at, ID: id},
+		},
+		{
+			name:    "other sort",
+			made:    dto.ListRequest{Sort: "created_at"},
+			used:    dto.ListRequest{Sort: "updated_at"},
+			pos:     repository.ListPosition{Time: at, ID: id},
+			wantErr: apperror.ErrInvalidCursor,
+		},
+		{
+			name:    "other direction",
+			made:    dto.ListRequest{Order: "desc"},
+			used:    dto.ListRequest{Order: "asc"},
+			pos:     repository.ListPosition{Time: at, ID: id},
+			wantErr: apperror.ErrInvalidCursor,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			made, err := listQuery(&tt.made, id)
+			if err != nil {
+				t.Fatalf("listQuery() error = %v", err)
+			}
+			tt.used.Cursor = encodeCursor(made, tt.pos)
+
+			used, err := listQuery(&tt.used, id)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("listQuery() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			got := used.After
+			if got == nil || !got.Time.Equal(tt.pos.Time) || got.Name != tt.pos.Name || got.ID != tt.pos.ID {
+				t.Errorf("decoded cursor = %+v, want %+v", got, tt.pos)
+			}
+		})
+	}
+}
+
+func TestPageSize(t *testing.T) {
+	query := repository.ListQuery{Limit: 11}
+
+	tests := []struct {
+		rows     int
+		wantSize int
+		wantMore bool
+	}{
+		{rows: 0, wantSize: 0, wantMore: false},
+		{rows: 3, wantSize: 3, wantMore: false},
+		{rows: 10, wantSize: 10, wantMore: false},
+		{rows: 11, wantSize: 10, wantMore: true},
+	}
+
+	for _, tt := range tests {
+		size, more := pageSize(query, tt.rows)
+		if size != tt.wantSize || more != tt.wantMore {
+			t.Errorf("pageSize(%d rows) = %d, %v, want %d, %v", tt.rows, size, more, tt.wantSize, tt.wantMore)
+		}
+	}
+}
+
+func TestListPosition(t *testing.T) {
+	id := uuid.New()
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	updated := created.Add(time.Hour)
+
+	tests := []struct {
+		sort repository.ListSort
+		want repository.ListPosition
+	}{
+		{sort: repository.SortUpdatedAt, want: repository.ListPosition{Time: updated, ID: id}},
+		{sort: repository.SortCreatedAt, want: repository.ListPosition{Time: created, ID: id}},
+		{sort: repository.SortName, want: repository.ListPosition{Name: "name", ID: id}},
+	}
+
+	for _, tt := range tests {
+		got := listPosition(repository.ListQuery{Sort: tt.sort}, id, "name", created, updated)
+		if got != tt.want {
+			t.Errorf("listPosition(%s) = %+v, want %+v", tt.sort, got, tt.want)
+		}
+	}
+}
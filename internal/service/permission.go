@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"go-training-system/internal/graph/apperror"
+	"go-training-system/internal/model"
+	"go-training-system/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// PermissionResolver is the single place that decides what a user may do with
+// a folder or note. Every folder and note read/write path goes through it,
+// deleting included, which takes manage access.
+//
+// A few rules are about who owns or wrote something rather than about
+// access, and are checked where they apply:
+//   - only the owner can restore or purge a folder or note in the trash;
+//     deleted items are in their owner's trash and grant no access
+//   - only a folder's owner can move it to the top level, and only the owner
+//     of a folder or note can drop its shares when moving it
+//   - only its author can edit a comment
+//
+// Templates are not folders or notes; TemplateService resolves access to
+// them.
+type PermissionResolver interface {
+	FolderAccess(ctx context.Context, folderID uuid.UUID, userID uuid.UUID) (model.AccessLevel, error)
+	FoldersAccess(ctx context.Context, folderIDs []uuid.UUID, userID uuid.UUID) (map[uuid.UUID]model.AccessLevel, error)
+	NoteAccess(ctx context.Context, noteID uuid.UUID, userID uuid.UUID) (model.AccessLevel, error)
+	NotesAccess(ctx context.Context, noteIDs []uuid.UUID, userID uuid.UUID) (map[uuid.UUID]model.AccessLevel, error)
+}
+
+type permissionResolver struct {
+	repo repository.PermissionRepository
+}
+
+func NewPermissionResolver(repo repository.PermissionRepository) PermissionResolver {
+	return &permissionResolver{repo: repo}
+}
+
+func (r *permissionResolver) FolderAccess(ctx context.Context, folderID uuid.UUID, userID uuid.UUID) (model.AccessLevel, error) {
+	grants, err := r.repo.GetFolderGrants(ctx, folderID, userID)
+	if err != nil {
+		return model.AccessLevelNone, err
+	}
+	if grants == nil {
+		return model.AccessLevelNone, apperror.ErrFolderNotFound
+	}
+	return effectiveAccess(grants, userID), nil
+}
+
+// FoldersAccess resolves several folders in one round trip. Folders that do
+// not exist are missing from the result.
+func (r *permissionResolver) FoldersAccess(ctx context.Context, folderIDs []uuid.UUID, userID uuid.UUID) (map[uuid.UUID]model.AccessLevel, error) {
+	grants, err := r.repo.GetFoldersGrants(ctx, folderIDs, userID)
+	if err != nil {
+		return nil, err
+	}
+	access := make(map[uuid.UUID]model.AccessLevel, len(grants))
+	for i := range grants {
+		access[grants[i].ID] = effectiveAccess(&grants[i], userID)
+	}
+	return access, nil
+}
+
+func (r *permissionResolver) NoteAccess(ctx context.Context, noteID uuid.UUID, userID uuid.UUID) (model.AccessLevel, error) {
+	access, err := r.NotesAccess(ctx, []uuid.UUID{noteID}, userID)
+	if err != nil {
+		return model.AccessLevelNone, err
+	}
+	level, ok := access[noteID]
+	if !ok {
+		return model.AccessLevelNone, apperror.ErrNoteNotFound
+	}
+	return level, nil
+}
+
+// NotesAccess resolves several notes in one round trip. Notes that do not
+// exist are missing from the result.
+func (r *permissionResolver) NotesAccess(ctx context.Context, noteIDs []uuid.UUID, userID uuid.UUID) (map[uuid.UUID]model.AccessLevel, error) {
+	grants, err := r.repo.GetNoteGrants(ctx, noteIDs, userID)
+	if err != nil {
+		return nil, err
+	}
+	access := make(map[uuid.UUID]model.AccessLevel, len(grants))
+	for i := range grants {
+		access[grants[i].ID] = effectiveAccess(&grants[i], userID)
+	}
+	return access, nil
+}
+
+// effectiveAccess combines a user's grants into one access level. Owning the
+// resource or any folder above it gives manage. Otherwise a note override, if
+// present, is the answer, and without one the most permissive grant wins.
+func effectiveAccess(g *repository.AccessGrants, userID uuid.UUID) model.AccessLevel {
+	if g.OwnerID == userID || g.OwnsFolder {
+		return model.AccessLevelManage
+	}
+	if g.NoteOverride {
+		return mostPermissive(g.NoteUser)
+	}
+	return mostPermissive(g.FolderUser, g.FolderTeam, g.NoteUser, g.NoteTeam)
+}
+
+func mostPermissive(levels ...model.AccessLevel) model.AccessLevel {
+	best := model.AccessLevelNone
+	for _, level := range levels {
+		switch level {
+		case model.AccessLevelManage:
+			return model.AccessLevelManage
+		case model.AccessLevelWrite:
+			best = model.AccessLevelWrite
+		case model.AccessLevelRead:
+			if best == model.AccessLevelNone {
+				best = model.AccessLevelRead
+			}
+		}
+	}
+	return best
+}
+
+func canRead(access model.AccessLevel) bool {
+	return access == model.AccessLevelRead || canWrite(access)
+}
+
+func canWrite(access model.AccessLevel) bool {
+	return access == model.AccessLevelWrite || canManage(access)
+}
+
+func canManage(access model.AccessLevel) bool {
+	return access == model.AccessLevelManage
+}
+
+// shareExpired reports whether a share with the given expiry no longer
+// grants anything. The expiry job deletes such shares, but may not have run
+// yet.
+func shareExpired(expiresAt *time.Time) bool {
+	return expiresAt != nil && !expiresAt.After(time.Now())
+}
+
+// validateAccess rejects access levels other than read and write.
+func validateAccess(access model.AccessLevel) error {
+	if access != model.AccessLevelRead && access != model.AccessLevelWrite {
+		return apperror.ErrInvalidAccessLevel
+	}
+	return nil
+}
+
+// validateShareAccess also allows manage, which only user shares on folders
+// and notes can grant.
+func validateShareAccess(access model.AccessLevel) error {
+	if access == model.AccessLevelManage {
+		return nil
+	}
+	if err := validateAccess(access); err != nil {
+		return apperror.ErrInvalidShareAccess
+	}
+	return nil
+}
+
+// validateNoteShareAccess additionally allows none, but only on overrides.
+func validateNoteShareAccess(access model.AccessLevel, override bool) error {
+	if override && access == model.AccessLevelNone {
+		return nil
+	}
+	return validateShareAccess(access)
+}
+
+// validateShareExpiry rejects expiries that have already passed. A nil
+// expiry never expires.
+func validateShareExpiry(expiresAt *time.Time) error {
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return apperror.ErrInvalidShareExpiry
+	}
+	return nil
+}
@@ -0,0 +1,274 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go-training-system/internal/graph/apperror"
+	"go-training-system/internal/model"
+	"go-training-system/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+func TestEffectiveAccess(t *testing.T) {
+	user := uuid.New()
+	other := uuid.New()
+
+	tests := []struct {
+		name   string
+		grants repository.AccessGrants
+		want   model.AccessLevel
+	}{
+		{
+			name:   "no grants",
+			grants: repository.AccessGrants{OwnerID: other},
+			want:   model.AccessLevelNone,
+		},
+		{
+			name:   "note owner",
+			grants: repository.AccessGrants{OwnerID: user},
+			want:   model.AccessLevelManage,
+		},
+		{
+			name:   "folder or ancestor owner manages notes of others",
+			grants: repository.AccessGrants{OwnerID: other, OwnsFolder: true},
+			want:   model.AccessLevelManage,
+		},
+		{
+			name:   "folder read share is inherited",
+			grants: repository.AccessGrants{OwnerID: other, FolderUser: model.AccessLevelRead},
+			want:   model.AccessLevelRead,
+		},
+		{
+			name:   "folder write share is inherited",
+			grants: repository.AccessGrants{OwnerID: other, FolderUser: model.AccessLevelWrite},
+			want:   model.AccessLevelWrite,
+		},
+		{
+			name:   "folder manage share is inherited",
+			grants: repository.AccessGrants{OwnerID: other, FolderUser: model.AccessLevelManage},
+			want:   model.AccessLevelManage,
+		},
+		{
+			name:   "team folder share is inherited",
+			grants: repository.AccessGrants{OwnerID: other, FolderTeam: model.AccessLevelWrite},
+			want:   model.AccessLevelWrite,
+		},
+		{
+			name: "folder write beats note read",
+			grants: repository.AccessGrants{
+				OwnerID:    other,
+				FolderUser: model.AccessLevelWrite, NoteUser: model.AccessLevelRead,
+			},
+			want: model.AccessLevelWrite,
+		},
+		{
+			name: "note write beats folder read",
+			grants: repository.AccessGrants{
+				OwnerID:    other,
+				FolderUser: model.AccessLevelRead, NoteUser: model.AccessLevelWrite,
+			},
+			want: model.AccessLevelWrite,
+		},
+		{
+			name: "note manage beats team folder write",
+			grants: repository.AccessGrants{
+				OwnerID:    other,
+				FolderTeam: model.AccessLevelWrite, NoteUser: model.AccessLevelManage,
+			},
+			want: model.AccessLevelManage,
+		},
+		{
+			name: "team note share beats user folder read",
+			grants: repository.AccessGrants{
+				OwnerID:    other,
+				FolderUser: model.AccessLevelRead, NoteTeam: model.AccessLevelWrite,
+			},
+			want: model.AccessLevelWrite,
+		},
+		{
+			name: "override downgrades inherited write",
+			grants: repository.AccessGrants{
+				OwnerID:    other,
+				FolderUser: model.AccessLevelWrite, FolderTeam: model.AccessLevelWrite,
+				NoteUser: model.AccessLevelRead, NoteOverride: true,
+			},
+			want: model.AccessLevelRead,
+		},
+		{
+			name: "override none denies despite team grants",
+			grants: repository.AccessGrants{
+				OwnerID:    other,
+				FolderTeam: model.AccessLevelRead, NoteTeam: model.AccessLevelWrite,
+				NoteUser: model.AccessLevelNone, NoteOverride: true,
+			},
+			want: model.AccessLevelNone,
+		},
+		{
+			name: "override upgrades inherited read",
+			grants: repository.AccessGrants{
+				OwnerID:    other,
+				FolderUser: model.AccessLevelRead,
+				NoteUser:   model.AccessLevelWrite, NoteOverride: true,
+			},
+			want: model.AccessLevelWrite,
+		},
+		{
+			name: "override does not restrict the folder owner",
+			grants: repository.AccessGrants{
+				OwnerID: other, OwnsFolder: true,
+				NoteUser: model.AccessLevelNone, NoteOverride: true,
+			},
+			want: model.AccessLevelManage,
+		},
+		{
+			name: "stored none without override grants nothing",
+			grants: repository.AccessGrants{
+				OwnerID:  other,
+				NoteUser: model.AccessLevelNone,
+			},
+			want: model.AccessLevelNone,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := effectiveAccess(&tt.grants, user); got != tt.want {
+				t.Errorf("effectiveAccess() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateNoteShareAccess(t *testing.T) {
+	tests := []struct {
+		name     string
+		access   model.AccessLevel
+		override bool
+		wantErr  error
+	}{
+		{name: "read", access: model.AccessLevelRead},
+		{name: "write", access: model.AccessLevelWrite},
+		{name: "manage", access: model.AccessLevelManage},
+		{name: "manage override", access: model.AccessLevelManage, override: true},
+		{name: "read override", access: model.AccessLevelRead, override: true},
+		{name: "none override", access: model.AccessLevelNone, override: true},
+		{name: "none without override", access: model.AccessLevelNone, wantErr: apperror.ErrInvalidShareAccess},
+		{name: "unknown level", access: "admin", wantErr: apperror.ErrInvalidShareAccess},
+		{name: "empty level", access: "", override: true, wantErr: apperror.ErrInvalidShareAccess},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateNoteShareAccess(tt.access, tt.override)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("validateNoteShareAccess() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+type fakePermissionRepository struct {
+	folders map[uuid.UUID]repository.AccessGrants
+	notes   map[uuid.UUID]repository.AccessGrants
+}
+
+func (f *fakePermissionRepository) GetFolderGrants(ctx context.Context, folderID, userID uuid.UUID) (*repository.AccessGrants, error) {
+	g, ok := f.folders[folderID]
+	if !ok {
+		return nil, nil
+	}
+	return &g, nil
+}
+
+func (f *fakePermissionRepository) GetFoldersGrants(ctx context.Context, folderIDs []uuid.UUID, userID uuid.UUID) ([]repository.AccessGrants, error) {
+	var grants []repository.AccessGrants
+	for _, id := range folderIDs {
+		if g, ok := f.folders[id]; ok {
+			grants = append(grants, g)
+		}
+	}
+	return grants, nil
+}
+
+func (f *fakePermissionRepository) GetNoteGrants(ctx context.Context, noteIDs []uuid.UUID, userID uuid.UUID) ([]repository.AccessGrants, error) {
+	var grants []repository.AccessGrants
+	for _, id := range noteIDs {
+		if g, ok := f.notes[id]; ok {
+			grants = append(grants, g)
+		}
+	}
+	return grants, nil
+}
+
+func TestPermissionResolver(t *testing.T) {
+	user := uuid.New()
+	owner := uuid.New()
+	folderID := uuid.New()
+	readable := uuid.New()
+	denied := uuid.New()
+	missing := uuid.New()
+
+	resolver := NewPermissionResolver(&fakePermissionRepository{
+		folders: map[uuid.UUID]repository.AccessGrants{
+			folderID: {ID: folderID, OwnerID: owner, FolderTeam: model.AccessLevelRead},
+		},
+		notes: map[uuid.UUID]repository.AccessGrants{
+			readable: {ID: readable, OwnerID: owner, FolderTeam: model.AccessLevelRead},
+			denied: {
+				ID: denied, OwnerID: owner, FolderTeam: model.AccessLevelRead,
+				NoteUser: model.AccessLevelNone, NoteOverride: true,
+			},
+		},
+	})
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		resolve func() (model.AccessLevel, error)
+		want    model.AccessLevel
+		wantErr error
+	}{
+		{
+			name:    "folder",
+			resolve: func() (model.AccessLevel, error) { return resolver.FolderAccess(ctx, folderID, user) },
+			want:    model.AccessLevelRead,
+		},
+		{
+			name:    "missing folder",
+			resolve: func() (model.AccessLevel, error) { return resolver.FolderAccess(ctx, uuid.New(), user) },
+			want:    model.AccessLevelNone,
+			wantErr: apperror.ErrFolderNotFound,
+		},
+		{
+			name:    "inherited note",
+			resolve: func() (model.AccessLevel, error) { return resolver.NoteAccess(ctx, readable, user) },
+			want:    model.AccessLevelRead,
+		},
+		{
+			name:    "denied note",
+			resolve: func() (model.AccessLevel, error) { return resolver.NoteAccess(ctx, denied, user) },
+			want:    model.AccessLevelNone,
+		},
+		{
+			name:    "missing note",
+			resolve: func() (model.AccessLevel, error) { return resolver.NoteAccess(ctx, missing, user) },
+			want:    model.AccessLevelNone,
+			wantErr: apperror.ErrNoteNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.resolve()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("access = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"go-training-system/internal/event"
+	"go-training-system/internal/model"
+	"go-training-system/internal/repository"
+)
+
+// ShareExpiryService enforces the expiry of folder and note shares. Expired
+// shares already grant nothing at access time; this deletes them and warns
+// both the grantee and the granter beforehand.
+type ShareExpiryService interface {
+	ExpireShares(ctx context.Context) (int, error)
+}
+
+type shareExpiryService struct {
+	shareRepo repository.ShareRepository
+	warning   time.Duration
+	events    *event.Bus
+}
+
+// NewShareExpiryService warns about shares the given duration before they
+// expire.
+func NewShareExpiryService(shareRepo repository.ShareRepository, warning time.Duration, events *event.Bus) ShareExpiryService {
+	return &shareExpiryService{
+		shareRepo: shareRepo,
+		warning:   warning,
+		events:    events,
+	}
+}
+
+// ExpireShares sends the warnings that are due and deletes every share that
+// has expired. It is run periodically by a background job and returns how
+// many shares it warned about or deleted.
+func (s *shareExpiryService) ExpireShares(ctx context.Context) (int, error) {
+	now := time.Now()
+	processed := 0
+
+	folderShares, noteShares, err := s.shareRepo.MarkExpiringShares(ctx, now, now.Add(s.warning))
+	if err != nil {
+		return processed, err
+	}
+	processed += s.publish(ctx, event.KindShareExpiring, folderShares, noteShares)
+
+	folderShares, noteShares, err = s.shareRepo.ExpireShares(ctx, now)
+	if err != nil {
+		return processed, err
+	}
+	processed += s.publish(ctx, event.KindShareExpired, folderShares, noteShares)
+
+	return processed, nil
+}
+
+func (s *shareExpiryService) publish(ctx context.Context, kind event.Kind, folderShares []model.FolderShare, noteShares []model.NoteShare) int {
+	for _, share := range folderShares {
+		s.events.ShareChanged(ctx, event.ShareEvent{
+			ItemType:   event.ItemFolder,
+			ItemID:     share.FolderID,
+			UserID:     share.UserID,
+			SharedByID: share.SharedByID,
+			Kind:       kind,
+			ExpiresAt:  *share.ExpiresAt,
+		})
+	}
+	for _, share := range noteShares {
+		s.events.ShareChanged(ctx, event.ShareEvent{
+			ItemType:   event.ItemNote,
+			ItemID:     share.NoteID,
+			UserID:     share.UserID,
+			SharedByID: share.SharedByID,
+			Kind:       kind,
+			ExpiresAt:  *share.ExpiresAt,
+		})
+	}
+	return len(folderShares) + len(noteShares)
+}
@@ -0,0 +1,373 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"go-training-system/internal/dto"
+	"go-training-system/internal/graph/apperror"
+	"go-training-system/internal/model"
+	"go-training-system/internal/repository"
+	"go-training-system/pkg/hash"
+	"go-training-system/pkg/logger"
+	"go-training-system/pkg/ratelimit"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// ShareLinkService manages public links to notes and folders and serves them
+// read-only to anyone holding one, no account needed. Only the owner of a
+// note or folder and users with manage access can create, list and revoke
+// its links. A link stops working
+// as soon as it is revoked, expires or its note or folder is deleted; like
+// shares, it works again if the note or folder is restored from the trash.
+type ShareLinkService interface {
+	CreateFolderLink(ctx context.Context, folderID uuid.UUID, req *dto.CreateShareLinkRequest, userID uuid.UUID) (*dto.ShareLinkResponse, error)
+	CreateNoteLink(ctx context.Context, noteID uuid.UUID, req *dto.CreateShareLinkRequest, userID uuid.UUID) (*dto.ShareLinkResponse, error)
+	GetFolderLinks(ctx context.Context, folderID uuid.UUID, userID uuid.UUID) ([]dto.ShareLinkResponse, error)
+	GetNoteLinks(ctx context.Context, noteID uuid.UUID, userID uuid.UUID) ([]dto.ShareLinkResponse, error)
+	RevokeLink(ctx context.Context, id uuid.UUID, userID uuid.UUID) error
+	// OpenLink shows what the link points at.
+	OpenLink(ctx context.Context, req *dto.PublicLinkRequest) (*dto.PublicLinkResponse, error)
+	// OpenLinkFolder shows a folder inside a folder link's folder.
+	OpenLinkFolder(ctx context.Context, req *dto.PublicLinkRequest, folderID uuid.UUID) (*dto.PublicFolderResponse, error)
+	// OpenLinkNote shows a note inside a folder link's folder, or a note
+	// link's own note.
+	OpenLinkNote(ctx context.Context, req *dto.PublicLinkRequest, noteID uuid.UUID) (*dto.PublicNoteResponse, error)
+}
+
+type shareLinkService struct {
+	linkRepo    repository.ShareLinkRepository
+	folderRepo  repository.FolderRepository
+	noteRepo    repository.NoteRepository
+	permissions PermissionResolver
+	// limiter counts the uses of each link per client, failed ones included,
+	// so passwords cannot be guessed quickly.
+	limiter *ratelimit.Limiter
+}
+
+// maxLinkPassword is the longest password bcrypt can hash.
+const maxLinkPassword = 72
+
+func NewShareLinkService(linkRepo repository.ShareLinkRepository, folderRepo repository.FolderRepository, noteRepo repository.NoteRepository, permissions PermissionResolver, limiter *ratelimit.Limiter) ShareLinkService {
+	return &shareLinkService{
+		linkRepo:    linkRepo,
+		folderRepo:  folderRepo,
+		noteRepo:    noteRepo,
+		permissions: permissions,
+		limiter:     limiter,
+	}
+}
+
+func (s *shareLinkService) CreateFolderLink(ctx context.Context, folderID uuid.UUID, req *dto.CreateShareLinkRequest, userID uuid.UUID) (*dto.ShareLinkResponse, error) {
+	if err := requireManage(s.permissions.FolderAccess(ctx, folderID, userID)); err != nil {
+		return nil, err
+	}
+	return s.createLink(ctx, &model.ShareLink{FolderID: &folderID}, req, userID)
+}
+
+func (s *shareLinkService) CreateNoteLink(ctx context.Context, noteID uuid.UUID, req *dto.CreateShareLinkRequest, userID uuid.UUID) (*dto.ShareLinkResponse, error) {
+	if err := requireManage(s.permissions.NoteAccess(ctx, noteID, userID)); err != nil {
+		return nil, err
+	}
+	return s.createLink(ctx, &model.ShareLink{NoteID: &noteID}, req, userID)
+}
+
+func (s *shareLinkService) createLink(ctx context.Context, link *model.ShareLink, req *dto.CreateShareLinkRequest, userID uuid.UUID) (*dto.ShareLinkResponse, error) {
+	if len(req.Password) > maxLinkPassword {
+		return nil, apperror.ErrInvalidLinkPassword
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, apperror.ErrInvalidLinkExpiry
+	}
+
+	token, err := newLinkToken()
+	if err != nil {
+		return nil, err
+	}
+	link.TokenHash = hashLinkToken(token)
+	link.ExpiresAt = req.ExpiresAt
+	link.CreatedByID = userID
+	if req.Password != "" {
+		if link.PasswordHash, err = hash.Hash(req.Password); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.linkRepo.Create(ctx, link); err != nil {
+		return nil, err
+	}
+	response := toShareLinkResponse(link)
+	response.Token = token
+	return &response, nil
+}
+
+func (s *shareLinkService) GetFolderLinks(ctx context.Context, folderID uuid.UUID, userID uuid.UUID) ([]dto.ShareLinkResponse, error) {
+	if err := requireManage(s.permissions.FolderAccess(ctx, folderID, userID)); err != nil {
+		return nil, err
+	}
+
+	links, err := s.linkRepo.GetFolderLinks(ctx, folderID)
+	if err != nil {
+		return nil, err
+	}
+	return toShareLinkResponses(links), nil
+}
+
+func (s *shareLinkService) GetNoteLinks(ctx context.Context, noteID uuid.UUID, userID uuid.UUID) ([]dto.ShareLinkResponse, error) {
+	if err := requireManage(s.permissions.NoteAccess(ctx, noteID, userID)); err != nil {
+		return nil, err
+	}
+
+	links, err := s.linkRepo.GetNoteLinks(ctx, noteID)
+	if err != nil {
+		return nil, err
+	}
+	return toShareLinkResponses(links), nil
+}
+
+// RevokeLink deletes a link. Whoever created it can revoke it, even while
+// its note or folder is in the trash; otherwise it takes the owner or a
+// manager.
+func (s *shareLinkService) RevokeLink(ctx context.Context, id uuid.UUID, userID uuid.UUID) error {
+	link, err := s.linkRepo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if link.CreatedByID != userID {
+		if link.NoteID != nil {
+			err = requireManage(s.permissions.NoteAccess(ctx, *link.NoteID, userID))
+		} else {
+			err = requireManage(s.permissions.FolderAccess(ctx, *link.FolderID, userID))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.linkRepo.Delete(ctx, id)
+}
+
+// requireManage takes the result of a permission lookup and allows only
+// manage access.
+func requireManage(access model.AccessLevel, err error) error {
+	if err != nil {
+		return err
+	}
+	if !canManage(access) {
+		return apperror.ErrAccessDenied
+	}
+	return nil
+}
+
+func (s *shareLinkService) OpenLink(ctx context.Context, req *dto.PublicLinkRequest) (*dto.PublicLinkResponse, error) {
+	link, err := s.open(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &dto.PublicLinkResponse{ExpiresAt: link.ExpiresAt}
+	if link.NoteID != nil {
+		note, err := s.noteRepo.GetByID(ctx, *link.NoteID)
+		if errors.Is(err, apperror.ErrNoteNotFound) {
+			return nil, apperror.ErrShareLinkNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		response.Type = "note"
+		response.Note = toPublicNote(note)
+	} else {
+		folder, err := s.folderRepo.GetByID(ctx, *link.FolderID)
+		if errors.Is(err, apperror.ErrFolderNotFound) {
+			return nil, apperror.ErrShareLinkNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		response.Type = "folder"
+		if response.Folder, err = s.publicFolder(ctx, folder); err != nil {
+			return nil, err
+		}
+	}
+
+	s.recordAccess(ctx, link)
+	return response, nil
+}
+
+func (s *shareLinkService) OpenLinkFolder(ctx context.Context, req *dto.PublicLinkRequest, folderID uuid.UUID) (*dto.PublicFolderResponse, error) {
+	link, err := s.open(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.requireInLink(ctx, link, folderID); err != nil {
+		return nil, err
+	}
+
+	folder, err := s.folderRepo.GetByID(ctx, folderID)
+	if err != nil {
+		return nil, err
+	}
+	response, err := s.publicFolder(ctx, folder)
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordAccess(ctx, link)
+	return response, nil
+}
+
+func (s *shareLinkService) OpenLinkNote(ctx context.Context, req *dto.PublicLinkRequest, noteID uuid.UUID) (*dto.PublicNoteResponse, error) {
+	link, err := s.open(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if link.NoteID != nil && *link.NoteID != noteID {
+		return nil, apperror.ErrNoteNotFound
+	}
+
+	note, err := s.noteRepo.GetByID(ctx, noteID)
+	if err != nil {
+		return nil, err
+	}
+	if link.FolderID != nil {
+		err := s.requireInLink(ctx, link, note.FolderID)
+		if errors.Is(err, apperror.ErrFolderNotFound) {
+			return nil, apperror.ErrNoteNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s.recordAccess(ctx, link)
+	return toPublicNote(note), nil
+}
+
+// open finds the link for a token and checks its password, counting the
+// attempt against the client's rate limit first.
+func (s *shareLinkService) open(ctx context.Context, req *dto.PublicLinkRequest) (*model.ShareLink, error) {
+	tokenHash := hashLinkToken(req.Token)
+	if !s.limiter.Allow(tokenHash + "|" + req.Client) {
+		return nil, apperror.ErrTooManyRequests
+	}
+
+	link, err := s.linkRepo.GetByTokenHash(ctx, tokenHash)
+	if err != nil {
+		return nil, err
+	}
+	if link.ExpiresAt != nil && !link.ExpiresAt.After(time.Now()) {
+		return nil, apperror.ErrShareLinkNotFound
+	}
+	if link.PasswordHash != "" && !hash.CheckHash(req.Password, link.PasswordHash) {
+		return nil, apperror.ErrLinkPassword
+	}
+	return link, nil
+}
+
+// requireInLink checks that the folder is a folder link's folder or inside
+// it. A folder link's folder that is in the trash contains nothing.
+func (s *shareLinkService) requireInLink(ctx context.Context, link *model.ShareLink, folderID uuid.UUID) error {
+	if link.FolderID == nil {
+		return apperror.ErrFolderNotFound
+	}
+	path, err := s.folderRepo.GetPath(ctx, folderID)
+	if err != nil {
+		return err
+	}
+	for _, folder := range path {
+		if folder.ID == *link.FolderID {
+			return nil
+		}
+	}
+	return apperror.ErrFolderNotFound
+}
+
+func (s *shareLinkService) publicFolder(ctx context.Context, folder *model.Folder) (*dto.PublicFolderResponse, error) {
+	children, err := s.folderRepo.GetChildren(ctx, folder.ID)
+	if err != nil {
+		return nil, err
+	}
+	notes, err := s.noteRepo.GetByFolderID(ctx, folder.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &dto.PublicFolderResponse{
+		ID:          folder.ID,
+		Name:        folder.Name,
+		Description: folder.Description,
+		UpdatedAt:   folder.UpdatedAt,
+		Folders:     make([]dto.PublicFolderEntry, len(children)),
+		Notes:       make([]dto.PublicNoteEntry, len(notes)),
+	}
+	for i, child := range children {
+		response.Folders[i] = dto.PublicFolderEntry{ID: child.ID, Name: child.Name}
+	}
+	for i, note := range notes {
+		response.Notes[i] = dto.PublicNoteEntry{ID: note.ID, Title: note.Title, UpdatedAt: note.UpdatedAt}
+	}
+	return response, nil
+}
+
+// recordAccess counts a successful use of the link. Failing to count it does
+// not fail the request.
+func (s *shareLinkService) recordAccess(ctx context.Context, link *model.ShareLink) {
+	if err := s.linkRepo.RecordAccess(ctx, link.ID); err != nil {
+		logger.Log.Error("failed to record share link access", zap.String("link", link.ID.String()), zap.Error(err))
+	}
+}
+
+// newLinkToken returns 256 random bits, URL-safe.
+func newLinkToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashLinkToken is what is stored of a token. Tokens are random enough that
+// a fast hash does, and it lets links be looked up by token.
+func hashLinkToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func toPublicNote(note *model.Note) *dto.PublicNoteResponse {
+	return &dto.PublicNoteResponse{
+		ID:        note.ID,
+		Title:     note.Title,
+		Body:      note.Body,
+		UpdatedAt: note.UpdatedAt,
+	}
+}
+
+func toShareLinkResponse(link *model.ShareLink) dto.ShareLinkResponse {
+	return dto.ShareLinkResponse{
+		ID:             link.ID,
+		FolderID:       link.FolderID,
+		NoteID:         link.NoteID,
+		HasPassword:    link.PasswordHash != "",
+		ExpiresAt:      link.ExpiresAt,
+		AccessCount:    link.AccessCount,
+		LastAccessedAt: link.LastAccessedAt,
+		CreatedByID:    link.CreatedByID,
+		CreatedAt:      link.CreatedAt,
+	}
+}
+
+func toShareLinkResponses(links []model.ShareLink) []dto.ShareLinkResponse {
+	response := make([]dto.ShareLinkResponse, len(links))
+	for i := range links {
+		response[i] = toShareLinkResponse(&links[i])
+	}
+	return response
+}
@@ -0,0 +1,313 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"go-training-system/internal/dto"
+	"go-training-system/internal/graph/apperror"
+	"go-training-system/internal/model"
+	"go-training-system/internal/repository"
+	"go-training-system/pkg/tagexpr"
+
+	"github.com/google/uuid"
+)
+
+const (
+	maxTagNameLength = 100
+	// maxTagFilterLength leaves room for tagexpr.MaxTags quoted names of the
+	// longest length joined by operators.
+	maxTagFilterLength = 4096
+)
+
+// TagService manages personal and team tags and their use on notes. Personal
+// tags belong to one user. Team tags can be used by every current member of
+// the team and managed by its managers and the tag's creator.
+type TagService interface {
+	CreateTag(ctx context.Context, req *dto.CreateTagRequest, userID uuid.UUID) (*dto.TagResponse, error)
+	ListTags(ctx context.Context, userID uuid.UUID) ([]dto.TagResponse, error)
+	RenameTag(ctx context.Context, tagID uuid.UUID, req *dto.RenameTagRequest, userID uuid.UUID) (*dto.TagResponse, error)
+	MergeTag(ctx context.Context, tagID uuid.UUID, req *dto.MergeTagRequest, userID uuid.UUID) error
+	DeleteTag(ctx context.Context, tagID uuid.UUID, userID uuid.UUID) error
+	GetNoteTags(ctx context.Context, noteID uuid.UUID, userID uuid.UUID) ([]dto.TagResponse, error)
+	AddNoteTag(ctx context.Context, noteID uuid.UUID, req *dto.NoteTagRequest, userID uuid.UUID) error
+	RemoveNoteTag(ctx context.Context, noteID uuid.UUID, tagID uuid.UUID, userID uuid.UUID) error
+}
+
+type tagService struct {
+	tagRepo     repository.TagRepository
+	teamRepo    repository.TeamRepository
+	noteRepo    repository.NoteRepository
+	permissions PermissionResolver
+}
+
+func NewTagService(tagRepo repository.TagRepository, teamRepo repository.TeamRepository, noteRepo repository.NoteRepository, permissions PermissionResolver) TagService {
+	return &tagService{
+		tagRepo:     tagRepo,
+		teamRepo:    teamRepo,
+		noteRepo:    noteRepo,
+		permissions: permissions,
+	}
+}
+
+// CreateTag creates a personal tag, or a team tag when the request names a
+// team the user belongs to.
+func (s *tagService) CreateTag(ctx context.Context, req *dto.CreateTagRequest, userID uuid.UUID) (*dto.TagResponse, error) {
+	name, err := normalizeTagName(req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	tag := &model.Tag{Name: name, CreatedByID: userID}
+	if req.TeamID != nil {
+		ok, err := s.inTeam(ctx, *req.TeamID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, apperror.ErrAccessDenied
+		}
+		tag.TeamID = req.TeamID
+	} else {
+		tag.UserID = &userID
+	}
+
+	if err := s.tagRepo.Create(ctx, tag); err != nil {
+		return nil, err
+	}
+
+	return &dto.TagResponse{
+		ID:        tag.ID,
+		Name:      tag.Name,
+		TeamID:    tag.TeamID,
+		CreatedAt: tag.CreatedAt,
+	}, nil
+}
+
+// ListTags returns the user's tag cloud: every tag they can use with its
+// note count, most used first.
+func (s *tagService) ListTags(ctx context.Context, userID uuid.UUID) ([]dto.TagResponse, error) {
+	tags, err := s.tagRepo.GetVisibleWithCounts(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]dto.TagResponse, len(tags))
+	for i, tag := range tags {
+		response[i] = dto.TagResponse{
+			ID:        tag.Tag.ID,
+			Name:      tag.Tag.Name,
+			TeamID:    tag.Tag.TeamID,
+			NoteCount: tag.NoteCount,
+			CreatedAt: tag.Tag.CreatedAt,
+		}
+	}
+
+	return response, nil
+}
+
+func (s *tagService) RenameTag(ctx context.Context, tagID uuid.UUID, req *dto.RenameTagRequest, userID uuid.UUID) (*dto.TagResponse, error) {
+	tag, err := s.manageableTag(ctx, tagID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := normalizeTagName(req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.tagRepo.Rename(ctx, tag, name); err != nil {
+		return nil, err
+	}
+
+	return &dto.TagResponse{
+		ID:        tag.ID,
+		Name:      tag.Name,
+		TeamID:    tag.TeamID,
+		CreatedAt: tag.CreatedAt,
+	}, nil
+}
+
+// MergeTag moves every note of a tag onto another tag of the same user or
+// team and deletes the first tag. The user must be able to manage both.
+func (s *tagService) MergeTag(ctx context.Context, tagID uuid.UUID, req *dto.MergeTagRequest, userID uuid.UUID) error {
+	if req.IntoTagID == tagID {
+		return apperror.ErrTagScopeMismatch
+	}
+
+	source, err := s.manageableTag(ctx, tagID, userID)
+	if err != nil {
+		return err
+	}
+	target, err := s.manageableTag(ctx, req.IntoTagID, userID)
+	if err != nil {
+		return err
+	}
+	if !sameTagScope(source, target) {
+		return apperror.ErrTagScopeMismatch
+	}
+
+	return s.tagRepo.Merge(ctx, source.ID, target.ID)
+}
+
+func (s *tagService) DeleteTag(ctx context.Context, tagID uuid.UUID, userID uuid.UUID) error {
+	if _, err := s.manageableTag(ctx, tagID, userID); err != nil {
+		return err
+	}
+	return s.tagRepo.Delete(ctx, tagID)
+}
+
+// GetNoteTags lists the tags on a note that the user can see; other users'
+// personal tags and tags of teams they are not in stay hidden.
+func (s *tagService) GetNoteTags(ctx context.Context, noteID uuid.UUID, userID uuid.UUID) ([]dto.TagResponse, error) {
+	if err := s.requireNoteRead(ctx, noteID, userID); err != nil {
+		return nil, err
+	}
+
+	tags, err := s.tagRepo.GetNoteTags(ctx, noteID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]dto.TagResponse, len(tags))
+	for i, tag := range tags {
+		response[i] = dto.TagResponse{
+			ID:        tag.ID,
+			Name:      tag.Name,
+			TeamID:    tag.TeamID,
+			CreatedAt: tag.CreatedAt,
+		}
+	}
+
+	return response, nil
+}
+
+// AddNoteTag tags a note the user can read with a tag they can use. Tags
+// organize notes for their viewers, so write access is not needed.
+func (s *tagService) AddNoteTag(ctx context.Context, noteID uuid.UUID, req *dto.NoteTagRequest, userID uuid.UUID) error {
+	if err := s.requireNoteRead(ctx, noteID, userID); err != nil {
+		return err
+	}
+	if _, err := s.usableTag(ctx, req.TagID, userID); err != nil {
+		return err
+	}
+
+	return s.tagRepo.AddToNote(ctx, &model.NoteTag{
+		NoteID:     noteID,
+		TagID:      req.TagID,
+		TaggedByID: userID,
+	})
+}
+
+func (s *tagService) RemoveNoteTag(ctx context.Context, noteID uuid.UUID, tagID uuid.UUID, userID uuid.UUID) error {
+	if err := s.requireNoteRead(ctx, noteID, userID); err != nil {
+		return err
+	}
+	if _, err := s.usableTag(ctx, tagID, userID); err != nil {
+		return err
+	}
+
+	return s.tagRepo.RemoveFromNote(ctx, noteID, tagID)
+}
+
+func (s *tagService) requireNoteRead(ctx context.Context, noteID uuid.UUID, userID uuid.UUID) error {
+	if _, err := s.noteRepo.GetByID(ctx, noteID); err != nil {
+		return err
+	}
+	access, err := s.permissions.NoteAccess(ctx, noteID, userID)
+	if err != nil {
+		return err
+	}
+	if !canRead(access) {
+		return apperror.ErrAccessDenied
+	}
+	return nil
+}
+
+// usableTag loads a tag the user can apply. Tags they cannot see are
+// reported as not found.
+func (s *tagService) usableTag(ctx context.Context, tagID uuid.UUID, userID uuid.UUID) (*model.Tag, error) {
+	tag, err := s.tagRepo.GetByID(ctx, tagID)
+	if err != nil {
+		return nil, err
+	}
+	if tag.TeamID == nil {
+		if tag.UserID == nil || *tag.UserID != userID {
+			return nil, apperror.ErrTagNotFound
+		}
+		return tag, nil
+	}
+	ok, err := s.inTeam(ctx, *tag.TeamID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, apperror.ErrTagNotFound
+	}
+	return tag, nil
+}
+
+// manageableTag loads a tag the user can rename, merge or delete: their own
+// personal tag, or a team tag they created or whose team they manage.
+func (s *tagService) manageableTag(ctx context.Context, tagID uuid.UUID, userID uuid.UUID) (*model.Tag, error) {
+	tag, err := s.usableTag(ctx, tagID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if tag.TeamID == nil || tag.CreatedByID == userID {
+		return tag, nil
+	}
+	isManager, err := s.teamRepo.IsTeamManager(ctx, *tag.TeamID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !isManager {
+		return nil, apperror.ErrAccessDenied
+	}
+	return tag, nil
+}
+
+// inTeam reports whether the user is a current member of the team or manages
+// it.
+func (s *tagService) inTeam(ctx context.Context, teamID uuid.UUID, userID uuid.UUID) (bool, error) {
+	member, err := s.tagRepo.IsTeamMember(ctx, teamID, userID)
+	if err != nil || member {
+		return member, err
+	}
+	return s.teamRepo.IsTeamManager(ctx, teamID, userID)
+}
+
+func sameTagScope(a, b *model.Tag) bool {
+	if a.TeamID != nil || b.TeamID != nil {
+		return a.TeamID != nil && b.TeamID != nil && *a.TeamID == *b.TeamID
+	}
+	return a.UserID != nil && b.UserID != nil && *a.UserID == *b.UserID
+}
+
+// normalizeTagName trims a tag name and checks it can be written in a tag
+// expression: double quotes are the only character that cannot be quoted.
+func normalizeTagName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxTagNameLength || strings.Contains(name, `"`) {
+		return "", apperror.ErrInvalidTagName
+	}
+	return name, nil
+}
+
+// parseTagFilter parses an optional tag expression; an empty one yields nil.
+func parseTagFilter(tags string) (tagexpr.Expr, error) {
+	if strings.TrimSpace(tags) == "" {
+		return nil, nil
+	}
+	if len(tags) > maxTagFilterLength {
+		return nil, fmt.Errorf("%w: longer than %d bytes", apperror.ErrInvalidTagFilter, maxTagFilterLength)
+	}
+	expr, err := tagexpr.Parse(tags)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", apperror.ErrInvalidTagFilter, err)
+	}
+	return expr, nil
+}
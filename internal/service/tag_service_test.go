@@ -0,0 +1,37 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"go-training-system/internal/graph/apperror"
+)
+
+func TestParseTagFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		tags    string
+		wantNil bool
+		wantErr error
+	}{
+		{name: "empty", tags: " ", wantNil: true},
+		{name: "valid", tags: "go and testing"},
+		{name: "at the length limit", tags: "go or " + strings.Repeat("x", maxTagFilterLength-len("go or "))},
+		{name: "too long", tags: strings.Repeat("(", maxTagFilterLength+1), wantErr: apperror.ErrInvalidTagFilter},
+		{name: "nested too deep", tags: strings.Repeat("(", 100) + "go" + strings.Repeat(")", 100), wantErr: apperror.ErrInvalidTagFilter},
+		{name: "malformed", tags: "go and", wantErr: apperror.ErrInvalidTagFilter},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := parseTagFilter(tt.tags)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("parseTagFilter() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (expr == nil) != tt.wantNil {
+				t.Errorf("parseTagFilter() = %v, want nil: %v", expr, tt.wantNil)
+			}
+		})
+	}
+}
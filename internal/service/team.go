@@ -3,30 +3,53 @@ package service
 import (
 	"context"
 	"errors"
+	"time"
 
 	"go-training-system/internal/dto"
+	"go-training-system/internal/event"
+	"go-training-system/internal/graph/apperror"
+	"go-training-system/internal/graph/constant"
 	"go-training-system/internal/model"
 	"go-training-system/internal/repository"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type TeamService interface {
 	CreateTeam(ctx context.Context, createdBy uuid.UUID, req *dto.CreateTeamRequest) error
 	GetTeamByID(ctx context.Context, teamID uuid.UUID) (*model.Team, error)
 	GetTeamsByUserID(ctx context.Context, userID uuid.UUID) ([]model.Team, error)
-	AddMember(ctx context.Context, teamID uuid.UUID, userID uuid.UUID, addedBy uuid.UUID) error
-	AddManager(ctx context.Context, teamID uuid.UUID, userID uuid.UUID, addedBy uuid.UUID) error
-	RemoveMember(ctx context.Context, teamID uuid.UUID, userID uuid.UUID) error
-	RemoveManager(ctx context.Context, teamID uuid.UUID, userID uuid.UUID) error
+	AddMember(ctx context.Context, teamID uuid.UUID, userID uuid.UUID, addedBy uuid.UUID, expiresAt *time.Time) error
+	AddManager(ctx context.Context, teamID uuid.UUID, userID uuid.UUID, addedBy uuid.UUID, expiresAt *time.Time) error
+	RemoveMember(ctx context.Context, teamID uuid.UUID, userID uuid.UUID, removedBy uuid.UUID) error
+	RemoveManager(ctx context.Context, teamID uuid.UUID, userID uuid.UUID, removedBy uuid.UUID) error
+
+	// Hierarchy
+	GetTeam(ctx context.Context, teamID uuid.UUID, userID uuid.UUID, includeDescendants bool) (*dto.TeamResponse, error)
+	GetAncestors(ctx context.Context, teamID uuid.UUID, userID uuid.UUID) ([]dto.TeamSummary, error)
+	GetDescendants(ctx context.Context, teamID uuid.UUID, userID uuid.UUID) ([]dto.TeamSummary, error)
+	GetSubtree(ctx context.Context, teamID uuid.UUID, userID uuid.UUID) (*dto.TeamTreeNode, error)
+	MoveTeam(ctx context.Context, teamID uuid.UUID, parentID *uuid.UUID, movedBy uuid.UUID) error
+	CanManageTeam(ctx context.Context, teamID uuid.UUID, userID uuid.UUID) (bool, error)
+	CanViewTeam(ctx context.Context, teamID uuid.UUID, userID uuid.UUID) (bool, error)
+
+	BulkUpdateMembers(ctx context.Context, req *dto.BulkMembershipRequest, actorID uuid.UUID) (*dto.BulkMembershipResult, error)
+
+	// Membership history
+	GetMembershipAt(ctx context.Context, teamID uuid.UUID, userID uuid.UUID, at time.Time) ([]dto.TeamMembershipPeriod, error)
+	GetTeamMembershipHistory(ctx context.Context, teamID uuid.UUID, userID uuid.UUID) ([]dto.TeamMembershipPeriod, error)
+	GetUserTeamHistory(ctx context.Context, userID uuid.UUID) ([]dto.TeamMembershipPeriod, error)
+	ExpireMemberships(ctx context.Context) (int, error)
 }
 
 type teamService struct {
-	repo repository.TeamRepository
+	repo   repository.TeamRepository
+	events *event.Bus
 }
 
-func NewTeamService(repo repository.TeamRepository) TeamService {
-	return &teamService{repo: repo}
+func NewTeamService(repo repository.TeamRepository, events *event.Bus) TeamService {
+	return &teamService{repo: repo, events: events}
 }
 
 func (s *teamService) CreateTeam(ctx context.Context, createdBy uuid.UUID, req *dto.CreateTeamRequest) error {
@@ -41,6 +64,18 @@ func (s *teamService) CreateTeam(ctx context.Context, createdBy uuid.UUID, req *
 		TeamName:    req.TeamName,
 	}
 
+	// Creating a child unit requires authority over the parent unit
+	if req.ParentID != "" {
+		parentID, err := uuid.Parse(req.ParentID)
+		if err != nil {
+			return err
+		}
+		if err := s.authorize(ctx, parentID, createdBy); err != nil {
+			return err
+		}
+		team.ParentID = &parentID
+	}
+
 	err := s.repo.CreateTeam(ctx, team)
 	if err != nil {
 		return err
@@ -76,15 +111,575 @@ func (s *teamService) GetTeamByID(ctx context.Context, teamID uuid.UUID) (*model
 func (s *teamService) GetTeamsByUserID(ctx context.Context, userID uuid.UUID) ([]model.Team, error) {
 	return s.repo.GetTeamsByUserID(ctx, userID)
 }
-func (s *teamService) AddMember(ctx context.Context, teamID uuid.UUID, userID uuid.UUID, addedBy uuid.UUID) error {
-	return s.repo.AddMemberToTeam(ctx, teamID, userID, addedBy)
+
+// AddMember adds a user with the MEMBER role. A non-nil expiresAt makes the
+// membership temporary; it is removed automatically once it expires.
+func (s *teamService) AddMember(ctx context.Context, teamID uuid.UUID, userID uuid.UUID, addedBy uuid.UUID, expiresAt *time.Time) error {
+	if err := validateExpiry(expiresAt); err != nil {
+		return err
+	}
+	if err := s.authorize(ctx, teamID, addedBy); err != nil {
+		return err
+	}
+	if err := s.repo.AddUserToTeam(ctx, teamID, userID, model.UserRoleMember, addedBy, expiresAt); err != nil {
+		return err
+	}
+	s.membershipChanged(ctx, teamID, userID, event.KindMemberAdded, model.UserRoleMember, addedBy)
+	return nil
+}
+
+func (s *teamService) AddManager(ctx context.Context, teamID uuid.UUID, userID uuid.UUID, addedBy uuid.UUID, expiresAt *time.Time) error {
+	if err := validateExpiry(expiresAt); err != nil {
+		return err
+	}
+	if err := s.authorize(ctx, teamID, addedBy); err != nil {
+		return err
+	}
+	if err := s.repo.AddUserToTeam(ctx, teamID, userID, model.UserRoleManager, addedBy, expiresAt); err != nil {
+		return err
+	}
+	s.membershipChanged(ctx, teamID, userID, event.KindMemberAdded, model.UserRoleManager, addedBy)
+	return nil
+}
+
+func (s *teamService) RemoveMember(ctx context.Context, teamID uuid.UUID, userID uuid.UUID, removedBy uuid.UUID) error {
+	if err := s.authorize(ctx, teamID, removedBy); err != nil {
+		return err
+	}
+	if err := s.repo.RemoveMemberFromTeam(ctx, teamID, userID, removedBy); err != nil {
+		return err
+	}
+	s.membershipChanged(ctx, teamID, userID, event.KindMemberRemoved, model.UserRoleMember, removedBy)
+	return nil
+}
+
+func (s *teamService) RemoveManager(ctx context.Context, teamID uuid.UUID, userID uuid.UUID, removedBy uuid.UUID) error {
+	if err := s.authorize(ctx, teamID, removedBy); err != nil {
+		return err
+	}
+	if err := s.repo.RemoveManagerFromTeam(ctx, teamID, userID, removedBy); err != nil {
+		return err
+	}
+	s.membershipChanged(ctx, teamID, userID, event.KindMemberRemoved, model.UserRoleManager, removedBy)
+	return nil
+}
+
+// GetTeam returns a team with its members to a user who can view it. With
+// includeDescendants the memberships of every child team are listed as
+// well, flagged as inherited.
+func (s *teamService) GetTeam(ctx context.Context, teamID uuid.UUID, userID uuid.UUID, includeDescendants bool) (*dto.TeamResponse, error) {
+	team, err := s.repo.GetTeamByID(ctx, teamID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperror.ErrTeamNotFound
+		}
+		return nil, err
+	}
+	if err := s.authorizeView(ctx, teamID, userID); err != nil {
+		return nil, err
+	}
+
+	members := team.Users
+	if includeDescendants {
+		members, err = s.repo.GetSubtreeMembers(ctx, teamID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	response := &dto.TeamResponse{
+		TeamID:      team.ID,
+		TeamName:    team.TeamName,
+		ParentID:    team.ParentID,
+		CreatedByID: team.CreatedByID,
+		Members:     make([]dto.TeamMemberResponse, 0, len(members)),
+		CreatedAt:   team.CreatedAt,
+		UpdatedAt:   team.UpdatedAt,
+	}
+	for _, m := range members {
+		teamName := m.Team.TeamName
+		if m.TeamID == team.ID {
+			teamName = team.TeamName
+		}
+		response.Members = append(response.Members, dto.TeamMemberResponse{
+			UserID:    m.UserID,
+			Username:  m.User.Username,
+			Email:     m.User.Email,
+			Role:      string(m.Role),
+			TeamID:    m.TeamID,
+			TeamName:  teamName,
+			Inherited: m.TeamID != team.ID,
+			AddedAt:   m.AddedAt,
+		})
+	}
+
+	return response, nil
+}
+
+func (s *teamService) GetAncestors(ctx context.Context, teamID uuid.UUID, userID uuid.UUID) ([]dto.TeamSummary, error) {
+	if err := s.ensureTeamExists(ctx, teamID); err != nil {
+		return nil, err
+	}
+	if err := s.authorizeView(ctx, teamID, userID); err != nil {
+		return nil, err
+	}
+
+	teams, err := s.repo.GetAncestors(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	return toTeamSummaries(teams), nil
+}
+
+func (s *teamService) GetDescendants(ctx context.Context, teamID uuid.UUID, userID uuid.UUID) ([]dto.TeamSummary, error) {
+	if err := s.ensureTeamExists(ctx, teamID); err != nil {
+		return nil, err
+	}
+	if err := s.authorizeView(ctx, teamID, userID); err != nil {
+		return nil, err
+	}
+
+	teams, err := s.repo.GetDescendants(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	return toTeamSummaries(teams), nil
+}
+
+// GetSubtree builds the nested organization tree rooted at teamID.
+func (s *teamService) GetSubtree(ctx context.Context, teamID uuid.UUID, userID uuid.UUID) (*dto.TeamTreeNode, error) {
+	if err := s.ensureTeamExists(ctx, teamID); err != nil {
+		return nil, err
+	}
+	if err := s.authorizeView(ctx, teamID, userID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.repo.GetSubtree(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, apperror.ErrTeamNotFound
+	}
+
+	// Rows are ordered by depth, so every parent is indexed before its children
+	nodes := make(map[uuid.UUID]*dto.TeamTreeNode, len(rows))
+	var root *dto.TeamTreeNode
+	for _, row := range rows {
+		node := &dto.TeamTreeNode{
+			TeamID:       row.ID,
+			TeamName:     row.TeamName,
+			ParentID:     row.ParentID,
+			Depth:        row.Depth,
+			MemberCount:  row.MemberCount,
+			ManagerCount: row.ManagerCount,
+			Children:     []*dto.TeamTreeNode{},
+		}
+		nodes[row.ID] = node
+
+		if row.Depth == 0 {
+			root = node
+			continue
+		}
+		if parent, ok := nodes[*row.ParentID]; ok {
+			parent.Children = append(parent.Children, node)
+		}
+	}
+
+	sumSubtreeMembers(root)
+	return root, nil
+}
+
+// MoveTeam re-parents a team together with its subtree. The caller needs
+// authority over the team's current position and over the new parent, so a
+// team manager cannot detach a unit from the department that administers it.
+func (s *teamService) MoveTeam(ctx context.Context, teamID uuid.UUID, parentID *uuid.UUID, movedBy uuid.UUID) error {
+	team, err := s.repo.GetTeamByID(ctx, teamID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperror.ErrTeamNotFound
+		}
+		return err
+	}
+
+	current := team.ID
+	if team.ParentID != nil {
+		current = *team.ParentID
+	}
+	if err := s.authorize(ctx, current, movedBy); err != nil {
+		return err
+	}
+
+	if parentID != nil {
+		if *parentID == teamID {
+			return apperror.ErrTeamCycle
+		}
+		if err := s.authorize(ctx, *parentID, movedBy); err != nil {
+			return err
+		}
+	}
+
+	return s.repo.MoveTeam(ctx, teamID, parentID)
+}
+
+func (s *teamService) CanManageTeam(ctx context.Context, teamID uuid.UUID, userID uuid.UUID) (bool, error) {
+	return s.repo.IsTeamManager(ctx, teamID, userID)
+}
+
+// CanViewTeam reports whether the user belongs to the team or manages it.
+func (s *teamService) CanViewTeam(ctx context.Context, teamID uuid.UUID, userID uuid.UUID) (bool, error) {
+	member, err := s.repo.IsTeamMember(ctx, teamID, userID)
+	if err != nil || member {
+		return member, err
+	}
+	return s.repo.IsTeamManager(ctx, teamID, userID)
+}
+
+func (s *teamService) membershipChanged(ctx context.Context, teamID uuid.UUID, userID uuid.UUID, kind event.Kind, role model.UserRole, actorID uuid.UUID) {
+	s.events.MembershipChanged(ctx, event.MembershipEvent{
+		TeamID:  teamID,
+		UserID:  userID,
+		Kind:    kind,
+		Role:    string(role),
+		ActorID: actorID,
+	})
+}
+
+// authorize fails with ErrTeamNotFound for an unknown team and with
+// ErrAccessDenied unless the user manages it.
+func (s *teamService) authorize(ctx context.Context, teamID uuid.UUID, userID uuid.UUID) error {
+	return s.authorizeBulkTeam(ctx, s.repo, teamID, userID)
+}
+
+// authorizeView fails with ErrAccessDenied unless the user can view the team.
+func (s *teamService) authorizeView(ctx context.Context, teamID uuid.UUID, userID uuid.UUID) error {
+	allowed, err := s.CanViewTeam(ctx, teamID, userID)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return apperror.ErrAccessDenied
+	}
+	return nil
+}
+
+func (s *teamService) ensureTeamExists(ctx context.Context, teamID uuid.UUID) error {
+	if _, err := s.repo.GetTeamByID(ctx, teamID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return apperror.ErrTeamNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// errBatchAborted rolls back an atomic bulk batch after a failed operation.
+var errBatchAborted = errors.New("bulk membership batch aborted")
+
+// membershipOpError is a bulk operation failure with its machine-readable code.
+type membershipOpError struct {
+	code string
+	err  error
+}
+
+func (e *membershipOpError) Error() string { return e.err.Error() }
+func (e *membershipOpError) Unwrap() error { return e.err }
+
+// BulkUpdateMembers applies a batch of add, remove and role-change operations
+// across one or more teams and reports a result for every item.
+func (s *teamService) BulkUpdateMembers(ctx context.Context, req *dto.BulkMembershipRequest, actorID uuid.UUID) (*dto.BulkMembershipResult, error) {
+	result := &dto.BulkMembershipResult{
+		Atomic:  req.Atomic,
+		Results: make([]dto.MembershipOperationResult, len(req.Operations)),
+	}
+	for i, op := range req.Operations {
+		result.Results[i] = dto.MembershipOperationResult{
+			Index:  i,
+			Op:     op.Op,
+			TeamID: op.TeamID,
+			UserID: op.UserID,
+		}
+	}
+
+	if !req.Atomic {
+		// Authorization is resolved once per team for the whole batch
+		authorized := map[uuid.UUID]error{}
+		for i, op := range req.Operations {
+			recordOperation(result, i, s.applyOperation(ctx, s.repo, op, actorID, authorized))
+		}
+		result.Applied = result.Succeeded > 0
+		s.publishOperations(ctx, req.Operations, result, actorID)
+		return result, nil
+	}
+
+	failedAt := -1
+	err := s.repo.Transaction(ctx, func(repo repository.TeamRepository) error {
+		authorized := map[uuid.UUID]error{}
+		for i, op := range req.Operations {
+			if err := s.applyOperation(ctx, repo, op, actorID, authorized); err != nil {
+				failedAt = i
+				recordOperation(result, i, err)
+				return errBatchAborted
+			}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errBatchAborted) {
+		return nil, err
+	}
+
+	if failedAt < 0 {
+		for i := range result.Results {
+			recordOperation(result, i, nil)
+		}
+		result.Applied = true
+		s.publishOperations(ctx, req.Operations, result, actorID)
+		return result, nil
+	}
+
+	// Nothing was committed: report what happened to the other items
+	for i := range result.Results {
+		switch {
+		case i < failedAt:
+			result.Results[i].ErrorCode = constant.ErrRolledBack
+			result.Results[i].Message = "rolled back because another operation in the batch failed"
+			result.Failed++
+		case i > failedAt:
+			result.Results[i].ErrorCode = constant.ErrSkipped
+			result.Results[i].Message = "not attempted because another operation in the batch failed"
+			result.Failed++
+		}
+	}
+	return result, nil
+}
+
+// publishOperations publishes a membership event for every operation of a
+// bulk batch that was applied.
+func (s *teamService) publishOperations(ctx context.Context, ops []dto.MembershipOperation, result *dto.BulkMembershipResult, actorID uuid.UUID) {
+	for i, op := range ops {
+		if !result.Results[i].Success {
+			continue
+		}
+		// The IDs parsed when the operation was applied
+		teamID, userID := uuid.MustParse(op.TeamID), uuid.MustParse(op.UserID)
+		switch op.Op {
+		case dto.MembershipOpAdd:
+			role := model.UserRoleMember
+			if op.Role != "" {
+				role = model.UserRole(op.Role)
+			}
+			s.membershipChanged(ctx, teamID, userID, event.KindMemberAdded, role, actorID)
+		case dto.MembershipOpRemove:
+			s.membershipChanged(ctx, teamID, userID, event.KindMemberRemoved, "", actorID)
+		case dto.MembershipOpChangeRole:
+			s.membershipChanged(ctx, teamID, userID, event.KindRoleChanged, model.UserRole(op.Role), actorID)
+		}
+	}
+}
+
+func recordOperation(result *dto.BulkMembershipResult, index int, err error) {
+	item := &result.Results[index]
+	if err == nil {
+		item.Success = true
+		result.Succeeded++
+		return
+	}
+	item.ErrorCode = membershipErrorCode(err)
+	item.Message = err.Error()
+	result.Failed++
+}
+
+func (s *teamService) applyOperation(ctx context.Context, repo repository.TeamRepository, op dto.MembershipOperation, actorID uuid.UUID, authorized map[uuid.UUID]error) error {
+	teamID, err := uuid.Parse(op.TeamID)
+	if err != nil {
+		return &membershipOpError{code: constant.ErrInvalidID, err: errors.New("team ID must be a valid UUID")}
+	}
+	userID, err := uuid.Parse(op.UserID)
+	if err != nil {
+		return &membershipOpError{code: constant.ErrInvalidID, err: errors.New("user ID must be a valid UUID")}
+	}
+
+	authErr, seen := authorized[teamID]
+	if !seen {
+		authErr = s.authorizeBulkTeam(ctx, repo, teamID, actorID)
+		authorized[teamID] = authErr
+	}
+	if authErr != nil {
+		return authErr
+	}
+
+	switch op.Op {
+	case dto.MembershipOpAdd:
+		role := model.UserRoleMember
+		if op.Role != "" {
+			if role, err = parseTeamRole(op.Role); err != nil {
+				return err
+			}
+		}
+		if err := validateExpiry(op.ExpiresAt); err != nil {
+			return err
+		}
+		return repo.AddUserToTeam(ctx, teamID, userID, role, actorID, op.ExpiresAt)
+	case dto.MembershipOpRemove:
+		return repo.RemoveUserFromTeam(ctx, teamID, userID, actorID)
+	case dto.MembershipOpChangeRole:
+		role, err := parseTeamRole(op.Role)
+		if err != nil {
+			return err
+		}
+		return repo.ChangeMemberRole(ctx, teamID, userID, role, actorID)
+	default:
+		return &membershipOpError{code: constant.ErrInvalidOperation, err: errors.New("op must be one of ADD, REMOVE, CHANGE_ROLE")}
+	}
+}
+
+func (s *teamService) authorizeBulkTeam(ctx context.Context, repo repository.TeamRepository, teamID uuid.UUID, actorID uuid.UUID) error {
+	exists, err := repo.TeamExists(ctx, teamID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return apperror.ErrTeamNotFound
+	}
+
+	allowed, err := repo.IsTeamManager(ctx, teamID, actorID)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return apperror.ErrAccessDenied
+	}
+	return nil
+}
+
+func parseTeamRole(role string) (model.UserRole, error) {
+	switch model.UserRole(role) {
+	case model.UserRoleManager, model.UserRoleMember:
+		return model.UserRole(role), nil
+	default:
+		return "", &membershipOpError{code: constant.ErrInvalidRole, err: errors.New("role must be MANAGER or MEMBER")}
+	}
 }
-func (s *teamService) AddManager(ctx context.Context, teamID uuid.UUID, userID uuid.UUID, addedBy uuid.UUID) error {
-	return s.repo.AddManagerToTeam(ctx, teamID, userID, addedBy)
+
+func membershipErrorCode(err error) string {
+	var opErr *membershipOpError
+	switch {
+	case errors.As(err, &opErr):
+		return opErr.code
+	case errors.Is(err, apperror.ErrUserNotFound):
+		return constant.ErrUserNotFound
+	case errors.Is(err, apperror.ErrTeamNotFound):
+		return constant.ErrTeamNotFound
+	case errors.Is(err, apperror.ErrAlreadyMember):
+		return constant.ErrAlreadyMember
+	case errors.Is(err, apperror.ErrMemberNotFound), errors.Is(err, apperror.ErrManagerNotFound):
+		return constant.ErrNotMember
+	case errors.Is(err, apperror.ErrAccessDenied):
+		return constant.ErrForbidden
+	case errors.Is(err, apperror.ErrInvalidExpiry):
+		return constant.ErrInvalidExpiry
+	default:
+		return constant.ErrUnknown
+	}
 }
-func (s *teamService) RemoveMember(ctx context.Context, teamID uuid.UUID, userID uuid.UUID) error {
-	return s.repo.RemoveMemberFromTeam(ctx, teamID, userID)
+
+// GetMembershipAt lists who was on the team at an instant, for a user who
+// can view the team now.
+func (s *teamService) GetMembershipAt(ctx context.Context, teamID uuid.UUID, userID uuid.UUID, at time.Time) ([]dto.TeamMembershipPeriod, error) {
+	if err := s.ensureTeamExists(ctx, teamID); err != nil {
+		return nil, err
+	}
+	if err := s.authorizeView(ctx, teamID, userID); err != nil {
+		return nil, err
+	}
+
+	periods, err := s.repo.GetMembershipAt(ctx, teamID, at)
+	if err != nil {
+		return nil, err
+	}
+	return toMembershipPeriods(periods), nil
 }
-func (s *teamService) RemoveManager(ctx context.Context, teamID uuid.UUID, userID uuid.UUID) error {
-	return s.repo.RemoveManagerFromTeam(ctx, teamID, userID)
+
+// GetTeamMembershipHistory lists the team's membership periods for a user
+// who can view the team now.
+func (s *teamService) GetTeamMembershipHistory(ctx context.Context, teamID uuid.UUID, userID uuid.UUID) ([]dto.TeamMembershipPeriod, error) {
+	if err := s.ensureTeamExists(ctx, teamID); err != nil {
+		return nil, err
+	}
+	if err := s.authorizeView(ctx, teamID, userID); err != nil {
+		return nil, err
+	}
+
+	periods, err := s.repo.GetTeamMembershipHistory(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	return toMembershipPeriods(periods), nil
+}
+
+func (s *teamService) GetUserTeamHistory(ctx context.Context, userID uuid.UUID) ([]dto.TeamMembershipPeriod, error) {
+	periods, err := s.repo.GetUserMembershipHistory(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return toMembershipPeriods(periods), nil
+}
+
+// ExpireMemberships ends every temporary membership that has passed its
+// expiry. It is run periodically by a background job.
+func (s *teamService) ExpireMemberships(ctx context.Context) (int, error) {
+	expired, err := s.repo.ExpireMemberships(ctx, time.Now())
+	for _, m := range expired {
+		s.membershipChanged(ctx, m.TeamID, m.UserID, event.KindMemberExpired, m.Role, uuid.Nil)
+	}
+	return len(expired), err
+}
+
+func validateExpiry(expiresAt *time.Time) error {
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return apperror.ErrInvalidExpiry
+	}
+	return nil
+}
+
+func toMembershipPeriods(periods []model.TeamMembershipPeriod) []dto.TeamMembershipPeriod {
+	result := make([]dto.TeamMembershipPeriod, len(periods))
+	for i, p := range periods {
+		result[i] = dto.TeamMembershipPeriod{
+			TeamID:      p.TeamID,
+			TeamName:    p.Team.TeamName,
+			UserID:      p.UserID,
+			Username:    p.User.Username,
+			Role:        string(p.Role),
+			JoinedAt:    p.JoinedAt,
+			LeftAt:      p.LeftAt,
+			ExpiresAt:   p.ExpiresAt,
+			AddedByID:   p.AddedByID,
+			RemovedByID: p.RemovedByID,
+			EndReason:   string(p.EndReason),
+		}
+	}
+	return result
+}
+
+func toTeamSummaries(teams []model.Team) []dto.TeamSummary {
+	result := make([]dto.TeamSummary, len(teams))
+	for i, team := range teams {
+		result[i] = dto.TeamSummary{
+			TeamID:   team.ID,
+			TeamName: team.TeamName,
+			ParentID: team.ParentID,
+		}
+	}
+	return result
+}
+
+func sumSubtreeMembers(node *dto.TeamTreeNode) int64 {
+	total := node.MemberCount + node.ManagerCount
+	for _, child := range node.Children {
+		total += sumSubtreeMembers(child)
+	}
+	node.SubtreeMemberCount = total
+	return total
 }
@@ -0,0 +1,603 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-training-system/internal/dto"
+	"go-training-system/internal/graph/apperror"
+	"go-training-system/internal/model"
+	"go-training-system/internal/repository"
+	"go-training-system/pkg/notetemplate"
+
+	"github.com/google/uuid"
+)
+
+// builtinPlaceholders are the values every template can use besides its own
+// fields. team.name is only available in team templates.
+var builtinPlaceholders = map[string]bool{
+	"date":          true,
+	"time":          true,
+	"datetime":      true,
+	"user.username": true,
+	"user.email":    true,
+	"team.name":     true,
+	"folder.name":   true,
+}
+
+// TemplateService manages personal and team note templates and creates notes
+// from them. The owner of a template can do anything with it, and the
+// managers of a team template's team can edit and delete it. Everyone else
+// gets access the way they do to folders, through user and team shares; in
+// addition, current members of a template's team can use it.
+type TemplateService interface {
+	CreateTemplate(ctx context.Context, req *dto.CreateTemplateRequest, ownerID uuid.UUID) (*dto.TemplateResponse, error)
+	GetTemplate(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*dto.TemplateResponse, error)
+	ListTemplates(ctx context.Context, userID uuid.UUID) ([]dto.TemplateResponse, error)
+	UpdateTemplate(ctx context.Context, id uuid.UUID, req *dto.UpdateTemplateRequest, userID uuid.UUID, expectedVersion int64) (*dto.TemplateResponse, error)
+	DeleteTemplate(ctx context.Context, id uuid.UUID, userID uuid.UUID, expectedVersion int64) error
+	CreateNoteFromTemplate(ctx context.Context, id uuid.UUID, req *dto.CreateFromTemplateRequest, userID uuid.UUID) (*dto.NoteResponse, error)
+	ShareTemplate(ctx context.Context, templateID uuid.UUID, req *dto.ShareRequest, sharedByID uuid.UUID) error
+	GetTemplateShares(ctx context.Context, templateID uuid.UUID, userID uuid.UUID) ([]dto.ShareResponse, error)
+	UpdateTemplateShare(ctx context.Context, templateID uuid.UUID, userID uuid.UUID, req *dto.UpdateShareRequest, updatedByID uuid.UUID) error
+	RevokeTemplateShare(ctx context.Context, templateID uuid.UUID, userID uuid.UUID, revokedByID uuid.UUID) error
+	ShareTemplateWithTeam(ctx context.Context, templateID uuid.UUID, req *dto.TeamShareRequest, sharedByID uuid.UUID) error
+	GetTemplateTeamShares(ctx context.Context, templateID uuid.UUID, userID uuid.UUID) ([]dto.TeamShareResponse, error)
+	RevokeTemplateTeamShare(ctx context.Context, templateID uuid.UUID, teamID uuid.UUID, userID uuid.UUID) error
+}
+
+type templateService struct {
+	templateRepo  repository.TemplateRepository
+	shareRepo     repository.ShareRepository
+	teamShareRepo repository.TeamShareRepository
+	teamRepo      repository.TeamRepository
+	userRepo      repository.UserRepository
+	folderRepo    repository.FolderRepository
+	noteService   NoteService
+}
+
+func NewTemplateService(templateRepo repository.TemplateRepository, shareRepo repository.ShareRepository, teamShareRepo repository.TeamShareRepository, teamRepo repository.TeamRepository, userRepo repository.UserRepository, folderRepo repository.FolderRepository, noteService NoteService) TemplateService {
+	return &templateService{
+		templateRepo:  templateRepo,
+		shareRepo:     shareRepo,
+		teamShareRepo: teamShareRepo,
+		teamRepo:      teamRepo,
+		userRepo:      userRepo,
+		folderRepo:    folderRepo,
+		noteService:   noteService,
+	}
+}
+
+// CreateTemplate creates a personal template, or a team template when the
+// request names a team the user manages.
+func (s *templateService) CreateTemplate(ctx context.Context, req *dto.CreateTemplateRequest, ownerID uuid.UUID) (*dto.TemplateResponse, error) {
+	if req.TeamID != nil {
+		isManager, err := s.teamRepo.IsTeamManager(ctx, *req.TeamID, ownerID)
+		if err != nil {
+			return nil, err
+		}
+		if !isManager {
+			return nil, apperror.ErrAccessDenied
+		}
+	}
+
+	template := &model.NoteTemplate{OwnerID: ownerID, TeamID: req.TeamID}
+	if err := setTemplateContent(template, req.Name, req.Description, req.Title, req.Body, req.Fields); err != nil {
+		return nil, err
+	}
+
+	if err := s.templateRepo.Create(ctx, template); err != nil {
+		return nil, err
+	}
+
+	return toTemplateResponse(template), nil
+}
+
+func (s *templateService) GetTemplate(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*dto.TemplateResponse, error) {
+	template, err := s.templateRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	access, err := s.templateAccess(ctx, template, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !canRead(access) {
+		return nil, apperror.ErrAccessDenied
+	}
+
+	return toTemplateResponse(template), nil
+}
+
+// ListTemplates returns every template the user can use, by name.
+func (s *templateService) ListTemplates(ctx context.Context, userID uuid.UUID) ([]dto.TemplateResponse, error) {
+	templates, err := s.templateRepo.GetVisibleToUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]dto.TemplateResponse, len(templates))
+	for i := range templates {
+		response[i] = *toTemplateResponse(&templates[i])
+	}
+
+	return response, nil
+}
+
+func (s *templateService) UpdateTemplate(ctx context.Context, id uuid.UUID, req *dto.UpdateTemplateRequest, userID uuid.UUID, expectedVersion int64) (*dto.TemplateResponse, error) {
+	template, err := s.templateRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	access, err := s.templateAccess(ctx, template, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !canWrite(access) {
+		return nil, apperror.ErrAccessDenied
+	}
+
+	if err := setTemplateContent(template, req.Name, req.Description, req.Title, req.Body, req.Fields); err != nil {
+		return nil, err
+	}
+
+	if err := s.templateRepo.Update(ctx, template, expectedVersion); err != nil {
+		return nil, err
+	}
+
+	return toTemplateResponse(template), nil
+}
+
+// DeleteTemplate deletes a template with its shares. Only the owner and, for
+// team templates, the team's managers can delete it.
+func (s *templateService) DeleteTemplate(ctx context.Context, id uuid.UUID, userID uuid.UUID, expectedVersion int64) error {
+	template, err := s.templateRepo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if template.OwnerID != userID {
+		isManager, err := s.managesTeam(ctx, template, userID)
+		if err != nil {
+			return err
+		}
+		if !isManager {
+			return apperror.ErrAccessDenied
+		}
+	}
+
+	return s.templateRepo.Delete(ctx, id, expectedVersion)
+}
+
+// CreateNoteFromTemplate renders a template and creates a note from it in
+// the requested folder, which the user needs write access to. A title that
+// renders empty falls back to the template's name.
+func (s *templateService) CreateNoteFromTemplate(ctx context.Context, id uuid.UUID, req *dto.CreateFromTemplateRequest, userID uuid.UUID) (*dto.NoteResponse, error) {
+	template, err := s.templateRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	access, err := s.templateAccess(ctx, template, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !canRead(access) {
+		return nil, apperror.ErrAccessDenied
+	}
+
+	values, err := s.templateValues(ctx, template, req, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	title := strings.TrimSpace(notetemplate.Render(template.Title, values))
+	if title == "" {
+		title = template.Name
+	}
+
+	return s.noteService.CreateNote(ctx, &dto.CreateNoteRequest{
+		Title:    title,
+		Body:     notetemplate.Render(template.Body, values),
+		FolderID: req.FolderID,
+	}, userID)
+}
+
+func (s *templateService) ShareTemplate(ctx context.Context, templateID uuid.UUID, req *dto.ShareRequest, sharedByID uuid.UUID) error {
+	template, err := s.templateRepo.GetByID(ctx, templateID)
+	if err != nil {
+		return err
+	}
+
+	isOwner, err := s.authorizeTemplateShare(ctx, template, sharedByID)
+	if err != nil {
+		return err
+	}
+	if req.Override {
+		return apperror.ErrInvalidOverride
+	}
+	if req.ExpiresAt != nil {
+		return apperror.ErrExpiryNotSupported
+	}
+	if err := validateAccess(req.Access); err != nil {
+		return err
+	}
+	if req.UserID == sharedByID {
+		return apperror.ErrSelfShare
+	}
+	if req.UserID == template.OwnerID {
+		return apperror.ErrShareWithOwner
+	}
+	if _, err := s.userRepo.FindByID(ctx, req.UserID.String()); err != nil {
+		return err
+	}
+
+	// Re-sharers can add new shares but not take over ones granted by
+	// someone else, and cannot pass the re-share right on.
+	if !isOwner {
+		if req.AllowReshare {
+			return apperror.ErrAccessDenied
+		}
+		existing, err := s.shareRepo.GetTemplateShare(ctx, templateID, req.UserID)
+		if err != nil && !errors.Is(err, apperror.ErrShareNotFound) {
+			return err
+		}
+		if existing != nil && existing.SharedByID != sharedByID {
+			return apperror.ErrAccessDenied
+		}
+	}
+
+	return s.shareRepo.ShareTemplate(ctx, &model.TemplateShare{
+		TemplateID:   templateID,
+		UserID:       req.UserID,
+		Access:       req.Access,
+		AllowReshare: req.AllowReshare,
+		SharedAt:     time.Now(),
+		SharedByID:   sharedByID,
+	})
+}
+
+func (s *templateService) GetTemplateShares(ctx context.Context, templateID uuid.UUID, userID uuid.UUID) ([]dto.ShareResponse, error) {
+	template, err := s.templateRepo.GetByID(ctx, templateID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.authorizeTemplateShare(ctx, template, userID); err != nil {
+		return nil, err
+	}
+
+	shares, err := s.shareRepo.GetTemplateShares(ctx, templateID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]dto.ShareResponse, len(shares))
+	for i, share := range shares {
+		response[i] = dto.ShareResponse{
+			UserID:       share.UserID,
+			Username:     share.User.Username,
+			Access:       share.Access,
+			AllowReshare: share.AllowReshare,
+			SharedByID:   share.SharedByID,
+			SharedAt:     share.SharedAt,
+		}
+	}
+
+	return response, nil
+}
+
+func (s *templateService) UpdateTemplateShare(ctx context.Context, templateID uuid.UUID, userID uuid.UUID, req *dto.UpdateShareRequest, updatedByID uuid.UUID) error {
+	template, err := s.templateRepo.GetByID(ctx, templateID)
+	if err != nil {
+		return err
+	}
+
+	isOwner, err := s.authorizeTemplateShare(ctx, template, updatedByID)
+	if err != nil {
+		return err
+	}
+	if req.Override != nil && *req.Override {
+		return apperror.ErrInvalidOverride
+	}
+	if req.ExpiresAt != nil || req.RemoveExpiry {
+		return apperror.ErrExpiryNotSupported
+	}
+	if err := validateAccess(req.Access); err != nil {
+		return err
+	}
+
+	share, err := s.shareRepo.GetTemplateShare(ctx, templateID, userID)
+	if err != nil {
+		return err
+	}
+	if !isOwner && (share.SharedByID != updatedByID || req.AllowReshare != nil) {
+		return apperror.ErrAccessDenied
+	}
+
+	share.Access = req.Access
+	if req.AllowReshare != nil {
+		share.AllowReshare = *req.AllowReshare
+	}
+	return s.shareRepo.ShareTemplate(ctx, share)
+}
+
+// RevokeTemplateShare removes a user's share. The owner can revoke any share,
+// a re-sharer only the ones they granted, and any user can drop their own.
+func (s *templateService) RevokeTemplateShare(ctx context.Context, templateID uuid.UUID, userID uuid.UUID, revokedByID uuid.UUID) error {
+	template, err := s.templateRepo.GetByID(ctx, templateID)
+	if err != nil {
+		return err
+	}
+
+	if userID != revokedByID {
+		isOwner, err := s.authorizeTemplateShare(ctx, template, revokedByID)
+		if err != nil {
+			return err
+		}
+		if !isOwner {
+			share, err := s.shareRepo.GetTemplateShare(ctx, templateID, userID)
+			if err != nil {
+				return err
+			}
+			if share.SharedByID != revokedByID {
+				return apperror.ErrAccessDenied
+			}
+		}
+	}
+
+	return s.shareRepo.RevokeTemplateShare(ctx, templateID, userID)
+}
+
+func (s *templateService) ShareTemplateWithTeam(ctx context.Context, templateID uuid.UUID, req *dto.TeamShareRequest, sharedByID uuid.UUID) error {
+	template, err := s.templateRepo.GetByID(ctx, templateID)
+	if err != nil {
+		return err
+	}
+
+	if template.OwnerID != sharedByID {
+		return apperror.ErrAccessDenied
+	}
+
+	if err := validateAccess(req.Access); err != nil {
+		return err
+	}
+
+	return s.teamShareRepo.ShareTemplate(ctx, &model.TemplateTeamShare{
+		TemplateID:   templateID,
+		TeamID:       req.TeamID,
+		Access:       req.Access,
+		ManagersOnly: req.ManagersOnly,
+		SharedAt:     time.Now(),
+		SharedByID:   sharedByID,
+	})
+}
+
+func (s *templateService) GetTemplateTeamShares(ctx context.Context, templateID uuid.UUID, userID uuid.UUID) ([]dto.TeamShareResponse, error) {
+	template, err := s.templateRepo.GetByID(ctx, templateID)
+	if err != nil {
+		return nil, err
+	}
+
+	if template.OwnerID != userID {
+		return nil, apperror.ErrAccessDenied
+	}
+
+	shares, err := s.teamShareRepo.GetTemplateShares(ctx, templateID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]dto.TeamShareResponse, len(shares))
+	for i, share := range shares {
+		response[i] = dto.TeamShareResponse{
+			TeamID:       share.TeamID,
+			TeamName:     share.Team.TeamName,
+			Access:       share.Access,
+			ManagersOnly: share.ManagersOnly,
+			SharedByID:   share.SharedByID,
+			SharedAt:     share.SharedAt,
+		}
+	}
+
+	return response, nil
+}
+
+func (s *templateService) RevokeTemplateTeamShare(ctx context.Context, templateID uuid.UUID, teamID uuid.UUID, userID uuid.UUID) error {
+	template, err := s.templateRepo.GetByID(ctx, templateID)
+	if err != nil {
+		return err
+	}
+
+	if template.OwnerID != userID {
+		return apperror.ErrAccessDenied
+	}
+
+	return s.teamShareRepo.RevokeTemplateShare(ctx, templateID, teamID)
+}
+
+// templateAccess works out the user's access to a template: write for the
+// owner and the managers of its team, otherwise the most permissive of the
+// user's shares, with read for current members of its team.
+func (s *templateService) templateAccess(ctx context.Context, template *model.NoteTemplate, userID uuid.UUID) (model.AccessLevel, error) {
+	if template.OwnerID == userID {
+		return model.AccessLevelWrite, nil
+	}
+	isManager, err := s.managesTeam(ctx, template, userID)
+	if err != nil {
+		return model.AccessLevelNone, err
+	}
+	if isManager {
+		return model.AccessLevelWrite, nil
+	}
+
+	grants, err := s.templateRepo.GetGrants(ctx, template.ID, userID)
+	if err != nil {
+		return model.AccessLevelNone, err
+	}
+	if grants == nil {
+		return model.AccessLevelNone, apperror.ErrTemplateNotFound
+	}
+	member := model.AccessLevelNone
+	if grants.TeamMember {
+		member = model.AccessLevelRead
+	}
+	return mostPermissive(grants.User, grants.Team, member), nil
+}
+
+// managesTeam reports whether the template is a team template and the user
+// manages its team.
+func (s *templateService) managesTeam(ctx context.Context, template *model.NoteTemplate, userID uuid.UUID) (bool, error) {
+	if template.TeamID == nil {
+		return false, nil
+	}
+	return s.teamRepo.IsTeamManager(ctx, *template.TeamID, userID)
+}
+
+// authorizeTemplateShare reports whether the user may manage shares on the
+// template: the owner always can, and a write-share holder can when the owner
+// allowed re-sharing. isOwner tells the two apart.
+func (s *templateService) authorizeTemplateShare(ctx context.Context, template *model.NoteTemplate, userID uuid.UUID) (isOwner bool, err error) {
+	if template.OwnerID == userID {
+		return true, nil
+	}
+	share, err := s.shareRepo.GetTemplateShare(ctx, template.ID, userID)
+	if errors.Is(err, apperror.ErrShareNotFound) {
+		return false, apperror.ErrAccessDenied
+	}
+	if err != nil {
+		return false, err
+	}
+	if share.Access != model.AccessLevelWrite || !share.AllowReshare {
+		return false, apperror.ErrAccessDenied
+	}
+	return false, nil
+}
+
+// templateValues collects the value of every placeholder the template can
+// use: the built-in ones and its custom fields.
+func (s *templateService) templateValues(ctx context.Context, template *model.NoteTemplate, req *dto.CreateFromTemplateRequest, userID uuid.UUID) (map[string]string, error) {
+	loc := time.UTC
+	if req.TimeZone != "" {
+		var err error
+		if loc, err = time.LoadLocation(req.TimeZone); err != nil {
+			return nil, fmt.Errorf("%w: %s", apperror.ErrInvalidTimeZone, req.TimeZone)
+		}
+	}
+	now := time.Now().In(loc)
+
+	user, err := s.userRepo.FindByID(ctx, userID.String())
+	if err != nil {
+		return nil, err
+	}
+	folder, err := s.folderRepo.GetByID(ctx, req.FolderID)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{
+		"date":          now.Format("2006-01-02"),
+		"time":          now.Format("15:04"),
+		"datetime":      now.Format("2006-01-02 15:04"),
+		"user.username": user.Username,
+		"user.email":    user.Email,
+		"folder.name":   folder.Name,
+	}
+	if template.TeamID != nil {
+		team, err := s.teamRepo.GetTeamByID(ctx, *template.TeamID)
+		if err != nil {
+			return nil, apperror.ErrTeamNotFound
+		}
+		values["team.name"] = team.TeamName
+	}
+
+	for _, field := range template.Fields {
+		value, ok := req.Values[field.Name]
+		if !ok {
+			value = field.Default
+		}
+		if field.Required && strings.TrimSpace(value) == "" {
+			return nil, fmt.Errorf("%w: %s", apperror.ErrTemplateValueMissing, field.Name)
+		}
+		values[field.Name] = value
+	}
+	return values, nil
+}
+
+// setTemplateContent validates a template's content and fields and sets
+// them on template. Every placeholder must be built in or one of the fields.
+func setTemplateContent(template *model.NoteTemplate, name, description, title, body string, fields []dto.TemplateField) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf("%w: name is required", apperror.ErrInvalidTemplate)
+	}
+	if strings.TrimSpace(title) == "" {
+		return fmt.Errorf("%w: title is required", apperror.ErrInvalidTemplate)
+	}
+
+	custom := make([]model.TemplateField, len(fields))
+	defined := make(map[string]bool, len(fields))
+	for i, field := range fields {
+		if !notetemplate.ValidFieldName(field.Name) {
+			return fmt.Errorf("%w: field name %q must be lowercase letters, digits and underscores", apperror.ErrInvalidTemplate, field.Name)
+		}
+		if defined[field.Name] {
+			return fmt.Errorf("%w: duplicate field %q", apperror.ErrInvalidTemplate, field.Name)
+		}
+		defined[field.Name] = true
+		custom[i] = model.TemplateField{
+			TemplateID: template.ID,
+			Name:       field.Name,
+			Label:      field.Label,
+			Default:    field.Default,
+			Required:   field.Required,
+			Position:   i,
+		}
+	}
+
+	for _, used := range notetemplate.Placeholders(title + "\n" + body) {
+		if used == "team.name" && template.TeamID == nil {
+			return fmt.Errorf("%w: {{team.name}} is only available in team templates", apperror.ErrInvalidTemplate)
+		}
+		if !builtinPlaceholders[used] && !defined[used] {
+			return fmt.Errorf("%w: unknown placeholder {{%s}}", apperror.ErrInvalidTemplate, used)
+		}
+	}
+
+	template.Name = name
+	template.Description = description
+	template.Title = title
+	template.Body = body
+	template.Fields = custom
+	return nil
+}
+
+func toTemplateResponse(template *model.NoteTemplate) *dto.TemplateResponse {
+	fields := make([]dto.TemplateField, len(template.Fields))
+	for i, field := range template.Fields {
+		fields[i] = dto.TemplateField{
+			Name:     field.Name,
+			Label:    field.Label,
+			Default:  field.Default,
+			Required: field.Required,
+		}
+	}
+	return &dto.TemplateResponse{
+		ID:          template.ID,
+		Name:        template.Name,
+		Description: template.Description,
+		Title:       template.Title,
+		Body:        template.Body,
+		OwnerID:     template.OwnerID,
+		TeamID:      template.TeamID,
+		Fields:      fields,
+		Version:     template.Version,
+		CreatedAt:   template.CreatedAt,
+		UpdatedAt:   template.UpdatedAt,
+	}
+}
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"go-training-system/internal/dto"
+	"go-training-system/internal/event"
+	"go-training-system/internal/graph/apperror"
+	"go-training-system/internal/repository"
+	"go-training-system/pkg/blobstore"
+
+	"github.com/google/uuid"
+)
+
+// TrashService lets owners see, restore and permanently delete their deleted
+// folders and notes, whoever deleted them. Deleted items keep their shares,
+// so restoring them gives everyone back the access they had. Items are purged for good once
+// they have been in the trash for the retention period.
+type TrashService interface {
+	ListTrash(ctx context.Context, userID uuid.UUID) ([]dto.TrashItemResponse, error)
+	RestoreFolder(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*dto.FolderResponse, error)
+	RestoreNote(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*dto.NoteResponse, error)
+	PurgeFolder(ctx context.Context, id uuid.UUID, userID uuid.UUID) error
+	PurgeNote(ctx context.Context, id uuid.UUID, userID uuid.UUID) error
+	PurgeExpired(ctx context.Context) (int, error)
+}
+
+type trashService struct {
+	trashRepo      repository.TrashRepository
+	attachmentRepo repository.AttachmentRepository
+	blobs          blobstore.BlobStore
+	retention      time.Duration
+	events         *event.Bus
+}
+
+func NewTrashService(trashRepo repository.TrashRepository, attachmentRepo repository.AttachmentRepository, blobs blobstore.BlobStore, retention time.Duration, events *event.Bus) TrashService {
+	return &trashService{
+		trashRepo:      trashRepo,
+		attachmentRepo: attachmentRepo,
+		blobs:          blobs,
+		retention:      retention,
+		events:         events,
+	}
+}
+
+// ListTrash returns the user's deleted folders and notes, most recently
+// deleted first. Contents of a deleted folder are not listed separately.
+func (s *trashService) ListTrash(ctx context.Context, userID uuid.UUID) ([]dto.TrashItemResponse, error) {
+	items, err := s.trashRepo.GetTrash(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]dto.TrashItemResponse, len(items))
+	for i, item := range items {
+		response[i] = dto.TrashItemResponse{
+			Type:      item.Type,
+			ID:        item.ID,
+			Name:      item.Name,
+			ParentID:  item.ParentID,
+			DeletedAt: item.DeletedAt,
+			PurgeAt:   item.DeletedAt.Add(s.retention),
+		}
+	}
+	return response, nil
+}
+
+// RestoreFolder restores a deleted folder with everything deleted together
+// with it. Deleted parent folders are restored too, so it lands where it was.
+func (s *trashService) RestoreFolder(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*dto.FolderResponse, error) {
+	folder, err := s.trashRepo.GetDeletedFolder(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if folder.OwnerID != userID {
+		return nil, apperror.ErrAccessDenied
+	}
+	if folder.ParentID != nil {
+		if err := s.checkDeletedAncestors(ctx, *folder.ParentID, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.trashRepo.RestoreFolder(ctx, folder); err != nil {
+		return nil, err
+	}
+	s.events.FolderChanged(ctx, folder.ID, event.KindRestored, userID, folder.ParentID)
+
+	return &dto.FolderResponse{
+		ID:          folder.ID,
+		Name:        folder.Name,
+		Description: folder.Description,
+		ParentID:    folder.ParentID,
+		OwnerID:     folder.OwnerID,
+		Version:     folder.Version,
+		CreatedAt:   folder.CreatedAt,
+		UpdatedAt:   folder.UpdatedAt,
+	}, nil
+}
+
+// RestoreNote restores a deleted note, and its folder if that was deleted.
+func (s *trashService) RestoreNote(ctx context.Context, id uuid.UUID, userID uuid.UUID) (*dto.NoteResponse, error) {
+	note, err := s.trashRepo.GetDeletedNote(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if note.OwnerID != userID {
+		return nil, apperror.ErrAccessDenied
+	}
+	if err := s.checkDeletedAncestors(ctx, note.FolderID, userID); err != nil {
+		return nil, err
+	}
+
+	if err := s.trashRepo.RestoreNote(ctx, note); err != nil {
+		return nil, err
+	}
+	s.events.NoteChanged(ctx, event.NoteEvent{
+		NoteID:   note.ID,
+		FolderID: note.FolderID,
+		Kind:     event.KindRestored,
+		Version:  note.Version,
+		ActorID:  userID,
+	})
+
+	return &dto.NoteResponse{
+		ID:        note.ID,
+		Title:     note.Title,
+		Body:      note.Body,
+		FolderID:  note.FolderID,
+		OwnerID:   note.OwnerID,
+		Version:   note.Version,
+		CreatedAt: note.CreatedAt,
+		UpdatedAt: note.UpdatedAt,
+	}, nil
+}
+
+// PurgeFolder permanently deletes a folder in the trash with all of its
+// contents.
+func (s *trashService) PurgeFolder(ctx context.Context, id uuid.UUID, userID uuid.UUID) error {
+	folder, err := s.trashRepo.GetDeletedFolder(ctx, id)
+	if err != nil {
+		return err
+	}
+	if folder.OwnerID != userID {
+		return apperror.ErrAccessDenied
+	}
+
+	set, err := s.trashRepo.FolderPurgeSet(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.purge(ctx, set)
+}
+
+func (s *trashService) PurgeNote(ctx context.Context, id uuid.UUID, userID uuid.UUID) error {
+	note, err := s.trashRepo.GetDeletedNote(ctx, id)
+	if err != nil {
+		return err
+	}
+	if note.OwnerID != userID {
+		return apperror.ErrAccessDenied
+	}
+
+	return s.purge(ctx, &repository.PurgeSet{NoteIDs: []uuid.UUID{id}})
+}
+
+// PurgeExpired permanently deletes everything that has been in the trash for
+// longer than the retention period. It is run periodically by a background
+// job.
+func (s *trashService) PurgeExpired(ctx context.Context) (int, error) {
+	set, err := s.trashRepo.ExpiredPurgeSet(ctx, time.Now().Add(-s.retention))
+	if err != nil {
+		return 0, err
+	}
+	if err := s.purge(ctx, set); err != nil {
+		return 0, err
+	}
+	return len(set.FolderIDs) + len(set.NoteIDs), nil
+}
+
+// purge deletes the attachments of the notes in set, releasing their blobs,
+// and then the folders and notes themselves.
+func (s *trashService) purge(ctx context.Context, set *repository.PurgeSet) error {
+	if len(set.FolderIDs) == 0 && len(set.NoteIDs) == 0 {
+		return nil
+	}
+
+	attachments, err := s.attachmentRepo.GetByNoteIDs(ctx, set.NoteIDs)
+	if err != nil {
+		return err
+	}
+	for _, attachment := range attachments {
+		err := s.attachmentRepo.Delete(ctx, attachment.ID, func(sha256 string) error {
+			return s.blobs.Delete(ctx, sha256)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.trashRepo.Purge(ctx, set)
+}
+
+// checkDeletedAncestors makes sure every deleted folder that restoring into
+// folderID would bring back belongs to the user.
+func (s *trashService) checkDeletedAncestors(ctx context.Context, folderID uuid.UUID, userID uuid.UUID) error {
+	ancestors, err := s.trashRepo.GetDeletedAncestors(ctx, folderID)
+	if err != nil {
+		return err
+	}
+	for _, folder := range ancestors {
+		if folder.OwnerID != userID {
+			return apperror.ErrParentInTrash
+		}
+	}
+	return nil
+}
@@ -8,12 +8,15 @@ import (
 	"go-training-system/internal/model"
 	"go-training-system/internal/repository"
 	"go-training-system/pkg/hash"
+
+	"github.com/google/uuid"
 )
 
 type UserService interface {
 	Register(ctx context.Context, input *gqlmodel.CreateUserInput) (*model.User, error)
 	Update(ctx context.Context, userID string, input *gqlmodel.UpdateUserInput) (*model.User, error)
 	GetByID(ctx context.Context, userID string) (*model.User, error)
+	GetByIDs(ctx context.Context, userIDs []uuid.UUID) ([]model.User, error)
 	GetByEmail(ctx context.Context, email string) (*model.User, error)
 	GetAll(ctx context.Context, role *gqlmodel.UserType) ([]*model.User, error)
 	Login(ctx context.Context, input *gqlmodel.UserInput) (*model.User, error)
@@ -69,6 +72,11 @@ func (s *userService) GetByID(ctx context.Context, userID string) (*model.User,
 	return s.repo.FindByID(ctx, userID)
 }
 
+// GetByIDs returns the users with the given IDs, leaving out unknown ones
+func (s *userService) GetByIDs(ctx context.Context, userIDs []uuid.UUID) ([]model.User, error) {
+	return s.repo.FindByIDs(ctx, userIDs)
+}
+
 // GetByEmail returns a user by email
 func (s *userService) GetByEmail(ctx context.Context, email string) (*model.User, error) {
 	return s.repo.FindByEmail(ctx, email)
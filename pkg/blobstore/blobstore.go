@@ -0,0 +1,42 @@
+// Package blobstore stores immutable binary objects under caller-chosen keys.
+// Keys are opaque to the store; callers that address content by hash get
+// deduplication for free, since writing an existing key is never needed.
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore is implemented by Local, for a directory on disk, and S3, for
+// any S3-compatible object store.
+type BlobStore interface {
+	// Put stores size bytes read from r under key, replacing any blob
+	// already there.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get opens the blob stored under key. The returned reader can seek, so
+	// callers can serve byte ranges without reading the whole blob.
+	Get(ctx context.Context, key string) (io.ReadSeekCloser, error)
+	Exists(ctx context.Context, key string) (bool, error)
+	// Delete removes the blob under key. Deleting a missing blob is not an
+	// error.
+	Delete(ctx context.Context, key string) error
+}
+
+// checkKey rejects keys that could escape a directory or bucket prefix.
+// Keys may only contain ASCII letters, digits, '-' and '_'.
+func checkKey(key string) error {
+	if key == "" {
+		return errors.New("blob key is empty")
+	}
+	for _, c := range key {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return fmt.Errorf("invalid blob key %q", key)
+		}
+	}
+	return nil
+}
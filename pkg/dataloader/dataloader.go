@@ -0,0 +1,114 @@
+// Package dataloader batches and caches lookups by key. Loads made within a
+// short wait of each other are fetched together in one call, and every key
+// is fetched at most once for the life of the loader, so a loader should live
+// for a single request.
+package dataloader
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// FetchFunc fetches the values for a batch of distinct keys. Keys missing
+// from the result load as the zero value.
+type FetchFunc[K comparable, V any] func(ctx context.Context, keys []K) (map[K]V, error)
+
+type result[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+type batch[K comparable, V any] struct {
+	keys    []K
+	results []*result[V]
+}
+
+// Loader batches the loads of values of type V by keys of type K.
+type Loader[K comparable, V any] struct {
+	ctx      context.Context
+	fetch    FetchFunc[K, V]
+	wait     time.Duration
+	maxBatch int
+
+	mu      sync.Mutex
+	cache   map[K]*result[V]
+	pending *batch[K, V]
+}
+
+// New returns a loader that fetches with ctx, collecting keys for up to wait
+// before fetching them, or fewer once maxBatch keys are waiting. A maxBatch
+// of 0 or less does not limit the batch size.
+func New[K comparable, V any](ctx context.Context, fetch FetchFunc[K, V], wait time.Duration, maxBatch int) *Loader[K, V] {
+	return &Loader[K, V]{
+		ctx:      ctx,
+		fetch:    fetch,
+		wait:     wait,
+		maxBatch: maxBatch,
+		cache:    make(map[K]*result[V]),
+	}
+}
+
+// Load returns the value for key, waiting for the batch it joins to be
+// fetched. A failed fetch fails every load in its batch.
+func (l *Loader[K, V]) Load(ctx context.Context, key K) (V, error) {
+	l.mu.Lock()
+	res, ok := l.cache[key]
+	if !ok {
+		res = &result[V]{done: make(chan struct{})}
+		l.cache[key] = res
+		l.enqueue(key, res)
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-res.done:
+		return res.value, res.err
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+// enqueue adds key to the pending batch, starting one if there is none. The
+// caller holds l.mu.
+func (l *Loader[K, V]) enqueue(key K, res *result[V]) {
+	b := l.pending
+	if b == nil {
+		b = &batch[K, V]{}
+		l.pending = b
+		time.AfterFunc(l.wait, func() { l.dispatch(b) })
+	}
+	b.keys = append(b.keys, key)
+	b.results = append(b.results, res)
+	if l.maxBatch > 0 && len(b.keys) >= l.maxBatch {
+		l.pending = nil
+		go l.run(b)
+	}
+}
+
+// dispatch fetches the batch when its wait is over, unless it was already
+// fetched for being full.
+func (l *Loader[K, V]) dispatch(b *batch[K, V]) {
+	l.mu.Lock()
+	if l.pending != b {
+		l.mu.Unlock()
+		return
+	}
+	l.pending = nil
+	l.mu.Unlock()
+	l.run(b)
+}
+
+func (l *Loader[K, V]) run(b *batch[K, V]) {
+	values, err := l.fetch(l.ctx, b.keys)
+	for i, res := range b.results {
+		if err != nil {
+			res.err = err
+		} else {
+			res.value = values[b.keys[i]]
+		}
+		close(res.done)
+	}
+}
@@ -2,7 +2,7 @@ package helper
 
 import "github.com/gin-gonic/gin"
 
-func respondError(c *gin.Context, status int, code string, message string, err error) {
+func RespondError(c *gin.Context, status int, code string, message string, err error) {
 	resp := gin.H{
 		"code":    code,
 		"success": false,
@@ -14,7 +14,7 @@ func respondError(c *gin.Context, status int, code string, message string, err e
 	c.JSON(status, resp)
 }
 
-func respondSuccess(c *gin.Context, status int, code string, message string, data interface{}) {
+func RespondSuccess(c *gin.Context, status int, code string, message string, data interface{}) {
 	resp := gin.H{
 		"code":    code,
 		"success": true,
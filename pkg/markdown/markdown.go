@@ -0,0 +1,92 @@
+// Package markdown renders note bodies written in CommonMark, with GitHub
+// flavoured tables, task lists, strikethrough and autolinks, to HTML that is
+// safe to embed in a page. Raw HTML in the source is kept but goes through
+// the same sanitizer as the rendered output, so scripts, event handlers and
+// javascript: URLs never survive.
+package markdown
+
+import (
+	"bytes"
+	"regexp"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+// Renderer converts markdown to sanitized HTML. It is safe for concurrent
+// use.
+type Renderer struct {
+	md     goldmark.Markdown
+	policy *bluemonday.Policy
+	cache  *lru.Cache[string, cached]
+}
+
+type cached struct {
+	version int64
+	html    string
+}
+
+// NewRenderer returns a renderer that keeps the output of up to cacheSize
+// documents; a cacheSize of 0 disables caching.
+func NewRenderer(cacheSize int) *Renderer {
+	r := &Renderer{
+		md: goldmark.New(
+			goldmark.WithExtensions(extension.GFM),
+			goldmark.WithRendererOptions(html.WithUnsafe()),
+		),
+		policy: newPolicy(),
+	}
+	if cacheSize > 0 {
+		// New only fails for a non-positive size.
+		r.cache, _ = lru.New[string, cached](cacheSize)
+	}
+	return r
+}
+
+// Render converts source to sanitized HTML.
+func (r *Renderer) Render(source string) string {
+	var buf bytes.Buffer
+	if err := r.md.Convert([]byte(source), &buf); err != nil {
+		// goldmark only fails when writing to buf fails, which it cannot.
+		return ""
+	}
+	return r.policy.Sanitize(buf.String())
+}
+
+// RenderCached is Render for a versioned document identified by key. The
+// output is reused until the document's version changes.
+func (r *Renderer) RenderCached(key string, version int64, source string) string {
+	if r.cache == nil {
+		return r.Render(source)
+	}
+	if c, ok := r.cache.Get(key); ok && c.version == version {
+		return c.html
+	}
+	out := r.Render(source)
+	r.cache.Add(key, cached{version: version, html: out})
+	return out
+}
+
+// Invalidate drops the cached output for key.
+func (r *Renderer) Invalidate(key string) {
+	if r.cache != nil {
+		r.cache.Remove(key)
+	}
+}
+
+var codeLanguage = regexp.MustCompile(`^language-[\w+#-]+$`)
+
+// newPolicy extends bluemonday's user generated content policy with what
+// goldmark emits for GFM: disabled task list checkboxes, table cell
+// alignment and code block language classes.
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+	p.AllowStyles("text-align").MatchingEnum("left", "center", "right").OnElements("th", "td")
+	p.AllowAttrs("class").Matching(codeLanguage).OnElements("code")
+	return p
+}
@@ -0,0 +1,140 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		want    []string
+		notWant []string
+	}{
+		{
+			name:   "emphasis and links",
+			source: "**bold** and [site](https://example.com)",
+			want:   []string{"<strong>bold</strong>", `<a href="https://example.com" rel="nofollow">site</a>`},
+		},
+		{
+			name:    "script tag",
+			source:  "before\n\n<script>alert(1)</script>\n\nafter",
+			want:    []string{"before", "after"},
+			notWant: []string{"<script", "alert(1)"},
+		},
+		{
+			name:    "inline script tag",
+			source:  "text <script>alert(1)</script> more",
+			notWant: []string{"<script"},
+		},
+		{
+			name:    "event handler attributes",
+			source:  `<img src="https://example.com/a.png" onerror="alert(1)"> <a href="https://example.com" onclick="alert(1)">x</a>`,
+			want:    []string{`src="https://example.com/a.png"`},
+			notWant: []string{"onerror", "onclick"},
+		},
+		{
+			name:    "javascript link in markdown",
+			source:  "[click](javascript:alert(1))",
+			want:    []string{"click"},
+			notWant: []string{"javascript:"},
+		},
+		{
+			name:    "javascript link in raw HTML",
+			source:  `<a href="JaVaScRiPt:alert(1)">click</a>`,
+			notWant: []string{"javascript:", "JaVaScRiPt:"},
+		},
+		{
+			name:    "harmless raw HTML is kept",
+			source:  "<details><summary>More</summary>\n\nhidden\n\n</details>",
+			want:    []string{"<details>", "<summary>More</summary>"},
+			notWant: []string{"&lt;details"},
+		},
+		{
+			name:    "iframe and style",
+			source:  `<iframe src="https://example.com"></iframe><style>body{display:none}</style>`,
+			notWant: []string{"<iframe", "<style", "display:none"},
+		},
+		{
+			name:   "task list checkboxes",
+			source: "- [x] done\n- [ ] todo",
+			want:   []string{`<input checked="" disabled="" type="checkbox"`, `<input disabled="" type="checkbox"`},
+		},
+		{
+			name:    "raw input elements other than checkboxes",
+			source:  `<input type="text" value="x"> <input type="checkbox" onclick="alert(1)">`,
+			notWant: []string{`type="text"`, "onclick"},
+		},
+		{
+			name:   "table alignment",
+			source: "| a | b |\n|:--|--:|\n| 1 | 2 |",
+			want:   []string{`<th style="text-align: left">`, `<td style="text-align: right">`},
+		},
+		{
+			name:    "styles other than alignment",
+			source:  `<td style="background: url(javascript:alert(1))">x</td>`,
+			notWant: []string{"background", "javascript:"},
+		},
+		{
+			name:    "code block language",
+			source:  "```go\nfmt.Println(\"<b>\")\n```",
+			want:    []string{`<code class="language-go">`, "&lt;b&gt;"},
+			notWant: []string{"<b>"},
+		},
+		{
+			name:    "arbitrary classes",
+			source:  `<code class="evil">x</code>`,
+			notWant: []string{"evil"},
+		},
+	}
+
+	r := NewRenderer(0)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := r.Render(tt.source)
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("Render() = %q, want it to contain %q", got, want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(got, notWant) {
+					t.Errorf("Render() = %q, want it not to contain %q", got, notWant)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderCached(t *testing.T) {
+	r := NewRenderer(10)
+
+	if got := r.RenderCached("note", 1, "first"); !strings.Contains(got, "first") {
+		t.Fatalf("RenderCached() = %q, want the first version", got)
+	}
+	// The output is reused while the version is unchanged
+	if got := r.RenderCached("note", 1, "second"); !strings.Contains(got, "first") {
+		t.Errorf("RenderCached() with the same version = %q, want the cached output", got)
+	}
+	if got := r.RenderCached("note", 2, "second"); !strings.Contains(got, "second") {
+		t.Errorf("RenderCached() with a new version = %q, want a fresh render", got)
+	}
+
+	r.Invalidate("note")
+	if got := r.RenderCached("note", 2, "third"); !strings.Contains(got, "third") {
+		t.Errorf("RenderCached() after Invalidate() = %q, want a fresh render", got)
+	}
+	if got := r.RenderCached("other", 2, "<script>x</script>other"); strings.Contains(got, "<script") {
+		t.Errorf("RenderCached() = %q, want sanitized output", got)
+	}
+}
+
+func TestRenderCachedDisabled(t *testing.T) {
+	r := NewRenderer(0)
+	r.RenderCached("note", 1, "first")
+	if got := r.RenderCached("note", 1, "second"); !strings.Contains(got, "second") {
+		t.Errorf("RenderCached() without a cache = %q, want a fresh render", got)
+	}
+	r.Invalidate("note")
+}
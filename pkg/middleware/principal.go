@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go-training-system/pkg/jwt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ErrNoPrincipal is returned when the request carries no authenticated user.
+var ErrNoPrincipal = errors.New("unauthenticated")
+
+// Principal is the authenticated caller as set by the auth middleware.
+type Principal struct {
+	UserID uuid.UUID
+	Role   string
+}
+
+// IsManager reports whether the caller holds the global MANAGER role.
+func (p Principal) IsManager() bool {
+	return p.Role == "MANAGER"
+}
+
+// GetPrincipal reads the authenticated caller from the gin context.
+func GetPrincipal(c *gin.Context) (Principal, error) {
+	return newPrincipal(c.Value(ContextUserID), c.Value(ContextRole))
+}
+
+// PrincipalFromContext reads the authenticated caller from a request context
+// populated by ContextMiddleware, as seen by GraphQL resolvers.
+func PrincipalFromContext(ctx context.Context) (Principal, error) {
+	return newPrincipal(ctx.Value(ContextUserID), ctx.Value(ContextRole))
+}
+
+// PrincipalFromToken verifies an access token sent other than in the
+// Authorization header, such as in the first message on a websocket.
+func PrincipalFromToken(token string, secret string) (Principal, error) {
+	claims, err := jwt.VerifyToken(strings.TrimPrefix(token, "Bearer "), secret)
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: %v", ErrNoPrincipal, err)
+	}
+	return newPrincipal(claims.UserID, claims.Role)
+}
+
+func newPrincipal(userID, role any) (Principal, error) {
+	raw, ok := userID.(string)
+	if !ok || raw == "" {
+		return Principal{}, ErrNoPrincipal
+	}
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: malformed user id", ErrNoPrincipal)
+	}
+	roleStr, _ := role.(string)
+	return Principal{UserID: id, Role: roleStr}, nil
+}
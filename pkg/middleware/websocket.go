@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go-training-system/pkg/jwt"
+
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+)
+
+// WebsocketInit authenticates GraphQL websocket connections. Browsers cannot
+// set headers on a websocket, so graphql-ws clients send the token in the
+// connection_init payload, as "Authorization" (optionally "Bearer "-prefixed)
+// or "authToken". Without one, the connection must have been authenticated
+// by the Authorization header of the upgrade request.
+func WebsocketInit(secret string) transport.WebsocketInitFunc {
+	return func(ctx context.Context, payload transport.InitPayload) (context.Context, *transport.InitPayload, error) {
+		token := payload.Authorization()
+		if token == "" {
+			token = payload.GetString("authToken")
+		}
+		if token == "" {
+			if _, err := PrincipalFromContext(ctx); err != nil {
+				return nil, nil, err
+			}
+			return ctx, &payload, nil
+		}
+
+		claims, err := jwt.VerifyToken(strings.TrimPrefix(token, "Bearer "), secret)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrNoPrincipal, err)
+		}
+		ctx = context.WithValue(ctx, ContextUserID, claims.UserID)
+		ctx = context.WithValue(ctx, ContextRole, claims.Role)
+		return ctx, &payload, nil
+	}
+}
@@ -0,0 +1,325 @@
+// Package notearchive reads and writes zip archives of notes. Folders are
+// directories and every note is a Markdown file starting with YAML front
+// matter:
+//
+//	---
+//	id: 6f1c2a7e-8d47-4a0e-9d0c-3b1f5e2a9c41
+//	title: Release checklist
+//	created_at: 2024-05-01T10:00:00Z
+//	updated_at: 2024-05-03T08:30:00Z
+//	tags: [release, ops]
+//	---
+//
+//	The note body.
+//
+// Files without front matter are read as notes titled after the file name.
+package notearchive
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maxNameLength caps file and directory names, in characters, leaving room
+// for a uniqueness suffix.
+const maxNameLength = 100
+
+// Error explains why Read rejected an archive. Limit tells an archive that
+// broke the Limits apart from one that is not a zip file or holds unsafe
+// paths or malformed notes.
+type Error struct {
+	Limit  bool
+	Reason string
+}
+
+func (e *Error) Error() string {
+	return e.Reason
+}
+
+func invalid(format string, args ...interface{}) error {
+	return &Error{Reason: fmt.Sprintf(format, args...)}
+}
+
+func limitExceeded(format string, args ...interface{}) error {
+	return &Error{Limit: true, Reason: fmt.Sprintf(format, args...)}
+}
+
+// Meta is a note's front matter.
+type Meta struct {
+	ID        string    `yaml:"id,omitempty"`
+	Title     string    `yaml:"title"`
+	CreatedAt time.Time `yaml:"created_at,omitempty"`
+	UpdatedAt time.Time `yaml:"updated_at,omitempty"`
+	Tags      []string  `yaml:"tags,omitempty"`
+}
+
+// Note is a note read from an archive. Dir is the slash-separated folder
+// path it is in, empty for the top of the archive, and Path the file's own
+// path.
+type Note struct {
+	Dir  string
+	Path string
+	Meta Meta
+	Body string
+}
+
+// Archive is the content of a note archive.
+type Archive struct {
+	// Folders lists every folder path, each one after its parent.
+	Folders []string
+	Notes   []Note
+	// Ignored lists files that are not Markdown notes.
+	Ignored []string
+}
+
+// Limits bound what Read accepts, to guard against zip bombs.
+type Limits struct {
+	// MaxEntries is the most files and directories the archive may hold.
+	MaxEntries int
+	// MaxSize is the most bytes the notes may decompress to in total.
+	MaxSize int64
+}
+
+// Writer streams a note archive to an underlying writer.
+type Writer struct {
+	zw   *zip.Writer
+	used map[string]bool
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{zw: zip.NewWriter(w), used: make(map[string]bool)}
+}
+
+// Folder adds a folder called name inside dir and returns its path. The
+// name is made safe for file systems and unique among its siblings.
+func (w *Writer) Folder(dir, name string) (string, error) {
+	p := w.unique(dir, cleanName(name), "")
+	if _, err := w.zw.CreateHeader(&zip.FileHeader{Name: p + "/", Modified: time.Now()}); err != nil {
+		return "", err
+	}
+	return p, nil
+}
+
+// Note adds a note inside dir, in a file named after its title.
+func (w *Writer) Note(dir string, meta Meta, body string) error {
+	front, err := yaml.Marshal(&meta)
+	if err != nil {
+		return err
+	}
+
+	modified := meta.UpdatedAt
+	if modified.IsZero() {
+		modified = time.Now()
+	}
+	f, err := w.zw.CreateHeader(&zip.FileHeader{
+		Name:     w.unique(dir, cleanName(meta.Title), ".md"),
+		Method:   zip.Deflate,
+		Modified: modified,
+	})
+	if err != nil {
+		return err
+	}
+	for _, part := range []string{"---\n", string(front), "---\n\n", body} {
+		if _, err := io.WriteString(f, part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close finishes the archive. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	return w.zw.Close()
+}
+
+// unique returns dir/name+ext, numbering the name if that path is taken.
+// Paths are compared ignoring case, for case-insensitive file systems.
+func (w *Writer) unique(dir, name, ext string) string {
+	candidate := name
+	for n := 2; w.used[strings.ToLower(path.Join(dir, candidate+ext))]; n++ {
+		candidate = name + " (" + strconv.Itoa(n) + ")"
+	}
+	p := path.Join(dir, candidate+ext)
+	w.used[strings.ToLower(p)] = true
+	return p
+}
+
+// Read parses a note archive. Only .md files are read as notes; other files
+// are listed in Ignored. Paths that could escape the archive are rejected.
+func Read(r io.ReaderAt, size int64, limits Limits) (*Archive, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, invalid("%v", err)
+	}
+	if len(zr.File) > limits.MaxEntries {
+		return nil, limitExceeded("more than %d entries", limits.MaxEntries)
+	}
+
+	archive := &Archive{}
+	folders := make(map[string]bool)
+	remaining := limits.MaxSize
+	for _, f := range zr.File {
+		p, err := cleanPath(f.Name)
+		if err != nil {
+			return nil, err
+		}
+		if p == "" || skipped(p) {
+			continue
+		}
+		if f.FileInfo().IsDir() {
+			addFolder(folders, p)
+			continue
+		}
+		if !strings.EqualFold(path.Ext(p), ".md") {
+			archive.Ignored = append(archive.Ignored, p)
+			continue
+		}
+
+		// Count the bytes actually inflated rather than trusting the sizes
+		// the archive declares.
+		data, err := readEntry(f, remaining+1)
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(data)) > remaining {
+			return nil, limitExceeded("notes decompress to more than %d bytes", limits.MaxSize)
+		}
+		remaining -= int64(len(data))
+
+		note, err := parseNote(p, data)
+		if err != nil {
+			return nil, err
+		}
+		addFolder(folders, note.Dir)
+		archive.Notes = append(archive.Notes, *note)
+	}
+
+	for p := range folders {
+		archive.Folders = append(archive.Folders, p)
+	}
+	sort.Slice(archive.Folders, func(i, j int) bool {
+		di, dj := strings.Count(archive.Folders[i], "/"), strings.Count(archive.Folders[j], "/")
+		if di != dj {
+			return di < dj
+		}
+		return archive.Folders[i] < archive.Folders[j]
+	})
+	return archive, nil
+}
+
+// readEntry reads at most max bytes of a file.
+func readEntry(f *zip.File, max int64) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, invalid("%s: %v", f.Name, err)
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, max))
+	if err != nil {
+		return nil, invalid("%s: %v", f.Name, err)
+	}
+	return data, nil
+}
+
+// parseNote splits a note file into its front matter and body.
+func parseNote(p string, data []byte) (*Note, error) {
+	if !utf8.Valid(data) {
+		return nil, invalid("%s is not UTF-8 text", p)
+	}
+	data = bytes.TrimPrefix(data, []byte("\uFEFF"))
+
+	note := &Note{Dir: path.Dir(p), Path: p, Body: string(data)}
+	if note.Dir == "." {
+		note.Dir = ""
+	}
+
+	lines := strings.SplitAfter(note.Body, "\n")
+	if isDelimiter(lines[0]) {
+		for i := 1; i < len(lines); i++ {
+			if !isDelimiter(lines[i]) {
+				continue
+			}
+			if err := yaml.Unmarshal([]byte(strings.Join(lines[1:i], "")), &note.Meta); err != nil {
+				return nil, invalid("front matter of %s: %v", p, err)
+			}
+			body := strings.Join(lines[i+1:], "")
+			body = strings.TrimPrefix(body, "\r")
+			note.Body = strings.TrimPrefix(body, "\n")
+			break
+		}
+	}
+
+	if strings.TrimSpace(note.Meta.Title) == "" {
+		note.Meta.Title = strings.TrimSuffix(path.Base(p), path.Ext(p))
+	}
+	return note, nil
+}
+
+func isDelimiter(line string) bool {
+	return strings.TrimRight(line, "\r\n") == "---"
+}
+
+// cleanPath validates an entry name and returns it without a trailing slash.
+func cleanPath(name string) (string, error) {
+	if strings.Contains(name, "\\") || strings.HasPrefix(name, "/") {
+		return "", invalid("unsafe path %q", name)
+	}
+	p := path.Clean(name)
+	if p == "." {
+		return "", nil
+	}
+	if p == ".." || strings.HasPrefix(p, "../") {
+		return "", invalid("unsafe path %q", name)
+	}
+	return p, nil
+}
+
+// skipped reports whether a path is metadata added by archiving tools, such
+// as __MACOSX/ or .DS_Store.
+func skipped(p string) bool {
+	for _, part := range strings.Split(p, "/") {
+		if strings.HasPrefix(part, ".") || part == "__MACOSX" {
+			return true
+		}
+	}
+	return false
+}
+
+// addFolder records a folder path together with all of its parents.
+func addFolder(folders map[string]bool, p string) {
+	for p != "" && p != "." && !folders[p] {
+		folders[p] = true
+		p = path.Dir(p)
+	}
+}
+
+// cleanName turns a folder name or note title into a file name that is
+// valid on common file systems.
+func cleanName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if utf8.RuneCountInString(name) > maxNameLength {
+		name = string([]rune(name)[:maxNameLength])
+	}
+	name = strings.Trim(name, " .")
+	if name == "" {
+		return "Untitled"
+	}
+	return name
+}
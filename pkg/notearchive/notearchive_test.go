@@ -0,0 +1,254 @@
+package notearchive
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testLimits = Limits{MaxEntries: 10, MaxSize: 1 << 10}
+
+type entry struct {
+	name string
+	body string
+}
+
+func buildZip(t *testing.T, entries []entry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: e.name, Method: zip.Deflate})
+		if err != nil {
+			t.Fatalf("CreateHeader(%q) error = %v", e.name, err)
+		}
+		if _, err := f.Write([]byte(e.body)); err != nil {
+			t.Fatalf("Write(%q) error = %v", e.name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+// buildLyingZip stores body under a header that declares it to be one byte.
+func buildLyingZip(t *testing.T, name, body string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	f, err := zw.CreateRaw(&zip.FileHeader{
+		Name:               name,
+		Method:             zip.Store,
+		CompressedSize64:   uint64(len(body)),
+		UncompressedSize64: 1,
+	})
+	if err != nil {
+		t.Fatalf("CreateRaw() error = %v", err)
+	}
+	f.Write([]byte(body))
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func read(data []byte) (*Archive, error) {
+	return Read(bytes.NewReader(data), int64(len(data)), testLimits)
+}
+
+func TestReadRejects(t *testing.T) {
+	tooMany := make([]entry, testLimits.MaxEntries+1)
+	for i := range tooMany {
+		tooMany[i] = entry{name: fmt.Sprintf("n%d.md", i), body: "x"}
+	}
+
+	tests := []struct {
+		name      string
+		data      func(t *testing.T) []byte
+		wantLimit bool
+	}{
+		{
+			name:      "more entries than allowed",
+			data:      func(t *testing.T) []byte { return buildZip(t, tooMany) },
+			wantLimit: true,
+		},
+		{
+			name: "one note inflating past the size limit",
+			data: func(t *testing.T) []byte {
+				return buildZip(t, []entry{{name: "bomb.md", body: strings.Repeat("0", 1<<20)}})
+			},
+			wantLimit: true,
+		},
+		{
+			name: "notes adding up past the size limit",
+			data: func(t *testing.T) []byte {
+				half := strings.Repeat("a", int(testLimits.MaxSize)/2+1)
+				return buildZip(t, []entry{{name: "a.md", body: half}, {name: "b.md", body: half}})
+			},
+			wantLimit: true,
+		},
+		{
+			name: "declared size smaller than the content",
+			data: func(t *testing.T) []byte { return buildLyingZip(t, "liar.md", strings.Repeat("a", 100)) },
+		},
+		{
+			name: "parent directory entry",
+			data: func(t *testing.T) []byte { return buildZip(t, []entry{{name: "../evil.md", body: "x"}}) },
+		},
+		{
+			name: "parent directory after cleaning",
+			data: func(t *testing.T) []byte { return buildZip(t, []entry{{name: "a/../../evil.md", body: "x"}}) },
+		},
+		{
+			name: "bare parent directory",
+			data: func(t *testing.T) []byte { return buildZip(t, []entry{{name: "../", body: ""}}) },
+		},
+		{
+			name: "absolute path",
+			data: func(t *testing.T) []byte { return buildZip(t, []entry{{name: "/etc/evil.md", body: "x"}}) },
+		},
+		{
+			name: "backslash path",
+			data: func(t *testing.T) []byte { return buildZip(t, []entry{{name: `..\evil.md`, body: "x"}}) },
+		},
+		{
+			name: "not UTF-8",
+			data: func(t *testing.T) []byte { return buildZip(t, []entry{{name: "a.md", body: "\xff\xfe"}}) },
+		},
+		{
+			name: "malformed front matter",
+			data: func(t *testing.T) []byte { return buildZip(t, []entry{{name: "a.md", body: "---\ntitle: [\n---\n"}}) },
+		},
+		{
+			name: "not a zip file",
+			data: func(t *testing.T) []byte { return []byte("not a zip") },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := read(tt.data(t))
+			var archiveErr *Error
+			if !errors.As(err, &archiveErr) {
+				t.Fatalf("Read() error = %v, want an *Error", err)
+			}
+			if archiveErr.Limit != tt.wantLimit {
+				t.Errorf("Read() error = %v with Limit %v, want Limit %v", err, archiveErr.Limit, tt.wantLimit)
+			}
+		})
+	}
+}
+
+func TestReadAtLimits(t *testing.T) {
+	entries := make([]entry, testLimits.MaxEntries)
+	for i := range entries {
+		entries[i] = entry{name: fmt.Sprintf("n%d.md", i)}
+	}
+	entries[0].body = strings.Repeat("a", int(testLimits.MaxSize))
+
+	archive, err := read(buildZip(t, entries))
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if len(archive.Notes) != testLimits.MaxEntries {
+		t.Errorf("Read() returned %d notes, want %d", len(archive.Notes), testLimits.MaxEntries)
+	}
+}
+
+func TestReadPaths(t *testing.T) {
+	archive, err := read(buildZip(t, []entry{
+		{name: "a/./b/../c.md", body: "cleaned"},
+		{name: "x/y/z.MD", body: "nested"},
+		{name: "empty/"},
+		{name: "__MACOSX/a/._c.md", body: "resource fork"},
+		{name: ".DS_Store", body: "finder"},
+		{name: "a/.hidden/h.md", body: "hidden"},
+		{name: "a/image.png", body: "png"},
+	}))
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+
+	var paths []string
+	for _, n := range archive.Notes {
+		paths = append(paths, n.Dir+" "+n.Path)
+	}
+	if want := []string{"a a/c.md", "x/y x/y/z.MD"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("notes = %q, want %q", paths, want)
+	}
+	if want := []string{"a", "empty", "x", "x/y"}; !reflect.DeepEqual(archive.Folders, want) {
+		t.Errorf("Folders = %q, want %q", archive.Folders, want)
+	}
+	if want := []string{"a/image.png"}; !reflect.DeepEqual(archive.Ignored, want) {
+		t.Errorf("Ignored = %q, want %q", archive.Ignored, want)
+	}
+}
+
+func TestReadFrontMatter(t *testing.T) {
+	archive, err := read(buildZip(t, []entry{
+		{name: "with.md", body: "\uFEFF---\r\ntitle: Release\r\ntags: [ops]\r\n---\r\n\r\nBody\r\n"},
+		{name: "dir/without.md", body: "Just text"},
+	}))
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+
+	with, without := archive.Notes[0], archive.Notes[1]
+	if with.Meta.Title != "Release" || !reflect.DeepEqual(with.Meta.Tags, []string{"ops"}) || with.Body != "Body\r\n" {
+		t.Errorf("note with front matter = %+v", with)
+	}
+	if without.Meta.Title != "without" || without.Body != "Just text" {
+		t.Errorf("note without front matter = %+v", without)
+	}
+}
+
+func TestWriterNames(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	dir, err := w.Folder("", `Q1/Q2: "plans"?`)
+	if err != nil {
+		t.Fatalf("Folder() error = %v", err)
+	}
+	titles := []string{"a<b>|c*", "Note", "note", "  ..  ", strings.Repeat("é", maxNameLength+10), "../../escape"}
+	for _, title := range titles {
+		if err := w.Note(dir, Meta{Title: title, UpdatedAt: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)}, "body"); err != nil {
+			t.Fatalf("Note(%q) error = %v", title, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	archive, err := Read(bytes.NewReader(buf.Bytes()), int64(buf.Len()), Limits{MaxEntries: 100, MaxSize: 1 << 20})
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	wantDir := `Q1_Q2_ _plans__`
+	var names []string
+	for i, n := range archive.Notes {
+		if n.Dir != wantDir {
+			t.Errorf("note %d is in %q, want %q", i, n.Dir, wantDir)
+		}
+		if n.Meta.Title != titles[i] {
+			t.Errorf("note %d title = %q, want %q", i, n.Meta.Title, titles[i])
+		}
+		names = append(names, strings.TrimPrefix(n.Path, wantDir+"/"))
+	}
+	want := []string{
+		"a_b__c_.md",
+		"Note.md",
+		"note (2).md",
+		"Untitled.md",
+		strings.Repeat("é", maxNameLength) + ".md",
+		"_.._escape.md",
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("file names = %q, want %q", names, want)
+	}
+}
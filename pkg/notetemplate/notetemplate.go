@@ -0,0 +1,51 @@
+// Package notetemplate fills in the placeholders of note templates. A
+// placeholder is a name between double braces, optionally padded with
+// spaces:
+//
+//	Meeting notes {{date}}
+//	Taken by {{ user.username }} for {{team.name}}
+//
+// Anything else between double braces is left as it is.
+package notetemplate
+
+import (
+	"regexp"
+)
+
+var (
+	placeholder = regexp.MustCompile(`\{\{\s*([A-Za-z][A-Za-z0-9_.]*)\s*\}\}`)
+	fieldName   = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+)
+
+// Placeholders returns the names used in text, each once, in order of first
+// use.
+func Placeholders(text string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, match := range placeholder.FindAllStringSubmatch(text, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			names = append(names, match[1])
+		}
+	}
+	return names
+}
+
+// Render replaces every placeholder that has a value. Placeholders without
+// one are kept verbatim.
+func Render(text string, values map[string]string) string {
+	return placeholder.ReplaceAllStringFunc(text, func(match string) string {
+		name := placeholder.FindStringSubmatch(match)[1]
+		if value, ok := values[name]; ok {
+			return value
+		}
+		return match
+	})
+}
+
+// ValidFieldName reports whether name can be used for a custom field: a
+// lowercase letter followed by lowercase letters, digits and underscores.
+// Dotted names are reserved for built-in values.
+func ValidFieldName(name string) bool {
+	return fieldName.MatchString(name)
+}
@@ -0,0 +1,333 @@
+// Package ot implements operational transformation for plain text. An
+// Operation walks the whole document from start to end, retaining, inserting
+// and deleting text, and is encoded in JSON as in ot.js: a positive number
+// retains that many characters, a negative number deletes them and a string
+// is inserted.
+//
+// Lengths count Unicode code points, not bytes or UTF-16 code units, so
+// clients must count the same way. Documents longer than MaxLength cannot be
+// edited.
+package ot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// MaxLength bounds the length of the documents operations apply to and
+// produce, which keeps every length sum far from overflowing.
+const MaxLength = 1 << 24
+
+var (
+	// ErrBaseLength is returned when an operation does not span the whole
+	// document it is applied or transformed against.
+	ErrBaseLength = errors.New("operation length does not match the document")
+	ErrInvalid    = errors.New("invalid operation")
+)
+
+// Component is one step of an operation. Exactly one field is set.
+type Component struct {
+	Retain int
+	Insert string
+	Delete int
+}
+
+func (c Component) isRetain() bool { return c.Retain > 0 }
+func (c Component) isInsert() bool { return c.Insert != "" }
+func (c Component) isDelete() bool { return c.Delete > 0 }
+
+type Operation []Component
+
+// BaseLen is the length of the documents the operation applies to, or -1
+// when it exceeds MaxLength.
+func (op Operation) BaseLen() int {
+	n := 0
+	for _, c := range op {
+		if n = addLen(n, c.Retain+c.Delete); n < 0 {
+			return -1
+		}
+	}
+	return n
+}
+
+// TargetLen is the length of the document the operation produces, or -1
+// when it exceeds MaxLength.
+func (op Operation) TargetLen() int {
+	n := 0
+	for _, c := range op {
+		if n = addLen(n, c.Retain+utf8.RuneCountInString(c.Insert)); n < 0 {
+			return -1
+		}
+	}
+	return n
+}
+
+// addLen adds a component length to a running total, returning -1 once
+// either is out of range. Components beyond MaxLength are rejected before
+// they are summed, so the sum itself cannot overflow.
+func addLen(total, n int) int {
+	if total < 0 || n < 0 || n > MaxLength || total+n > MaxLength {
+		return -1
+	}
+	return total + n
+}
+
+// IsNoop reports whether the operation leaves the document unchanged.
+func (op Operation) IsNoop() bool {
+	for _, c := range op {
+		if !c.isRetain() {
+			return false
+		}
+	}
+	return true
+}
+
+func (op Operation) MarshalJSON() ([]byte, error) {
+	parts := make([]any, len(op))
+	for i, c := range op {
+		switch {
+		case c.isInsert():
+			parts[i] = c.Insert
+		case c.isDelete():
+			parts[i] = -c.Delete
+		default:
+			parts[i] = c.Retain
+		}
+	}
+	return json.Marshal(parts)
+}
+
+// UnmarshalJSON accepts any well-formed operation and normalizes it. It
+// rejects operations on or producing documents longer than MaxLength.
+func (op *Operation) UnmarshalJSON(data []byte) error {
+	var parts []json.RawMessage
+	if err := json.Unmarshal(data, &parts); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	var result Operation
+	baseLen, targetLen := 0, 0
+	for _, part := range parts {
+		var text string
+		if err := json.Unmarshal(part, &text); err == nil {
+			if text == "" || !utf8.ValidString(text) {
+				return fmt.Errorf("%w: inserted text must be non-empty UTF-8", ErrInvalid)
+			}
+			if targetLen = addLen(targetLen, utf8.RuneCountInString(text)); targetLen < 0 {
+				return fmt.Errorf("%w: the document would be too long", ErrInvalid)
+			}
+			result = result.insert(text)
+			continue
+		}
+		var n int
+		if err := json.Unmarshal(part, &n); err != nil || n == 0 {
+			return fmt.Errorf("%w: components must be strings or non-zero integers", ErrInvalid)
+		}
+		if n < -MaxLength || n > MaxLength {
+			return fmt.Errorf("%w: components must be at most %d long", ErrInvalid, MaxLength)
+		}
+		if baseLen = addLen(baseLen, max(n, -n)); baseLen < 0 {
+			return fmt.Errorf("%w: the document would be too long", ErrInvalid)
+		}
+		if n > 0 {
+			if targetLen = addLen(targetLen, n); targetLen < 0 {
+				return fmt.Errorf("%w: the document would be too long", ErrInvalid)
+			}
+			result = result.retain(n)
+		} else {
+			result = result.delete(-n)
+		}
+	}
+	*op = result
+	return nil
+}
+
+// Apply returns doc with the operation applied.
+func Apply(doc string, op Operation) (string, error) {
+	text := []rune(doc)
+	if len(text) != op.BaseLen() {
+		return "", ErrBaseLength
+	}
+
+	var b strings.Builder
+	pos := 0
+	for _, c := range op {
+		// BaseLen already matched, so this only guards against components
+		// that are negative or otherwise malformed
+		if c.Retain < 0 || c.Delete < 0 || c.Retain > len(text)-pos || c.Delete > len(text)-pos {
+			return "", ErrBaseLength
+		}
+		switch {
+		case c.isRetain():
+			b.WriteString(string(text[pos : pos+c.Retain]))
+			pos += c.Retain
+		case c.isInsert():
+			b.WriteString(c.Insert)
+		case c.isDelete():
+			pos += c.Delete
+		}
+	}
+	return b.String(), nil
+}
+
+// Transform takes two operations made concurrently on the same document and
+// returns a' and b' such that applying a then b' gives the same document as
+// applying b then a'. When both insert at the same place, a's text comes
+// first.
+func Transform(a, b Operation) (Operation, Operation, error) {
+	if a.BaseLen() != b.BaseLen() {
+		return nil, nil, ErrBaseLength
+	}
+
+	var aPrime, bPrime Operation
+	i, j := 0, 0
+	var ca, cb Component
+	next := func(op Operation, k *int) Component {
+		if *k >= len(op) {
+			return Component{}
+		}
+		c := op[*k]
+		*k++
+		return c
+	}
+	ca, cb = next(a, &i), next(b, &j)
+
+	for {
+		switch {
+		case ca == Component{} && cb == Component{}:
+			return aPrime, bPrime, nil
+		case ca.isInsert():
+			aPrime = aPrime.insert(ca.Insert)
+			bPrime = bPrime.retain(utf8.RuneCountInString(ca.Insert))
+			ca = next(a, &i)
+			continue
+		case cb.isInsert():
+			aPrime = aPrime.retain(utf8.RuneCountInString(cb.Insert))
+			bPrime = bPrime.insert(cb.Insert)
+			cb = next(b, &j)
+			continue
+		case ca == Component{} || cb == Component{}:
+			return nil, nil, ErrBaseLength
+		}
+
+		// Both are retains or deletes: consume the shorter of the two
+		n := min(ca.Retain+ca.Delete, cb.Retain+cb.Delete)
+		switch {
+		case ca.isRetain() && cb.isRetain():
+			aPrime = aPrime.retain(n)
+			bPrime = bPrime.retain(n)
+		case ca.isDelete() && cb.isRetain():
+			aPrime = aPrime.delete(n)
+		case ca.isRetain() && cb.isDelete():
+			bPrime = bPrime.delete(n)
+		}
+		// Text deleted by both is simply gone on both sides
+
+		ca = shorten(ca, n)
+		if ca == (Component{}) {
+			ca = next(a, &i)
+		}
+		cb = shorten(cb, n)
+		if cb == (Component{}) {
+			cb = next(b, &j)
+		}
+	}
+}
+
+// TransformIndex moves a position in the document, such as a cursor, to
+// where it is after the operation. Text inserted at the position ends up
+// before it.
+func TransformIndex(index int, op Operation) int {
+	newIndex := index
+	for _, c := range op {
+		switch {
+		case c.isRetain():
+			index -= c.Retain
+		case c.isInsert():
+			newIndex += utf8.RuneCountInString(c.Insert)
+		case c.isDelete():
+			newIndex -= min(index, c.Delete)
+			index -= c.Delete
+		}
+		if index < 0 {
+			break
+		}
+	}
+	return newIndex
+}
+
+// Diff returns an operation turning oldText into newText.
+func Diff(oldText, newText string) Operation {
+	var op Operation
+	for _, d := range diffmatchpatch.New().DiffMain(oldText, newText, false) {
+		switch d.Type {
+		case diffmatchpatch.DiffEqual:
+			op = op.retain(utf8.RuneCountInString(d.Text))
+		case diffmatchpatch.DiffInsert:
+			op = op.insert(d.Text)
+		case diffmatchpatch.DiffDelete:
+			op = op.delete(utf8.RuneCountInString(d.Text))
+		}
+	}
+	return op
+}
+
+func shorten(c Component, n int) Component {
+	switch {
+	case c.isRetain():
+		c.Retain -= n
+	case c.isDelete():
+		c.Delete -= n
+	}
+	return c
+}
+
+// retain, insert and delete append a component, merging it with the last
+// one where possible. An insert next to a delete always goes first, so equal
+// edits have a single representation.
+func (op Operation) retain(n int) Operation {
+	if n == 0 {
+		return op
+	}
+	if last := len(op) - 1; last >= 0 && op[last].isRetain() {
+		op[last].Retain += n
+		return op
+	}
+	return append(op, Component{Retain: n})
+}
+
+func (op Operation) insert(text string) Operation {
+	if text == "" {
+		return op
+	}
+	last := len(op) - 1
+	switch {
+	case last >= 0 && op[last].isInsert():
+		op[last].Insert += text
+	case last >= 0 && op[last].isDelete():
+		if last > 0 && op[last-1].isInsert() {
+			op[last-1].Insert += text
+		} else {
+			op = append(op, op[last])
+			op[last] = Component{Insert: text}
+		}
+	default:
+		op = append(op, Component{Insert: text})
+	}
+	return op
+}
+
+func (op Operation) delete(n int) Operation {
+	if n == 0 {
+		return op
+	}
+	if last := len(op) - 1; last >= 0 && op[last].isDelete() {
+		op[last].Delete += n
+		return op
+	}
+	return append(op, Component{Delete: n})
+}
@@ -0,0 +1,333 @@
+package ot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func mustApply(t *testing.T, doc string, op Operation) string {
+	t.Helper()
+	result, err := Apply(doc, op)
+	if err != nil {
+		t.Fatalf("Apply(%q, %v) error = %v", doc, op, err)
+	}
+	return result
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		op      Operation
+		want    string
+		wantErr error
+	}{
+		{
+			name: "retain everything",
+			doc:  "hello",
+			op:   Operation{{Retain: 5}},
+			want: "hello",
+		},
+		{
+			name: "insert in the middle",
+			doc:  "hello",
+			op:   Operation{{Retain: 2}, {Insert: "XY"}, {Retain: 3}},
+			want: "heXYllo",
+		},
+		{
+			name: "delete at the end",
+			doc:  "hello",
+			op:   Operation{{Retain: 3}, {Delete: 2}},
+			want: "hel",
+		},
+		{
+			name: "replace non-BMP text",
+			doc:  "a😀b",
+			op:   Operation{{Retain: 1}, {Insert: "🎉"}, {Delete: 1}, {Retain: 1}},
+			want: "a🎉b",
+		},
+		{
+			name:    "too short",
+			doc:     "hello",
+			op:      Operation{{Retain: 4}},
+			wantErr: ErrBaseLength,
+		},
+		{
+			name:    "too long",
+			doc:     "hello",
+			op:      Operation{{Retain: 6}},
+			wantErr: ErrBaseLength,
+		},
+		{
+			name:    "non-BMP text counts as one character",
+			doc:     "😀",
+			op:      Operation{{Retain: 2}},
+			wantErr: ErrBaseLength,
+		},
+		{
+			name:    "negative components summing to the length",
+			doc:     "hello",
+			op:      Operation{{Retain: 10}, {Retain: -5}},
+			wantErr: ErrBaseLength,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Apply(tt.doc, tt.op)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Apply() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Apply() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransform(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		a    Operation
+		b    Operation
+		want string
+	}{
+		{
+			name: "inserts at different places",
+			doc:  "hello world",
+			a:    Operation{{Insert: "> "}, {Retain: 11}},
+			b:    Operation{{Retain: 11}, {Insert: "!"}},
+			want: "> hello world!",
+		},
+		{
+			name: "inserts at the same place put a first",
+			doc:  "ab",
+			a:    Operation{{Retain: 1}, {Insert: "X"}, {Retain: 1}},
+			b:    Operation{{Retain: 1}, {Insert: "Y"}, {Retain: 1}},
+			want: "aXYb",
+		},
+		{
+			name: "overlapping deletes",
+			doc:  "abcdef",
+			a:    Operation{{Retain: 1}, {Delete: 3}, {Retain: 2}},
+			b:    Operation{{Retain: 2}, {Delete: 3}, {Retain: 1}},
+			want: "af",
+		},
+		{
+			name: "insert inside a concurrent delete",
+			doc:  "abcdef",
+			a:    Operation{{Retain: 1}, {Delete: 4}, {Retain: 1}},
+			b:    Operation{{Retain: 3}, {Insert: "X"}, {Retain: 3}},
+			want: "aXf",
+		},
+		{
+			name: "same delete on both sides",
+			doc:  "abc",
+			a:    Operation{{Retain: 1}, {Delete: 1}, {Retain: 1}},
+			b:    Operation{{Retain: 1}, {Delete: 1}, {Retain: 1}},
+			want: "ac",
+		},
+		{
+			name: "non-BMP edits",
+			doc:  "😀😀😀",
+			a:    Operation{{Retain: 1}, {Insert: "🎉"}, {Retain: 2}},
+			b:    Operation{{Retain: 2}, {Delete: 1}},
+			want: "😀🎉😀",
+		},
+		{
+			name: "empty document",
+			doc:  "",
+			a:    Operation{{Insert: "a"}},
+			b:    Operation{{Insert: "b"}},
+			want: "ab",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			aPrime, bPrime, err := Transform(tt.a, tt.b)
+			if err != nil {
+				t.Fatalf("Transform() error = %v", err)
+			}
+			ab := mustApply(t, mustApply(t, tt.doc, tt.a), bPrime)
+			ba := mustApply(t, mustApply(t, tt.doc, tt.b), aPrime)
+			if ab != ba {
+				t.Fatalf("documents diverge: a then b' = %q, b then a' = %q", ab, ba)
+			}
+			if ab != tt.want {
+				t.Errorf("transformed result = %q, want %q", ab, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransformBaseLength(t *testing.T) {
+	_, _, err := Transform(Operation{{Retain: 3}}, Operation{{Retain: 4}})
+	if !errors.Is(err, ErrBaseLength) {
+		t.Errorf("Transform() error = %v, want %v", err, ErrBaseLength)
+	}
+}
+
+func TestTransformIndex(t *testing.T) {
+	tests := []struct {
+		name  string
+		index int
+		op    Operation
+		want  int
+	}{
+		{
+			name:  "insert before",
+			index: 3,
+			op:    Operation{{Retain: 1}, {Insert: "XY"}, {Retain: 4}},
+			want:  5,
+		},
+		{
+			name:  "insert after",
+			index: 1,
+			op:    Operation{{Retain: 3}, {Insert: "XY"}, {Retain: 2}},
+			want:  1,
+		},
+		{
+			name:  "insert at the index goes before it",
+			index: 2,
+			op:    Operation{{Retain: 2}, {Insert: "X"}, {Retain: 3}},
+			want:  3,
+		},
+		{
+			name:  "delete before",
+			index: 4,
+			op:    Operation{{Delete: 2}, {Retain: 3}},
+			want:  2,
+		},
+		{
+			name:  "delete around the index",
+			index: 3,
+			op:    Operation{{Retain: 1}, {Delete: 4}},
+			want:  1,
+		},
+		{
+			name:  "delete after",
+			index: 1,
+			op:    Operation{{Retain: 2}, {Delete: 3}},
+			want:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TransformIndex(tt.index, tt.op); got != tt.want {
+				t.Errorf("TransformIndex() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name    string
+		oldText string
+		newText string
+	}{
+		{name: "identical", oldText: "same", newText: "same"},
+		{name: "from empty", oldText: "", newText: "new text"},
+		{name: "to empty", oldText: "old text", newText: ""},
+		{name: "edit in the middle", oldText: "the quick fox", newText: "the slow brown fox"},
+		{name: "multiple lines", oldText: "a\nb\nc\n", newText: "a\nB\nc\nd"},
+		{name: "non-BMP text", oldText: "x😀y🎉z", newText: "x🎉y😀😀z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			op := Diff(tt.oldText, tt.newText)
+			if got := mustApply(t, tt.oldText, op); got != tt.newText {
+				t.Errorf("applying Diff() = %q, want %q", got, tt.newText)
+			}
+		})
+	}
+}
+
+func TestOperationJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		want    Operation
+		wantErr bool
+	}{
+		{
+			name: "all component kinds",
+			json: `[2,"ab",-3,1]`,
+			want: Operation{{Retain: 2}, {Insert: "ab"}, {Delete: 3}, {Retain: 1}},
+		},
+		{
+			name: "adjacent components are merged",
+			json: `[1,1,"a","b",-1,-1]`,
+			want: Operation{{Retain: 2}, {Insert: "ab"}, {Delete: 2}},
+		},
+		{
+			name: "insert goes before an adjacent delete",
+			json: `[-2,"x"]`,
+			want: Operation{{Insert: "x"}, {Delete: 2}},
+		},
+		{
+			name: "non-BMP insert",
+			json: `["😀"]`,
+			want: Operation{{Insert: "😀"}},
+		},
+		{name: "not an array", json: `{"retain":1}`, wantErr: true},
+		{name: "zero", json: `[0]`, wantErr: true},
+		{name: "empty insert", json: `[""]`, wantErr: true},
+		{name: "fraction", json: `[1.5]`, wantErr: true},
+		{name: "nested array", json: `[[1]]`, wantErr: true},
+		{name: "null component", json: `[null]`, wantErr: true},
+		{name: "integer overflow", json: `[92233720368547758070]`, wantErr: true},
+		{name: "retain beyond the maximum", json: fmt.Sprintf(`[%d]`, MaxLength+1), wantErr: true},
+		{name: "delete beyond the maximum", json: fmt.Sprintf(`[%d]`, -MaxLength-1), wantErr: true},
+		{name: "retains summing beyond the maximum", json: fmt.Sprintf(`[%d,"x",%d]`, MaxLength, MaxLength), wantErr: true},
+		{name: "base length wrapping around", json: `[9223372036854775807,"x",9223372036854775807,7]`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var op Operation
+			err := json.Unmarshal([]byte(tt.json), &op)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalid) {
+					t.Fatalf("Unmarshal() error = %v, want %v", err, ErrInvalid)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if fmt.Sprint(op) != fmt.Sprint(tt.want) {
+				t.Fatalf("Unmarshal() = %v, want %v", op, tt.want)
+			}
+
+			encoded, err := json.Marshal(op)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			var again Operation
+			if err := json.Unmarshal(encoded, &again); err != nil || fmt.Sprint(again) != fmt.Sprint(op) {
+				t.Errorf("round trip = %v (%v), want %v", again, err, op)
+			}
+		})
+	}
+}
+
+func TestLengthsOverflow(t *testing.T) {
+	op := Operation{{Retain: MaxLength}, {Retain: MaxLength}, {Insert: strings.Repeat("x", 3)}}
+	if got := op.BaseLen(); got != -1 {
+		t.Errorf("BaseLen() = %d, want -1", got)
+	}
+	if got := op.TargetLen(); got != -1 {
+		t.Errorf("TargetLen() = %d, want -1", got)
+	}
+	if _, err := Apply("hello", op); !errors.Is(err, ErrBaseLength) {
+		t.Errorf("Apply() error = %v, want %v", err, ErrBaseLength)
+	}
+}
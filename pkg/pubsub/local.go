@@ -0,0 +1,42 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+)
+
+// Local delivers messages within the process only. It suits a single
+// replica and development.
+type Local struct {
+	mu      sync.RWMutex
+	deliver func(topic string, payload []byte)
+}
+
+func NewLocal() *Local {
+	return &Local{}
+}
+
+// Publish hands the message straight to the listener. Messages published
+// while nothing is listening are dropped.
+func (l *Local) Publish(ctx context.Context, topic string, payload []byte) error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if l.deliver != nil {
+		l.deliver(topic, payload)
+	}
+	return nil
+}
+
+func (l *Local) Listen(ctx context.Context, deliver func(topic string, payload []byte)) error {
+	l.mu.Lock()
+	l.deliver = deliver
+	l.mu.Unlock()
+
+	<-ctx.Done()
+
+	l.mu.Lock()
+	l.deliver = nil
+	l.mu.Unlock()
+	return nil
+}
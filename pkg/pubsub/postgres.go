@@ -0,0 +1,94 @@
+package pubsub
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-training-system/pkg/logger"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// maxNotifyPayload is the largest payload Postgres accepts in NOTIFY, less
+// one byte for the separator between topic and payload.
+const maxNotifyPayload = 7999
+
+const (
+	minReconnectDelay = time.Second
+	maxReconnectDelay = 30 * time.Second
+)
+
+// Postgres sends messages with NOTIFY on one channel and receives them on a
+// dedicated LISTEN connection, so every replica connected to the database
+// sees every message. Messages are limited to 8000 bytes including the
+// topic, and those sent while the listener reconnects are lost.
+type Postgres struct {
+	db      *sql.DB
+	dsn     string
+	channel string
+}
+
+// NewPostgres publishes through db and listens on a connection of its own
+// opened with dsn.
+func NewPostgres(db *sql.DB, dsn string, channel string) *Postgres {
+	return &Postgres{db: db, dsn: dsn, channel: channel}
+}
+
+func (p *Postgres) Publish(ctx context.Context, topic string, payload []byte) error {
+	if len(topic)+len(payload) > maxNotifyPayload {
+		return fmt.Errorf("pubsub message on %s is %d bytes, at most %d fit in a notification", topic, len(topic)+len(payload), maxNotifyPayload)
+	}
+	_, err := p.db.ExecContext(ctx, "SELECT pg_notify($1, $2)", p.channel, topic+"\n"+string(payload))
+	return err
+}
+
+// Listen keeps a LISTEN connection open until ctx is cancelled, reconnecting
+// with backoff when it drops.
+func (p *Postgres) Listen(ctx context.Context, deliver func(topic string, payload []byte)) error {
+	delay := minReconnectDelay
+	for {
+		err := p.listen(ctx, deliver, func() { delay = minReconnectDelay })
+		if ctx.Err() != nil {
+			return nil
+		}
+		logger.Log.Warn("pubsub listener disconnected, reconnecting", zap.String("channel", p.channel), zap.Duration("delay", delay), zap.Error(err))
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, maxReconnectDelay)
+	}
+}
+
+// listen serves one connection, calling connected once it is listening.
+func (p *Postgres) listen(ctx context.Context, deliver func(topic string, payload []byte), connected func()) error {
+	conn, err := pgx.Connect(ctx, p.dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{p.channel}.Sanitize()); err != nil {
+		return err
+	}
+	connected()
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		topic, payload, ok := strings.Cut(notification.Payload, "\n")
+		if !ok {
+			logger.Log.Warn("ignoring malformed pubsub notification", zap.String("channel", p.channel))
+			continue
+		}
+		deliver(topic, []byte(payload))
+	}
+}
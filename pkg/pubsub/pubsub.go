@@ -0,0 +1,104 @@
+// Package pubsub fans messages out to in-process subscribers by topic. The
+// messages travel through a Backend, so subscribers on every server replica
+// sharing the backend see every message, wherever it was published.
+//
+// Delivery is best effort: a subscriber that falls behind loses messages,
+// and so does every subscriber while a backend reconnects. Subscribers that
+// need the current state should re-read it rather than rely on the messages.
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+)
+
+// ErrInvalidTopic is returned when publishing to an empty topic or one that
+// contains a newline.
+var ErrInvalidTopic = errors.New("invalid pubsub topic")
+
+// subscriberBuffer is how many messages a subscriber can fall behind before
+// new ones are dropped for it.
+const subscriberBuffer = 32
+
+// Backend is implemented by Local, for a single process, and Postgres, which
+// uses LISTEN/NOTIFY to reach every replica connected to the same database.
+type Backend interface {
+	Publish(ctx context.Context, topic string, payload []byte) error
+	// Listen calls deliver for every message published through the backend,
+	// by this process or any other, until ctx is cancelled. deliver must not
+	// block.
+	Listen(ctx context.Context, deliver func(topic string, payload []byte)) error
+}
+
+// Broker delivers the messages received from its backend to the local
+// subscribers of their topic. Run must be running for anything to be
+// delivered.
+type Broker struct {
+	backend Backend
+
+	mu     sync.RWMutex
+	topics map[string]map[chan []byte]struct{}
+}
+
+func NewBroker(backend Backend) *Broker {
+	return &Broker{
+		backend: backend,
+		topics:  make(map[string]map[chan []byte]struct{}),
+	}
+}
+
+// Run receives messages from the backend until ctx is cancelled.
+func (b *Broker) Run(ctx context.Context) error {
+	return b.backend.Listen(ctx, b.dispatch)
+}
+
+// Publish sends payload to the subscribers of topic on every replica.
+func (b *Broker) Publish(ctx context.Context, topic string, payload []byte) error {
+	if topic == "" || strings.ContainsRune(topic, '\n') {
+		return ErrInvalidTopic
+	}
+	return b.backend.Publish(ctx, topic, payload)
+}
+
+// Subscribe returns a channel receiving the payloads published to topic. The
+// channel is closed once ctx is cancelled.
+func (b *Broker) Subscribe(ctx context.Context, topic string) <-chan []byte {
+	ch := make(chan []byte, subscriberBuffer)
+
+	b.mu.Lock()
+	subscribers := b.topics[topic]
+	if subscribers == nil {
+		subscribers = make(map[chan []byte]struct{})
+		b.topics[topic] = subscribers
+	}
+	subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		b.mu.Lock()
+		delete(subscribers, ch)
+		if len(subscribers) == 0 {
+			delete(b.topics, topic)
+		}
+		b.mu.Unlock()
+		close(ch)
+	}()
+	return ch
+}
+
+func (b *Broker) dispatch(topic string, payload []byte) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for ch := range b.topics[topic] {
+		select {
+		case ch <- payload:
+		default:
+			// The subscriber is not keeping up
+		}
+	}
+}
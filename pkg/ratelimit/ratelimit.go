@@ -0,0 +1,72 @@
+// Package ratelimit counts events per key in fixed time windows, in memory.
+// Each server replica counts on its own, so with N replicas a client can get
+// up to N times the limit through.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+type window struct {
+	start time.Time
+	count int
+}
+
+// Limiter allows up to limit events per key in each window.
+type Limiter struct {
+	limit  int
+	period time.Duration
+
+	mu      sync.Mutex
+	windows map[string]*window
+	swept   time.Time
+}
+
+// New returns a limiter allowing limit events per key every period. A limit
+// of 0 or less allows everything.
+func New(limit int, period time.Duration) *Limiter {
+	return &Limiter{
+		limit:   limit,
+		period:  period,
+		windows: make(map[string]*window),
+		swept:   time.Now(),
+	}
+}
+
+// Allow records an event for key and reports whether it is within the limit.
+func (l *Limiter) Allow(key string) bool {
+	if l.limit <= 0 {
+		return true
+	}
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+
+	w := l.windows[key]
+	if w == nil || now.Sub(w.start) >= l.period {
+		w = &window{start: now}
+		l.windows[key] = w
+	}
+	if w.count >= l.limit {
+		return false
+	}
+	w.count++
+	return true
+}
+
+// sweep forgets the keys whose window has ended, at most once a period, so
+// the map does not grow with every key ever seen. Callers hold l.mu.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < l.period {
+		return
+	}
+	for key, w := range l.windows {
+		if now.Sub(w.start) >= l.period {
+			delete(l.windows, key)
+		}
+	}
+	l.swept = now
+}
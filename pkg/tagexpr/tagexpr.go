@@ -0,0 +1,248 @@
+// Package tagexpr parses boolean tag filters such as
+//
+//	go AND (testing OR "code review")
+//
+// AND binds tighter than OR, and both keywords are case-insensitive. A comma
+// is shorthand for AND and a pipe for OR. Tag names containing spaces or
+// reserved characters must be double-quoted.
+package tagexpr
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// MaxTags caps how many tag names one expression may reference.
+	MaxTags = 20
+	// MaxDepth caps how deeply parentheses may nest.
+	MaxDepth = 16
+)
+
+var ErrEmpty = errors.New("tag expression is empty")
+
+// Expr is a parsed expression: a Tag, an And or an Or.
+type Expr interface {
+	expr()
+}
+
+// Tag matches notes carrying the named tag.
+type Tag struct {
+	Name string
+}
+
+// And matches notes matched by every term.
+type And struct {
+	Terms []Expr
+}
+
+// Or matches notes matched by any term.
+type Or struct {
+	Terms []Expr
+}
+
+func (Tag) expr() {}
+func (And) expr() {}
+func (Or) expr()  {}
+
+// Parse parses a tag expression.
+func Parse(input string) (Expr, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, ErrEmpty
+	}
+
+	p := &parser{tokens: tokens}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %s", p.tokens[p.pos])
+	}
+	if n := len(Names(e)); n > MaxTags {
+		return nil, fmt.Errorf("tag expression references %d tags, at most %d allowed", n, MaxTags)
+	}
+	return e, nil
+}
+
+// Names lists the tag names an expression references, in order of first
+// appearance and without duplicates.
+func Names(e Expr) []string {
+	var names []string
+	seen := make(map[string]bool)
+	var walk func(Expr)
+	walk = func(e Expr) {
+		switch e := e.(type) {
+		case Tag:
+			if !seen[e.Name] {
+				seen[e.Name] = true
+				names = append(names, e.Name)
+			}
+		case And:
+			for _, t := range e.Terms {
+				walk(t)
+			}
+		case Or:
+			for _, t := range e.Terms {
+				walk(t)
+			}
+		}
+	}
+	walk(e)
+	return names
+}
+
+type tokenKind int
+
+const (
+	tokenName tokenKind = iota
+	tokenAnd
+	tokenOr
+	tokenOpen
+	tokenClose
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func (t token) String() string {
+	if t.kind == tokenName {
+		return fmt.Sprintf("tag %q", t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+func tokenize(input string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(input); {
+		switch c := input[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{tokenOpen, "("})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{tokenClose, ")"})
+			i++
+		case c == ',':
+			tokens = append(tokens, token{tokenAnd, ","})
+			i++
+		case c == '|':
+			tokens = append(tokens, token{tokenOr, "|"})
+			i++
+		case c == '"':
+			end := strings.IndexByte(input[i+1:], '"')
+			if end < 0 {
+				return nil, errors.New("unterminated quoted tag name")
+			}
+			name := strings.TrimSpace(input[i+1 : i+1+end])
+			if name == "" {
+				return nil, errors.New("empty quoted tag name")
+			}
+			tokens = append(tokens, token{tokenName, name})
+			i += end + 2
+		default:
+			start := i
+			for i < len(input) && !strings.ContainsRune(" \t\n\r(),|\"", rune(input[i])) {
+				i++
+			}
+			word := input[start:i]
+			switch strings.ToUpper(word) {
+			case "AND":
+				tokens = append(tokens, token{tokenAnd, word})
+			case "OR":
+				tokens = append(tokens, token{tokenOr, word})
+			default:
+				tokens = append(tokens, token{tokenName, word})
+			}
+		}
+	}
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	depth  int
+}
+
+func (p *parser) peek(kind tokenKind) bool {
+	return p.pos < len(p.tokens) && p.tokens[p.pos].kind == kind
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	first, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	terms := []Expr{first}
+	for p.peek(tokenOr) {
+		p.pos++
+		next, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, next)
+	}
+	if len(terms) == 1 {
+		return first, nil
+	}
+	return Or{Terms: terms}, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	first, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	terms := []Expr{first}
+	for p.peek(tokenAnd) {
+		p.pos++
+		next, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, next)
+	}
+	if len(terms) == 1 {
+		return first, nil
+	}
+	return And{Terms: terms}, nil
+}
+
+func (p *parser) parseTerm() (Expr, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, errors.New("unexpected end of tag expression")
+	}
+	t := p.tokens[p.pos]
+	switch t.kind {
+	case tokenName:
+		p.pos++
+		return Tag{Name: t.text}, nil
+	case tokenOpen:
+		if p.depth == MaxDepth {
+			return nil, fmt.Errorf("parentheses nest more than %d deep", MaxDepth)
+		}
+		p.pos++
+		p.depth++
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.peek(tokenClose) {
+			return nil, errors.New("missing closing parenthesis")
+		}
+		p.pos++
+		p.depth--
+		return e, nil
+	default:
+		return nil, fmt.Errorf("unexpected %s", t)
+	}
+}
@@ -0,0 +1,88 @@
+package tagexpr
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  Expr
+	}{
+		{
+			name:  "single tag",
+			input: "go",
+			want:  Tag{Name: "go"},
+		},
+		{
+			name:  "AND binds tighter than OR",
+			input: "a or b and c",
+			want:  Or{Terms: []Expr{Tag{Name: "a"}, And{Terms: []Expr{Tag{Name: "b"}, Tag{Name: "c"}}}}},
+		},
+		{
+			name:  "parentheses and shorthand",
+			input: `go, (testing | "code review")`,
+			want:  And{Terms: []Expr{Tag{Name: "go"}, Or{Terms: []Expr{Tag{Name: "testing"}, Tag{Name: "code review"}}}}},
+		},
+		{
+			name:  "nesting up to the limit",
+			input: strings.Repeat("(", MaxDepth) + "go" + strings.Repeat(")", MaxDepth),
+			want:  Tag{Name: "go"},
+		},
+		{
+			name:  "sibling groups do not add up",
+			input: strings.Repeat("("+strings.Repeat("(", MaxDepth-1)+"a"+strings.Repeat(")", MaxDepth-1)+") or ", 2) + "b",
+			want:  Or{Terms: []Expr{Tag{Name: "a"}, Tag{Name: "a"}, Tag{Name: "b"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.input)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tags := make([]string, MaxTags+1)
+	for i := range tags {
+		tags[i] = fmt.Sprintf("t%d", i)
+	}
+
+	tests := []struct {
+		name    string
+		input   string
+		wantErr error
+	}{
+		{name: "empty", input: "  ", wantErr: ErrEmpty},
+		{name: "unterminated quote", input: `"go`},
+		{name: "missing closing parenthesis", input: "(go"},
+		{name: "dangling operator", input: "go and"},
+		{name: "too many tags", input: strings.Join(tags, " or ")},
+		{name: "nested too deep", input: strings.Repeat("(", MaxDepth+1) + "go" + strings.Repeat(")", MaxDepth+1)},
+		{name: "deep nesting fails before the stack grows", input: strings.Repeat("(", 1_000_000)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			if err == nil {
+				t.Fatal("Parse() error = nil, want an error")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Parse() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
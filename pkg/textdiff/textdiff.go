@@ -0,0 +1,65 @@
+// Package textdiff produces line-based diffs between two texts.
+package textdiff
+
+import (
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// Op says whether a line is unchanged, only in the new text, or only in the
+// old text.
+type Op string
+
+const (
+	OpEqual  Op = "equal"
+	OpInsert Op = "insert"
+	OpDelete Op = "delete"
+)
+
+// Line is one line of a diff. OldLine and NewLine are 1-based line numbers
+// in the old and new text, and zero where the line does not exist.
+type Line struct {
+	Op      Op     `json:"op"`
+	OldLine int    `json:"old_line,omitempty"`
+	NewLine int    `json:"new_line,omitempty"`
+	Text    string `json:"text"`
+}
+
+// Lines diffs oldText against newText line by line.
+func Lines(oldText, newText string) []Line {
+	dmp := diffmatchpatch.New()
+	a, b, lineArray := dmp.DiffLinesToChars(oldText, newText)
+	diffs := dmp.DiffCharsToLines(dmp.DiffMain(a, b, false), lineArray)
+
+	var lines []Line
+	oldLine, newLine := 0, 0
+	for _, d := range diffs {
+		for _, text := range splitLines(d.Text) {
+			line := Line{Text: text}
+			switch d.Type {
+			case diffmatchpatch.DiffEqual:
+				oldLine++
+				newLine++
+				line.Op, line.OldLine, line.NewLine = OpEqual, oldLine, newLine
+			case diffmatchpatch.DiffInsert:
+				newLine++
+				line.Op, line.NewLine = OpInsert, newLine
+			case diffmatchpatch.DiffDelete:
+				oldLine++
+				line.Op, line.OldLine = OpDelete, oldLine
+			}
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// splitLines splits a chunk of whole lines, dropping the empty string after
+// a trailing newline.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
@@ -0,0 +1,130 @@
+package textdiff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		oldText string
+		newText string
+		want    []Line
+	}{
+		{
+			name:    "both empty",
+			oldText: "",
+			newText: "",
+			want:    nil,
+		},
+		{
+			name:    "identical",
+			oldText: "a\nb\n",
+			newText: "a\nb\n",
+			want: []Line{
+				{Op: OpEqual, OldLine: 1, NewLine: 1, Text: "a"},
+				{Op: OpEqual, OldLine: 2, NewLine: 2, Text: "b"},
+			},
+		},
+		{
+			name:    "empty old text",
+			oldText: "",
+			newText: "a\nb",
+			want: []Line{
+				{Op: OpInsert, NewLine: 1, Text: "a"},
+				{Op: OpInsert, NewLine: 2, Text: "b"},
+			},
+		},
+		{
+			name:    "empty new text",
+			oldText: "a\nb\n",
+			newText: "",
+			want: []Line{
+				{Op: OpDelete, OldLine: 1, Text: "a"},
+				{Op: OpDelete, OldLine: 2, Text: "b"},
+			},
+		},
+		{
+			name:    "changed line",
+			oldText: "a\nb\nc\n",
+			newText: "a\nB\nc\n",
+			want: []Line{
+				{Op: OpEqual, OldLine: 1, NewLine: 1, Text: "a"},
+				{Op: OpDelete, OldLine: 2, Text: "b"},
+				{Op: OpInsert, NewLine: 2, Text: "B"},
+				{Op: OpEqual, OldLine: 3, NewLine: 3, Text: "c"},
+			},
+		},
+		{
+			name:    "trailing newline added",
+			oldText: "a\nb",
+			newText: "a\nb\n",
+			want: []Line{
+				{Op: OpEqual, OldLine: 1, NewLine: 1, Text: "a"},
+				{Op: OpDelete, OldLine: 2, Text: "b"},
+				{Op: OpInsert, NewLine: 2, Text: "b"},
+			},
+		},
+		{
+			name:    "line appended after a missing trailing newline",
+			oldText: "a",
+			newText: "a\nb",
+			want: []Line{
+				{Op: OpDelete, OldLine: 1, Text: "a"},
+				{Op: OpInsert, NewLine: 1, Text: "a"},
+				{Op: OpInsert, NewLine: 2, Text: "b"},
+			},
+		},
+		{
+			name:    "blank lines",
+			oldText: "a\n\nb\n",
+			newText: "a\n\n\nb\n",
+			want: []Line{
+				{Op: OpEqual, OldLine: 1, NewLine: 1, Text: "a"},
+				{Op: OpEqual, OldLine: 2, NewLine: 2, Text: ""},
+				{Op: OpInsert, NewLine: 3, Text: ""},
+				{Op: OpEqual, OldLine: 3, NewLine: 4, Text: "b"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Lines(tt.oldText, tt.newText)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Lines() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestLinesNumbering checks that the line numbers of every diff account for
+// each line of both texts exactly once, in order.
+func TestLinesNumbering(t *testing.T) {
+	oldText := "one\ntwo\nthree\nfour\nfive\n"
+	newText := "zero\none\nthree\n3.5\nfive\nsix"
+
+	var oldLines, newLines []string
+	for _, l := range Lines(oldText, newText) {
+		if l.OldLine != 0 {
+			if l.OldLine != len(oldLines)+1 {
+				t.Fatalf("old line %d follows line %d", l.OldLine, len(oldLines))
+			}
+			oldLines = append(oldLines, l.Text)
+		}
+		if l.NewLine != 0 {
+			if l.NewLine != len(newLines)+1 {
+				t.Fatalf("new line %d follows line %d", l.NewLine, len(newLines))
+			}
+			newLines = append(newLines, l.Text)
+		}
+	}
+	if got := strings.Join(oldLines, "\n") + "\n"; got != oldText {
+		t.Errorf("old lines = %q, want %q", got, oldText)
+	}
+	if got := strings.Join(newLines, "\n"); got != newText {
+		t.Errorf("new lines = %q, want %q", got, newText)
+	}
+}